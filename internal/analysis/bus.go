@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import "sync"
+
+// bus fans values out from any number of publishers to any number of subscribers. It
+// never blocks: values are dropped for subscribers whose buffer is full, so it can be
+// published to from the audio callback. EventBus and FrameBus are its two instances.
+type bus[T any] struct {
+	mu     sync.Mutex
+	subs   map[chan T]struct{} // Subscriber channels.
+	closed bool                // Whether Close has been called.
+}
+
+// newBus creates a bus with no subscribers.
+func newBus[T any]() bus[T] {
+	return bus[T]{subs: make(map[chan T]struct{})}
+}
+
+// Publish delivers v to every subscriber that has room for it.
+func (b *bus[T]) Publish(v T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving published values, buffering up to buffer
+// values, and a function to cancel the subscription. The channel is closed on cancel or
+// when the bus is closed.
+func (b *bus[T]) Subscribe(buffer int) (<-chan T, func()) {
+	ch := make(chan T, max(buffer, 0))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close closes all subscriber channels. Later values are discarded and later
+// subscriptions receive a closed channel. It is safe to call multiple times.
+func (b *bus[T]) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+	}
+	clear(b.subs)
+	return nil
+}
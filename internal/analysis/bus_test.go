@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import "testing"
+
+// TestBus covers the behaviour EventBus and FrameBus share.
+func TestBus(t *testing.T) {
+	b := newBus[int]()
+	a, cancelA := b.Subscribe(2)
+	c, _ := b.Subscribe(1)
+
+	b.Publish(1)
+	b.Publish(2) // Dropped for c, whose buffer is full.
+
+	if v := <-a; v != 1 {
+		t.Errorf("a got %d, want 1", v)
+	}
+	if v := <-a; v != 2 {
+		t.Errorf("a got %d, want 2", v)
+	}
+	if v := <-c; v != 1 {
+		t.Errorf("c got %d, want 1", v)
+	}
+
+	cancelA()
+	cancelA() // Safe to call twice.
+	if _, ok := <-a; ok {
+		t.Error("a still open after cancel")
+	}
+
+	b.Close()
+	b.Close() // Safe to call twice.
+	if _, ok := <-c; ok {
+		t.Error("c still open after Close")
+	}
+	b.Publish(3) // Must not panic.
+	late, cancelLate := b.Subscribe(1)
+	if late == nil || cancelLate == nil {
+		t.Fatal("Subscribe after Close returned a nil channel or cancel")
+	}
+	if _, ok := <-late; ok {
+		t.Error("subscription after Close is open")
+	}
+	cancelLate() // Must not panic.
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Chord event names and labels.
+const (
+	EventChordChange = "chord_change"
+	NoChordLabel     = "N" // Label used when no chord template fits (silence, noise, single notes).
+)
+
+// Tuning constants for the chord recognizer.
+const (
+	chordEmissionSharpness = 20.0 // Scales template similarity into log emission probabilities.
+	chordNoChordScore      = 0.6  // Similarity of the "no chord" state; flat chroma scores 0.5 against triads, 0.58 against sevenths.
+	chordEventBuffer       = 16   // Capacity of the event channel.
+)
+
+// chordQuality describes one chord type by its intervals above the root in semitones.
+type chordQuality struct {
+	suffix    string
+	intervals []int
+}
+
+// chordQualities are the chord types recognised, in tie-breaking order.
+var chordQualities = []chordQuality{
+	{"", []int{0, 4, 7}},         // Major.
+	{"m", []int{0, 3, 7}},        // Minor.
+	{"7", []int{0, 4, 7, 10}},    // Dominant seventh.
+	{"maj7", []int{0, 4, 7, 11}}, // Major seventh.
+	{"m7", []int{0, 3, 7, 10}},   // Minor seventh.
+	{"dim", []int{0, 3, 6}},      // Diminished.
+	{"aug", []int{0, 4, 8}},      // Augmented.
+	{"sus2", []int{0, 2, 7}},     // Suspended second.
+	{"sus4", []int{0, 5, 7}},     // Suspended fourth.
+}
+
+// ChordProcessor recognises chords from a ChromaProvider. Every frame the chroma vector
+// is compared against binary chord templates (12 roots × major, minor, 7, maj7, m7, dim,
+// aug, sus2, sus4, plus a "no chord" state) using cosine similarity. The similarities are
+// turned into emission probabilities of a hidden Markov model whose transitions favour
+// staying on the current chord, and the Viterbi algorithm is run over a short sliding
+// window of frames. Single-frame glitches therefore don't cause chord changes, at the
+// cost of a few frames of latency for real ones. Chords sharing a pitch set (Csus4 and
+// Fsus2, or the three spellings of an augmented triad) cannot be told apart from chroma
+// alone; the first template in chordQualities/root order wins.
+//
+// A chord_change event carrying the chord label and a confidence (the emission
+// probability of the chord in the current frame) is emitted whenever the decoded chord
+// changes. The processor must be registered after the processor backing its source.
+type ChordProcessor struct {
+	source    ChromaProvider // Chroma to recognise chords from.
+	window    int            // Number of frames in the Viterbi window.
+	logStay   float64        // Log probability of staying on the same chord.
+	logSwitch float64        // Log probability of switching to a specific other chord.
+	templates [][12]float64  // Normalised template for each chord state (excludes "no chord").
+	labels    []string       // Label for each state; the last state is "no chord".
+
+	chroma    [12]float64 // Pre-allocated chroma frame.
+	emissions [][]float64 // Ring buffer of log emission probabilities (window x states).
+	pos       int         // Next write position in emissions.
+	count     int         // Number of valid frames in emissions.
+	delta     []float64   // Viterbi scores for the previous frame.
+	next      []float64   // Viterbi scores for the current frame.
+
+	events    chan Event   // Chord change events.
+	closeOnce sync.Once    // Ensures the event channel is closed once.
+	mu        sync.RWMutex // Protects chord and confidence.
+
+	chord      string  // Label of the current chord.
+	confidence float64 // Confidence of the current chord (0..1).
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*ChordProcessor)(nil)
+var _ ClosableProcessor = (*ChordProcessor)(nil)
+var _ EventProvider = (*ChordProcessor)(nil)
+
+// NewChordProcessor creates a chord recognizer. window is the number of frames the
+// Viterbi decoder looks back over and selfTransition the probability (0..1) that the
+// chord stays the same from one frame to the next; higher values smooth more.
+func NewChordProcessor(source ChromaProvider, window int, selfTransition float64) (*ChordProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("chord source cannot be nil")
+	}
+	if window < 1 {
+		return nil, fmt.Errorf("chord window must be positive, got %d", window)
+	}
+	if selfTransition <= 0 || selfTransition >= 1 {
+		return nil, fmt.Errorf("chord self transition probability must be between 0 and 1, got %f", selfTransition)
+	}
+
+	// --- 1. Build Templates ---
+
+	states := 12*len(chordQualities) + 1
+	templates := make([][12]float64, 0, states-1)
+	labels := make([]string, 0, states)
+	for _, quality := range chordQualities {
+		for root := range 12 {
+			var template [12]float64
+			norm := 1 / math.Sqrt(float64(len(quality.intervals)))
+			for _, interval := range quality.intervals {
+				template[(root+interval)%12] = norm
+			}
+			templates = append(templates, template)
+			labels = append(labels, PitchClassNames[root]+quality.suffix)
+		}
+	}
+	labels = append(labels, NoChordLabel)
+
+	emissions := make([][]float64, window)
+	for i := range emissions {
+		emissions[i] = make([]float64, states)
+	}
+
+	log.Printf("Analysis: Initializing ChordProcessor (Chords: %d, Window: %d, SelfTransition: %.2f)",
+		states, window, selfTransition)
+
+	return &ChordProcessor{
+		source:    source,
+		window:    window,
+		logStay:   math.Log(selfTransition),
+		logSwitch: math.Log((1 - selfTransition) / float64(states-1)),
+		templates: templates,
+		labels:    labels,
+		emissions: emissions,
+		delta:     make([]float64, states),
+		next:      make([]float64, states),
+		events:    make(chan Event, chordEventBuffer),
+		chord:     NoChordLabel,
+	}, nil
+}
+
+// Process fetches the latest chroma vector, updates the Viterbi window and emits an event
+// if the decoded chord changed. The input buffer itself is ignored.
+// Implements analysis.AudioProcessor.
+func (p *ChordProcessor) Process(_ []int32) {
+	p.source.GetChromaInto(&p.chroma)
+
+	// --- 1. Emission Probabilities ---
+
+	norm := 0.0
+	for _, v := range p.chroma {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	emission := p.emissions[p.pos]
+	for s, template := range p.templates {
+		similarity := 0.0
+		if norm > 0 {
+			for i, v := range p.chroma {
+				similarity += v * template[i]
+			}
+			similarity /= norm
+		}
+		emission[s] = chordEmissionSharpness * similarity
+	}
+	emission[len(emission)-1] = chordEmissionSharpness * chordNoChordScore
+
+	// Normalise to log probabilities (log-sum-exp).
+	peak := math.Inf(-1)
+	for _, v := range emission {
+		peak = math.Max(peak, v)
+	}
+	sum := 0.0
+	for _, v := range emission {
+		sum += math.Exp(v - peak)
+	}
+	logNorm := peak + math.Log(sum)
+	for s := range emission {
+		emission[s] -= logNorm
+	}
+
+	p.pos = (p.pos + 1) % p.window
+	p.count = min(p.count+1, p.window)
+
+	// --- 2. Viterbi Over Window ---
+
+	// With uniform switching probabilities the best predecessor of a state is either the
+	// state itself or the overall best state, so each step is O(states) instead of O(states²).
+	oldest := (p.pos - p.count + p.window) % p.window
+	copy(p.delta, p.emissions[oldest])
+	for t := 1; t < p.count; t++ {
+		frame := p.emissions[(oldest+t)%p.window]
+		best := math.Inf(-1)
+		for _, v := range p.delta {
+			best = math.Max(best, v)
+		}
+		for s, v := range p.delta {
+			p.next[s] = math.Max(v+p.logStay, best+p.logSwitch) + frame[s]
+		}
+		p.delta, p.next = p.next, p.delta
+	}
+	state := 0
+	for s, v := range p.delta {
+		if v > p.delta[state] {
+			state = s
+		}
+	}
+	label := p.labels[state]
+	confidence := math.Exp(emission[state])
+
+	// --- 3. Publish ---
+
+	p.mu.Lock()
+	changed := label != p.chord
+	p.chord = label
+	p.confidence = confidence
+	p.mu.Unlock()
+
+	if changed {
+		select {
+		case p.events <- Event{Source: "chord", Name: EventChordChange, Label: label, Value: confidence, Time: time.Now()}:
+		default:
+		}
+	}
+}
+
+// GetChord returns the label of the current chord (e.g. "Am7", or "N" for no chord)
+// and its confidence (0..1).
+func (p *ChordProcessor) GetChord() (string, float64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.chord, p.confidence
+}
+
+// Events returns the channel on which chord_change events are delivered.
+// Implements the analysis.EventProvider interface.
+func (p *ChordProcessor) Events() <-chan Event {
+	return p.events
+}
+
+// Close closes the event channel. The audio stream must be stopped before calling Close.
+// Implements the analysis.ClosableProcessor interface.
+func (p *ChordProcessor) Close() error {
+	p.closeOnce.Do(func() {
+		log.Printf("Analysis: Closing ChordProcessor")
+		close(p.events)
+	})
+	return nil
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// staticChroma is a ChromaProvider returning whatever chroma the test sets.
+type staticChroma struct {
+	chroma [12]float64
+}
+
+func (s *staticChroma) GetChromaInto(dst *[12]float64) { *dst = s.chroma }
+
+func (s *staticChroma) set(pitchClasses ...int) {
+	s.chroma = [12]float64{}
+	for i := range s.chroma {
+		s.chroma[i] = 0.05 // A little background energy.
+	}
+	for _, pc := range pitchClasses {
+		s.chroma[pc] = 1
+	}
+}
+
+func TestChordProcessor_Recognises(t *testing.T) {
+	src := &staticChroma{}
+	p, err := NewChordProcessor(src, 8, 0.9)
+	if err != nil {
+		t.Fatalf("NewChordProcessor error: %v", err)
+	}
+
+	tests := []struct {
+		notes []int
+		want  string
+	}{
+		{[]int{0, 4, 7}, "C"},
+		{[]int{9, 0, 4}, "Am"},
+		{[]int{7, 11, 2, 5}, "G7"},
+		{[]int{11, 2, 5}, "Bdim"},
+		{[]int{0, 4, 8}, "Caug"},
+		{nil, NoChordLabel},
+	}
+	for _, tt := range tests {
+		src.set(tt.notes...)
+		for range 8 {
+			p.Process(nil)
+		}
+		if got, conf := p.GetChord(); got != tt.want {
+			t.Errorf("notes %v: chord = %q (%.2f), want %q", tt.notes, got, conf, tt.want)
+		}
+	}
+
+	var changes []string
+	for len(p.Events()) > 0 {
+		ev := <-p.Events()
+		changes = append(changes, ev.Label)
+	}
+	if len(changes) != len(tests) {
+		t.Errorf("chord changes = %v, want %d changes", changes, len(tests))
+	}
+}
+
+func TestChordProcessor_Smoothing(t *testing.T) {
+	src := &staticChroma{}
+	p, err := NewChordProcessor(src, 8, 0.99)
+	if err != nil {
+		t.Fatalf("NewChordProcessor error: %v", err)
+	}
+
+	src.set(0, 4, 7)
+	for range 8 {
+		p.Process(nil)
+	}
+
+	// A single frame of another chord must not change the decoded chord.
+	src.set(2, 5, 9)
+	p.Process(nil)
+	src.set(0, 4, 7)
+	p.Process(nil)
+	if got, _ := p.GetChord(); got != "C" {
+		t.Errorf("chord after one-frame glitch = %q, want C", got)
+	}
+}
+
+func TestChromaProcessor_PitchClass(t *testing.T) {
+	const sampleRate = 8000.0
+	fft, err := NewFFTProcessor(4096, sampleRate, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	chroma, err := NewChromaProcessor(fft, 55, 2000)
+	if err != nil {
+		t.Fatalf("NewChromaProcessor error: %v", err)
+	}
+
+	// A4 = 440 Hz.
+	buf := make([]int32, 4096)
+	for i := range buf {
+		buf[i] = int32(0.5 * math.MaxInt32 * math.Sin(2*math.Pi*440*float64(i)/sampleRate))
+	}
+	fft.Process(buf)
+	chroma.Process(buf)
+
+	var got [12]float64
+	chroma.GetChromaInto(&got)
+	if got[9] != 1 {
+		t.Errorf("chroma[A] = %f, want 1 (chroma %v)", got[9], got)
+	}
+	for i, v := range got {
+		if i != 9 && v > 0.1 {
+			t.Errorf("chroma[%s] = %f, want ~0", PitchClassNames[i], v)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+)
+
+// PitchClassNames are the names of the 12 pitch classes in chroma order.
+var PitchClassNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// ChromaProcessor folds each spectrum produced by an FFTResultProvider into a 12 bin
+// chroma vector by summing the power of every bin into the pitch class nearest to its
+// centre frequency (A4 = 440 Hz). Low notes need a fine frequency resolution to be told
+// apart, so larger FFT sizes (frames_per_buffer) give noticeably cleaner chroma.
+//
+// The processor must be registered after the processor backing its source.
+type ChromaProcessor struct {
+	source     FFTResultProvider // Spectrum to fold.
+	pitchClass []int             // Pitch class for each bin, -1 for bins outside the analysed range.
+	magnitude  []float64         // Pre-allocated buffer for the spectrum.
+	chroma     [12]float64       // Latest normalised chroma vector.
+	mu         sync.RWMutex      // Protects chroma.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*ChromaProcessor)(nil)
+var _ ClosableProcessor = (*ChromaProcessor)(nil)
+var _ ChromaProvider = (*ChromaProcessor)(nil)
+
+// NewChromaProcessor creates a chroma processor folding bins between minFreq and maxFreq (Hz).
+func NewChromaProcessor(source FFTResultProvider, minFreq, maxFreq float64) (*ChromaProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("chroma source cannot be nil")
+	}
+	if minFreq <= 0 || maxFreq <= minFreq {
+		return nil, fmt.Errorf("chroma frequency range invalid: %.1f - %.1f Hz", minFreq, maxFreq)
+	}
+
+	bins := source.GetFFTSize()/2 + 1
+	pitchClass := make([]int, bins)
+	used := 0
+	for bin := range pitchClass {
+		freq := source.GetFrequencyForBin(bin)
+		if freq < minFreq || freq > maxFreq {
+			pitchClass[bin] = -1
+			continue
+		}
+		// MIDI note 69 is A4; MIDI note 0 is a C, so note % 12 is the pitch class.
+		note := int(math.Round(69 + 12*math.Log2(freq/440)))
+		pitchClass[bin] = ((note % 12) + 12) % 12
+		used++
+	}
+	if used == 0 {
+		return nil, fmt.Errorf("chroma frequency range %.1f - %.1f Hz contains no FFT bins", minFreq, maxFreq)
+	}
+
+	log.Printf("Analysis: Initializing ChromaProcessor (Range: %.1f - %.1f Hz, Bins: %d)", minFreq, maxFreq, used)
+
+	return &ChromaProcessor{
+		source:     source,
+		pitchClass: pitchClass,
+		magnitude:  make([]float64, bins),
+	}, nil
+}
+
+// Process fetches the latest spectrum from the source and folds it into pitch classes.
+// The input buffer itself is ignored. Implements analysis.AudioProcessor.
+func (p *ChromaProcessor) Process(_ []int32) {
+	if err := p.source.GetMagnitudesInto(p.magnitude); err != nil {
+		return
+	}
+
+	var chroma [12]float64
+	for bin, pc := range p.pitchClass {
+		if pc >= 0 {
+			chroma[pc] += p.magnitude[bin] * p.magnitude[bin]
+		}
+	}
+	peak := 0.0
+	for _, v := range chroma {
+		peak = math.Max(peak, v)
+	}
+	if peak > 0 {
+		for i := range chroma {
+			chroma[i] /= peak
+		}
+	}
+
+	p.mu.Lock()
+	p.chroma = chroma
+	p.mu.Unlock()
+}
+
+// GetChromaInto copies the latest chroma vector into dst.
+// Implements the analysis.ChromaProvider interface.
+func (p *ChromaProcessor) GetChromaInto(dst *[12]float64) {
+	p.mu.RLock()
+	*dst = p.chroma
+	p.mu.RUnlock()
+}
+
+// Close handles any necessary cleanup for the ChromaProcessor.
+// Implements the analysis.ClosableProcessor interface.
+func (p *ChromaProcessor) Close() error {
+	log.Printf("Analysis: Closing ChromaProcessor (no specific resources to release)")
+	return nil
+}
@@ -0,0 +1,14 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+// EventBus fans events from any number of EventProviders out to any number of
+// subscribers. Like the providers themselves it never blocks: events are dropped for
+// subscribers whose buffer is full.
+type EventBus struct {
+	bus[Event]
+}
+
+// NewEventBus creates an event bus with no subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{newBus[Event]()}
+}
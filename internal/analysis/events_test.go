@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEventBus checks that events from several providers reach every subscriber intact;
+// dropping, cancelling and closing are covered by TestBus.
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+	a, cancelA := bus.Subscribe(4)
+	defer cancelA()
+	b, cancelB := bus.Subscribe(4)
+
+	now := time.Now()
+	sent := []Event{
+		{Source: "vad", Name: EventSpeechStart, Value: 0.9, Time: now},
+		{Source: "chord", Name: EventChordChange, Label: "Am", Value: 0.7, Time: now},
+		{Source: "tempo", Name: EventBeat, Value: 120, Time: now},
+	}
+	for _, e := range sent {
+		bus.Publish(e)
+	}
+	for i, want := range sent {
+		if got := <-a; got != want {
+			t.Errorf("a event %d = %+v, want %+v", i, got, want)
+		}
+		if got := <-b; got != want {
+			t.Errorf("b event %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Cancelling one subscription leaves the other one running.
+	cancelB()
+	bus.Publish(Event{Source: "onset", Name: EventOnset, Value: 2})
+	if got := <-a; got.Name != EventOnset {
+		t.Errorf("a got %+v after b cancelled, want an onset", got)
+	}
+}
@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+// Features collects the processors whose results are published by transports that carry
+// more than a single spectrum. Every field except Spectrum is optional and nil when the
+// corresponding processor is disabled; transports skip the features they cannot read.
+type Features struct {
+	Spectrum FFTResultProvider // Spectrum to publish (FFT or an HPSS component).
+	Levels   *LevelProcessor   // RMS, peak and band levels.
+	HPSS     *HPSSProcessor    // Harmonic and percussive energies.
+	VAD      *VADProcessor     // Speech probability and state.
+	Peaks    *PeakProcessor    // Strongest spectral peaks.
+	Chroma   *ChromaProcessor  // Pitch class profile.
+	Chord    *ChordProcessor   // Recognised chord.
+	Onset    *OnsetProcessor   // Onset strength and detected onsets.
+	Tempo    *TempoProcessor   // Tempo and beat phase.
+	Events   *EventBus         // Events detected by all processors.
+}
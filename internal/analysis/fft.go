@@ -32,7 +32,8 @@ type fftWorkspace struct {
 	fftOutput []complex128 // Buffer for FFT complex results.
 	magnitude []float64    // Buffer for calculated magnitudes.
 	window    []float64    // Pre-calculated window coefficients.
-	mu        sync.RWMutex // Protects concurrent access to magnitude buffer.
+	frame     uint64       // Number of buffers processed, the index of the frame in magnitude.
+	mu        sync.RWMutex // Protects concurrent access to magnitude buffer and frame.
 }
 
 // FFTProcessor is a real-time audio processor that performs FFT analysis on input audio data.
@@ -50,6 +51,7 @@ type FFTProcessor struct {
 // Compile-time checks for interface implementations.
 var _ AudioProcessor = (*FFTProcessor)(nil)
 var _ FFTResultProvider = (*FFTProcessor)(nil)
+var _ FrameResultProvider = (*FFTProcessor)(nil)
 var _ ClosableProcessor = (*FFTProcessor)(nil)
 
 // TODO:
@@ -116,6 +118,7 @@ func (p *FFTProcessor) Process(inputBuffer []int32) {
 	for i, c := range p.workspace.fftOutput {
 		p.workspace.magnitude[i] = cmplx.Abs(c)
 	}
+	p.workspace.frame++
 
 	// --- 4. Unlock Workspace ---
 
@@ -155,6 +158,21 @@ func (p *FFTProcessor) GetMagnitudesInto(dest []float64) error {
 	return nil
 }
 
+// GetFrameInto copies the latest magnitudes into dst like GetMagnitudesInto and returns
+// the number of buffers processed so far, which is the index of the frame they belong to.
+// Implements the analysis.FrameResultProvider interface.
+func (p *FFTProcessor) GetFrameInto(dest []float64) (uint64, error) {
+	p.workspace.mu.RLock()
+	defer p.workspace.mu.RUnlock()
+
+	if len(dest) != len(p.workspace.magnitude) {
+		return 0, fmt.Errorf("destination slice length %d does not match required length %d", len(dest), len(p.workspace.magnitude))
+	}
+
+	copy(dest, p.workspace.magnitude)
+	return p.workspace.frame, nil
+}
+
 // GetFrequencyForBin returns the center frequency (Hz) for a given FFT bin index.
 // Implements the analysis.FFTResultProvider interface.
 func (p *FFTProcessor) GetFrequencyForBin(binIndex int) float64 {
@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import "time"
+
+// Frame describes one completed analysis frame: an audio buffer that every registered
+// processor has processed. Frames are small value types so they can be published from
+// the real-time callback without allocating.
+type Frame struct {
+	Index      uint64        // Number of buffers processed since the engine started, from 1.
+	Time       time.Time     // Wall clock time the first sample of the buffer was captured.
+	StreamTime time.Duration // Capture time of the first sample on the audio stream's clock.
+}
+
+// FrameBus notifies subscribers of completed frames, so consumers can publish results
+// once per frame instead of polling on a timer. Like EventBus it never blocks: frames
+// are dropped for subscribers whose buffer is full.
+type FrameBus struct {
+	bus[Frame]
+}
+
+// NewFrameBus creates a frame bus with no subscribers.
+func NewFrameBus() *FrameBus {
+	return &FrameBus{newBus[Frame]()}
+}
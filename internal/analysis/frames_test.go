@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import "testing"
+
+// TestFrameResultProvider checks that the FFT and HPSS outputs report the frame their
+// magnitudes belong to.
+func TestFrameResultProvider(t *testing.T) {
+	fft, err := NewFFTProcessor(64, 48000, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	hpss, err := NewHPSSProcessor(fft, 3, 3, 2)
+	if err != nil {
+		t.Fatalf("NewHPSSProcessor error: %v", err)
+	}
+	dst := make([]float64, 33)
+	for want := range uint64(3) {
+		for _, provider := range []FrameResultProvider{fft, hpss.Harmonic().(FrameResultProvider)} {
+			if frame, err := provider.GetFrameInto(dst); err != nil || frame != want {
+				t.Errorf("GetFrameInto = %d, %v, want frame %d", frame, err, want)
+			}
+		}
+		in := make([]int32, 64)
+		fft.Process(in)
+		hpss.Process(in)
+	}
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sync"
+)
+
+// Pre-allocated buffers for harmonic/percussive separation.
+type hpssWorkspace struct {
+	current    []float64   // Latest magnitude frame fetched from the source.
+	history    [][]float64 // Ring buffer of past magnitude frames (timeKernel x bins).
+	scratch    []float64   // Scratch space for median calculations.
+	harmonic   []float64   // Harmonic part of the latest frame.
+	percussive []float64   // Percussive part of the latest frame.
+
+	harmonicEnergy   float64      // Sum of squared harmonic magnitudes.
+	percussiveEnergy float64      // Sum of squared percussive magnitudes.
+	frame            uint64       // Index of the source frame harmonic and percussive belong to.
+	mu               sync.RWMutex // Protects harmonic, percussive, the energies and frame.
+}
+
+// HPSSProcessor splits each spectrum produced by an FFTResultProvider into harmonic and
+// percussive parts using median filtering (Fitzgerald, 2010). Harmonic sounds are smooth
+// over time and get enhanced by a median across the spectrogram history of each bin,
+// percussive sounds are smooth across frequency and get enhanced by a median across the
+// bins of the current frame. Soft (Wiener-style) masks derived from both estimates are
+// then applied to the original spectrum.
+//
+// The processor does not look at the raw input buffer; it must be registered after the
+// processor backing its source so it sees the spectrum of the current callback.
+type HPSSProcessor struct {
+	source     FFTResultProvider // Spectrum to separate.
+	bins       int               // Number of magnitude bins per frame (N/2 + 1).
+	timeKernel int               // Length of the median filter over time (frames, odd).
+	freqKernel int               // Length of the median filter over frequency (bins, odd).
+	maskPower  float64           // Exponent used for the soft masks (2 = Wiener).
+	histPos    int               // Next write position in the history ring buffer.
+	histCount  int               // Number of valid frames in the history.
+	workspace  hpssWorkspace     // Pre-allocated buffers.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*HPSSProcessor)(nil)
+var _ ClosableProcessor = (*HPSSProcessor)(nil)
+var _ FrameResultProvider = (*hpssComponent)(nil)
+
+// NewHPSSProcessor creates a harmonic/percussive separator reading from source.
+// timeKernel and freqKernel are the median filter lengths in frames and bins; even
+// values are rounded up to the next odd number. maskPower <= 0 defaults to 2.
+func NewHPSSProcessor(source FFTResultProvider, timeKernel, freqKernel int, maskPower float64) (*HPSSProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("hpss source cannot be nil")
+	}
+	if timeKernel < 1 || freqKernel < 1 {
+		return nil, fmt.Errorf("hpss kernel sizes must be positive, got time=%d freq=%d", timeKernel, freqKernel)
+	}
+	if timeKernel%2 == 0 {
+		timeKernel++
+	}
+	if freqKernel%2 == 0 {
+		freqKernel++
+	}
+	if maskPower <= 0 {
+		maskPower = 2
+	}
+
+	bins := source.GetFFTSize()/2 + 1
+	history := make([][]float64, timeKernel)
+	for i := range history {
+		history[i] = make([]float64, bins)
+	}
+
+	log.Printf("Analysis: Initializing HPSSProcessor (Bins: %d, TimeKernel: %d, FreqKernel: %d, MaskPower: %.1f)",
+		bins, timeKernel, freqKernel, maskPower)
+
+	return &HPSSProcessor{
+		source:     source,
+		bins:       bins,
+		timeKernel: timeKernel,
+		freqKernel: freqKernel,
+		maskPower:  maskPower,
+		workspace: hpssWorkspace{
+			current:    make([]float64, bins),
+			history:    history,
+			scratch:    make([]float64, max(timeKernel, freqKernel)),
+			harmonic:   make([]float64, bins),
+			percussive: make([]float64, bins),
+		},
+	}, nil
+}
+
+// Process fetches the latest spectrum from the source and separates it.
+// The input buffer itself is ignored. Implements analysis.AudioProcessor.
+func (p *HPSSProcessor) Process(_ []int32) {
+	ws := &p.workspace
+
+	// --- 1. Fetch Spectrum & Update History ---
+
+	// Sources that don't count frames are assumed to produce one per call.
+	frame := ws.frame + 1
+	var err error
+	if source, ok := p.source.(FrameResultProvider); ok {
+		frame, err = source.GetFrameInto(ws.current)
+	} else {
+		err = p.source.GetMagnitudesInto(ws.current)
+	}
+	if err != nil {
+		return
+	}
+	copy(ws.history[p.histPos], ws.current)
+	p.histPos = (p.histPos + 1) % p.timeKernel
+	p.histCount = min(p.histCount+1, p.timeKernel)
+
+	// --- 2. Median Filter & Mask ---
+
+	// Writers hold the lock for the whole separation; it only touches pre-allocated
+	// buffers, so readers never wait on anything but arithmetic.
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	half := p.freqKernel / 2
+	var harmonicEnergy, percussiveEnergy float64
+	for bin := range p.bins {
+		// Harmonic estimate: median of this bin over the available history.
+		timeValues := ws.scratch[:p.histCount]
+		for i := range p.histCount {
+			timeValues[i] = ws.history[i][bin]
+		}
+		h := median(timeValues)
+
+		// Percussive estimate: median across neighbouring bins of the current frame.
+		lo, hi := max(bin-half, 0), min(bin+half+1, p.bins)
+		freqValues := ws.scratch[:hi-lo]
+		copy(freqValues, ws.current[lo:hi])
+		perc := median(freqValues)
+
+		hp, pp := math.Pow(h, p.maskPower), math.Pow(perc, p.maskPower)
+		mag := ws.current[bin]
+		if total := hp + pp; total > 0 {
+			ws.harmonic[bin] = mag * hp / total
+			ws.percussive[bin] = mag * pp / total
+		} else {
+			ws.harmonic[bin] = 0
+			ws.percussive[bin] = 0
+		}
+		harmonicEnergy += ws.harmonic[bin] * ws.harmonic[bin]
+		percussiveEnergy += ws.percussive[bin] * ws.percussive[bin]
+	}
+	ws.harmonicEnergy = harmonicEnergy
+	ws.percussiveEnergy = percussiveEnergy
+	ws.frame = frame
+}
+
+// GetEnergies returns the energy (sum of squared magnitudes) of the harmonic and
+// percussive parts of the latest frame.
+func (p *HPSSProcessor) GetEnergies() (harmonic, percussive float64) {
+	p.workspace.mu.RLock()
+	defer p.workspace.mu.RUnlock()
+	return p.workspace.harmonicEnergy, p.workspace.percussiveEnergy
+}
+
+// Harmonic returns an FFTResultProvider view of the harmonic spectrum, so it can be
+// consumed anywhere a plain FFT spectrum can (publishers, further analysis stages).
+func (p *HPSSProcessor) Harmonic() FFTResultProvider {
+	return &hpssComponent{p: p, percussive: false}
+}
+
+// Percussive returns an FFTResultProvider view of the percussive spectrum.
+func (p *HPSSProcessor) Percussive() FFTResultProvider {
+	return &hpssComponent{p: p, percussive: true}
+}
+
+// Close handles any necessary cleanup for the HPSSProcessor.
+// Implements the analysis.ClosableProcessor interface.
+func (p *HPSSProcessor) Close() error {
+	log.Printf("Analysis: Closing HPSSProcessor (no specific resources to release)")
+	return nil
+}
+
+// hpssComponent exposes one half of the separation through the FFTResultProvider interface.
+type hpssComponent struct {
+	p          *HPSSProcessor
+	percussive bool
+}
+
+// buffer returns the workspace buffer this view reads from. Callers must hold the read lock.
+func (c *hpssComponent) buffer() []float64 {
+	if c.percussive {
+		return c.p.workspace.percussive
+	}
+	return c.p.workspace.harmonic
+}
+
+// GetMagnitudes returns a copy of the latest separated spectrum.
+func (c *hpssComponent) GetMagnitudes() []float64 {
+	c.p.workspace.mu.RLock()
+	defer c.p.workspace.mu.RUnlock()
+	return slices.Clone(c.buffer())
+}
+
+// GetMagnitudesInto copies the latest separated spectrum into dst.
+func (c *hpssComponent) GetMagnitudesInto(dst []float64) error {
+	c.p.workspace.mu.RLock()
+	defer c.p.workspace.mu.RUnlock()
+
+	src := c.buffer()
+	if len(dst) != len(src) {
+		return fmt.Errorf("destination slice length %d does not match required length %d", len(dst), len(src))
+	}
+	copy(dst, src)
+	return nil
+}
+
+// GetFrameInto copies the latest separated spectrum into dst and returns the index of the
+// source frame it was separated from.
+func (c *hpssComponent) GetFrameInto(dst []float64) (uint64, error) {
+	c.p.workspace.mu.RLock()
+	defer c.p.workspace.mu.RUnlock()
+
+	src := c.buffer()
+	if len(dst) != len(src) {
+		return 0, fmt.Errorf("destination slice length %d does not match required length %d", len(dst), len(src))
+	}
+	copy(dst, src)
+	return c.p.workspace.frame, nil
+}
+
+// GetFrequencyForBin delegates to the underlying source.
+func (c *hpssComponent) GetFrequencyForBin(binIndex int) float64 {
+	return c.p.source.GetFrequencyForBin(binIndex)
+}
+
+// GetFFTSize delegates to the underlying source.
+func (c *hpssComponent) GetFFTSize() int {
+	return c.p.source.GetFFTSize()
+}
+
+// GetSampleRate delegates to the underlying source.
+func (c *hpssComponent) GetSampleRate() float64 {
+	return c.p.source.GetSampleRate()
+}
+
+// median returns the median of values, reordering the slice in place.
+// It does not allocate, so it is safe to use on the hot path with a scratch buffer.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
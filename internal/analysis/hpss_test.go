@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import "testing"
+
+// staticProvider is an FFTResultProvider returning whatever spectrum the test sets.
+type staticProvider struct {
+	fftSize int
+	mags    []float64
+}
+
+func (s *staticProvider) GetMagnitudes() []float64 { return append([]float64(nil), s.mags...) }
+func (s *staticProvider) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s.mags)
+	return nil
+}
+func (s *staticProvider) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s *staticProvider) GetFFTSize() int                    { return s.fftSize }
+func (s *staticProvider) GetSampleRate() float64             { return float64(s.fftSize) }
+
+func TestHPSSProcessor_Separates(t *testing.T) {
+	src := &staticProvider{fftSize: 64, mags: make([]float64, 33)}
+	p, err := NewHPSSProcessor(src, 9, 9, 2)
+	if err != nil {
+		t.Fatalf("NewHPSSProcessor error: %v", err)
+	}
+
+	// A steady tone in bin 10 for a while: should end up in the harmonic part.
+	src.mags[10] = 1
+	for range 10 {
+		p.Process(nil)
+	}
+	h, perc := p.GetEnergies()
+	if h <= perc {
+		t.Errorf("steady tone: harmonic energy %f should exceed percussive energy %f", h, perc)
+	}
+
+	// A single broadband click: should end up in the percussive part.
+	for i := range src.mags {
+		src.mags[i] = 1
+	}
+	p.Process(nil)
+	h, perc = p.GetEnergies()
+	if perc <= h {
+		t.Errorf("click: percussive energy %f should exceed harmonic energy %f", perc, h)
+	}
+
+	// The views must add up to the original spectrum.
+	harm := p.Harmonic().GetMagnitudes()
+	percMags := make([]float64, 33)
+	if err := p.Percussive().GetMagnitudesInto(percMags); err != nil {
+		t.Fatalf("GetMagnitudesInto error: %v", err)
+	}
+	for i := range harm {
+		if sum := harm[i] + percMags[i]; sum < 0.999 || sum > 1.001 {
+			t.Errorf("bin %d: harmonic+percussive = %f, want 1", i, sum)
+		}
+	}
+	if err := p.Percussive().GetMagnitudesInto(make([]float64, 3)); err == nil {
+		t.Error("expected length mismatch error, got nil")
+	}
+}
+
+func TestNewHPSSProcessor_Invalid(t *testing.T) {
+	if _, err := NewHPSSProcessor(nil, 9, 9, 2); err == nil {
+		t.Error("expected error for nil source, got nil")
+	}
+	if _, err := NewHPSSProcessor(&staticProvider{fftSize: 64}, 0, 9, 2); err == nil {
+		t.Error("expected error for zero kernel, got nil")
+	}
+}
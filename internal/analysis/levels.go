@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+)
+
+// SilenceDB is the level reported for digital silence, and the lowest level any
+// processor reports.
+const SilenceDB = -120.0
+
+// DefaultBandEdges split the spectrum into the usual seven mixing bands: sub, bass,
+// low mids, mids, high mids, presence and brilliance (Hz).
+var DefaultBandEdges = []float64{20, 60, 250, 500, 2000, 4000, 6000, 20000}
+
+// LevelProcessor meters the loudness of each buffer. The RMS and peak sample levels are
+// measured from the input samples, and the level of each frequency band is measured
+// from the spectrum of an FFTResultProvider. All levels are in dBFS, where 0 is a full
+// scale signal; band levels are relative to a full scale sine in a rectangular window, so
+// tapered windows read a few dB lower.
+//
+// The processor must be registered after the processor backing its source.
+type LevelProcessor struct {
+	source    FFTResultProvider // Spectrum of the current buffer.
+	bandEdges []float64         // Band edges in Hz, len(bands)+1 entries.
+	bandBins  [][2]int          // First and last (exclusive) bin of each band.
+	magnitude []float64         // Pre-allocated buffer for the spectrum.
+	reference float64           // Magnitude of a full scale sine, used as 0 dB for bands.
+
+	mu     sync.RWMutex // Protects rms, peak and bands.
+	rms    float64      // Latest RMS level (dBFS).
+	peak   float64      // Latest peak sample level (dBFS).
+	bands  []float64    // Latest band levels (dBFS).
+	update []float64    // Scratch buffer the band levels are computed into.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*LevelProcessor)(nil)
+var _ ClosableProcessor = (*LevelProcessor)(nil)
+
+// NewLevelProcessor creates a level meter with frequency bands between consecutive
+// bandEdges (Hz, ascending). A nil or empty bandEdges uses DefaultBandEdges. Edges above
+// the Nyquist frequency are clamped, so bands may end up empty and read as silence.
+func NewLevelProcessor(source FFTResultProvider, bandEdges []float64) (*LevelProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("level source cannot be nil")
+	}
+	if len(bandEdges) == 0 {
+		bandEdges = DefaultBandEdges
+	}
+	if len(bandEdges) < 2 {
+		return nil, fmt.Errorf("level band edges need at least 2 entries, got %d", len(bandEdges))
+	}
+	for i := 1; i < len(bandEdges); i++ {
+		if bandEdges[i-1] < 0 || bandEdges[i] <= bandEdges[i-1] {
+			return nil, fmt.Errorf("level band edges must be ascending and non-negative, got %v", bandEdges)
+		}
+	}
+
+	bins := source.GetFFTSize()/2 + 1
+	binWidth := source.GetSampleRate() / float64(source.GetFFTSize())
+	bandBins := make([][2]int, len(bandEdges)-1)
+	for i := range bandBins {
+		lo := min(int(math.Ceil(bandEdges[i]/binWidth)), bins)
+		hi := min(int(math.Ceil(bandEdges[i+1]/binWidth)), bins)
+		bandBins[i] = [2]int{lo, hi}
+	}
+
+	log.Printf("Analysis: Initializing LevelProcessor (Bands: %d, Edges: %v Hz)", len(bandBins), bandEdges)
+
+	bands := make([]float64, len(bandBins))
+	for i := range bands {
+		bands[i] = SilenceDB
+	}
+	return &LevelProcessor{
+		source:    source,
+		bandEdges: append([]float64(nil), bandEdges...),
+		bandBins:  bandBins,
+		magnitude: make([]float64, bins),
+		reference: float64(source.GetFFTSize()) / 2,
+		rms:       SilenceDB,
+		peak:      SilenceDB,
+		bands:     bands,
+		update:    make([]float64, len(bandBins)),
+	}, nil
+}
+
+// Process measures the sample levels of inputBuffer and the band levels of the latest
+// spectrum. Implements analysis.AudioProcessor.
+func (p *LevelProcessor) Process(inputBuffer []int32) {
+	const normFactor = 1.0 / float64(0x80000000)
+
+	var sumSquares, peak float64
+	for _, s := range inputBuffer {
+		v := float64(s) * normFactor
+		sumSquares += v * v
+		peak = math.Max(peak, math.Abs(v))
+	}
+	rms := SilenceDB
+	if len(inputBuffer) > 0 {
+		rms = amplitudeDB(math.Sqrt(sumSquares / float64(len(inputBuffer))))
+	}
+
+	haveBands := p.source.GetMagnitudesInto(p.magnitude) == nil
+	if haveBands {
+		for i, band := range p.bandBins {
+			var energy float64
+			for _, m := range p.magnitude[band[0]:band[1]] {
+				energy += m * m
+			}
+			p.update[i] = amplitudeDB(math.Sqrt(energy) / p.reference)
+		}
+	}
+
+	p.mu.Lock()
+	p.rms = rms
+	p.peak = amplitudeDB(peak)
+	if haveBands {
+		copy(p.bands, p.update)
+	}
+	p.mu.Unlock()
+}
+
+// amplitudeDB converts a linear amplitude (1 = full scale) to dBFS, clamped to SilenceDB.
+func amplitudeDB(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return SilenceDB
+	}
+	return math.Max(20*math.Log10(amplitude), SilenceDB)
+}
+
+// GetLevels returns the RMS and peak sample level of the latest buffer in dBFS.
+func (p *LevelProcessor) GetLevels() (rms, peak float64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.rms, p.peak
+}
+
+// GetBandsInto copies the latest band levels (dBFS, lowest band first) into dst and
+// returns how many were written.
+func (p *LevelProcessor) GetBandsInto(dst []float64) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return copy(dst, p.bands)
+}
+
+// BandCount returns the number of frequency bands.
+func (p *LevelProcessor) BandCount() int {
+	return len(p.bandBins)
+}
+
+// BandEdges returns a copy of the band edges in Hz (BandCount()+1 entries).
+func (p *LevelProcessor) BandEdges() []float64 {
+	return append([]float64(nil), p.bandEdges...)
+}
+
+// Close handles any necessary cleanup for the LevelProcessor.
+// Implements the analysis.ClosableProcessor interface.
+func (p *LevelProcessor) Close() error {
+	log.Printf("Analysis: Closing LevelProcessor (no specific resources to release)")
+	return nil
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevelProcessor_Levels(t *testing.T) {
+	src := &staticProvider{fftSize: 64, mags: make([]float64, 33)}
+	p, err := NewLevelProcessor(src, []float64{0, 8, 16, 100})
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+	if p.BandCount() != 3 {
+		t.Fatalf("BandCount() = %d, want 3", p.BandCount())
+	}
+
+	// Silence.
+	p.Process(make([]int32, 64))
+	rms, peak := p.GetLevels()
+	if rms != SilenceDB || peak != SilenceDB {
+		t.Errorf("silence: levels = %.1f/%.1f, want %.1f", rms, peak, SilenceDB)
+	}
+
+	// Full scale square wave: RMS and peak are both 0 dBFS.
+	square := make([]int32, 64)
+	for i := range square {
+		square[i] = math.MaxInt32
+		if i%2 == 1 {
+			square[i] = math.MinInt32 + 1
+		}
+	}
+	// A full scale rectangular-window sine in bin 4 reads 0 dB in the first band.
+	src.mags[4] = 32
+	p.Process(square)
+	rms, peak = p.GetLevels()
+	if math.Abs(rms) > 0.01 || math.Abs(peak) > 0.01 {
+		t.Errorf("full scale: levels = %.3f/%.3f, want 0/0", rms, peak)
+	}
+
+	bands := make([]float64, 3)
+	if n := p.GetBandsInto(bands); n != 3 {
+		t.Fatalf("GetBandsInto wrote %d bands, want 3", n)
+	}
+	if math.Abs(bands[0]) > 0.01 {
+		t.Errorf("band 0 = %.2f dB, want 0", bands[0])
+	}
+	if bands[1] != SilenceDB {
+		t.Errorf("band 1 = %.2f dB, want silence", bands[1])
+	}
+	// The last edge is above Nyquist and is clamped.
+	if bands[2] != SilenceDB {
+		t.Errorf("band 2 = %.2f dB, want silence", bands[2])
+	}
+
+	if _, err := NewLevelProcessor(src, []float64{100, 50}); err == nil {
+		t.Error("expected error for descending band edges, got nil")
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// EventOnset is the name of the events emitted by the OnsetProcessor.
+const EventOnset = "onset"
+
+// Tuning constants for the onset detector.
+const (
+	onsetHistory     = time.Second // Length of the flux history the adaptive threshold averages.
+	onsetCompression = 100.0       // Scale of the log compression of magnitudes, log(1 + c*m).
+	onsetMinFlux     = 0.05        // Flux an onset must exceed even after silence.
+	onsetWarmup      = 8           // Frames of history needed before the average is trusted.
+	onsetEventBuffer = 16          // Capacity of the event channel.
+)
+
+// OnsetProcessor detects note onsets and drum hits from the spectral flux of an
+// FFTResultProvider: the sum, over all bins, of the increase of the log-compressed
+// magnitude since the previous frame. Decreases are ignored, so only energy appearing in
+// the spectrum counts (Dixon, 2006). An onset is detected when the flux rises above
+// threshold times its average over the last second, but no sooner than minInterval
+// after the previous one. The flux of every frame is the onset strength envelope that
+// the TempoProcessor estimates the tempo from.
+//
+// The processor must be registered after the processor backing its source.
+type OnsetProcessor struct {
+	source      FFTResultProvider // Spectrum of the current frame.
+	frameRate   float64           // Frames (calls to Process) per second.
+	threshold   float64           // Multiple of the average flux an onset must exceed.
+	minInterval int               // Minimum frames between onsets.
+	current     []float64         // Log-compressed magnitudes of the current frame.
+	previous    []float64         // Log-compressed magnitudes of the previous frame.
+	primed      bool              // Whether previous holds a frame.
+	history     []float64         // Ring buffer of recent flux values.
+	histPos     int               // Next write position in history.
+	histCount   int               // Number of valid values in history.
+	histSum     float64           // Sum of the valid values in history.
+	sinceOnset  int               // Frames since the latest onset.
+	armed       bool              // Whether the flux fell below the threshold since the latest onset.
+
+	events    chan Event   // Onset events.
+	closeOnce sync.Once    // Ensures the event channel is closed once.
+	mu        sync.RWMutex // Protects strength, onsets and onsetStrength.
+
+	strength      float64 // Flux of the latest frame.
+	onsets        uint64  // Onsets detected so far.
+	onsetStrength float64 // Flux of the latest onset.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*OnsetProcessor)(nil)
+var _ ClosableProcessor = (*OnsetProcessor)(nil)
+var _ EventProvider = (*OnsetProcessor)(nil)
+var _ OnsetProvider = (*OnsetProcessor)(nil)
+
+// NewOnsetProcessor creates an onset detector reading from source, whose Process is
+// called frameRate times per second (the sample rate divided by the hop between
+// frames). threshold is the multiple of the recent average flux an onset must exceed
+// (> 1, higher detects fewer onsets) and minInterval the shortest time between onsets.
+func NewOnsetProcessor(source FFTResultProvider, frameRate, threshold float64, minInterval time.Duration) (*OnsetProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("onset source cannot be nil")
+	}
+	if frameRate <= 0 {
+		return nil, fmt.Errorf("onset frame rate must be positive, got %f", frameRate)
+	}
+	if threshold <= 1 {
+		return nil, fmt.Errorf("onset threshold must be greater than 1, got %f", threshold)
+	}
+	if minInterval < 0 {
+		return nil, fmt.Errorf("onset minimum interval cannot be negative, got %s", minInterval)
+	}
+
+	bins := source.GetFFTSize()/2 + 1
+	historyLen := max(int(math.Round(onsetHistory.Seconds()*frameRate)), 1)
+	minFrames := int(math.Round(minInterval.Seconds() * frameRate))
+
+	log.Printf("Analysis: Initializing OnsetProcessor (FrameRate: %.1f Hz, Threshold: %.2f, MinInterval: %s, History: %d frames)",
+		frameRate, threshold, minInterval, historyLen)
+
+	return &OnsetProcessor{
+		source:      source,
+		frameRate:   frameRate,
+		threshold:   threshold,
+		minInterval: minFrames,
+		current:     make([]float64, bins),
+		previous:    make([]float64, bins),
+		history:     make([]float64, historyLen),
+		sinceOnset:  minFrames,
+		armed:       true,
+		events:      make(chan Event, onsetEventBuffer),
+	}, nil
+}
+
+// Process computes the spectral flux of the latest spectrum and emits an onset event if
+// it stands out. The input buffer itself is ignored. Implements analysis.AudioProcessor.
+func (p *OnsetProcessor) Process(_ []int32) {
+	if err := p.source.GetMagnitudesInto(p.current); err != nil {
+		return
+	}
+
+	// --- 1. Spectral Flux ---
+
+	var flux float64
+	for i, m := range p.current {
+		c := math.Log1p(onsetCompression * m)
+		if p.primed {
+			flux += math.Max(c-p.previous[i], 0)
+		}
+		p.current[i] = c
+	}
+	flux /= float64(len(p.current))
+	p.current, p.previous = p.previous, p.current
+	p.primed = true
+
+	// --- 2. Adaptive Threshold ---
+
+	// The average excludes the current frame, so a single loud hit can't raise its own bar.
+	mean := 0.0
+	if p.histCount > 0 {
+		mean = p.histSum / float64(p.histCount)
+	}
+	level := math.Max(p.threshold*mean, onsetMinFlux)
+	if p.histCount == len(p.history) {
+		p.histSum -= p.history[p.histPos]
+	}
+	p.history[p.histPos] = flux
+	p.histSum += flux
+	p.histPos = (p.histPos + 1) % len(p.history)
+	p.histCount = min(p.histCount+1, len(p.history))
+
+	// --- 3. Peak Picking ---
+
+	// Only the frame crossing the threshold counts; the flux must fall below it again
+	// before the next onset.
+	p.sinceOnset++
+	onset := false
+	if flux < level {
+		p.armed = true
+	} else if p.armed && p.histCount > min(onsetWarmup, len(p.history)-1) && p.sinceOnset > p.minInterval {
+		onset = true
+		p.armed = false
+		p.sinceOnset = 0
+	}
+
+	p.mu.Lock()
+	p.strength = flux
+	if onset {
+		p.onsets++
+		p.onsetStrength = flux
+	}
+	p.mu.Unlock()
+
+	if onset {
+		select {
+		case p.events <- Event{Source: "onset", Name: EventOnset, Value: flux, Time: time.Now()}:
+		default:
+		}
+	}
+}
+
+// GetOnsetStrength returns the spectral flux of the latest frame.
+// Implements the analysis.OnsetProvider interface.
+func (p *OnsetProcessor) GetOnsetStrength() float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.strength
+}
+
+// GetFrameRate returns the number of frames analysed per second.
+// Implements the analysis.OnsetProvider interface.
+func (p *OnsetProcessor) GetFrameRate() float64 {
+	return p.frameRate
+}
+
+// GetOnsets returns the number of onsets detected so far and the flux of the latest one.
+// Polling consumers compare the count with the previous one to learn about new onsets.
+func (p *OnsetProcessor) GetOnsets() (count uint64, strength float64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.onsets, p.onsetStrength
+}
+
+// Events returns the channel on which onset events are delivered.
+// Implements the analysis.EventProvider interface.
+func (p *OnsetProcessor) Events() <-chan Event {
+	return p.events
+}
+
+// Close closes the event channel. The audio stream must be stopped before calling Close.
+// Implements the analysis.ClosableProcessor interface.
+func (p *OnsetProcessor) Close() error {
+	p.closeOnce.Do(func() {
+		log.Printf("Analysis: Closing OnsetProcessor")
+		close(p.events)
+	})
+	return nil
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// clickTrack returns a function filling buffers with quiet noise and a short, loud
+// burst of noise every period frames, starting at frame offset.
+func clickTrack(seed int64, period, offset int) func(frame int, buf []int32) {
+	rng := rand.New(rand.NewSource(seed))
+	return func(frame int, buf []int32) {
+		amplitude := 0.002
+		if frame >= offset && (frame-offset)%period == 0 {
+			amplitude = 0.5
+		}
+		for i := range buf {
+			buf[i] = int32(amplitude * rng.NormFloat64() * math.MaxInt32 / 4)
+		}
+	}
+}
+
+func TestOnsetProcessor_Clicks(t *testing.T) {
+	const (
+		sampleRate = 48000.0
+		frameSize  = 512
+		frameRate  = sampleRate / frameSize // 93.75 frames per second.
+	)
+	fft, err := NewFFTProcessor(frameSize, sampleRate, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	onset, err := NewOnsetProcessor(fft, frameRate, 1.5, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewOnsetProcessor error: %v", err)
+	}
+	if _, err := NewOnsetProcessor(fft, frameRate, 1, 0); err == nil {
+		t.Error("expected error for a threshold of 1")
+	}
+
+	fill := clickTrack(1, 40, 20)
+	buf := make([]int32, frameSize)
+	var detected []int
+	for frame := range 400 {
+		fill(frame, buf)
+		fft.Process(buf)
+		onset.Process(buf)
+		select {
+		case ev := <-onset.Events():
+			if ev.Name != EventOnset || ev.Value <= 0 {
+				t.Errorf("event = %+v", ev)
+			}
+			detected = append(detected, frame)
+		default:
+		}
+	}
+
+	// Clicks at frames 20, 60, ..., 380; the background noise must not trigger.
+	if len(detected) != 10 {
+		t.Fatalf("onsets at frames %v, want 10 at 20, 60, ..., 380", detected)
+	}
+	for i, frame := range detected {
+		if frame != 20+40*i {
+			t.Errorf("onset %d at frame %d, want %d", i, frame, 20+40*i)
+		}
+	}
+	if count, strength := onset.GetOnsets(); count != 10 || strength <= 0 {
+		t.Errorf("GetOnsets = %d, %f; want 10 onsets", count, strength)
+	}
+
+	onset.Close()
+	onset.Close()
+	if _, ok := <-onset.Events(); ok {
+		t.Error("expected closed event channel")
+	}
+}
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+)
+
+// PeakInterpolation defines the type for selecting a sub-bin peak interpolation method.
+type PeakInterpolation int
+
+const (
+	// ParabolicInterpolation fits a parabola through the linear magnitudes of the peak bin
+	// and its two neighbours.
+	ParabolicInterpolation PeakInterpolation = iota
+	// GaussianInterpolation fits a parabola through the log magnitudes, which is exact for
+	// Gaussian-shaped peaks and very close for the main lobe of common windows.
+	GaussianInterpolation
+)
+
+// SpectralPeak describes a single peak found in a magnitude spectrum.
+type SpectralPeak struct {
+	Frequency float64 // Interpolated peak frequency (Hz).
+	Magnitude float64 // Interpolated peak magnitude (same scale as the source spectrum).
+	Bandwidth float64 // Width of the peak at -3 dB (Hz).
+}
+
+// Pre-allocated buffers for peak picking.
+type peakWorkspace struct {
+	magnitude []float64      // Latest magnitude frame fetched from the source.
+	bins      []int          // Bin indices of the strongest local maxima, strongest first.
+	peaks     []SpectralPeak // Peaks of the latest frame, strongest first.
+	count     int            // Number of valid entries in peaks.
+	mu        sync.RWMutex   // Protects peaks and count.
+}
+
+// PeakProcessor finds the strongest local maxima of each spectrum produced by an
+// FFTResultProvider and refines them to sub-bin accuracy. Bin centres alone are
+// sampleRate/fftSize apart (172 Hz at 256 points and 44.1 kHz); interpolating between
+// the peak bin and its neighbours recovers the true frequency to a small fraction of that.
+//
+// The processor must be registered after the processor backing its source.
+type PeakProcessor struct {
+	source        FFTResultProvider // Spectrum to analyse.
+	binWidth      float64           // Frequency spacing of bins (Hz).
+	maxPeaks      int               // Maximum number of peaks reported per frame.
+	interpolation PeakInterpolation // Sub-bin interpolation method.
+	threshold     float64           // Minimum magnitude relative to the strongest peak (linear).
+	workspace     peakWorkspace     // Pre-allocated buffers.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*PeakProcessor)(nil)
+var _ ClosableProcessor = (*PeakProcessor)(nil)
+
+// NewPeakProcessor creates a peak picker reporting up to maxPeaks peaks per frame.
+// Peaks more than thresholdDB below the strongest peak of the frame are ignored
+// (e.g. -60); a threshold of 0 or above disables this.
+func NewPeakProcessor(source FFTResultProvider, maxPeaks int, interpolation PeakInterpolation, thresholdDB float64) (*PeakProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("peak source cannot be nil")
+	}
+	if maxPeaks < 1 {
+		return nil, fmt.Errorf("peak count must be positive, got %d", maxPeaks)
+	}
+
+	threshold := 0.0
+	if thresholdDB < 0 {
+		threshold = math.Pow(10, thresholdDB/20)
+	}
+	bins := source.GetFFTSize()/2 + 1
+
+	log.Printf("Analysis: Initializing PeakProcessor (Peaks: %d, Interpolation: %v, Threshold: %.1f dB)",
+		maxPeaks, interpolation, thresholdDB)
+
+	return &PeakProcessor{
+		source:        source,
+		binWidth:      source.GetSampleRate() / float64(source.GetFFTSize()),
+		maxPeaks:      maxPeaks,
+		interpolation: interpolation,
+		threshold:     threshold,
+		workspace: peakWorkspace{
+			magnitude: make([]float64, bins),
+			bins:      make([]int, 0, maxPeaks),
+			peaks:     make([]SpectralPeak, maxPeaks),
+		},
+	}, nil
+}
+
+// Process fetches the latest spectrum from the source and picks its peaks.
+// The input buffer itself is ignored. Implements analysis.AudioProcessor.
+func (p *PeakProcessor) Process(_ []int32) {
+	ws := &p.workspace
+	mag := ws.magnitude
+	if err := p.source.GetMagnitudesInto(mag); err != nil {
+		return
+	}
+
+	// --- 1. Collect Strongest Local Maxima ---
+
+	// Keep the best maxPeaks candidates sorted by magnitude using insertion, which is
+	// cheap for the small N used here and doesn't allocate.
+	ws.bins = ws.bins[:0]
+	for k := 1; k < len(mag)-1; k++ {
+		if mag[k] <= mag[k-1] || mag[k] < mag[k+1] || mag[k] == 0 {
+			continue
+		}
+		if len(ws.bins) == p.maxPeaks && mag[k] <= mag[ws.bins[len(ws.bins)-1]] {
+			continue
+		}
+		if len(ws.bins) < p.maxPeaks {
+			ws.bins = append(ws.bins, k)
+		}
+		i := len(ws.bins) - 1
+		for ; i > 0 && mag[ws.bins[i-1]] < mag[k]; i-- {
+			ws.bins[i] = ws.bins[i-1]
+		}
+		ws.bins[i] = k
+	}
+
+	// --- 2. Interpolate ---
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.count = 0
+	if len(ws.bins) == 0 {
+		return
+	}
+	floor := mag[ws.bins[0]] * p.threshold
+	for _, k := range ws.bins {
+		if mag[k] < floor {
+			break
+		}
+		offset, peakMag := p.interpolate(mag[k-1], mag[k], mag[k+1])
+		ws.peaks[ws.count] = SpectralPeak{
+			Frequency: (float64(k) + offset) * p.binWidth,
+			Magnitude: peakMag,
+			Bandwidth: p.bandwidth(mag, k, peakMag),
+		}
+		ws.count++
+	}
+}
+
+// interpolate returns the fractional bin offset (-0.5..0.5) and magnitude of the
+// vertex of a parabola through three neighbouring values.
+func (p *PeakProcessor) interpolate(a, b, c float64) (offset, magnitude float64) {
+	if p.interpolation == GaussianInterpolation {
+		const tiny = 1e-300
+		a, b, c = math.Log(a+tiny), math.Log(b+tiny), math.Log(c+tiny)
+	}
+	denom := a - 2*b + c
+	if denom != 0 {
+		offset = 0.5 * (a - c) / denom
+	}
+	offset = math.Max(-0.5, math.Min(0.5, offset))
+	magnitude = b - 0.25*(a-c)*offset
+	if p.interpolation == GaussianInterpolation {
+		magnitude = math.Exp(magnitude)
+	}
+	return offset, magnitude
+}
+
+// bandwidth measures the width of the peak at bin k where the magnitude falls
+// below peakMag/√2 (-3 dB), interpolating linearly between bins.
+func (p *PeakProcessor) bandwidth(mag []float64, k int, peakMag float64) float64 {
+	level := peakMag / math.Sqrt2
+	crossing := func(step int) float64 {
+		i := k
+		for i+step >= 0 && i+step < len(mag) && mag[i+step] >= level {
+			i += step
+		}
+		next := i + step
+		if next < 0 || next >= len(mag) || mag[i] == mag[next] {
+			return float64(i)
+		}
+		frac := (mag[i] - level) / (mag[i] - mag[next])
+		return float64(i) + float64(step)*math.Min(frac, 1)
+	}
+	return (crossing(1) - crossing(-1)) * p.binWidth
+}
+
+// GetPeaks returns a copy of the peaks of the latest frame, strongest first.
+func (p *PeakProcessor) GetPeaks() []SpectralPeak {
+	p.workspace.mu.RLock()
+	defer p.workspace.mu.RUnlock()
+
+	peaks := make([]SpectralPeak, p.workspace.count)
+	copy(peaks, p.workspace.peaks)
+	return peaks
+}
+
+// GetPeaksInto copies the peaks of the latest frame into dst and returns how many were
+// written. Passing a slice of length maxPeaks avoids all allocations.
+func (p *PeakProcessor) GetPeaksInto(dst []SpectralPeak) int {
+	p.workspace.mu.RLock()
+	defer p.workspace.mu.RUnlock()
+	return copy(dst, p.workspace.peaks[:p.workspace.count])
+}
+
+// MaxPeaks returns the maximum number of peaks reported per frame.
+func (p *PeakProcessor) MaxPeaks() int {
+	return p.maxPeaks
+}
+
+// Close handles any necessary cleanup for the PeakProcessor.
+// Implements the analysis.ClosableProcessor interface.
+func (p *PeakProcessor) Close() error {
+	log.Printf("Analysis: Closing PeakProcessor (no specific resources to release)")
+	return nil
+}
+
+// String returns the configuration name of the interpolation method.
+func (i PeakInterpolation) String() string {
+	switch i {
+	case ParabolicInterpolation:
+		return "parabolic"
+	case GaussianInterpolation:
+		return "gaussian"
+	default:
+		return fmt.Sprintf("PeakInterpolation(%d)", int(i))
+	}
+}
+
+// ParsePeakInterpolation converts a string name (case-insensitive) to a PeakInterpolation,
+// returns a known default (parabolic) and an error if the name is unknown.
+func ParsePeakInterpolation(name string) (PeakInterpolation, error) {
+	switch strings.ToLower(name) {
+	case "parabolic", "quadratic":
+		return ParabolicInterpolation, nil
+	case "gaussian":
+		return GaussianInterpolation, nil
+	default:
+		return ParabolicInterpolation, fmt.Errorf("unknown peak interpolation name: '%s'", name)
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPeakProcessor_SubBinAccuracy(t *testing.T) {
+	const (
+		sampleRate = 44100.0
+		fftSize    = 256
+	)
+	// Bin spacing is ~172 Hz; pick frequencies well between bin centres.
+	freqs := []float64{1000, 5300}
+	amps := []float64{0.5, 0.25}
+
+	buf := make([]int32, fftSize)
+	for i := range buf {
+		var v float64
+		for j, f := range freqs {
+			v += amps[j] * math.Sin(2*math.Pi*f*float64(i)/sampleRate)
+		}
+		buf[i] = int32(v * math.MaxInt32)
+	}
+
+	fft, err := NewFFTProcessor(fftSize, sampleRate, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	fft.Process(buf)
+
+	tests := []struct {
+		interp    PeakInterpolation
+		tolerance float64
+	}{
+		{ParabolicInterpolation, 20},
+		{GaussianInterpolation, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interp.String(), func(t *testing.T) {
+			p, err := NewPeakProcessor(fft, 4, tt.interp, -40)
+			if err != nil {
+				t.Fatalf("NewPeakProcessor error: %v", err)
+			}
+			p.Process(nil)
+
+			peaks := p.GetPeaks()
+			if len(peaks) != 2 {
+				t.Fatalf("got %d peaks above threshold, want 2: %+v", len(peaks), peaks)
+			}
+			for i, want := range freqs {
+				if diff := math.Abs(peaks[i].Frequency - want); diff > tt.tolerance {
+					t.Errorf("peak %d frequency = %.1f Hz, want %.1f ±%.0f Hz", i, peaks[i].Frequency, want, tt.tolerance)
+				}
+				// A Hann main lobe is roughly 1.44 bins wide at -3 dB.
+				if bw := peaks[i].Bandwidth / (sampleRate / fftSize); bw < 1 || bw > 2 {
+					t.Errorf("peak %d bandwidth = %.2f bins, want ~1.44", i, bw)
+				}
+			}
+			if peaks[0].Magnitude <= peaks[1].Magnitude {
+				t.Error("peaks not ordered by magnitude")
+			}
+
+			dst := make([]SpectralPeak, 1)
+			if n := p.GetPeaksInto(dst); n != 1 || dst[0] != peaks[0] {
+				t.Errorf("GetPeaksInto = %d %+v, want 1 %+v", n, dst[0], peaks[0])
+			}
+		})
+	}
+}
+
+func TestParsePeakInterpolation(t *testing.T) {
+	if i, err := ParsePeakInterpolation("Gaussian"); err != nil || i != GaussianInterpolation {
+		t.Errorf("ParsePeakInterpolation(Gaussian) = %v, %v", i, err)
+	}
+	if i, err := ParsePeakInterpolation("cubic"); err == nil || i != ParabolicInterpolation {
+		t.Errorf("ParsePeakInterpolation(cubic) = %v, %v; want default and error", i, err)
+	}
+}
@@ -1,6 +1,8 @@
 // SPDX-License-Identifier: MIT
 package analysis
 
+import "time"
+
 // AudioProcessor defines the standard interface for components that process audio buffers.
 // Implementations are expected to analyze or transform the provided audio data.
 type AudioProcessor interface {
@@ -42,3 +44,51 @@ type FFTResultProvider interface {
 	// GetSampleRate returns the sample rate (in Hz) of the audio data used for the FFT analysis.
 	GetSampleRate() float64
 }
+
+// FrameResultProvider is implemented by spectrum providers that know which frame their
+// latest result was computed from, so frame-driven consumers can tell a result of the
+// frame they were notified about from a newer one.
+type FrameResultProvider interface {
+	FFTResultProvider
+
+	// GetFrameInto copies the latest magnitudes into dst like GetMagnitudesInto and
+	// returns the index of the frame they belong to (see Frame; 0 before the first one).
+	GetFrameInto(dst []float64) (uint64, error)
+}
+
+// Event describes a discrete occurrence detected by an analysis processor, such as the
+// start of speech or a chord change. Events are small value types so they can be passed
+// through channels from the real-time callback without allocating.
+type Event struct {
+	Source string    // Name of the processor that produced the event (e.g. "vad").
+	Name   string    // Event name (e.g. "speech_start").
+	Label  string    // Optional label carried by the event (e.g. a chord name).
+	Value  float64   // Strength or confidence associated with the event.
+	Time   time.Time // Wall clock time at which the event was detected.
+}
+
+// EventProvider defines an interface for processors that emit discrete events. Events are
+// delivered on a buffered channel; processors drop events rather than block the audio
+// callback when the consumer falls behind. The channel is closed when the processor is closed.
+type EventProvider interface {
+	Events() <-chan Event
+}
+
+// ChromaProvider defines an interface for components that fold a spectrum into the 12
+// pitch classes of the chromatic scale (a chromagram frame). Index 0 is C, 1 is C#, and
+// so on up to 11 for B.
+type ChromaProvider interface {
+	// GetChromaInto copies the latest chroma vector into dst. Values are normalised so
+	// the strongest pitch class is 1, or all zero for silence.
+	GetChromaInto(dst *[12]float64)
+}
+
+// OnsetProvider defines an interface for components that compute an onset strength
+// envelope: one value per analysis frame that peaks where notes or hits begin.
+type OnsetProvider interface {
+	// GetOnsetStrength returns the onset strength of the latest frame.
+	GetOnsetStrength() float64
+
+	// GetFrameRate returns the number of frames per second the envelope is sampled at.
+	GetFrameRate() float64
+}
@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// EventBeat is the name of the events emitted by the TempoProcessor.
+const EventBeat = "beat"
+
+// Tuning constants for the tempo estimator.
+const (
+	tempoPriorBPM      = 120.0                  // Centre of the tempo prior, resolves octave ambiguity.
+	tempoPriorOctaves  = 1.0                    // Width (standard deviation) of the tempo prior in octaves.
+	tempoUpdate        = 500 * time.Millisecond // Time between tempo estimates.
+	tempoMinConfidence = 0.1                    // Autocorrelation (relative to lag 0) below which no tempo is reported.
+	tempoMinVariance   = 1e-12                  // Envelope variance below which it is considered flat.
+	tempoEventBuffer   = 16                     // Capacity of the event channel.
+)
+
+// TempoProcessor estimates the tempo and tracks the beat from an OnsetProvider. Twice a
+// second, the autocorrelation of the onset strength envelope over the last window is
+// computed for the lags between maxBPM and minBPM. It is weighted by a log-normal prior
+// around 120 BPM, so a track is reported at 120 rather than at 60 or 240 BPM when all
+// three fit, and the best lag is refined by parabolic interpolation. The beat phase is
+// then aligned with the comb of beat positions that best matches the envelope and runs
+// freely at the estimated tempo between updates. A beat event carrying the tempo is
+// emitted whenever the phase wraps.
+//
+// The processor must be registered after the processor backing its source.
+type TempoProcessor struct {
+	source      OnsetProvider // Onset strength envelope.
+	frameRate   float64       // Envelope frames per second.
+	minLag      int           // Shortest beat period in frames (maxBPM).
+	maxLag      int           // Longest beat period in frames (minBPM).
+	envelope    []float64     // Ring buffer of the envelope over the window.
+	pos         int           // Next write position in envelope.
+	count       int           // Number of valid frames in envelope.
+	linear      []float64     // Scratch: envelope in time order, mean removed.
+	updateEvery int           // Frames between tempo estimates.
+	sinceUpdate int           // Frames since the latest estimate.
+	period      float64       // Beat period in frames, 0 while the tempo is unknown.
+	beatPhase   float64       // Beat phase (0..1) in the current frame.
+
+	events    chan Event   // Beat events.
+	closeOnce sync.Once    // Ensures the event channel is closed once.
+	mu        sync.RWMutex // Protects bpm, confidence, phase and beats.
+
+	bpm        float64 // Latest tempo estimate, 0 if unknown.
+	confidence float64 // Confidence of the estimate (0..1).
+	phase      float64 // Latest beat phase.
+	beats      uint64  // Beats so far.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*TempoProcessor)(nil)
+var _ ClosableProcessor = (*TempoProcessor)(nil)
+var _ EventProvider = (*TempoProcessor)(nil)
+
+// NewTempoProcessor creates a tempo estimator reading from source. Tempos between minBPM
+// and maxBPM are considered, from the envelope of the last window.
+func NewTempoProcessor(source OnsetProvider, minBPM, maxBPM float64, window time.Duration) (*TempoProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("tempo source cannot be nil")
+	}
+	if minBPM <= 0 || maxBPM <= minBPM {
+		return nil, fmt.Errorf("tempo range must be positive and ascending, got %.1f-%.1f BPM", minBPM, maxBPM)
+	}
+	frameRate := source.GetFrameRate()
+	minLag := int(math.Floor(60 * frameRate / maxBPM))
+	maxLag := int(math.Ceil(60 * frameRate / minBPM))
+	frames := int(math.Round(window.Seconds() * frameRate))
+	if minLag < 2 {
+		return nil, fmt.Errorf("tempo of %.1f BPM is too fast for %.1f frames per second", maxBPM, frameRate)
+	}
+	if frames < 2*maxLag {
+		return nil, fmt.Errorf("tempo window %s must cover at least two beats at %.1f BPM", window, minBPM)
+	}
+
+	log.Printf("Analysis: Initializing TempoProcessor (Range: %.0f-%.0f BPM, Lags: %d-%d frames, Window: %s)",
+		minBPM, maxBPM, minLag, maxLag, window)
+
+	return &TempoProcessor{
+		source:      source,
+		frameRate:   frameRate,
+		minLag:      minLag,
+		maxLag:      maxLag,
+		envelope:    make([]float64, frames),
+		linear:      make([]float64, frames),
+		updateEvery: max(int(tempoUpdate.Seconds()*frameRate), 1),
+		events:      make(chan Event, tempoEventBuffer),
+	}, nil
+}
+
+// Process appends the latest onset strength to the envelope, re-estimates the tempo when
+// due and advances the beat phase. The input buffer itself is ignored.
+// Implements analysis.AudioProcessor.
+func (p *TempoProcessor) Process(_ []int32) {
+	p.envelope[p.pos] = p.source.GetOnsetStrength()
+	p.pos = (p.pos + 1) % len(p.envelope)
+	p.count = min(p.count+1, len(p.envelope))
+
+	// --- 1. Advance the Beat ---
+
+	beat := false
+	if p.period > 0 {
+		p.beatPhase += 1 / p.period
+		if p.beatPhase >= 1 {
+			p.beatPhase -= math.Floor(p.beatPhase)
+			beat = true
+		}
+	}
+
+	// --- 2. Re-estimate ---
+
+	var bpm, confidence float64
+	p.sinceUpdate++
+	update := p.sinceUpdate >= p.updateEvery && p.count >= 2*p.maxLag
+	if update {
+		p.sinceUpdate = 0
+		bpm, confidence = p.estimate()
+	}
+
+	p.mu.Lock()
+	if update {
+		p.bpm, p.confidence = bpm, confidence
+	}
+	p.phase = p.beatPhase
+	if beat {
+		p.beats++
+	}
+	bpm = p.bpm
+	p.mu.Unlock()
+
+	if beat {
+		select {
+		case p.events <- Event{Source: "tempo", Name: EventBeat, Value: bpm, Time: time.Now()}:
+		default:
+		}
+	}
+}
+
+// estimate computes the tempo from the envelope and aligns the beat phase with it.
+func (p *TempoProcessor) estimate() (bpm, confidence float64) {
+	// --- 1. Linearise & Remove the Mean ---
+
+	n := p.count
+	start := (p.pos - n + len(p.envelope)) % len(p.envelope)
+	var mean float64
+	for i := range n {
+		p.linear[i] = p.envelope[(start+i)%len(p.envelope)]
+		mean += p.linear[i]
+	}
+	mean /= float64(n)
+	x := p.linear[:n]
+	var energy float64
+	for i := range x {
+		x[i] -= mean
+		energy += x[i] * x[i]
+	}
+	energy /= float64(n)
+	if energy <= tempoMinVariance {
+		p.period = 0
+		return 0, 0
+	}
+
+	// --- 2. Weighted Autocorrelation ---
+
+	acf := func(lag int) float64 {
+		var sum float64
+		for i := lag; i < n; i++ {
+			sum += x[i] * x[i-lag]
+		}
+		return sum / float64(n-lag)
+	}
+	score := func(lag float64, r float64) float64 {
+		octaves := math.Log2(60 * p.frameRate / lag / tempoPriorBPM)
+		return r * math.Exp(-0.5*octaves*octaves/(tempoPriorOctaves*tempoPriorOctaves))
+	}
+	bestLag, bestScore, bestR := 0, math.Inf(-1), 0.0
+	for lag := p.minLag; lag <= p.maxLag; lag++ {
+		r := acf(lag)
+		if s := score(float64(lag), r); s > bestScore {
+			bestLag, bestScore, bestR = lag, s, r
+		}
+	}
+	confidence = math.Max(0, math.Min(bestR/energy, 1))
+	if confidence < tempoMinConfidence {
+		p.period = 0
+		return 0, confidence
+	}
+
+	// Refine the lag between its neighbours.
+	period := float64(bestLag)
+	if bestLag > p.minLag && bestLag < p.maxLag {
+		a := score(float64(bestLag-1), acf(bestLag-1))
+		c := score(float64(bestLag+1), acf(bestLag+1))
+		if d := a - 2*bestScore + c; d < 0 {
+			period += 0.5 * (a - c) / d
+		}
+	}
+
+	// --- 3. Align the Beat ---
+
+	// Find how many frames ago the latest beat was: the offset whose comb of beats,
+	// one period apart, collects the most onset strength.
+	bestOffset, bestSum := 0, math.Inf(-1)
+	for offset := range int(math.Ceil(period)) {
+		var sum float64
+		for t := float64(n - 1 - offset); t >= 0; t -= period {
+			sum += x[int(math.Round(t))]
+		}
+		if sum > bestSum {
+			bestOffset, bestSum = offset, sum
+		}
+	}
+	p.period = period
+	p.beatPhase = float64(bestOffset) / period
+	return 60 * p.frameRate / period, confidence
+}
+
+// GetTempo returns the latest tempo estimate in BPM and its confidence (0..1). The tempo
+// is 0 until enough of the envelope has been seen or while it has no clear period.
+func (p *TempoProcessor) GetTempo() (bpm, confidence float64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.bpm, p.confidence
+}
+
+// GetBeatPhase returns the position within the current beat: 0 on the beat, rising
+// towards 1 just before the next one. It stays 0 while the tempo is unknown.
+func (p *TempoProcessor) GetBeatPhase() float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.phase
+}
+
+// GetBeats returns the number of beats so far. Polling consumers compare it with the
+// previous count to learn about new beats.
+func (p *TempoProcessor) GetBeats() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.beats
+}
+
+// Events returns the channel on which beat events are delivered.
+// Implements the analysis.EventProvider interface.
+func (p *TempoProcessor) Events() <-chan Event {
+	return p.events
+}
+
+// Close closes the event channel. The audio stream must be stopped before calling Close.
+// Implements the analysis.ClosableProcessor interface.
+func (p *TempoProcessor) Close() error {
+	p.closeOnce.Do(func() {
+		log.Printf("Analysis: Closing TempoProcessor")
+		close(p.events)
+	})
+	return nil
+}
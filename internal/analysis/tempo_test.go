@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTempoProcessor_ClickTrack(t *testing.T) {
+	const (
+		sampleRate = 48000.0
+		frameSize  = 512
+		frameRate  = sampleRate / frameSize
+	)
+	fft, err := NewFFTProcessor(frameSize, sampleRate, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	onset, err := NewOnsetProcessor(fft, frameRate, 1.5, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewOnsetProcessor error: %v", err)
+	}
+	tempo, err := NewTempoProcessor(onset, 60, 180, 6*time.Second)
+	if err != nil {
+		t.Fatalf("NewTempoProcessor error: %v", err)
+	}
+	if _, err := NewTempoProcessor(onset, 60, 180, time.Second); err == nil {
+		t.Error("expected error for a window shorter than two beats")
+	}
+
+	// 45 frames per click is 125 BPM; half-time (62.5) and double-time (250) also fit
+	// the envelope, the prior must pick 125.
+	const period = 45
+	fill := clickTrack(2, period, 10)
+	buf := make([]int32, frameSize)
+	beats := 0
+	var lastBeat int
+	for frame := range 1200 {
+		fill(frame, buf)
+		fft.Process(buf)
+		onset.Process(buf)
+		tempo.Process(buf)
+		select {
+		case <-onset.Events():
+		default:
+		}
+		select {
+		case ev := <-tempo.Events():
+			if ev.Name != EventBeat {
+				t.Errorf("event = %+v", ev)
+			}
+			beats++
+			lastBeat = frame
+		default:
+		}
+	}
+
+	bpm, confidence := tempo.GetTempo()
+	want := 60 * frameRate / period
+	if math.Abs(bpm-want) > 1 || confidence < 0.5 {
+		t.Errorf("GetTempo = %.2f BPM (confidence %.2f), want %.2f", bpm, confidence, want)
+	}
+	if beats < 15 || tempo.GetBeats() != uint64(beats) {
+		t.Errorf("beats = %d (GetBeats %d), want one per click once the tempo is known", beats, tempo.GetBeats())
+	}
+	// Beats fall on the clicks, give or take a frame.
+	if off := (lastBeat - 10) % period; off > 1 && off < period-1 {
+		t.Errorf("latest beat at frame %d, %d frames off the clicks", lastBeat, off)
+	}
+	if phase := tempo.GetBeatPhase(); phase < 0 || phase >= 1 {
+		t.Errorf("GetBeatPhase = %f, want 0..1", phase)
+	}
+
+	tempo.Close()
+	if _, ok := <-tempo.Events(); ok {
+		t.Error("expected closed event channel")
+	}
+}
+
+// constantOnsets is an OnsetProvider without any rhythm.
+type constantOnsets float64
+
+func (c constantOnsets) GetOnsetStrength() float64 { return float64(c) }
+func (c constantOnsets) GetFrameRate() float64     { return 100 }
+
+func TestTempoProcessor_NoRhythm(t *testing.T) {
+	tempo, err := NewTempoProcessor(constantOnsets(0.3), 60, 180, 4*time.Second)
+	if err != nil {
+		t.Fatalf("NewTempoProcessor error: %v", err)
+	}
+	defer tempo.Close()
+	for range 1000 {
+		tempo.Process(nil)
+	}
+	if bpm, _ := tempo.GetTempo(); bpm != 0 {
+		t.Errorf("GetTempo = %.2f BPM for a flat envelope, want 0", bpm)
+	}
+	if tempo.GetBeats() != 0 || len(tempo.Events()) != 0 {
+		t.Errorf("beats = %d for a flat envelope, want 0", tempo.GetBeats())
+	}
+}
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// VAD event names.
+const (
+	EventSpeechStart = "speech_start"
+	EventSpeechEnd   = "speech_end"
+)
+
+// Tuning constants for the voice activity detector.
+const (
+	vadSpeechLowHz     = 300.0  // Lower edge of the main speech band.
+	vadSpeechHighHz    = 3400.0 // Upper edge of the main speech band.
+	vadZCRLowHz        = 50.0   // Below this zero-crossing frequency the frame is hum/rumble.
+	vadZCRHighHz       = 3500.0 // Above this zero-crossing frequency the frame is hiss/fricative noise.
+	vadSNRMidpointDB   = 9.0    // SNR above the noise floor at which the energy score is 0.5.
+	vadSNRSlopeDB      = 3.0    // Width of the energy score sigmoid.
+	vadFloorRiseDB     = 0.05   // Noise floor rise per frame, lets the floor follow slowly increasing noise.
+	vadSmoothingAttack = 0.5    // Probability smoothing when rising (0..1, higher is faster).
+	vadSmoothingDecay  = 0.1    // Probability smoothing when falling.
+	vadEventBuffer     = 16     // Capacity of the event channel.
+)
+
+// VADProcessor is a voice activity detector. For every buffer it combines three cues into
+// a speech probability:
+//
+//   - Energy: the RMS level relative to an adaptive noise floor.
+//   - Zero-crossing rate: speech crosses zero at a rate between rumble and hiss.
+//   - Spectrum: the share of energy in the 300-3400 Hz speech band, weighted by how
+//     un-flat (formant-like) the spectrum is.
+//
+// When the probability crosses the threshold a speech_start event is emitted. Once it
+// drops below the threshold for longer than the hangover a speech_end event follows, so
+// short pauses between words don't split an utterance.
+//
+// Spectral features are read from an FFTResultProvider, so the processor must be
+// registered after the processor backing it. Only the energy and zero-crossing cues use
+// the mono downmix: the spectrum is whatever the source computed from its own input. The
+// engine's FFT transforms the raw input buffer, so with several interleaved channels the
+// spectral cue sees the channels interleaved as one signal, with each channel's content
+// folded across the band, and is unreliable. Use a mono input where speech matters.
+type VADProcessor struct {
+	source      FFTResultProvider // Spectrum of the current buffer.
+	sampleRate  float64           // Sample rate of the input (Hz).
+	channels    int               // Interleaved channels per input frame, downmixed to mono.
+	threshold   float64           // Probability above which a frame counts as speech.
+	hangover    time.Duration     // How long speech state is held after the probability drops.
+	magnitudes  []float64         // Pre-allocated buffer for the spectrum.
+	speechLoBin int               // First bin of the speech band.
+	speechHiBin int               // Last bin of the speech band.
+
+	noiseFloorDB float64       // Adaptive noise floor estimate (dBFS).
+	smoothed     float64       // Smoothed speech probability.
+	silentFor    time.Duration // Time spent below the threshold while speaking.
+
+	events    chan Event   // Speech start/end events.
+	closeOnce sync.Once    // Ensures the event channel is closed once.
+	mu        sync.RWMutex // Protects probability and speaking.
+
+	probability float64 // Latest (smoothed) speech probability, 0..1.
+	speaking    bool    // Whether speech is currently considered active.
+}
+
+// Compile-time checks for interface implementations.
+var _ AudioProcessor = (*VADProcessor)(nil)
+var _ ClosableProcessor = (*VADProcessor)(nil)
+var _ EventProvider = (*VADProcessor)(nil)
+
+// NewVADProcessor creates a voice activity detector using source for spectral features.
+// channels is the number of interleaved channels in the input buffers, which are
+// downmixed to mono. threshold is the speech probability (0..1) that starts an utterance
+// and hangover how long the utterance is held open after the probability drops below it.
+func NewVADProcessor(source FFTResultProvider, channels int, threshold float64, hangover time.Duration) (*VADProcessor, error) {
+	if source == nil {
+		return nil, fmt.Errorf("vad source cannot be nil")
+	}
+	if channels < 1 {
+		return nil, fmt.Errorf("vad channel count must be positive, got %d", channels)
+	}
+	if threshold <= 0 || threshold >= 1 {
+		return nil, fmt.Errorf("vad threshold must be between 0 and 1, got %f", threshold)
+	}
+	if hangover < 0 {
+		return nil, fmt.Errorf("vad hangover cannot be negative, got %s", hangover)
+	}
+
+	bins := source.GetFFTSize()/2 + 1
+	binWidth := source.GetSampleRate() / float64(source.GetFFTSize())
+	loBin := max(int(math.Round(vadSpeechLowHz/binWidth)), 1)
+	hiBin := min(int(math.Round(vadSpeechHighHz/binWidth)), bins-1)
+
+	log.Printf("Analysis: Initializing VADProcessor (Channels: %d, Threshold: %.2f, Hangover: %s, SpeechBins: %d-%d)",
+		channels, threshold, hangover, loBin, hiBin)
+
+	return &VADProcessor{
+		source:       source,
+		sampleRate:   source.GetSampleRate(),
+		channels:     channels,
+		threshold:    threshold,
+		hangover:     hangover,
+		magnitudes:   make([]float64, bins),
+		speechLoBin:  loBin,
+		speechHiBin:  hiBin,
+		noiseFloorDB: 0, // Starts high and drops to the quietest level seen.
+		events:       make(chan Event, vadEventBuffer),
+	}, nil
+}
+
+// Process updates the speech probability for the given buffer and emits start/end events.
+// Implements analysis.AudioProcessor.
+func (p *VADProcessor) Process(inputBuffer []int32) {
+	frames := len(inputBuffer) / p.channels
+	if frames == 0 {
+		return
+	}
+
+	// --- 1. Time Domain Features ---
+
+	// Interleaved channels are averaged per frame, so crossings between the samples of
+	// different channels don't count.
+	norm := 1.0 / float64(0x80000000) / float64(p.channels)
+	var sumSquares float64
+	crossings := 0
+	previous := 0.0
+	for i := range frames {
+		v := 0.0
+		for _, s := range inputBuffer[i*p.channels : (i+1)*p.channels] {
+			v += float64(s)
+		}
+		v *= norm
+		sumSquares += v * v
+		if i > 0 && (v >= 0) != (previous >= 0) {
+			crossings++
+		}
+		previous = v
+	}
+	levelDB := 10 * math.Log10(math.Max(sumSquares/float64(frames), 1e-12))
+	frameDuration := time.Duration(float64(frames) / p.sampleRate * float64(time.Second))
+	zcrHz := float64(crossings) / 2 * p.sampleRate / float64(frames)
+
+	// Track the noise floor: follow drops immediately, rise slowly.
+	p.noiseFloorDB = math.Min(levelDB, p.noiseFloorDB+vadFloorRiseDB)
+	energyScore := sigmoid((levelDB - p.noiseFloorDB - vadSNRMidpointDB) / vadSNRSlopeDB)
+
+	zcrScore := 1.0
+	if zcrHz < vadZCRLowHz {
+		zcrScore = zcrHz / vadZCRLowHz
+	} else if zcrHz > vadZCRHighHz {
+		zcrScore = math.Max(0, 1-(zcrHz-vadZCRHighHz)/vadZCRHighHz)
+	}
+
+	// --- 2. Spectral Features ---
+
+	spectralScore := 0.0
+	if err := p.source.GetMagnitudesInto(p.magnitudes); err == nil {
+		var total, band, logSum float64
+		n := 0
+		for i := 1; i < len(p.magnitudes); i++ {
+			power := p.magnitudes[i] * p.magnitudes[i]
+			total += power
+			if i >= p.speechLoBin && i <= p.speechHiBin {
+				band += power
+				logSum += math.Log(power + 1e-20)
+				n++
+			}
+		}
+		if total > 0 && n > 0 && band > 0 {
+			// Spectral flatness (geometric / arithmetic mean) within the speech band:
+			// ~1 for white noise, close to 0 for harmonic, formant-shaped spectra.
+			flatness := math.Exp(logSum/float64(n)) / (band / float64(n))
+			spectralScore = (band / total) * (1 - math.Min(flatness, 1))
+		}
+	}
+
+	// --- 3. Combine & Smooth ---
+
+	raw := energyScore * (0.5*spectralScore + 0.5*zcrScore)
+	rate := vadSmoothingDecay
+	if raw > p.smoothed {
+		rate = vadSmoothingAttack
+	}
+	p.smoothed += rate * (raw - p.smoothed)
+
+	// --- 4. State Machine ---
+
+	p.mu.Lock()
+	p.probability = p.smoothed
+	wasSpeaking := p.speaking
+	if p.smoothed >= p.threshold {
+		p.silentFor = 0
+		p.speaking = true
+	} else if p.speaking {
+		p.silentFor += frameDuration
+		if p.silentFor > p.hangover {
+			p.speaking = false
+		}
+	}
+	speaking := p.speaking
+	p.mu.Unlock()
+
+	if speaking && !wasSpeaking {
+		p.emit(EventSpeechStart, p.smoothed)
+	} else if !speaking && wasSpeaking {
+		p.emit(EventSpeechEnd, p.smoothed)
+	}
+}
+
+// emit delivers an event without blocking, dropping it if the consumer is not keeping up.
+func (p *VADProcessor) emit(name string, value float64) {
+	select {
+	case p.events <- Event{Source: "vad", Name: name, Value: value, Time: time.Now()}:
+	default:
+	}
+}
+
+// GetSpeechProbability returns the latest smoothed speech probability (0..1).
+func (p *VADProcessor) GetSpeechProbability() float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.probability
+}
+
+// IsSpeaking reports whether an utterance is currently active (including hangover).
+func (p *VADProcessor) IsSpeaking() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.speaking
+}
+
+// Events returns the channel on which speech_start and speech_end events are delivered.
+// Implements the analysis.EventProvider interface.
+func (p *VADProcessor) Events() <-chan Event {
+	return p.events
+}
+
+// Close closes the event channel. The audio stream must be stopped before calling Close.
+// Implements the analysis.ClosableProcessor interface.
+func (p *VADProcessor) Close() error {
+	p.closeOnce.Do(func() {
+		log.Printf("Analysis: Closing VADProcessor")
+		close(p.events)
+	})
+	return nil
+}
+
+// sigmoid maps x to (0, 1) with sigmoid(0) = 0.5.
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
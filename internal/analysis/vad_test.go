@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: MIT
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestVADProcessor_StartEnd(t *testing.T) {
+	const (
+		sampleRate = 16000.0
+		frameSize  = 512
+	)
+	fft, err := NewFFTProcessor(frameSize, sampleRate, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	vad, err := NewVADProcessor(fft, 1, 0.5, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewVADProcessor error: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	buf := make([]int32, frameSize)
+	pos := 0
+	run := func(frames int, voiced bool) {
+		for range frames {
+			for i := range buf {
+				v := 0.001 * rng.NormFloat64() // Quiet background noise.
+				if voiced {
+					// Vowel-like signal: harmonics of 150 Hz with a falling envelope.
+					for k := 1; k <= 20; k++ {
+						v += 0.2 / float64(k) * math.Sin(2*math.Pi*150*float64(k)*float64(pos)/sampleRate)
+					}
+				}
+				buf[i] = int32(v * math.MaxInt32)
+				pos++
+			}
+			fft.Process(buf)
+			vad.Process(buf)
+		}
+	}
+
+	run(30, false)
+	if vad.IsSpeaking() {
+		t.Fatalf("speaking during background noise (p=%.2f)", vad.GetSpeechProbability())
+	}
+
+	run(20, true)
+	if !vad.IsSpeaking() {
+		t.Fatalf("not speaking during voiced signal (p=%.2f)", vad.GetSpeechProbability())
+	}
+	select {
+	case ev := <-vad.Events():
+		if ev.Name != EventSpeechStart {
+			t.Errorf("first event = %q, want %q", ev.Name, EventSpeechStart)
+		}
+	default:
+		t.Fatal("expected speech_start event")
+	}
+
+	// A pause shorter than the hangover (~3 frames of 32ms) must not end the utterance.
+	run(2, false)
+	if !vad.IsSpeaking() {
+		t.Error("utterance ended during a pause shorter than the hangover")
+	}
+
+	run(30, false)
+	if vad.IsSpeaking() {
+		t.Errorf("still speaking after hangover (p=%.2f)", vad.GetSpeechProbability())
+	}
+	select {
+	case ev := <-vad.Events():
+		if ev.Name != EventSpeechEnd {
+			t.Errorf("second event = %q, want %q", ev.Name, EventSpeechEnd)
+		}
+	default:
+		t.Fatal("expected speech_end event")
+	}
+
+	vad.Close()
+	vad.Close()
+	if _, ok := <-vad.Events(); ok {
+		t.Error("expected closed event channel")
+	}
+}
+
+func TestVADProcessor_Stereo(t *testing.T) {
+	const (
+		sampleRate = 16000.0
+		frameSize  = 512
+	)
+	fft, err := NewFFTProcessor(frameSize, sampleRate, Hann)
+	if err != nil {
+		t.Fatalf("NewFFTProcessor error: %v", err)
+	}
+	mono, err := NewVADProcessor(fft, 1, 0.5, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewVADProcessor error: %v", err)
+	}
+	stereo, err := NewVADProcessor(fft, 2, 0.5, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewVADProcessor error: %v", err)
+	}
+	if _, err := NewVADProcessor(fft, 0, 0.5, 0); err == nil {
+		t.Error("expected error for zero channels")
+	}
+
+	// The same signal on both channels must be judged like the mono signal: same
+	// probability, and the hangover must run at the same speed.
+	rng := rand.New(rand.NewSource(1))
+	buf := make([]int32, frameSize)
+	interleaved := make([]int32, 2*frameSize)
+	pos := 0
+	for frame := range 80 {
+		voiced := frame >= 20 && frame < 40
+		for i := range buf {
+			v := 0.001 * rng.NormFloat64()
+			if voiced {
+				for k := 1; k <= 20; k++ {
+					v += 0.2 / float64(k) * math.Sin(2*math.Pi*150*float64(k)*float64(pos)/sampleRate)
+				}
+			}
+			buf[i] = int32(v * math.MaxInt32)
+			interleaved[2*i], interleaved[2*i+1] = buf[i], buf[i]
+			pos++
+		}
+		fft.Process(buf)
+		mono.Process(buf)
+		stereo.Process(interleaved)
+		if m, s := mono.GetSpeechProbability(), stereo.GetSpeechProbability(); math.Abs(m-s) > 1e-9 {
+			t.Fatalf("frame %d: stereo probability %.4f, mono %.4f", frame, s, m)
+		}
+		if mono.IsSpeaking() != stereo.IsSpeaking() {
+			t.Fatalf("frame %d: stereo speaking %v, mono %v", frame, stereo.IsSpeaking(), mono.IsSpeaking())
+		}
+	}
+	if len(stereo.Events()) != 2 {
+		t.Errorf("stereo events = %d, want start and end", len(stereo.Events()))
+	}
+}
@@ -4,9 +4,21 @@ package audio
 import (
 	"audio/internal/analysis"
 	"audio/internal/config"
+	"audio/internal/metrics"
+	dmxTransport "audio/internal/transport/dmx"
+	localTransport "audio/internal/transport/local"
+	mqttTransport "audio/internal/transport/mqtt"
+	oscTransport "audio/internal/transport/osc"
+	rpcTransport "audio/internal/transport/rpc"
+	tcpTransport "audio/internal/transport/tcp"
 	udpTransport "audio/internal/transport/udp"
+	webTransport "audio/internal/transport/web"
+	"audio/pkg/discovery"
+	"audio/pkg/protocol"
 	"fmt"
+	"net"
 	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
@@ -22,13 +34,29 @@ type Engine struct {
 	inputDevice  *portaudio.DeviceInfo        // Information about the selected input device.
 	inputLatency time.Duration                // Configured input latency for the stream.
 	processors   []analysis.AudioProcessor    // Slice of processors to apply to the audio data.
+	events       *analysis.EventBus           // Events from all processors, for logging and transports.
+	frames       *analysis.FrameBus           // Completed analysis frames, for frame-driven publishers.
+	frameIndex   uint64                       // Number of buffers processed (audio callback only).
 	closables    []interface{ Close() error } // Components needing graceful shutdown (processors, transports).
 	streamActive bool                         // Flag indicating if the audio stream is currently running.
 	streamMu     sync.Mutex                   // Mutex protecting stream and streamActive state.
+	health       streamHealth                 // Callback and processor counters for the metrics endpoint.
+	levels       *analysis.LevelProcessor     // Level processor (if enabled), for the metrics endpoint.
+	tempo        *analysis.TempoProcessor     // Tempo processor (if enabled), for the metrics endpoint.
 
 	// Transport components (optional, based on config)
-	udpSender    *udpTransport.UDPSender    // UDP sender instance (if enabled).
-	udpPublisher *udpTransport.UDPPublisher // UDP publisher instance (if enabled).
+	udpSenders       []*udpTransport.UDPSender             // UDP sender per target (if enabled).
+	udpPublishers    []*udpTransport.UDPPublisher          // UDP publisher per target (if enabled).
+	udpSubscriptions *udpTransport.SubscriptionServer      // UDP subscription control port (if configured).
+	tcpServer        *tcpTransport.Server                  // TCP stream server (if enabled).
+	tcpPublisher     *udpTransport.UDPPublisher            // Publisher feeding the TCP stream server (if enabled).
+	oscPublisher     *oscTransport.Publisher               // OSC publisher instance (if enabled).
+	webServer        *webTransport.Server                  // Embedded HTTP server (if enabled).
+	rpcServer        *rpcTransport.Server                  // gRPC server (if enabled).
+	unixPublisher    *localTransport.SocketPublisher       // Unix socket publisher (if enabled).
+	shmPublisher     *localTransport.SharedMemoryPublisher // Shared-memory publisher (if enabled).
+	mqttPublisher    *mqttTransport.Publisher              // MQTT publisher (if enabled).
+	dmxPublisher     *dmxTransport.Publisher               // Art-Net / sACN publisher (if enabled).
 }
 
 // NewEngine creates and initializes a new audio Engine based on the provided configuration.
@@ -60,9 +88,16 @@ func NewEngine(config *config.Config) (*Engine, error) {
 		inputLatency: latency,
 		processors:   make([]analysis.AudioProcessor, 0),
 		closables:    make([]interface{ Close() error }, 0),
-		// stream, streamActive, streamMu, udpSender, udpPublisher initialized later or zero-value ready.
+		events:       analysis.NewEventBus(),
+		frames:       analysis.NewFrameBus(),
+		// stream, streamActive, streamMu, udpSenders, udpPublishers initialized later or zero-value ready.
 	}
 
+	engine.health.callbackTime = metrics.NewHistogram(nil)
+
+	// The event and frame buses are closed last, after every processor feeding them.
+	engine.closables = append(engine.closables, engine.events, engine.frames)
+
 	// --- 4. Setup Processors ---
 
 	fftWindowFunc, err := analysis.ParseWindowFunc(engine.config.Audio.FFTWindow)
@@ -81,37 +116,590 @@ func NewEngine(config *config.Config) (*Engine, error) {
 	}
 	engine.RegisterProcessor(fftProcessor)
 
-	// --- 5. Setup Transport ---
+	// Processors whose results are published by feature-rich transports such as OSC.
+	features := analysis.Features{Events: engine.events}
 
-	if config.Transport.UDPEnabled {
-		// Create the UDP sender.
-		sender, err := udpTransport.NewUDPSender(config.Transport.UDPTargetAddress, config.Debug)
+	// Optional processors reading from the FFT spectrum must be registered after it.
+	if config.Analysis.Levels.Enabled {
+		levelProcessor, err := analysis.NewLevelProcessor(fftProcessor, config.Analysis.Levels.BandEdges)
 		if err != nil {
-			engine.Close() // Attempt to clean up already registered processors.
-			return nil, fmt.Errorf("engine: failed to create UDP sender: %w", err)
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create level processor: %w", err)
 		}
-		engine.udpSender = sender
-		engine.closables = append(engine.closables, sender)
+		engine.RegisterProcessor(levelProcessor)
+		features.Levels = levelProcessor
+		engine.levels = levelProcessor
+	}
 
-		// Create the UDP Publisher, linking it to the sender and FFT processor.
-		publisher, err := udpTransport.NewUDPPublisher(
-			config.Transport.UDPSendInterval,
-			sender,
+	var hpssProcessor *analysis.HPSSProcessor
+	if config.Analysis.HPSS.Enabled {
+		hpssProcessor, err = analysis.NewHPSSProcessor(
 			fftProcessor,
+			config.Analysis.HPSS.HarmonicKernel,
+			config.Analysis.HPSS.PercussiveKernel,
+			config.Analysis.HPSS.MaskPower,
 		)
 		if err != nil {
-			engine.Close() // Attempt to clean up sender and processors
-			return nil, fmt.Errorf("engine: failed to create UDP publisher: %w", err)
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create HPSS processor: %w", err)
 		}
-		engine.udpPublisher = publisher
-		engine.closables = append(engine.closables, publisher)
+		engine.RegisterProcessor(hpssProcessor)
+		features.HPSS = hpssProcessor
+	}
+
+	if config.Analysis.VAD.Enabled {
+		vadProcessor, err := analysis.NewVADProcessor(
+			fftProcessor,
+			config.Audio.InputChannels,
+			config.Analysis.VAD.Threshold,
+			config.Analysis.VAD.Hangover,
+		)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create VAD processor: %w", err)
+		}
+		engine.RegisterProcessor(vadProcessor)
+		features.VAD = vadProcessor
+	}
+
+	if config.Analysis.Peaks.Enabled {
+		interpolation, err := analysis.ParsePeakInterpolation(config.Analysis.Peaks.Interpolation)
+		if err != nil {
+			fmt.Printf("engine: %v. Using default peak interpolation (parabolic).\n", err)
+		}
+		peakProcessor, err := analysis.NewPeakProcessor(
+			fftProcessor,
+			config.Analysis.Peaks.Count,
+			interpolation,
+			config.Analysis.Peaks.ThresholdDB,
+		)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create peak processor: %w", err)
+		}
+		engine.RegisterProcessor(peakProcessor)
+		features.Peaks = peakProcessor
+	}
+
+	if config.Analysis.Chroma.Enabled || config.Analysis.Chord.Enabled {
+		chromaProcessor, err := analysis.NewChromaProcessor(
+			fftProcessor,
+			config.Analysis.Chroma.MinFrequency,
+			config.Analysis.Chroma.MaxFrequency,
+		)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create chroma processor: %w", err)
+		}
+		engine.RegisterProcessor(chromaProcessor)
+		features.Chroma = chromaProcessor
+
+		if config.Analysis.Chord.Enabled {
+			chordProcessor, err := analysis.NewChordProcessor(
+				chromaProcessor,
+				config.Analysis.Chord.Window,
+				config.Analysis.Chord.SelfTransition,
+			)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: failed to create chord processor: %w", err)
+			}
+			engine.RegisterProcessor(chordProcessor)
+			features.Chord = chordProcessor
+		}
+	}
+
+	if config.Analysis.Onset.Enabled || config.Analysis.Tempo.Enabled {
+		// Every callback analyses one buffer, so frames arrive at this rate.
+		frameRate := config.Audio.SampleRate / float64(config.Audio.FramesPerBuffer)
+		onsetProcessor, err := analysis.NewOnsetProcessor(
+			fftProcessor,
+			frameRate,
+			config.Analysis.Onset.Threshold,
+			config.Analysis.Onset.MinInterval,
+		)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create onset processor: %w", err)
+		}
+		engine.RegisterProcessor(onsetProcessor)
+		features.Onset = onsetProcessor
+
+		if config.Analysis.Tempo.Enabled {
+			tempoProcessor, err := analysis.NewTempoProcessor(
+				onsetProcessor,
+				config.Analysis.Tempo.MinBPM,
+				config.Analysis.Tempo.MaxBPM,
+				config.Analysis.Tempo.Window,
+			)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: failed to create tempo processor: %w", err)
+			}
+			engine.RegisterProcessor(tempoProcessor)
+			features.Tempo = tempoProcessor
+			engine.tempo = tempoProcessor
+		}
+	}
+
+	// --- 5. Setup Transport ---
+
+	if config.Transport.UDPEnabled {
+		// With a pre-shared key, all UDP publishers share one Sealer (one nonce sequence).
+		var sealer *protocol.Sealer
+		key, err := config.Transport.UDPSecurityKey()
+		if err == nil && key != nil {
+			sealer, err = protocol.NewSealer(key, config.Transport.UDPEncrypt)
+		}
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: UDP security: %w", err)
+		}
+		if sealer != nil {
+			fmt.Printf("engine: UDP packets are signed (Encrypted: %v)\n", config.Transport.UDPEncrypt)
+		}
+
+		for _, target := range config.Transport.ResolvedUDPTargets() {
+			// Select the spectrum and packet layout for this target.
+			spectrum, err := selectSpectrum(target.Spectrum, fftProcessor, hpssProcessor)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: UDP target %s: %w", target.Address, err)
+			}
+
+			var legacy bool
+			switch target.Protocol {
+			case "", "v1":
+			case "legacy":
+				legacy = true
+			default:
+				engine.Close()
+				return nil, fmt.Errorf("engine: UDP target %s: unknown protocol %q", target.Address, target.Protocol)
+			}
+
+			var frames *analysis.FrameBus
+			switch target.PublishMode {
+			case "", "interval":
+			case "frames":
+				frames = engine.frames
+			default:
+				engine.Close()
+				return nil, fmt.Errorf("engine: UDP target %s: unknown publish mode %q", target.Address, target.PublishMode)
+			}
+
+			encoding, err := spectrumEncoding(target.Encoding, target.Compression,
+				target.KeyframeInterval, target.MinDB, target.MaxDB)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: UDP target %s: %w", target.Address, err)
+			}
+
+			// Create the UDP sender.
+			sender, err := udpTransport.NewUDPSender(target.Address, config.Debug, udpTransport.UDPSenderOptions{
+				MulticastTTL:       target.MulticastTTL,
+				MulticastInterface: target.MulticastInterface,
+				MulticastLoopback:  target.MulticastLoopback,
+			})
+			if err != nil {
+				engine.Close() // Attempt to clean up already registered processors.
+				return nil, fmt.Errorf("engine: failed to create UDP sender: %w", err)
+			}
+			engine.udpSenders = append(engine.udpSenders, sender)
+			engine.closables = append(engine.closables, sender)
+
+			// Create the UDP Publisher, linking it to the sender and selected spectrum.
+			publisher, err := udpTransport.NewUDPPublisher(
+				target.Interval,
+				sender,
+				spectrum,
+				udpTransport.UDPPublisherOptions{
+					Legacy:        legacy,
+					Channel:       config.Transport.UDPChannelID,
+					MaxPacketSize: target.MaxPacketSize,
+					Frames:        frames,
+					Decimation:    target.Decimation,
+					Encoding:      encoding,
+					Sealer:        sealer,
+				},
+			)
+			if err != nil {
+				engine.Close() // Attempt to clean up sender and processors
+				return nil, fmt.Errorf("engine: failed to create UDP publisher: %w", err)
+			}
+			engine.udpPublishers = append(engine.udpPublishers, publisher)
+			engine.closables = append(engine.closables, publisher)
 
-		fmt.Printf("engine: UDP transport initialized (Target: %s, Interval: %s)\n",
-			config.Transport.UDPTargetAddress, config.Transport.UDPSendInterval)
+			fmt.Printf("engine: UDP transport initialized (Target: %s, Interval: %s, Spectrum: %s)\n",
+				target.Address, target.Interval, target.Spectrum)
+		}
+
+		if transport := config.Transport; transport.UDPControlAddress != "" {
+			// Subscribers get the transport-wide spectrum and encoding at their own rate.
+			spectrum, err := selectSpectrum(transport.UDPSpectrum, fftProcessor, hpssProcessor)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: UDP subscriptions: %w", err)
+			}
+			encoding, err := spectrumEncoding(transport.UDPEncoding, transport.UDPCompression,
+				transport.UDPKeyframeInterval, transport.UDPMinDB, transport.UDPMaxDB)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: UDP subscriptions: %w", err)
+			}
+			var opener *protocol.Opener
+			if key != nil {
+				if opener, err = protocol.NewOpener(key, transport.UDPMaxAge); err != nil {
+					engine.Close()
+					return nil, fmt.Errorf("engine: UDP subscriptions: %w", err)
+				}
+			}
+			server, err := udpTransport.NewSubscriptionServer(transport.UDPControlAddress, spectrum, udpTransport.SubscriptionOptions{
+				Lease:          transport.UDPSubscriptionLease,
+				MaxSubscribers: transport.UDPMaxSubscribers,
+				Interval:       transport.UDPSendInterval,
+				Publisher: udpTransport.UDPPublisherOptions{
+					Channel:       transport.UDPChannelID,
+					MaxPacketSize: transport.UDPMaxPacketSize,
+					Encoding:      encoding,
+					Sealer:        sealer,
+				},
+				Debug:  config.Debug,
+				Opener: opener,
+			})
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: failed to create UDP subscription server: %w", err)
+			}
+			engine.udpSubscriptions = server
+			engine.closables = append(engine.closables, server)
+		}
 	} else {
 		fmt.Printf("engine: UDP transport is disabled.\n")
 	}
 
+	var tcpAddr net.Addr // Address of the TCP stream (if enabled), for discovery.
+	if tcpConfig := config.Transport.TCP; tcpConfig.Enabled {
+		spectrum, err := selectSpectrum(tcpConfig.Spectrum, fftProcessor, hpssProcessor)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: TCP: %w", err)
+		}
+		var frames *analysis.FrameBus
+		switch tcpConfig.PublishMode {
+		case "", "interval":
+		case "frames":
+			frames = engine.frames
+		default:
+			engine.Close()
+			return nil, fmt.Errorf("engine: TCP: unknown publish mode %q", tcpConfig.PublishMode)
+		}
+
+		server, err := tcpTransport.NewServer(tcpConfig.ListenAddress, tcpTransport.ServerOptions{
+			MaxClients: tcpConfig.MaxClients,
+			QueueSize:  tcpConfig.QueueSize,
+			Debug:      config.Debug,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create TCP server: %w", err)
+		}
+		engine.tcpServer = server
+		engine.closables = append(engine.closables, server)
+		tcpAddr = server.Addr()
+
+		// The stream needs no fragmenting below the header's 64 KiB payload limit.
+		publisher, err := udpTransport.NewUDPPublisher(tcpConfig.SendInterval, server, spectrum, udpTransport.UDPPublisherOptions{
+			Channel:       config.Transport.UDPChannelID,
+			MaxPacketSize: udpTransport.MaxPacketSize,
+			Frames:        frames,
+			Decimation:    tcpConfig.Decimation,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create TCP publisher: %w", err)
+		}
+		engine.tcpPublisher = publisher
+		engine.closables = append(engine.closables, publisher)
+
+		fmt.Printf("engine: TCP transport initialized (Listen: %s, Interval: %s, Spectrum: %s)\n",
+			tcpConfig.ListenAddress, tcpConfig.SendInterval, tcpConfig.Spectrum)
+	}
+
+	if oscConfig := config.Transport.OSC; oscConfig.Enabled {
+		features.Spectrum, err = selectSpectrum(oscConfig.Spectrum, fftProcessor, hpssProcessor)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: OSC: %w", err)
+		}
+
+		var blob bool
+		switch oscConfig.SpectrumFormat {
+		case "", "floats":
+		case "blob":
+			blob = true
+		default:
+			engine.Close()
+			return nil, fmt.Errorf("engine: OSC: unknown spectrum format %q", oscConfig.SpectrumFormat)
+		}
+
+		// Create the sender for the selected network.
+		var sender interface {
+			Send(packet []byte) error
+			Close() error
+		}
+		switch oscConfig.Network {
+		case "", "udp":
+			sender, err = udpTransport.NewUDPSender(oscConfig.TargetAddress, config.Debug, udpTransport.UDPSenderOptions{})
+		case "tcp":
+			sender, err = oscTransport.NewTCPSender(oscConfig.TargetAddress, config.Debug)
+		default:
+			err = fmt.Errorf("unknown network %q", oscConfig.Network)
+		}
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create OSC sender: %w", err)
+		}
+		engine.closables = append(engine.closables, sender)
+
+		publisher, err := oscTransport.NewPublisher(oscConfig.SendInterval, sender, features, oscTransport.Options{
+			Prefix:    oscConfig.AddressPrefix,
+			Addresses: oscConfig.Addresses,
+			Blob:      blob,
+			Bundle:    oscConfig.Bundle,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create OSC publisher: %w", err)
+		}
+		engine.oscPublisher = publisher
+		engine.closables = append(engine.closables, publisher)
+
+		fmt.Printf("engine: OSC transport initialized (Target: %s/%s, Interval: %s)\n",
+			oscConfig.Network, oscConfig.TargetAddress, oscConfig.SendInterval)
+	}
+
+	if httpConfig := config.Transport.HTTP; httpConfig.Enabled {
+		webFeatures := features
+		webFeatures.Spectrum, err = selectSpectrum(httpConfig.Spectrum, fftProcessor, hpssProcessor)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: HTTP: %w", err)
+		}
+
+		server, err := webTransport.NewServer(httpConfig.ListenAddress, httpConfig.SendInterval, webFeatures, webTransport.Options{
+			Channel:        config.Transport.UDPChannelID,
+			MaxClients:     httpConfig.MaxClients,
+			AllowedOrigins: httpConfig.AllowedOrigins,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create HTTP server: %w", err)
+		}
+		engine.webServer = server
+		engine.closables = append(engine.closables, server)
+	}
+
+	if grpcConfig := config.Transport.GRPC; grpcConfig.Enabled {
+		rpcFeatures := features
+		rpcFeatures.Spectrum, err = selectSpectrum(grpcConfig.Spectrum, fftProcessor, hpssProcessor)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: gRPC: %w", err)
+		}
+
+		server, err := rpcTransport.NewServer(grpcConfig.ListenAddress, grpcConfig.SendInterval, rpcFeatures, rpcTransport.Options{
+			Channel:        config.Transport.UDPChannelID,
+			MaxSubscribers: grpcConfig.MaxSubscribers,
+			Config:         config,
+			TransportStats: engine.TransportStats,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create gRPC server: %w", err)
+		}
+		engine.rpcServer = server
+		engine.closables = append(engine.closables, server)
+	}
+
+	if metricsConfig := config.Transport.Metrics; metricsConfig.Enabled {
+		server, err := metrics.NewServer(metricsConfig.ListenAddress, engine.writeMetrics)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create metrics server: %w", err)
+		}
+		engine.closables = append(engine.closables, server)
+	}
+
+	if unixConfig := config.Transport.Unix; unixConfig.Enabled {
+		spectrum, err := selectSpectrum(unixConfig.Spectrum, fftProcessor, hpssProcessor)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: Unix socket: %w", err)
+		}
+
+		var sender localTransport.Sender
+		switch unixConfig.Network {
+		case "", "unixgram":
+			sender, err = localTransport.NewDatagramSender(unixConfig.Path, config.Debug)
+		case "unix":
+			sender, err = localTransport.NewStreamServer(unixConfig.Path, unixConfig.MaxClients)
+		default:
+			err = fmt.Errorf("unknown network %q", unixConfig.Network)
+		}
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create Unix socket sender: %w", err)
+		}
+		engine.closables = append(engine.closables, sender)
+
+		publisher, err := localTransport.NewSocketPublisher(unixConfig.SendInterval, sender, spectrum, config.Transport.UDPChannelID)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create Unix socket publisher: %w", err)
+		}
+		engine.unixPublisher = publisher
+		engine.closables = append(engine.closables, publisher)
+
+		fmt.Printf("engine: Unix socket transport initialized (%s: %s, Interval: %s)\n",
+			unixConfig.Network, unixConfig.Path, unixConfig.SendInterval)
+	}
+
+	if shmConfig := config.Transport.SharedMemory; shmConfig.Enabled {
+		spectrum, err := selectSpectrum(shmConfig.Spectrum, fftProcessor, hpssProcessor)
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: shared memory: %w", err)
+		}
+
+		publisher, err := localTransport.NewSharedMemoryPublisher(shmConfig.Path, shmConfig.SendInterval, spectrum, localTransport.SharedMemoryOptions{
+			Slots:   shmConfig.Slots,
+			Channel: config.Transport.UDPChannelID,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create shared-memory publisher: %w", err)
+		}
+		engine.shmPublisher = publisher
+		engine.closables = append(engine.closables, publisher)
+	}
+
+	if mqttConfig := config.Transport.MQTT; mqttConfig.Enabled {
+		qos := byte(mqttConfig.QoS)
+		client, err := mqttTransport.NewClient(mqttConfig.Broker, mqttTransport.ClientOptions{
+			ProtocolVersion: byte(mqttConfig.ProtocolVersion),
+			ClientID:        mqttConfig.ClientID,
+			Username:        mqttConfig.Username,
+			Password:        mqttConfig.Password,
+			KeepAlive:       mqttConfig.KeepAlive,
+			Will: &mqttTransport.Message{
+				Topic:   mqttTransport.StatusTopic(mqttConfig.TopicPrefix),
+				Payload: []byte(mqttTransport.StatusOffline),
+				QoS:     qos,
+				Retain:  true,
+			},
+			Debug: config.Debug,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create MQTT client: %w", err)
+		}
+		engine.closables = append(engine.closables, client) // Closed after the publisher marks the engine offline.
+
+		publisher, err := mqttTransport.NewPublisher(mqttConfig.PublishInterval, client, features, mqttTransport.Options{
+			Prefix:          mqttConfig.TopicPrefix,
+			Topics:          mqttConfig.Topics,
+			QoS:             qos,
+			Retain:          mqttConfig.Retain,
+			Discovery:       mqttConfig.Discovery,
+			DiscoveryPrefix: mqttConfig.DiscoveryPrefix,
+			NodeID:          mqttConfig.NodeID,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create MQTT publisher: %w", err)
+		}
+		engine.mqttPublisher = publisher
+		engine.closables = append(engine.closables, publisher)
+
+		fmt.Printf("engine: MQTT transport initialized (Broker: %s, Prefix: %s, Interval: %s)\n",
+			mqttConfig.Broker, mqttConfig.TopicPrefix, mqttConfig.PublishInterval)
+	}
+
+	if dmxConfig := config.Transport.DMX; dmxConfig.Enabled {
+		protocol := dmxTransport.Protocol(dmxConfig.Protocol)
+		target := dmxConfig.TargetAddress
+		if target == "" {
+			target, err = dmxTransport.DefaultTarget(protocol, dmxConfig.Universe)
+			if err != nil {
+				engine.Close()
+				return nil, fmt.Errorf("engine: DMX: %w", err)
+			}
+		}
+
+		sender, err := udpTransport.NewUDPSender(target, config.Debug, udpTransport.UDPSenderOptions{
+			MulticastTTL:       dmxConfig.MulticastTTL,
+			MulticastInterface: dmxConfig.MulticastInterface,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create DMX sender: %w", err)
+		}
+		engine.closables = append(engine.closables, sender)
+
+		mappings := make([]dmxTransport.Mapping, len(dmxConfig.Mappings))
+		for i, m := range dmxConfig.Mappings {
+			mappings[i] = dmxTransport.Mapping(m)
+		}
+		publisher, err := dmxTransport.NewPublisher(dmxConfig.SendInterval, sender, features, dmxTransport.Options{
+			Protocol:   protocol,
+			Universe:   dmxConfig.Universe,
+			SourceName: dmxConfig.SourceName,
+			Priority:   dmxConfig.Priority,
+			Mappings:   mappings,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to create DMX publisher: %w", err)
+		}
+		engine.dmxPublisher = publisher
+		engine.closables = append(engine.closables, publisher)
+
+		fmt.Printf("engine: DMX transport initialized (%s universe %d to %s, Interval: %s)\n",
+			dmxConfig.Protocol, dmxConfig.Universe, target, dmxConfig.SendInterval)
+	}
+
+	if mdnsConfig := config.Transport.MDNS; mdnsConfig.Enabled {
+		info := discovery.Info{
+			ProtocolVersion: int(protocol.Version),
+			SampleRate:      int(config.Audio.SampleRate),
+			FFTSize:         fftProcessor.GetFFTSize(),
+			Channel:         config.Transport.UDPChannelID,
+			Security:        "none",
+		}
+		if engine.udpSubscriptions != nil {
+			info.ControlPort = listenPort(engine.udpSubscriptions.Addr())
+			info.Encoding = config.Transport.UDPEncoding
+		}
+		if tcpAddr != nil {
+			info.TCPPort = listenPort(tcpAddr)
+		}
+		if key, err := config.Transport.UDPSecurityKey(); err == nil && key != nil && config.Transport.UDPEnabled {
+			info.Security = "signed"
+			if config.Transport.UDPEncrypt {
+				info.Security = "encrypted"
+			}
+		}
+		advertiser, err := discovery.NewAdvertiser(mdnsConfig.Instance, info, discovery.AdvertiserOptions{
+			Interface: mdnsConfig.Interface,
+			Debug:     config.Debug,
+		})
+		if err != nil {
+			engine.Close()
+			return nil, fmt.Errorf("engine: failed to start mDNS advertisement: %w", err)
+		}
+		engine.closables = append(engine.closables, advertiser) // Closed first, so the goodbye goes out before the transports stop.
+	}
+
 	// --- 6. Log Final Configuration ---
 
 	fmt.Printf("engine: Initialized successfully.\n")
@@ -123,9 +711,17 @@ func NewEngine(config *config.Config) (*Engine, error) {
 
 // RegisterProcessor adds an AudioProcessor to the engine's processing chain.
 // If the processor implements the io.Closer interface, it's also added to the
-// list of closables for graceful shutdown during Engine.Close().
+// list of closables for graceful shutdown during Engine.Close(). If it implements
+// analysis.EventProvider, its events are published on the engine's event bus, and
+// logged in debug mode, until the processor is closed.
 func (e *Engine) RegisterProcessor(processor analysis.AudioProcessor) {
 	e.processors = append(e.processors, processor)
+	e.health.processorName = append(e.health.processorName, processorName(processor))
+	e.health.processorTime = append(e.health.processorTime, metrics.NewHistogram(nil))
+
+	if provider, ok := processor.(analysis.EventProvider); ok {
+		go e.forwardEvents(provider.Events())
+	}
 
 	if closable, ok := processor.(interface{ Close() error }); ok {
 		e.closables = append(e.closables, closable)
@@ -135,12 +731,64 @@ func (e *Engine) RegisterProcessor(processor analysis.AudioProcessor) {
 	}
 }
 
+// selectSpectrum returns the spectrum provider a transport should publish, by name:
+// "fft" (or empty) for the raw FFT, "harmonic" or "percussive" for the HPSS outputs.
+func selectSpectrum(name string, fft *analysis.FFTProcessor, hpss *analysis.HPSSProcessor) (analysis.FFTResultProvider, error) {
+	switch name {
+	case "", "fft":
+		return fft, nil
+	case "harmonic", "percussive":
+		if hpss == nil {
+			return nil, fmt.Errorf("spectrum %q requires analysis.hpss to be enabled", name)
+		}
+		if name == "harmonic" {
+			return hpss.Harmonic(), nil
+		}
+		return hpss.Percussive(), nil
+	default:
+		return nil, fmt.Errorf("unknown spectrum %q", name)
+	}
+}
+
+// spectrumEncoding converts the encoding settings of a UDP target.
+func spectrumEncoding(format, compression string, keyframeInterval int, minDB, maxDB float64) (protocol.SpectrumEncoding, error) {
+	sampleFormat, err := protocol.ParseSampleFormat(format)
+	if err != nil {
+		return protocol.SpectrumEncoding{}, err
+	}
+	codec, err := protocol.ParseCompression(compression)
+	if err != nil {
+		return protocol.SpectrumEncoding{}, err
+	}
+	return protocol.SpectrumEncoding{
+		Format:           sampleFormat,
+		Compression:      codec,
+		MinDB:            minDB,
+		MaxDB:            maxDB,
+		KeyframeInterval: keyframeInterval,
+	}, nil
+}
+
+// forwardEvents publishes events from a processor on the event bus until the processor's
+// event channel is closed, printing each one in debug mode.
+func (e *Engine) forwardEvents(events <-chan analysis.Event) {
+	for event := range events {
+		if e.config.Debug {
+			fmt.Printf("engine: Event %s/%s %s (%.2f)\n", event.Source, event.Name, event.Label, event.Value)
+		}
+		e.events.Publish(event)
+	}
+}
+
 // processInputStream is the callback function passed to PortAudio.
 // It's executed by PortAudio's audio thread whenever a new buffer of input audio data is available.
 // IMPORTANT: This is a real-time audio callback (HOT PATH).
 // Avoid allocations, blocking operations, and excessive logging within this function.
 // It locks the OS thread to improve real-time performance guarantees.
-func (e *Engine) processInputStream(in []int32) {
+// Once every processor has run, the completed frame is published on the frame bus with
+// the capture time of the buffer's first sample. The time spent in every processor and
+// in the whole callback, and the overflows PortAudio reports, are counted for /metrics.
+func (e *Engine) processInputStream(in []int32, timeInfo portaudio.StreamCallbackTimeInfo, flags portaudio.StreamCallbackFlags) {
 	// Lock the OS thread. This is crucial for real-time audio callbacks
 	// to prevent the Go runtime scheduler from preempting the audio processing.
 	runtime.LockOSThread()
@@ -151,9 +799,47 @@ func (e *Engine) processInputStream(in []int32) {
 	// added via RegisterProcessor *before* the stream starts. If processors could
 	// be added/removed concurrently while the stream is active, a read lock
 	// (e.streamMu.RLock/RUnlock) around this loop would be necessary.
-	for _, processor := range e.processors {
+	now := time.Now()
+	h := &e.health
+	h.lastCallback.Store(now.UnixNano())
+	if flags&portaudio.InputOverflow != 0 {
+		h.overflows.Add(1)
+	}
+	if flags&portaudio.InputUnderflow != 0 {
+		h.underflows.Add(1)
+	}
+
+	start := now
+	for i, processor := range e.processors {
 		processor.Process(in)
+		end := time.Now()
+		h.processorTime[i].Observe(end.Sub(start))
+		start = end
+	}
+
+	e.frameIndex++
+	e.frames.Publish(analysis.Frame{
+		Index:      e.frameIndex,
+		Time:       captureTime(now, timeInfo),
+		StreamTime: timeInfo.InputBufferAdcTime,
+	})
+
+	elapsed := time.Since(now)
+	h.callbackTime.Observe(elapsed)
+	if elapsed > e.bufferDuration() {
+		h.overruns.Add(1)
+	}
+	h.callbacks.Add(1)
+}
+
+// captureTime converts the ADC time of an input buffer from the stream clock to wall
+// clock time, given the wall clock time the callback started. Host APIs that don't
+// report stream times (all zero) get the callback time.
+func captureTime(callback time.Time, timeInfo portaudio.StreamCallbackTimeInfo) time.Time {
+	if timeInfo.InputBufferAdcTime == 0 && timeInfo.CurrentTime == 0 {
+		return callback
 	}
+	return callback.Add(timeInfo.InputBufferAdcTime - timeInfo.CurrentTime)
 }
 
 // StartInputStream opens and starts the PortAudio input stream using the configured
@@ -203,8 +889,35 @@ func (e *Engine) StartInputStream() error {
 
 	// --- 4. Start Associated Components ---
 
-	if e.udpPublisher != nil {
-		e.udpPublisher.Start()
+	for _, publisher := range e.udpPublishers {
+		publisher.Start()
+	}
+	if e.udpSubscriptions != nil {
+		e.udpSubscriptions.Start()
+	}
+	if e.tcpPublisher != nil {
+		e.tcpPublisher.Start()
+	}
+	if e.oscPublisher != nil {
+		e.oscPublisher.Start()
+	}
+	if e.webServer != nil {
+		e.webServer.Start()
+	}
+	if e.rpcServer != nil {
+		e.rpcServer.Start()
+	}
+	if e.unixPublisher != nil {
+		e.unixPublisher.Start()
+	}
+	if e.shmPublisher != nil {
+		e.shmPublisher.Start()
+	}
+	if e.mqttPublisher != nil {
+		e.mqttPublisher.Start()
+	}
+	if e.dmxPublisher != nil {
+		e.dmxPublisher.Start()
 	}
 
 	return nil
@@ -231,11 +944,103 @@ func (e *Engine) StopInputStream() error {
 
 	// --- 1. Stop Associated Components First ---
 
-	if e.udpPublisher != nil {
-		fmt.Printf("engine: Stopping Stopping UDP publisher ...\n")
-		if err := e.udpPublisher.Stop(); err != nil {
+	for _, publisher := range e.udpPublishers {
+		fmt.Printf("engine: Stopping UDP publisher ...\n")
+		if err := publisher.Stop(); err != nil {
 			fmt.Printf("engine: Error stopping UDP publisher: %v\n", err)
-			firstErr = err
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.udpSubscriptions != nil {
+		fmt.Printf("engine: Stopping UDP subscriptions ...\n")
+		if err := e.udpSubscriptions.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping UDP subscriptions: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.tcpPublisher != nil {
+		fmt.Printf("engine: Stopping TCP publisher ...\n")
+		if err := e.tcpPublisher.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping TCP publisher: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.oscPublisher != nil {
+		fmt.Printf("engine: Stopping OSC publisher ...\n")
+		if err := e.oscPublisher.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping OSC publisher: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.webServer != nil {
+		fmt.Printf("engine: Stopping HTTP stream ...\n")
+		if err := e.webServer.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping HTTP stream: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.rpcServer != nil {
+		fmt.Printf("engine: Stopping gRPC stream ...\n")
+		if err := e.rpcServer.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping gRPC stream: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.unixPublisher != nil {
+		fmt.Printf("engine: Stopping Unix socket publisher ...\n")
+		if err := e.unixPublisher.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping Unix socket publisher: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.shmPublisher != nil {
+		fmt.Printf("engine: Stopping shared-memory publisher ...\n")
+		if err := e.shmPublisher.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping shared-memory publisher: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.mqttPublisher != nil {
+		fmt.Printf("engine: Stopping MQTT publisher ...\n")
+		if err := e.mqttPublisher.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping MQTT publisher: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if e.dmxPublisher != nil {
+		fmt.Printf("engine: Stopping DMX publisher ...\n")
+		if err := e.dmxPublisher.Stop(); err != nil {
+			fmt.Printf("engine: Error stopping DMX publisher: %v\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 	}
 
@@ -274,6 +1079,29 @@ func (e *Engine) StopInputStream() error {
 	return firstErr
 }
 
+// listenPort returns the port of a listening socket's address, or 0 if it has none.
+func listenPort(addr net.Addr) int {
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// TransportStats returns the counters of every UDP stream: one per configured target,
+// followed by one per active subscription. It is safe to call while the engine runs.
+func (e *Engine) TransportStats() []udpTransport.PublisherStats {
+	stats := make([]udpTransport.PublisherStats, 0, len(e.udpPublishers))
+	for _, publisher := range e.udpPublishers {
+		stats = append(stats, publisher.Stats())
+	}
+	if e.udpSubscriptions != nil {
+		stats = append(stats, e.udpSubscriptions.Stats()...)
+	}
+	return stats
+}
+
 // Close gracefully shuts down the audio engine. It stops the audio stream (if active) and then
 // closes all registered closable components (processors, transports) in reverse order of registration.
 // Note: It does *not* terminate the PortAudio library itself; that should be handled separately.
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: MIT
+package audio
+
+import (
+	"audio/internal/metrics"
+	"audio/internal/transport"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// streamHealth counts what happens in the audio callback, for the metrics endpoint. The
+// callback only uses atomics and lock-free histograms; the processor slices are filled
+// by RegisterProcessor before the stream starts.
+type streamHealth struct {
+	callbacks     atomic.Uint64      // Callbacks run.
+	overruns      atomic.Uint64      // Callbacks that took longer than the buffer lasts.
+	overflows     atomic.Uint64      // Callbacks reporting input overflow (samples lost before the callback).
+	underflows    atomic.Uint64      // Callbacks reporting input underflow (silence inserted).
+	lastCallback  atomic.Int64       // Wall clock time of the latest callback (Unix nanoseconds).
+	callbackTime  *metrics.Histogram // Duration of the whole callback.
+	processorName []string           // Name of each processor, in processing order.
+	processorTime []*metrics.Histogram
+}
+
+// processorName derives a metric label from a processor's type, e.g. "fft" for
+// *analysis.FFTProcessor.
+func processorName(processor any) string {
+	name := fmt.Sprintf("%T", processor)
+	name = name[strings.LastIndexByte(name, '.')+1:]
+	return strings.ToLower(strings.TrimSuffix(name, "Processor"))
+}
+
+// bufferDuration returns the time one buffer of audio lasts, the callback's budget.
+func (e *Engine) bufferDuration() time.Duration {
+	return time.Duration(float64(e.config.Audio.FramesPerBuffer) / e.config.Audio.SampleRate * float64(time.Second))
+}
+
+// outputStats is the counters of one enabled transport.
+type outputStats struct {
+	name    string          // Transport name, the metric label.
+	stats   transport.Stats // Counters.
+	clients bool            // Whether the transport serves clients, so Clients applies.
+}
+
+// outputStats returns the counters of every enabled transport. The UDP streams are
+// summed; their per-target breakdown is in TransportStats.
+func (e *Engine) outputStats() []outputStats {
+	var outputs []outputStats
+	if len(e.udpPublishers) > 0 || e.udpSubscriptions != nil {
+		var udp transport.Stats
+		for _, s := range e.TransportStats() {
+			udp.Messages += s.Packets
+			udp.Bytes += s.Bytes
+			udp.Dropped += s.Dropped
+			for _, n := range s.Errors {
+				udp.Errors += n
+			}
+			if s.Subscriber {
+				udp.Clients++
+			}
+		}
+		outputs = append(outputs, outputStats{"udp", udp, e.udpSubscriptions != nil})
+	}
+	if e.tcpServer != nil {
+		outputs = append(outputs, outputStats{"tcp", e.tcpServer.Totals(), true})
+	}
+	if e.oscPublisher != nil {
+		outputs = append(outputs, outputStats{"osc", e.oscPublisher.Stats(), false})
+	}
+	if e.webServer != nil {
+		outputs = append(outputs, outputStats{"web", e.webServer.Stats(), true})
+	}
+	if e.rpcServer != nil {
+		outputs = append(outputs, outputStats{"grpc", e.rpcServer.Stats(), true})
+	}
+	if e.unixPublisher != nil {
+		outputs = append(outputs, outputStats{"unix", e.unixPublisher.Stats(), false})
+	}
+	if e.shmPublisher != nil {
+		outputs = append(outputs, outputStats{"shm", e.shmPublisher.Stats(), false})
+	}
+	if e.mqttPublisher != nil {
+		outputs = append(outputs, outputStats{"mqtt", e.mqttPublisher.Stats(), false})
+	}
+	if e.dmxPublisher != nil {
+		outputs = append(outputs, outputStats{"dmx", e.dmxPublisher.Stats(), false})
+	}
+	return outputs
+}
+
+// writeMetrics writes the engine's health in the Prometheus text format: the audio
+// callback, each processor, every transport, the UDP streams and, if the level and tempo
+// processors run, the current levels and tempo. It is safe to call while the engine runs.
+func (e *Engine) writeMetrics(w *metrics.Writer) {
+	h := &e.health
+
+	e.streamMu.Lock()
+	active := e.streamActive
+	e.streamMu.Unlock()
+	w.Header("phase4_stream_active", "gauge", "Whether the audio input stream is running.")
+	w.Value("phase4_stream_active", boolValue(active))
+
+	w.Header("phase4_callbacks_total", "counter", "Audio callbacks run.")
+	w.Value("phase4_callbacks_total", float64(h.callbacks.Load()))
+	w.Header("phase4_last_callback_timestamp_seconds", "gauge", "Time of the latest audio callback (0 before the first).")
+	if last := h.lastCallback.Load(); last > 0 {
+		w.Value("phase4_last_callback_timestamp_seconds", float64(last)/1e9)
+	} else {
+		w.Value("phase4_last_callback_timestamp_seconds", 0)
+	}
+	w.Header("phase4_callback_duration_seconds", "histogram", "Time spent in the audio callback.")
+	w.Histogram("phase4_callback_duration_seconds", h.callbackTime)
+	w.Header("phase4_callback_budget_seconds", "gauge", "Duration of one audio buffer, the most a callback may take.")
+	w.Value("phase4_callback_budget_seconds", e.bufferDuration().Seconds())
+	w.Header("phase4_callback_overruns_total", "counter", "Audio callbacks that took longer than the buffer lasts.")
+	w.Value("phase4_callback_overruns_total", float64(h.overruns.Load()))
+	w.Header("phase4_input_overflows_total", "counter", "Audio callbacks reporting input overflow (samples lost).")
+	w.Value("phase4_input_overflows_total", float64(h.overflows.Load()))
+	w.Header("phase4_input_underflows_total", "counter", "Audio callbacks reporting input underflow (silence inserted).")
+	w.Value("phase4_input_underflows_total", float64(h.underflows.Load()))
+
+	w.Header("phase4_processor_duration_seconds", "histogram", "Time spent in each processor per audio buffer.")
+	for i, name := range h.processorName {
+		w.Histogram("phase4_processor_duration_seconds", h.processorTime[i], "processor", name)
+	}
+
+	outputs := e.outputStats()
+	type outputCounter struct {
+		name, help string
+		value      func(s transport.Stats) uint64
+	}
+	for _, c := range []outputCounter{
+		{"phase4_output_messages_total", "Messages sent per transport; servers count every copy sent to a client.", func(s transport.Stats) uint64 { return s.Messages }},
+		{"phase4_output_bytes_total", "Bytes sent per transport.", func(s transport.Stats) uint64 { return s.Bytes }},
+		{"phase4_output_dropped_total", "Messages discarded per transport because a client or target fell behind.", func(s transport.Stats) uint64 { return s.Dropped }},
+		{"phase4_output_errors_total", "Failed sends per transport.", func(s transport.Stats) uint64 { return s.Errors }},
+	} {
+		w.Header(c.name, "counter", c.help)
+		for _, o := range outputs {
+			w.Value(c.name, float64(c.value(o.stats)), "transport", o.name)
+		}
+	}
+	w.Header("phase4_output_clients", "gauge", "Connected clients per transport that serves them.")
+	for _, o := range outputs {
+		if o.clients {
+			w.Value("phase4_output_clients", float64(o.stats.Clients), "transport", o.name)
+		}
+	}
+
+	stats := e.TransportStats()
+	type counter struct {
+		name, help string
+		value      func(i int) float64
+	}
+	for _, c := range []counter{
+		{"phase4_transport_messages_total", "Messages built per UDP stream.", func(i int) float64 { return float64(stats[i].Messages) }},
+		{"phase4_transport_packets_total", "Datagrams sent per UDP stream.", func(i int) float64 { return float64(stats[i].Packets) }},
+		{"phase4_transport_bytes_total", "Bytes sent per UDP stream.", func(i int) float64 { return float64(stats[i].Bytes) }},
+		{"phase4_transport_dropped_total", "Datagrams not sent because the target refused them.", func(i int) float64 { return float64(stats[i].Dropped) }},
+	} {
+		w.Header(c.name, "counter", c.help)
+		for i, s := range stats {
+			w.Value(c.name, c.value(i), "target", s.Target, "subscriber", strconv.FormatBool(s.Subscriber))
+		}
+	}
+	w.Header("phase4_transport_errors_total", "counter", "Failed sends per UDP stream and error class.")
+	for _, s := range stats {
+		for _, class := range slices.Sorted(maps.Keys(s.Errors)) {
+			w.Value("phase4_transport_errors_total", float64(s.Errors[class]),
+				"target", s.Target, "subscriber", strconv.FormatBool(s.Subscriber), "class", string(class))
+		}
+	}
+	w.Header("phase4_transport_paused", "gauge", "Whether a UDP stream pauses because its target refuses packets.")
+	for _, s := range stats {
+		w.Value("phase4_transport_paused", boolValue(s.Paused), "target", s.Target, "subscriber", strconv.FormatBool(s.Subscriber))
+	}
+
+	if e.levels != nil {
+		rms, peak := e.levels.GetLevels()
+		w.Header("phase4_level_rms_dbfs", "gauge", "RMS level of the latest buffer in dBFS.")
+		w.Value("phase4_level_rms_dbfs", rms)
+		w.Header("phase4_level_peak_dbfs", "gauge", "Peak sample level of the latest buffer in dBFS.")
+		w.Value("phase4_level_peak_dbfs", peak)
+
+		edges := e.levels.BandEdges()
+		bands := make([]float64, e.levels.BandCount())
+		e.levels.GetBandsInto(bands)
+		w.Header("phase4_band_level_dbfs", "gauge", "Level of each frequency band in dBFS.")
+		for i, level := range bands {
+			w.Value("phase4_band_level_dbfs", level, "band", strconv.Itoa(i),
+				"low_hz", strconv.FormatFloat(edges[i], 'g', -1, 64), "high_hz", strconv.FormatFloat(edges[i+1], 'g', -1, 64))
+		}
+	}
+
+	if e.tempo != nil {
+		bpm, confidence := e.tempo.GetTempo()
+		w.Header("phase4_tempo_bpm", "gauge", "Estimated tempo in beats per minute (0 while unknown).")
+		w.Value("phase4_tempo_bpm", bpm)
+		w.Header("phase4_tempo_confidence", "gauge", "Confidence of the tempo estimate (0 to 1).")
+		w.Value("phase4_tempo_confidence", confidence)
+		w.Header("phase4_beats_total", "counter", "Beats tracked.")
+		w.Value("phase4_beats_total", float64(e.tempo.GetBeats()))
+	}
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: MIT
+package audio
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/config"
+	"audio/internal/metrics"
+	tcpTransport "audio/internal/transport/tcp"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// slowProcessor takes a fixed time per buffer.
+type slowProcessor time.Duration
+
+func (p slowProcessor) Process([]int32) { time.Sleep(time.Duration(p)) }
+
+func TestEngine_Metrics(t *testing.T) {
+	cfg := &config.Config{Audio: config.AudioConfig{SampleRate: 48000, FramesPerBuffer: 48}} // 1 ms buffers.
+	e := &Engine{config: cfg, frames: analysis.NewFrameBus()}
+	defer e.frames.Close()
+	e.health.callbackTime = metrics.NewHistogram(nil)
+	e.RegisterProcessor(slowProcessor(2 * time.Millisecond))
+
+	if got := processorName(&analysis.FFTProcessor{}); got != "fft" {
+		t.Errorf("processorName = %q, want fft", got)
+	}
+
+	e.processInputStream(make([]int32, 48), portaudio.StreamCallbackTimeInfo{}, 0)
+	e.processInputStream(make([]int32, 48), portaudio.StreamCallbackTimeInfo{}, portaudio.InputOverflow)
+
+	var w metrics.Writer
+	e.writeMetrics(&w)
+	out := string(w.Bytes())
+	for _, want := range []string{
+		"phase4_stream_active 0\n",
+		"phase4_callbacks_total 2\n",
+		"phase4_callback_duration_seconds_count 2\n",
+		"phase4_callback_budget_seconds 0.001\n",
+		"phase4_callback_overruns_total 2\n",
+		"phase4_input_overflows_total 1\n",
+		"phase4_input_underflows_total 0\n",
+		`phase4_processor_duration_seconds_bucket{processor="slow",le="0.001"} 0` + "\n",
+		`phase4_processor_duration_seconds_count{processor="slow"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics lack %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "phase4_level_rms_dbfs") {
+		t.Error("level metrics written without a level processor")
+	}
+}
+
+// steadyOnsets is an OnsetProvider with a flat envelope.
+type steadyOnsets struct{}
+
+func (steadyOnsets) GetOnsetStrength() float64 { return 1 }
+func (steadyOnsets) GetFrameRate() float64     { return 100 }
+
+func TestEngine_OutputMetrics(t *testing.T) {
+	cfg := &config.Config{Audio: config.AudioConfig{SampleRate: 48000, FramesPerBuffer: 48}}
+	e := &Engine{config: cfg}
+	e.health.callbackTime = metrics.NewHistogram(nil)
+
+	server, err := tcpTransport.NewServer("127.0.0.1:0", tcpTransport.ServerOptions{})
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	defer server.Close()
+	e.tcpServer = server
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(time.Second); server.Totals().Clients == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("client not accepted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := server.Send([]byte("spectrum")); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 4+8)); err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); server.Totals().Messages == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("frame not counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	e.tempo, err = analysis.NewTempoProcessor(steadyOnsets{}, 60, 180, 3*time.Second)
+	if err != nil {
+		t.Fatalf("NewTempoProcessor error: %v", err)
+	}
+	defer e.tempo.Close()
+
+	var w metrics.Writer
+	e.writeMetrics(&w)
+	out := string(w.Bytes())
+	for _, want := range []string{
+		`phase4_output_messages_total{transport="tcp"} 1` + "\n",
+		`phase4_output_bytes_total{transport="tcp"} 12` + "\n",
+		`phase4_output_errors_total{transport="tcp"} 0` + "\n",
+		`phase4_output_clients{transport="tcp"} 1` + "\n",
+		"phase4_tempo_bpm 0\n",
+		"phase4_beats_total 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics lack %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `transport="udp"`) {
+		t.Error("UDP metrics written without UDP publishers")
+	}
+}
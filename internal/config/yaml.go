@@ -2,6 +2,7 @@
 package config
 
 import (
+	"audio/pkg/protocol"
 	"fmt"
 	"os"
 	"strconv"
@@ -16,6 +17,7 @@ type Config struct {
 	LogLevel  string          `yaml:"log_level"`         // Logging level (e.g., "debug", "info", "warn", "error").
 	Command   string          `yaml:"command,omitempty"` // A one-off command to execute instead of running the engine (e.g., "list", "version").
 	Audio     AudioConfig     `yaml:"audio"`             // Audio processing settings.
+	Analysis  AnalysisConfig  `yaml:"analysis"`          // Optional analysis processor settings.
 	Recording RecordingConfig `yaml:"recording"`         // Audio recording settings.
 	Transport TransportConfig `yaml:"transport"`         // Data transport settings (e.g., UDP).
 }
@@ -32,6 +34,76 @@ type AudioConfig struct {
 	FFTWindow       string  `yaml:"fft_window"`        // Name of the window function for FFT analysis (e.g., "Hann", "Hamming").
 }
 
+// AnalysisConfig holds settings for the optional analysis processors that run after the FFT.
+type AnalysisConfig struct {
+	Levels LevelsConfig `yaml:"levels"` // Level and band meter settings.
+	HPSS   HPSSConfig   `yaml:"hpss"`   // Harmonic/percussive separation settings.
+	VAD    VADConfig    `yaml:"vad"`    // Voice activity detection settings.
+	Peaks  PeaksConfig  `yaml:"peaks"`  // Spectral peak picking settings.
+	Chroma ChromaConfig `yaml:"chroma"` // Chroma (pitch class profile) settings.
+	Chord  ChordConfig  `yaml:"chord"`  // Chord recognition settings (uses chroma).
+	Onset  OnsetConfig  `yaml:"onset"`  // Onset detection settings.
+	Tempo  TempoConfig  `yaml:"tempo"`  // Tempo and beat tracking settings (uses onset).
+}
+
+// LevelsConfig holds settings for the level and band meter.
+type LevelsConfig struct {
+	Enabled   bool      `yaml:"enabled"`    // Enable RMS, peak and band level metering.
+	BandEdges []float64 `yaml:"band_edges"` // Band edges in Hz, ascending (empty uses the seven standard mixing bands).
+}
+
+// HPSSConfig holds settings for the harmonic/percussive separation processor.
+type HPSSConfig struct {
+	Enabled          bool    `yaml:"enabled"`           // Enable harmonic/percussive separation.
+	HarmonicKernel   int     `yaml:"harmonic_kernel"`   // Median filter length over time in frames (odd).
+	PercussiveKernel int     `yaml:"percussive_kernel"` // Median filter length over frequency in bins (odd).
+	MaskPower        float64 `yaml:"mask_power"`        // Exponent for the soft masks (2 = Wiener).
+}
+
+// VADConfig holds settings for the voice activity detector.
+type VADConfig struct {
+	Enabled   bool          `yaml:"enabled"`   // Enable voice activity detection.
+	Threshold float64       `yaml:"threshold"` // Speech probability (0..1) above which speech starts.
+	Hangover  time.Duration `yaml:"hangover"`  // How long speech is held after the probability drops below the threshold.
+}
+
+// PeaksConfig holds settings for the spectral peak picking processor.
+type PeaksConfig struct {
+	Enabled       bool    `yaml:"enabled"`       // Enable spectral peak picking.
+	Count         int     `yaml:"count"`         // Maximum number of peaks reported per frame.
+	Interpolation string  `yaml:"interpolation"` // Sub-bin interpolation: "parabolic" or "gaussian".
+	ThresholdDB   float64 `yaml:"threshold_db"`  // Ignore peaks this far below the strongest peak (e.g. -60, 0 disables).
+}
+
+// ChromaConfig holds settings for the chroma processor.
+type ChromaConfig struct {
+	Enabled      bool    `yaml:"enabled"`       // Enable chroma analysis (implied by chord.enabled).
+	MinFrequency float64 `yaml:"min_frequency"` // Lowest frequency folded into the chroma (Hz).
+	MaxFrequency float64 `yaml:"max_frequency"` // Highest frequency folded into the chroma (Hz).
+}
+
+// ChordConfig holds settings for the chord recognition processor.
+type ChordConfig struct {
+	Enabled        bool    `yaml:"enabled"`         // Enable chord recognition.
+	Window         int     `yaml:"window"`          // Number of frames in the Viterbi smoothing window.
+	SelfTransition float64 `yaml:"self_transition"` // Probability (0..1) of staying on the same chord between frames.
+}
+
+// OnsetConfig holds settings for the onset detector.
+type OnsetConfig struct {
+	Enabled     bool          `yaml:"enabled"`      // Enable onset detection (implied by tempo.enabled).
+	Threshold   float64       `yaml:"threshold"`    // Multiple (> 1) of the recent average spectral flux an onset must exceed.
+	MinInterval time.Duration `yaml:"min_interval"` // Shortest time between two onsets.
+}
+
+// TempoConfig holds settings for the tempo estimator and beat tracker.
+type TempoConfig struct {
+	Enabled bool          `yaml:"enabled"` // Enable tempo estimation and beat tracking.
+	MinBPM  float64       `yaml:"min_bpm"` // Slowest tempo considered.
+	MaxBPM  float64       `yaml:"max_bpm"` // Fastest tempo considered.
+	Window  time.Duration `yaml:"window"`  // Length of the onset history the tempo is estimated from.
+}
+
 // RecordingConfig holds settings related to audio recording functionality.
 type RecordingConfig struct {
 	Enabled     bool    `yaml:"enabled"`              // Enable audio recording to file.
@@ -44,9 +116,295 @@ type RecordingConfig struct {
 
 // TransportConfig holds settings related to sending processed data over the network.
 type TransportConfig struct {
-	UDPEnabled       bool          `yaml:"udp_enabled"`        // Enable sending FFT data over UDP.
-	UDPTargetAddress string        `yaml:"udp_target_address"` // Target address and port for UDP packets (e.g., "127.0.0.1:9090").
-	UDPSendInterval  time.Duration `yaml:"udp_send_interval"`  // Interval between sending UDP packets.
+	UDPEnabled       bool          `yaml:"udp_enabled"`         // Enable sending FFT data over UDP.
+	UDPTargetAddress string        `yaml:"udp_target_address"`  // Target address and port for UDP packets (e.g., "127.0.0.1:9090").
+	UDPSendInterval  time.Duration `yaml:"udp_send_interval"`   // Interval between sending UDP packets.
+	UDPSpectrum      string        `yaml:"udp_spectrum"`        // Spectrum to send: "fft", "harmonic" or "percussive" (the latter two require analysis.hpss).
+	UDPProtocol      string        `yaml:"udp_protocol"`        // Packet layout: "v1" (versioned header) or "legacy" (header-less, for old receivers).
+	UDPChannelID     uint16        `yaml:"udp_channel_id"`      // Channel ID written to the packet header to tell streams apart on a shared port.
+	UDPMaxPacketSize int           `yaml:"udp_max_packet_size"` // Largest datagram in bytes (at most 65507); bigger messages are fragmented (v1 protocol only).
+	UDPPublishMode   string        `yaml:"udp_publish_mode"`    // "interval" (every udp_send_interval) or "frames" (once per analysis frame).
+	UDPDecimation    int           `yaml:"udp_decimation"`      // In frames mode, send every Nth frame (0 or 1 sends all).
+
+	// Compact spectrum encoding (v1 protocol only). The defaults send plain float32 spectra.
+	UDPEncoding         string  `yaml:"udp_encoding"`          // Sample format: "float32", "db16" or "db8" (quantized dBFS levels).
+	UDPCompression      string  `yaml:"udp_compression"`       // Compression of the samples: "none", "zstd" or "lz4".
+	UDPKeyframeInterval int     `yaml:"udp_keyframe_interval"` // Send deltas to the previous spectrum, with a full one every N messages (0 disables deltas).
+	UDPMinDB            float64 `yaml:"udp_min_db"`            // Lowest level of db16/db8; quieter bins are sent as silence (dBFS).
+	UDPMaxDB            float64 `yaml:"udp_max_db"`            // Highest level of db16/db8 (dBFS).
+
+	// UDPTargets lists unicast or multicast destinations with their own settings. When set,
+	// it replaces udp_target_address (and so ENV_UDP_TARGET_ADDRESS); when empty, a single
+	// target is built from the udp_* fields above.
+	UDPTargets []UDPTargetConfig `yaml:"udp_targets"`
+
+	// Subscriptions: receivers register on the control port and are streamed to until
+	// their lease expires. udp_target_address may be left empty to rely on them alone.
+	UDPControlAddress    string        `yaml:"udp_control_address"`    // Address to accept subscriptions on (e.g., ":9091"); empty disables subscriptions.
+	UDPSubscriptionLease time.Duration `yaml:"udp_subscription_lease"` // Longest lease granted; subscribers must renew within it.
+	UDPMaxSubscribers    int           `yaml:"udp_max_subscribers"`    // Subscribers served at once.
+
+	// Packet security (v1 protocol only): with a pre-shared key, every datagram is signed
+	// (HMAC-SHA256) or encrypted (AES-256-GCM) and subscriptions must be signed with it too.
+	UDPKey     string        `yaml:"udp_key"`      // Hex encoded pre-shared key of at least 16 bytes; empty disables security.
+	UDPKeyFile string        `yaml:"udp_key_file"` // File holding the hex encoded key, used when udp_key is empty.
+	UDPEncrypt bool          `yaml:"udp_encrypt"`  // Encrypt payloads instead of only signing them.
+	UDPMaxAge  time.Duration `yaml:"udp_max_age"`  // Reject subscriptions whose timestamp is further than this from the local clock (0 uses 30s, negative disables).
+
+	TCP  TCPConfig  `yaml:"tcp"`  // TCP stream settings.
+	OSC  OSCConfig  `yaml:"osc"`  // Open Sound Control output settings.
+	HTTP HTTPConfig `yaml:"http"` // Embedded HTTP server settings (WebSocket, SSE and snapshots).
+	GRPC GRPCConfig `yaml:"grpc"` // gRPC API settings.
+
+	Metrics MetricsConfig `yaml:"metrics"` // Prometheus metrics endpoint settings.
+	MDNS    MDNSConfig    `yaml:"mdns"`    // mDNS/DNS-SD advertisement settings.
+
+	Unix         UnixConfig         `yaml:"unix"`          // Unix domain socket output settings.
+	SharedMemory SharedMemoryConfig `yaml:"shared_memory"` // Shared-memory ring buffer settings.
+
+	MQTT MQTTConfig `yaml:"mqtt"` // MQTT publisher settings.
+	DMX  DMXConfig  `yaml:"dmx"`  // Art-Net / sACN lighting output settings.
+}
+
+// DMXConfig holds settings for driving lighting over Art-Net or sACN (E1.31). Each
+// mapping sets one channel of the universe from an analysis feature.
+type DMXConfig struct {
+	Enabled            bool               `yaml:"enabled"`             // Enable DMX output.
+	Protocol           string             `yaml:"protocol"`            // "artnet" or "sacn".
+	TargetAddress      string             `yaml:"target_address"`      // Node address and port (empty: Art-Net broadcast, sACN multicast group of the universe).
+	Universe           int                `yaml:"universe"`            // Art-Net port address (0-32767) or sACN universe (1-63999).
+	SendInterval       time.Duration      `yaml:"send_interval"`       // Interval between packets.
+	SourceName         string             `yaml:"source_name"`         // sACN source name shown by receivers.
+	Priority           int                `yaml:"priority"`            // sACN priority (1-200).
+	MulticastTTL       int                `yaml:"multicast_ttl"`       // TTL for sACN multicast (0 uses the OS default of 1).
+	MulticastInterface string             `yaml:"multicast_interface"` // Interface for sACN multicast (empty uses the OS default).
+	Mappings           []DMXMappingConfig `yaml:"mappings"`            // Channels to drive.
+}
+
+// DMXMappingConfig maps an analysis feature to a DMX channel. The value is scaled from
+// input to output, shaped by curve and smoothed by release.
+type DMXMappingConfig struct {
+	Channel int           `yaml:"channel"` // DMX channel, 1-512.
+	Source  string        `yaml:"source"`  // Feature, e.g. "level.rms", "band.0", "vad.probability", "event.chord_change".
+	Input   []float64     `yaml:"input"`   // Input range [low, high] (empty uses the source's natural range).
+	Output  []float64     `yaml:"output"`  // Output range [low, high] in 0-255 (empty uses [0, 255]; reversed inverts).
+	Curve   string        `yaml:"curve"`   // "linear", "square", "cube", "sqrt" or "smooth".
+	Release time.Duration `yaml:"release"` // Fall-off time constant after a rise (0 follows the input).
+	Fine    bool          `yaml:"fine"`    // 16 bit value on channel (coarse) and channel+1 (fine).
+}
+
+// MQTTConfig holds settings for publishing low-rate features to an MQTT broker. Every
+// enabled feature (level, bands, hpss, vad, chord, bpm) is published as JSON to
+// topic_prefix + "/" + feature, and detected events to topic_prefix + "/event", unless
+// overridden in topics. With discovery, Home Assistant picks the sensors up on its own.
+type MQTTConfig struct {
+	Enabled         bool              `yaml:"enabled"`          // Enable MQTT output.
+	Broker          string            `yaml:"broker"`           // Broker host and port (e.g., "127.0.0.1:1883").
+	ProtocolVersion int               `yaml:"protocol_version"` // 4 (MQTT 3.1.1) or 5 (MQTT 5).
+	ClientID        string            `yaml:"client_id"`        // Client identifier, unique per broker.
+	Username        string            `yaml:"username"`         // Optional user name.
+	Password        string            `yaml:"password"`         // Optional password.
+	KeepAlive       time.Duration     `yaml:"keep_alive"`       // Maximum time between packets.
+	PublishInterval time.Duration     `yaml:"publish_interval"` // Interval between feature updates.
+	TopicPrefix     string            `yaml:"topic_prefix"`     // Prefix of every topic (e.g., "venue/hall").
+	Topics          map[string]string `yaml:"topics"`           // Per-feature topic overrides; "" disables a feature.
+	QoS             int               `yaml:"qos"`              // QoS of feature messages: 0 or 1.
+	Retain          bool              `yaml:"retain"`           // Retain feature messages.
+	Discovery       bool              `yaml:"discovery"`        // Publish Home Assistant discovery messages.
+	DiscoveryPrefix string            `yaml:"discovery_prefix"` // Home Assistant discovery prefix.
+	NodeID          string            `yaml:"node_id"`          // Device identifier used in discovery topics.
+}
+
+// TCPConfig holds settings for streaming the spectrum to TCP clients, as the same packets
+// UDP carries, each prefixed with its length as a uint32. Every client has its own queue;
+// a client that falls behind loses its oldest packets rather than slowing the others.
+type TCPConfig struct {
+	Enabled       bool          `yaml:"enabled"`        // Enable the TCP stream.
+	ListenAddress string        `yaml:"listen_address"` // Address to listen on (e.g., ":9092", "127.0.0.1:9092").
+	SendInterval  time.Duration `yaml:"send_interval"`  // Interval between packets in interval mode.
+	Spectrum      string        `yaml:"spectrum"`       // Spectrum to send: "fft", "harmonic" or "percussive".
+	PublishMode   string        `yaml:"publish_mode"`   // "interval" (every send_interval) or "frames" (once per analysis frame).
+	Decimation    int           `yaml:"decimation"`     // In frames mode, send every Nth frame (0 or 1 sends all).
+	MaxClients    int           `yaml:"max_clients"`    // Maximum concurrent clients (0 for no limit).
+	QueueSize     int           `yaml:"queue_size"`     // Packets queued per client before the oldest is dropped.
+}
+
+// UnixConfig holds settings for sending the spectrum over a Unix domain socket to
+// consumers on the same host, using the same packets as UDP. With "unixgram" the engine
+// sends datagrams to a socket the consumer binds at path; with "unix" the engine
+// listens at path and streams the packets to every consumer that connects.
+type UnixConfig struct {
+	Enabled      bool          `yaml:"enabled"`       // Enable Unix socket output.
+	Network      string        `yaml:"network"`       // "unixgram" (consumer binds path) or "unix" (engine listens on path).
+	Path         string        `yaml:"path"`          // Socket path.
+	SendInterval time.Duration `yaml:"send_interval"` // Interval between packets.
+	Spectrum     string        `yaml:"spectrum"`      // Spectrum to send: "fft", "harmonic" or "percussive".
+	MaxClients   int           `yaml:"max_clients"`   // Maximum stream consumers (0 for no limit, "unix" only).
+}
+
+// SharedMemoryConfig holds settings for the shared-memory ring buffer that consumers
+// on the same host map to read the latest spectra (layout in pkg/shm).
+type SharedMemoryConfig struct {
+	Enabled      bool          `yaml:"enabled"`       // Enable the shared-memory buffer.
+	Path         string        `yaml:"path"`          // Buffer file (empty uses /dev/shm/phase4, or the temp directory without /dev/shm).
+	SendInterval time.Duration `yaml:"send_interval"` // Interval between spectra.
+	Spectrum     string        `yaml:"spectrum"`      // Spectrum to write: "fft", "harmonic" or "percussive".
+	Slots        int           `yaml:"slots"`         // Number of spectra kept in the ring.
+}
+
+// MetricsConfig holds settings for the Prometheus metrics endpoint (GET /metrics): audio
+// callback counts and durations, overflows, processor times, UDP stream counters and
+// current levels.
+type MetricsConfig struct {
+	Enabled       bool   `yaml:"enabled"`        // Enable the metrics endpoint.
+	ListenAddress string `yaml:"listen_address"` // Address to listen on (e.g., ":9095", "127.0.0.1:9095").
+}
+
+// MDNSConfig holds settings for advertising the engine on the local network with
+// multicast DNS service discovery, as an instance of "_phase4._udp" whose TXT record
+// carries the sample rate, FFT size, protocol version and control port (see
+// pkg/discovery).
+type MDNSConfig struct {
+	Enabled   bool   `yaml:"enabled"`   // Advertise the engine.
+	Instance  string `yaml:"instance"`  // Instance name, unique on the network (empty uses the host name).
+	Interface string `yaml:"interface"` // Interface to advertise on (empty uses the system default).
+}
+
+// GRPCConfig holds settings for the gRPC API (schema in pkg/api/v1/phase4.proto).
+// Subscribe streams typed frames of the enabled analysis features and detected events;
+// GetStatus and GetConfig report the engine's state and configuration.
+type GRPCConfig struct {
+	Enabled        bool          `yaml:"enabled"`         // Enable the gRPC server.
+	ListenAddress  string        `yaml:"listen_address"`  // Address to listen on (e.g., ":50051", "127.0.0.1:50051").
+	SendInterval   time.Duration `yaml:"send_interval"`   // Interval between periodic frames.
+	Spectrum       string        `yaml:"spectrum"`        // Spectrum to send: "fft", "harmonic" or "percussive".
+	MaxSubscribers int           `yaml:"max_subscribers"` // Maximum concurrent Subscribe calls (0 for no limit).
+}
+
+// HTTPConfig holds settings for the embedded HTTP server. Its WebSocket endpoint (/ws)
+// streams the same spectrum packets as UDP (binary) or JSON, plus the enabled analysis
+// features as JSON. /events streams low-rate updates and detected events as Server-Sent
+// Events, and /snapshot/{type} returns the latest values as JSON.
+type HTTPConfig struct {
+	Enabled        bool          `yaml:"enabled"`         // Enable the HTTP server.
+	ListenAddress  string        `yaml:"listen_address"`  // Address to listen on (e.g., ":8080", "127.0.0.1:8080").
+	SendInterval   time.Duration `yaml:"send_interval"`   // Interval between stream frames.
+	Spectrum       string        `yaml:"spectrum"`        // Spectrum to send: "fft", "harmonic" or "percussive".
+	MaxClients     int           `yaml:"max_clients"`     // Maximum concurrent streaming clients (0 for no limit).
+	AllowedOrigins []string      `yaml:"allowed_origins"` // Browser origins allowed to connect (empty allows any).
+}
+
+// OSCConfig holds settings for the Open Sound Control output. Every enabled analysis
+// feature is sent to address_prefix + "/" + feature (fft, level, bands, hpss, vad,
+// chord, chroma, peaks, onset, bpm, beat) unless overridden in addresses.
+type OSCConfig struct {
+	Enabled        bool              `yaml:"enabled"`         // Enable OSC output.
+	Network        string            `yaml:"network"`         // "udp" or "tcp" (size-prefixed OSC 1.0 stream).
+	TargetAddress  string            `yaml:"target_address"`  // Target address and port (e.g., "127.0.0.1:9000").
+	SendInterval   time.Duration     `yaml:"send_interval"`   // Interval between updates.
+	Spectrum       string            `yaml:"spectrum"`        // Spectrum to send: "fft", "harmonic" or "percussive".
+	SpectrumFormat string            `yaml:"spectrum_format"` // "floats" (one argument per bin) or "blob" (one float32 blob).
+	AddressPrefix  string            `yaml:"address_prefix"`  // Prefix of every address (e.g., "/phase4").
+	Addresses      map[string]string `yaml:"addresses"`       // Per-feature address overrides; "" disables a feature.
+	Bundle         bool              `yaml:"bundle"`          // Send each update as one bundle with an OSC time tag.
+}
+
+// UDPTargetConfig holds settings for one UDP destination. Zero values fall back to the
+// corresponding udp_* fields of TransportConfig. The encoding settings (encoding,
+// compression, keyframe_interval, min_db, max_db) fall back together when encoding is
+// empty, so a target either inherits the transport-wide encoding or defines its own.
+type UDPTargetConfig struct {
+	Address            string        `yaml:"address"`             // Unicast address or multicast group and port (e.g., "239.1.2.3:9090", "[ff15::4]:9090").
+	Interval           time.Duration `yaml:"interval"`            // Interval between packets.
+	Spectrum           string        `yaml:"spectrum"`            // Spectrum to send: "fft", "harmonic" or "percussive".
+	Protocol           string        `yaml:"protocol"`            // Packet layout: "v1" or "legacy".
+	MaxPacketSize      int           `yaml:"max_packet_size"`     // Largest datagram in bytes.
+	PublishMode        string        `yaml:"publish_mode"`        // "interval" or "frames".
+	Decimation         int           `yaml:"decimation"`          // In frames mode, send every Nth frame.
+	Encoding           string        `yaml:"encoding"`            // Sample format: "float32", "db16" or "db8".
+	Compression        string        `yaml:"compression"`         // Compression: "none", "zstd" or "lz4".
+	KeyframeInterval   int           `yaml:"keyframe_interval"`   // Messages per full spectrum when sending deltas (0 disables deltas).
+	MinDB              float64       `yaml:"min_db"`              // Lowest level of db16/db8 (dBFS).
+	MaxDB              float64       `yaml:"max_db"`              // Highest level of db16/db8 (dBFS).
+	MulticastTTL       int           `yaml:"multicast_ttl"`       // TTL / hop limit for multicast groups (0 uses the OS default of 1).
+	MulticastInterface string        `yaml:"multicast_interface"` // Interface name to send multicast on (empty uses the OS default).
+	MulticastLoopback  bool          `yaml:"multicast_loopback"`  // Also deliver multicast packets to listeners on this host.
+}
+
+// UDPSecurityKey returns the pre-shared key from udp_key or udp_key_file, or nil if
+// neither is set.
+func (t TransportConfig) UDPSecurityKey() ([]byte, error) {
+	value := t.UDPKey
+	if value == "" && t.UDPKeyFile != "" {
+		data, err := os.ReadFile(t.UDPKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transport.udp_key_file: %w", err)
+		}
+		value = string(data)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	key, err := protocol.ParseKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UDP key: %w", err)
+	}
+	return key, nil
+}
+
+// ResolvedUDPTargets returns the UDP destinations to send to, with unset fields filled
+// from the transport-wide udp_* settings. If no targets are listed, the single
+// udp_target_address is returned, or none if it is empty.
+func (t TransportConfig) ResolvedUDPTargets() []UDPTargetConfig {
+	if len(t.UDPTargets) == 0 && t.UDPTargetAddress == "" {
+		return nil
+	}
+	if len(t.UDPTargets) == 0 {
+		return []UDPTargetConfig{{
+			Address:          t.UDPTargetAddress,
+			Interval:         t.UDPSendInterval,
+			Spectrum:         t.UDPSpectrum,
+			Protocol:         t.UDPProtocol,
+			MaxPacketSize:    t.UDPMaxPacketSize,
+			PublishMode:      t.UDPPublishMode,
+			Decimation:       t.UDPDecimation,
+			Encoding:         t.UDPEncoding,
+			Compression:      t.UDPCompression,
+			KeyframeInterval: t.UDPKeyframeInterval,
+			MinDB:            t.UDPMinDB,
+			MaxDB:            t.UDPMaxDB,
+		}}
+	}
+
+	targets := make([]UDPTargetConfig, len(t.UDPTargets))
+	for i, target := range t.UDPTargets {
+		if target.Interval <= 0 {
+			target.Interval = t.UDPSendInterval
+		}
+		if target.Spectrum == "" {
+			target.Spectrum = t.UDPSpectrum
+		}
+		if target.Protocol == "" {
+			target.Protocol = t.UDPProtocol
+		}
+		if target.MaxPacketSize <= 0 {
+			target.MaxPacketSize = t.UDPMaxPacketSize
+		}
+		if target.PublishMode == "" {
+			target.PublishMode = t.UDPPublishMode
+		}
+		if target.Decimation <= 0 {
+			target.Decimation = t.UDPDecimation
+		}
+		if target.Encoding == "" {
+			target.Encoding = t.UDPEncoding
+			target.Compression = t.UDPCompression
+			target.KeyframeInterval = t.UDPKeyframeInterval
+			target.MinDB, target.MaxDB = t.UDPMinDB, t.UDPMaxDB
+		}
+		targets[i] = target
+	}
+	return targets
 }
 
 // LoadConfig loads configuration from a YAML file specified by path. If path is empty,
@@ -67,6 +425,49 @@ func LoadConfig(path string) (*Config, error) {
 			OutputChannels:  2,
 			FFTWindow:       "Hann",
 		},
+		Analysis: AnalysisConfig{
+			Levels: LevelsConfig{
+				Enabled: false,
+			},
+			HPSS: HPSSConfig{
+				Enabled:          false,
+				HarmonicKernel:   17,
+				PercussiveKernel: 17,
+				MaskPower:        2,
+			},
+			VAD: VADConfig{
+				Enabled:   false,
+				Threshold: 0.5,
+				Hangover:  300 * time.Millisecond,
+			},
+			Peaks: PeaksConfig{
+				Enabled:       false,
+				Count:         8,
+				Interpolation: "parabolic",
+				ThresholdDB:   -60,
+			},
+			Chroma: ChromaConfig{
+				Enabled:      false,
+				MinFrequency: 55,
+				MaxFrequency: 2000,
+			},
+			Chord: ChordConfig{
+				Enabled:        false,
+				Window:         16,
+				SelfTransition: 0.95,
+			},
+			Onset: OnsetConfig{
+				Enabled:     false,
+				Threshold:   1.5,
+				MinInterval: 50 * time.Millisecond,
+			},
+			Tempo: TempoConfig{
+				Enabled: false,
+				MinBPM:  60,
+				MaxBPM:  180,
+				Window:  6 * time.Second,
+			},
+		},
 		Recording: RecordingConfig{
 			Enabled:     false,
 			OutputDir:   "./recordings",
@@ -76,9 +477,96 @@ func LoadConfig(path string) (*Config, error) {
 			SilenceTh:   0.01,
 		},
 		Transport: TransportConfig{
-			UDPEnabled:       false, // Default UDP to false.
-			UDPTargetAddress: "127.0.0.1:9090",
-			UDPSendInterval:  33 * time.Millisecond, // Default ~30Hz.
+			UDPEnabled:           false, // Default UDP to false.
+			UDPTargetAddress:     "127.0.0.1:9090",
+			UDPSendInterval:      33 * time.Millisecond, // Default ~30Hz.
+			UDPSpectrum:          "fft",
+			UDPProtocol:          "v1",
+			UDPChannelID:         0,
+			UDPMaxPacketSize:     1400, // Fits a 1500 byte MTU with IPv4 or IPv6 headers.
+			UDPPublishMode:       "interval",
+			UDPDecimation:        1,
+			UDPSubscriptionLease: 10 * time.Second,
+			UDPMaxSubscribers:    16,
+			UDPMaxAge:            30 * time.Second,
+			UDPEncoding:          "float32",
+			UDPCompression:       "none",
+			UDPMinDB:             -120,
+			UDPMaxDB:             0,
+			OSC: OSCConfig{
+				Enabled:        false,
+				Network:        "udp",
+				TargetAddress:  "127.0.0.1:9000",
+				SendInterval:   33 * time.Millisecond,
+				Spectrum:       "fft",
+				SpectrumFormat: "floats",
+				AddressPrefix:  "/phase4",
+				Bundle:         false,
+			},
+			TCP: TCPConfig{
+				Enabled:       false,
+				ListenAddress: "127.0.0.1:9092",
+				SendInterval:  33 * time.Millisecond,
+				Spectrum:      "fft",
+				PublishMode:   "interval",
+				Decimation:    1,
+				MaxClients:    16,
+				QueueSize:     64,
+			},
+			HTTP: HTTPConfig{
+				Enabled:       false,
+				ListenAddress: "127.0.0.1:8080",
+				SendInterval:  33 * time.Millisecond,
+				Spectrum:      "fft",
+				MaxClients:    16,
+			},
+			GRPC: GRPCConfig{
+				Enabled:        false,
+				ListenAddress:  "127.0.0.1:50051",
+				SendInterval:   33 * time.Millisecond,
+				Spectrum:       "fft",
+				MaxSubscribers: 16,
+			},
+			Metrics: MetricsConfig{
+				Enabled:       false,
+				ListenAddress: "127.0.0.1:9095",
+			},
+			Unix: UnixConfig{
+				Enabled:      false,
+				Network:      "unixgram",
+				Path:         "/tmp/phase4.sock",
+				SendInterval: 16 * time.Millisecond,
+				Spectrum:     "fft",
+				MaxClients:   16,
+			},
+			SharedMemory: SharedMemoryConfig{
+				Enabled:      false,
+				SendInterval: 16 * time.Millisecond,
+				Spectrum:     "fft",
+				Slots:        8,
+			},
+			MQTT: MQTTConfig{
+				Enabled:         false,
+				Broker:          "127.0.0.1:1883",
+				ProtocolVersion: 4,
+				ClientID:        "phase4",
+				KeepAlive:       30 * time.Second,
+				PublishInterval: time.Second,
+				TopicPrefix:     "phase4",
+				QoS:             0,
+				Retain:          false,
+				Discovery:       true,
+				DiscoveryPrefix: "homeassistant",
+				NodeID:          "phase4",
+			},
+			DMX: DMXConfig{
+				Enabled:      false,
+				Protocol:     "artnet",
+				Universe:     0,
+				SendInterval: 25 * time.Millisecond,
+				SourceName:   "phase4",
+				Priority:     100,
+			},
 		},
 	}
 
@@ -178,6 +666,9 @@ func (cfg *Config) applyEnvOverrides() {
 	if val, ok := os.LookupEnv("ENV_UDP_TARGET_ADDRESS"); ok {
 		cfg.Transport.UDPTargetAddress = val
 		fmt.Printf("configuration: Overriding transport.udp_target_address from env: %s", val)
+		if len(cfg.Transport.UDPTargets) > 0 {
+			fmt.Printf("configuration: Warning: ENV_UDP_TARGET_ADDRESS has no effect, transport.udp_targets replaces udp_target_address\n")
+		}
 	}
 	// ENV_UDP_SEND_INTERVAL
 	if val, ok := os.LookupEnv("ENV_UDP_SEND_INTERVAL"); ok {
@@ -186,4 +677,9 @@ func (cfg *Config) applyEnvOverrides() {
 			fmt.Printf("configuration: Overriding transport.udp_send_interval from env: %s", dur)
 		}
 	}
+	// ENV_UDP_KEY (keeps the secret out of the configuration file)
+	if val, ok := os.LookupEnv("ENV_UDP_KEY"); ok {
+		cfg.Transport.UDPKey = val
+		fmt.Printf("configuration: Overriding transport.udp_key from env")
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func writeTempConfig(t *testing.T, content string) string {
@@ -48,3 +49,40 @@ func TestLoadConfig_UnmarshalError(t *testing.T) {
 		t.Error("expected unmarshal error, got nil or wrong error")
 	}
 }
+
+func TestResolvedUDPTargets(t *testing.T) {
+	t.Parallel()
+	path := writeTempConfig(t, `
+transport:
+  udp_target_address: "127.0.0.1:9000"
+  udp_send_interval: "20ms"
+  udp_spectrum: fft
+  udp_targets:
+    - address: "10.0.0.2:9090"
+    - address: "239.1.2.3:9090"
+      interval: "50ms"
+      spectrum: harmonic
+      multicast_ttl: 4
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	targets := cfg.Transport.ResolvedUDPTargets()
+	if len(targets) != 2 {
+		t.Fatalf("len(targets) = %d, want 2", len(targets))
+	}
+	if targets[0].Interval != 20*time.Millisecond || targets[0].Spectrum != "fft" || targets[0].MaxPacketSize != 1400 {
+		t.Errorf("target 0 defaults not applied: %+v", targets[0])
+	}
+	if targets[1].Interval != 50*time.Millisecond || targets[1].Spectrum != "harmonic" || targets[1].MulticastTTL != 4 {
+		t.Errorf("target 1 overrides lost: %+v", targets[1])
+	}
+
+	cfg.Transport.UDPTargets = nil
+	targets = cfg.Transport.ResolvedUDPTargets()
+	if len(targets) != 1 || targets[0].Address != "127.0.0.1:9000" {
+		t.Errorf("single target fallback = %+v, want udp_target_address", targets)
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: MIT
+
+// Package metrics exposes engine health in the Prometheus text format. Values are
+// gathered when /metrics is scraped, by a collect function writing them to a Writer, so
+// nothing is registered up front. Histogram records durations from the audio callback
+// without locks or allocations.
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the histogram buckets in seconds, from 10 µs to
+// 100 ms. A 512 frame buffer at 48 kHz has a budget of 10.7 ms.
+var DefaultBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1,
+}
+
+// Histogram counts durations in buckets. Observe is safe for concurrent use and
+// doesn't block, so it can be called from the audio callback.
+type Histogram struct {
+	bounds []float64       // Upper bounds of the buckets in seconds, ascending.
+	counts []atomic.Uint64 // Observations per bucket (not cumulative); the last is +Inf.
+	sum    atomic.Int64    // Sum of all observations in nanoseconds.
+	count  atomic.Uint64   // Number of observations.
+}
+
+// NewHistogram creates a histogram with the given bucket bounds in seconds, or
+// DefaultBuckets if bounds is empty.
+func NewHistogram(bounds []float64) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+	}
+}
+
+// Observe records one duration.
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := 0
+	for i < len(h.bounds) && seconds > h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+	h.count.Add(1)
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(h.sum.Load())
+}
+
+// Writer formats metrics in the Prometheus text exposition format (version 0.0.4).
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// Bytes returns the formatted metrics.
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// Header starts the metric family name of type kind ("counter", "gauge" or
+// "histogram"). Every family needs a header before its samples.
+func (w *Writer) Header(name, kind, help string) {
+	w.buf.WriteString("# HELP ")
+	w.buf.WriteString(name)
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(escape(help, false))
+	w.buf.WriteString("\n# TYPE ")
+	w.buf.WriteString(name)
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(kind)
+	w.buf.WriteByte('\n')
+}
+
+// Value writes one sample of name. labels are pairs of label name and value.
+func (w *Writer) Value(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) >= 2 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i])
+			w.buf.WriteString(`="`)
+			w.buf.WriteString(escape(labels[i+1], true))
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatFloat(value))
+	w.buf.WriteByte('\n')
+}
+
+// Histogram writes the buckets, sum and count of h as samples of name, which needs a
+// "histogram" header. labels are added to every sample.
+func (w *Writer) Histogram(name string, h *Histogram, labels ...string) {
+	// Buckets are read one by one while observations continue, so the total is taken
+	// from the buckets to keep the series consistent.
+	var cumulative uint64
+	bucketLabels := append(append(make([]string, 0, len(labels)+2), labels...), "le", "")
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		if i < len(h.bounds) {
+			bucketLabels[len(bucketLabels)-1] = formatFloat(h.bounds[i])
+		} else {
+			bucketLabels[len(bucketLabels)-1] = "+Inf"
+		}
+		w.Value(name+"_bucket", float64(cumulative), bucketLabels...)
+	}
+	w.Value(name+"_sum", h.Sum().Seconds(), labels...)
+	w.Value(name+"_count", float64(cumulative), labels...)
+}
+
+// formatFloat formats a sample value, with Prometheus' spelling of infinities.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape escapes backslashes and newlines, and in label values double quotes.
+func escape(s string, quote bool) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			b = append(b, `\\`...)
+		case c == '\n':
+			b = append(b, `\n`...)
+		case c == '"' && quote:
+			b = append(b, `\"`...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	h := NewHistogram([]float64{0.001, 0.01})
+	h.Observe(500 * time.Microsecond)
+	h.Observe(2 * time.Millisecond)
+	h.Observe(time.Second)
+
+	var w Writer
+	w.Header("test_total", "counter", "A counter\nwith two lines.")
+	w.Value("test_total", 3)
+	w.Value("test_total", 0.5, "name", `say "hi"\`, "id", "2")
+	w.Header("test_seconds", "histogram", "A histogram.")
+	w.Histogram("test_seconds", h, "stage", "a")
+
+	want := `# HELP test_total A counter\nwith two lines.
+# TYPE test_total counter
+test_total 3
+test_total{name="say \"hi\"\\",id="2"} 0.5
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{stage="a",le="0.001"} 1
+test_seconds_bucket{stage="a",le="0.01"} 2
+test_seconds_bucket{stage="a",le="+Inf"} 3
+test_seconds_sum{stage="a"} 1.0025
+test_seconds_count{stage="a"} 3
+`
+	if got := string(w.Bytes()); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+	if h.Count() != 3 || h.Sum() != time.Second+2500*time.Microsecond {
+		t.Errorf("Count, Sum = %d, %s", h.Count(), h.Sum())
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("127.0.0.1:0", func(w *Writer) {
+		w.Header("up", "gauge", "Whether the engine is up.")
+		w.Value("up", 1)
+	})
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	defer s.Close()
+
+	resp, err := http.Get("http://" + s.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentType || !strings.HasSuffix(string(body), "\nup 1\n") {
+		t.Errorf("GET /metrics = %d %q: %q", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Server serves GET /metrics, gathering the values with collect on every request.
+type Server struct {
+	listener   net.Listener  // Bound listener.
+	httpServer *http.Server  // HTTP server serving /metrics.
+	serveDone  chan struct{} // Closed when the HTTP server has returned.
+}
+
+// NewServer binds address (e.g. ":9095") and starts serving. collect is called from
+// request goroutines, possibly concurrently, and must be safe for that.
+func NewServer(address string, collect func(w *Writer)) (*Server, error) {
+	if collect == nil {
+		return nil, fmt.Errorf("metrics: collect function cannot be nil")
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: failed to listen on '%s': %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		var m Writer
+		collect(&m)
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = w.Write(m.Bytes())
+	})
+	s := &Server{
+		listener: listener,
+		httpServer: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		serveDone: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.serveDone)
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("metrics: HTTP server error: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("metrics: Serving /metrics on %s\n", listener.Addr())
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close shuts the server down, waiting briefly for scrapes in progress.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := s.httpServer.Shutdown(ctx)
+	<-s.serveDone
+	if err != nil {
+		return fmt.Errorf("metrics: failed to shut down HTTP server: %w", err)
+	}
+	return nil
+}
+
+// Ensure Server satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Server)(nil)
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: MIT
+package spectrogram
+
+import (
+	"audio/internal/analysis"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+)
+
+// Options controls how a spectrogram is computed and rendered.
+type Options struct {
+	Width        int                 // Output image width in pixels (time axis).
+	Height       int                 // Output image height in pixels (frequency axis).
+	FFTSize      int                 // Number of points per FFT frame (power of 2).
+	HopSize      int                 // Number of samples between successive frames (<= 0 uses FFTSize).
+	Window       analysis.WindowFunc // Window function applied before each FFT.
+	LogFrequency bool                // Use a logarithmic frequency axis instead of a linear one.
+	MinFrequency float64             // Lowest frequency shown in Hz (<= 0 uses 20 Hz for log axes, 0 Hz otherwise).
+	MaxFrequency float64             // Highest frequency shown in Hz (<= 0 uses Nyquist).
+	MinDB        float64             // Level mapped to the bottom of the color map.
+	MaxDB        float64             // Level mapped to the top of the color map.
+
+	Markers          bool          // Detect onsets and beats and mark them along the top and bottom edges.
+	OnsetThreshold   float64       // Onset threshold, a multiple of the average spectral flux (> 1).
+	OnsetMinInterval time.Duration // Shortest time between onsets.
+	MinBPM           float64       // Slowest tempo considered by the beat tracker.
+	MaxBPM           float64       // Fastest tempo considered by the beat tracker.
+	TempoWindow      time.Duration // Onset history the tempo is estimated from.
+}
+
+// DefaultOptions returns sensible defaults for rendering a spectrogram.
+func DefaultOptions() Options {
+	return Options{
+		Width:   1024,
+		Height:  512,
+		FFTSize: 2048,
+		Window:  analysis.Hann,
+		MinDB:   -100,
+		MaxDB:   0,
+
+		OnsetThreshold:   1.5,
+		OnsetMinInterval: 50 * time.Millisecond,
+		MinBPM:           60,
+		MaxBPM:           180,
+		TempoWindow:      6 * time.Second,
+	}
+}
+
+// Spectrogram holds the per-frame magnitude spectra (in dBFS) of an analysed signal.
+type Spectrogram struct {
+	SampleRate float64     // Sample rate of the analysed signal (Hz).
+	FFTSize    int         // FFT size used for each frame.
+	HopSize    int         // Hop size between frames, in samples.
+	Frames     [][]float64 // Frames[t][bin] magnitude in dBFS, bins 0..FFTSize/2.
+	Onsets     []int       // Frames with a detected onset, if markers were requested.
+	Beats      []int       // Frames with a tracked beat, if markers were requested.
+	BeatsErr   error       // Why beats could not be tracked, in which case only onsets are marked.
+}
+
+// FrameTime returns the start time in seconds of the given frame index.
+func (s *Spectrogram) FrameTime(frame int) float64 {
+	return float64(frame*s.HopSize) / s.SampleRate
+}
+
+// Compute runs mono int32 samples through an analysis.FFTProcessor frame by frame,
+// exactly as the engine does for live input, and collects the magnitude spectra.
+// The final partial frame is zero-padded by the processor. With opts.Markers, the
+// spectra also go through the engine's onset detector and beat tracker, and the frames
+// where they fire are collected in Onsets and Beats. If the frame rate or window don't
+// allow beat tracking (e.g. a large FFT without a smaller hop), only onsets are collected
+// and the reason is returned in BeatsErr.
+func Compute(samples []int32, sampleRate float64, opts Options) (*Spectrogram, error) {
+	hop := opts.HopSize
+	if hop <= 0 {
+		hop = opts.FFTSize
+	}
+
+	fftProcessor, err := analysis.NewFFTProcessor(opts.FFTSize, sampleRate, opts.Window)
+	if err != nil {
+		return nil, fmt.Errorf("spectrogram: failed to create FFT processor: %w", err)
+	}
+	defer fftProcessor.Close()
+
+	spec := &Spectrogram{
+		SampleRate: sampleRate,
+		FFTSize:    opts.FFTSize,
+		HopSize:    hop,
+	}
+
+	var onsets *analysis.OnsetProcessor
+	var tempo *analysis.TempoProcessor
+	if opts.Markers {
+		onsets, err = analysis.NewOnsetProcessor(fftProcessor, sampleRate/float64(hop), opts.OnsetThreshold, opts.OnsetMinInterval)
+		if err != nil {
+			return nil, fmt.Errorf("spectrogram: failed to create onset processor: %w", err)
+		}
+		defer onsets.Close()
+		if tempo, err = analysis.NewTempoProcessor(onsets, opts.MinBPM, opts.MaxBPM, opts.TempoWindow); err != nil {
+			spec.BeatsErr = err
+		} else {
+			defer tempo.Close()
+		}
+	}
+
+	// Magnitudes are scaled so that a full-scale sine with a rectangular window is 0 dBFS.
+	scale := 2.0 / float64(opts.FFTSize)
+	bins := opts.FFTSize/2 + 1
+
+	for pos := 0; pos < len(samples); pos += hop {
+		end := min(pos+opts.FFTSize, len(samples))
+		fftProcessor.Process(samples[pos:end])
+
+		frame := make([]float64, bins)
+		if err := fftProcessor.GetMagnitudesInto(frame); err != nil {
+			return nil, fmt.Errorf("spectrogram: failed to read magnitudes: %w", err)
+		}
+		for i, m := range frame {
+			frame[i] = 20 * math.Log10(math.Max(m*scale, 1e-12))
+		}
+		spec.Frames = append(spec.Frames, frame)
+
+		if onsets != nil {
+			count, _ := onsets.GetOnsets()
+			onsets.Process(nil)
+			if next, _ := onsets.GetOnsets(); next != count {
+				spec.Onsets = append(spec.Onsets, len(spec.Frames)-1)
+			}
+		}
+		if tempo != nil {
+			beats := tempo.GetBeats()
+			tempo.Process(nil)
+			if tempo.GetBeats() != beats {
+				spec.Beats = append(spec.Beats, len(spec.Frames)-1)
+			}
+		}
+	}
+
+	return spec, nil
+}
+
+// Render draws the spectrogram into an RGBA image, time running left to right and
+// frequency bottom to top. When several frames fall on the same column the loudest
+// value is kept so short transients stay visible in long files. Onsets are marked with
+// ticks along the top edge and beats with ticks along the bottom edge.
+func (s *Spectrogram) Render(opts Options) (*image.RGBA, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("spectrogram: image size must be positive, got %dx%d", opts.Width, opts.Height)
+	}
+	if len(s.Frames) == 0 {
+		return nil, fmt.Errorf("spectrogram: no frames to render")
+	}
+	if opts.MaxDB <= opts.MinDB {
+		return nil, fmt.Errorf("spectrogram: max dB (%.1f) must be greater than min dB (%.1f)", opts.MaxDB, opts.MinDB)
+	}
+
+	nyquist := s.SampleRate / 2
+	minFreq, maxFreq := opts.MinFrequency, opts.MaxFrequency
+	if maxFreq <= 0 || maxFreq > nyquist {
+		maxFreq = nyquist
+	}
+	if minFreq <= 0 && opts.LogFrequency {
+		minFreq = 20
+	}
+	if minFreq < 0 {
+		minFreq = 0
+	}
+	if minFreq >= maxFreq {
+		return nil, fmt.Errorf("spectrogram: min frequency (%.1f Hz) must be below max frequency (%.1f Hz)", minFreq, maxFreq)
+	}
+
+	// Pre-compute the fractional bin for each output row, row 0 is the top of the image.
+	binWidth := s.SampleRate / float64(s.FFTSize)
+	rowBins := make([]float64, opts.Height)
+	for y := range opts.Height {
+		pos := 1 - float64(y)/float64(max(opts.Height-1, 1))
+		var freq float64
+		if opts.LogFrequency {
+			freq = minFreq * math.Pow(maxFreq/minFreq, pos)
+		} else {
+			freq = minFreq + (maxFreq-minFreq)*pos
+		}
+		rowBins[y] = freq / binWidth
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	column := make([]float64, len(s.Frames[0]))
+	for x := range opts.Width {
+		first := x * len(s.Frames) / opts.Width
+		last := max((x+1)*len(s.Frames)/opts.Width, first+1)
+
+		copy(column, s.Frames[first])
+		for _, frame := range s.Frames[first+1 : last] {
+			for i, v := range frame {
+				column[i] = math.Max(column[i], v)
+			}
+		}
+
+		for y, bin := range rowBins {
+			level := interpolate(column, bin)
+			img.SetRGBA(x, y, ColorMap((level-opts.MinDB)/(opts.MaxDB-opts.MinDB)))
+		}
+	}
+
+	markerHeight := max(opts.Height/16, 1)
+	for _, frame := range s.Onsets {
+		x := frame * opts.Width / len(s.Frames)
+		for y := range markerHeight {
+			img.SetRGBA(x, y, onsetColor)
+		}
+	}
+	for _, frame := range s.Beats {
+		x := frame * opts.Width / len(s.Frames)
+		for y := opts.Height - markerHeight; y < opts.Height; y++ {
+			img.SetRGBA(x, y, beatColor)
+		}
+	}
+
+	return img, nil
+}
+
+// Marker colors, chosen to stand out against every color of the color map.
+var (
+	onsetColor = color.RGBA{255, 255, 255, 255}
+	beatColor  = color.RGBA{0, 220, 255, 255}
+)
+
+// interpolate linearly interpolates values at a fractional index, clamping at the edges.
+func interpolate(values []float64, index float64) float64 {
+	if index <= 0 {
+		return values[0]
+	}
+	lo := int(index)
+	if lo >= len(values)-1 {
+		return values[len(values)-1]
+	}
+	frac := index - float64(lo)
+	return values[lo]*(1-frac) + values[lo+1]*frac
+}
+
+// colorStops is a perceptually ordered black → purple → red → yellow → white gradient
+// similar to the "inferno" map, so quiet regions fade into the background.
+var colorStops = []color.RGBA{
+	{0, 0, 4, 255},
+	{40, 11, 84, 255},
+	{101, 21, 110, 255},
+	{159, 42, 99, 255},
+	{212, 72, 66, 255},
+	{245, 125, 21, 255},
+	{250, 193, 39, 255},
+	{252, 255, 164, 255},
+}
+
+// ColorMap maps a normalised level in [0, 1] to a color. Values outside the range are clamped.
+func ColorMap(v float64) color.RGBA {
+	if math.IsNaN(v) || v <= 0 {
+		return colorStops[0]
+	}
+	if v >= 1 {
+		return colorStops[len(colorStops)-1]
+	}
+	pos := v * float64(len(colorStops)-1)
+	i := int(pos)
+	frac := pos - float64(i)
+	a, b := colorStops[i], colorStops[i+1]
+	lerp := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-frac) + float64(y)*frac))
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: MIT
+package spectrogram
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func sine(freq, sampleRate float64, n int) []int32 {
+	samples := make([]int32, n)
+	for i := range samples {
+		samples[i] = int32(0.5 * math.MaxInt32 * math.Sin(2*math.Pi*freq*float64(i)/sampleRate))
+	}
+	return samples
+}
+
+func TestCompute_PeakBin(t *testing.T) {
+	t.Parallel()
+	const sampleRate = 8000.0
+	opts := DefaultOptions()
+	opts.FFTSize = 256
+	opts.HopSize = 128
+
+	spec, err := Compute(sine(1000, sampleRate, 1024), sampleRate, opts)
+	if err != nil {
+		t.Fatalf("Compute error: %v", err)
+	}
+	if len(spec.Frames) != 8 {
+		t.Fatalf("len(Frames) = %d, want 8", len(spec.Frames))
+	}
+
+	// 1000 Hz at 8 kHz / 256 points lands exactly on bin 32.
+	frame := spec.Frames[1]
+	peak := 0
+	for i, v := range frame {
+		if v > frame[peak] {
+			peak = i
+		}
+	}
+	if peak != 32 {
+		t.Errorf("peak bin = %d, want 32", peak)
+	}
+	if got := spec.FrameTime(2); got != 256/sampleRate {
+		t.Errorf("FrameTime(2) = %f, want %f", got, 256/sampleRate)
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	const sampleRate = 8000.0
+	opts := DefaultOptions()
+	opts.FFTSize = 256
+	opts.Width, opts.Height = 16, 64
+
+	spec, err := Compute(sine(2000, sampleRate, 4096), sampleRate, opts)
+	if err != nil {
+		t.Fatalf("Compute error: %v", err)
+	}
+
+	for _, logFreq := range []bool{false, true} {
+		opts.LogFrequency = logFreq
+		img, err := spec.Render(opts)
+		if err != nil {
+			t.Fatalf("Render(log=%v) error: %v", logFreq, err)
+		}
+		if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 64 {
+			t.Errorf("Render(log=%v) size = %v, want 16x64", logFreq, b)
+		}
+	}
+
+	// On a linear axis 2 kHz is exactly half way up, so the middle row should be brightest.
+	opts.LogFrequency = false
+	img, _ := spec.Render(opts)
+	brightest, best := 0, -1
+	for y := range opts.Height {
+		c := img.RGBAAt(8, y)
+		if sum := int(c.R) + int(c.G) + int(c.B); sum > best {
+			brightest, best = y, sum
+		}
+	}
+	if brightest < 30 || brightest > 33 {
+		t.Errorf("brightest row = %d, want ~31", brightest)
+	}
+
+	opts.MaxDB = opts.MinDB
+	if _, err := spec.Render(opts); err == nil {
+		t.Error("expected error for empty dB range, got nil")
+	}
+}
+
+func TestCompute_Markers(t *testing.T) {
+	t.Parallel()
+	const sampleRate = 8000.0
+	opts := DefaultOptions()
+	opts.FFTSize = 256
+	opts.Width, opts.Height = 200, 64
+	opts.Markers = true
+
+	// 20 noise bursts at 125 BPM (every 0.48 s), starting on a frame boundary.
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]int32, int(10*sampleRate))
+	for click := 0; click < 20; click++ {
+		start := click * 3840
+		for i := start; i < start+400 && i < len(samples); i++ {
+			samples[i] = int32((rng.Float64() - 0.5) * math.MaxInt32)
+		}
+	}
+
+	spec, err := Compute(samples, sampleRate, opts)
+	if err != nil {
+		t.Fatalf("Compute error: %v", err)
+	}
+	if len(spec.Onsets) < 18 || len(spec.Onsets) > 20 {
+		t.Errorf("len(Onsets) = %d, want ~20", len(spec.Onsets))
+	}
+	if len(spec.Beats) == 0 {
+		t.Error("no beats tracked")
+	}
+
+	img, err := spec.Render(opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	x := spec.Onsets[len(spec.Onsets)-1] * opts.Width / len(spec.Frames)
+	if c := img.RGBAAt(x, 0); c != onsetColor {
+		t.Errorf("pixel above the last onset = %v, want %v", c, onsetColor)
+	}
+	x = spec.Beats[len(spec.Beats)-1] * opts.Width / len(spec.Frames)
+	if c := img.RGBAAt(x, opts.Height-1); c != beatColor {
+		t.Errorf("pixel below the last beat = %v, want %v", c, beatColor)
+	}
+}
+
+func TestCompute_MarkersWithoutBeats(t *testing.T) {
+	t.Parallel()
+	// Two frames per second are too few to track beats between 60 and 180 BPM.
+	const sampleRate = 8000.0
+	opts := DefaultOptions()
+	opts.FFTSize = 4096
+	opts.Markers = true
+
+	spec, err := Compute(sine(1000, sampleRate, 8*4096), sampleRate, opts)
+	if err != nil {
+		t.Fatalf("Compute error: %v", err)
+	}
+	if spec.BeatsErr == nil || len(spec.Beats) != 0 {
+		t.Errorf("BeatsErr = %v, %d beats, want an error and no beats", spec.BeatsErr, len(spec.Beats))
+	}
+	if len(spec.Frames) != 8 {
+		t.Errorf("len(Frames) = %d, want 8", len(spec.Frames))
+	}
+}
+
+func TestColorMap_Clamps(t *testing.T) {
+	t.Parallel()
+	if c := ColorMap(-1); c != colorStops[0] {
+		t.Errorf("ColorMap(-1) = %v, want %v", c, colorStops[0])
+	}
+	if c := ColorMap(2); c != colorStops[len(colorStops)-1] {
+		t.Errorf("ColorMap(2) = %v, want %v", c, colorStops[len(colorStops)-1])
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+
+// Package dmx drives lighting directly from the analysis results: a mapping table turns
+// features into DMX channel values, which are sent as one universe over Art-Net or sACN
+// (ANSI E1.31).
+//
+// Art-Net packets are ArtDmx packets (protocol version 14) addressed by a 15 bit port
+// address. sACN packets are E1.31 data packets with the root, framing and DMP layers,
+// sent to the universe's multicast group (239.255.hi.lo:5568) unless a unicast target
+// is configured. Both carry the 512 slots of the universe after the start code.
+package dmx
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Protocol selects the packet format of a universe.
+type Protocol string
+
+// Supported protocols.
+const (
+	ArtNet Protocol = "artnet" // Art-Net 4 ArtDmx packets.
+	SACN   Protocol = "sacn"   // ANSI E1.31 (Streaming ACN) data packets.
+)
+
+// Ports and universe limits of the protocols.
+const (
+	ArtNetPort = 6454 // UDP port of Art-Net nodes.
+	SACNPort   = 5568 // UDP port of sACN receivers.
+
+	MaxArtNetUniverse = 0x7fff // Highest Art-Net port address (net, sub-net and universe).
+	MinSACNUniverse   = 1      // Lowest sACN universe.
+	MaxSACNUniverse   = 63999  // Highest sACN universe.
+
+	Slots = 512 // Channels in a DMX universe.
+)
+
+// DefaultPriority is the sACN priority used when Options.Priority is zero.
+const DefaultPriority = 100
+
+// artNetID starts every Art-Net packet.
+var artNetID = [8]byte{'A', 'r', 't', '-', 'N', 'e', 't', 0}
+
+// acnPacketID is the ACN packet identifier of the E1.31 root layer.
+var acnPacketID = [12]byte{'A', 'S', 'C', '-', 'E', '1', '.', '1', '7', 0, 0, 0}
+
+// Art-Net and E1.31 constants.
+const (
+	artNetOpDmx      = 0x5000 // ArtDmx operation code (sent little-endian).
+	artNetProtVer    = 14     // Art-Net protocol version.
+	sacnVectorRoot   = 0x00000004
+	sacnVectorFrame  = 0x00000002
+	sacnVectorDMP    = 0x02
+	sacnSourceName   = 64   // Size of the zero-terminated source name field.
+	sacnHeaderSize   = 126  // Bytes before the first slot, including the start code.
+	sacnTerminated   = 0x40 // Stream_Terminated option bit.
+	sacnFlags        = 0x7000
+	sacnRootOffset   = 16  // Start of the root layer PDU.
+	sacnFrameOffset  = 38  // Start of the framing layer PDU.
+	sacnDMPOffset    = 115 // Start of the DMP layer PDU.
+	sacnPreambleSize = 0x0010
+)
+
+// DefaultTarget returns the destination of universe when no target address is
+// configured: the limited broadcast address for Art-Net and the universe's multicast
+// group for sACN.
+func DefaultTarget(protocol Protocol, universe int) (string, error) {
+	if err := checkUniverse(protocol, universe); err != nil {
+		return "", err
+	}
+	switch protocol {
+	case ArtNet:
+		return fmt.Sprintf("255.255.255.255:%d", ArtNetPort), nil
+	default:
+		return fmt.Sprintf("239.255.%d.%d:%d", universe>>8, universe&0xff, SACNPort), nil
+	}
+}
+
+// checkUniverse validates the protocol and the universe number.
+func checkUniverse(protocol Protocol, universe int) error {
+	switch protocol {
+	case ArtNet:
+		if universe < 0 || universe > MaxArtNetUniverse {
+			return fmt.Errorf("Art-Net universe %d out of range (0-%d)", universe, MaxArtNetUniverse)
+		}
+	case SACN:
+		if universe < MinSACNUniverse || universe > MaxSACNUniverse {
+			return fmt.Errorf("sACN universe %d out of range (%d-%d)", universe, MinSACNUniverse, MaxSACNUniverse)
+		}
+	default:
+		return fmt.Errorf("unknown DMX protocol %q (use %q or %q)", protocol, ArtNet, SACN)
+	}
+	return nil
+}
+
+// AppendArtDMX appends an ArtDmx packet carrying data (up to 512 slots) to universe, the
+// 15 bit port address. A zero sequence tells nodes not to reorder packets.
+func AppendArtDMX(dst []byte, universe uint16, sequence byte, data []byte) []byte {
+	length := len(data) + len(data)%2 // The length must be even.
+	dst = append(dst, artNetID[:]...)
+	dst = binary.LittleEndian.AppendUint16(dst, artNetOpDmx)
+	dst = binary.BigEndian.AppendUint16(dst, artNetProtVer)
+	dst = append(dst, sequence, 0, byte(universe), byte(universe>>8)&0x7f) // Sequence, physical, SubUni, Net.
+	dst = binary.BigEndian.AppendUint16(dst, uint16(length))
+	dst = append(dst, data...)
+	if length != len(data) {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// SACNSource identifies an sACN sender.
+type SACNSource struct {
+	CID      [16]byte // Component identifier, a UUID that stays the same across restarts.
+	Name     string   // User-assigned name, truncated to 63 bytes.
+	Priority byte     // Priority of the data (0-200).
+}
+
+// NewSACNSource returns a source named name whose CID is derived from the host name and
+// name, so receivers see the same source after a restart.
+func NewSACNSource(name string, priority byte) SACNSource {
+	host, _ := os.Hostname()
+	sum := sha1.Sum([]byte("phase4/sacn/" + host + "/" + name))
+	var cid [16]byte
+	copy(cid[:], sum[:])
+	cid[6] = cid[6]&0x0f | 0x50 // Name-based UUID (version 5).
+	cid[8] = cid[8]&0x3f | 0x80 // RFC 4122 variant.
+	return SACNSource{CID: cid, Name: name, Priority: priority}
+}
+
+// AppendSACN appends an E1.31 data packet carrying data (up to 512 slots) to universe.
+// terminated marks the last packets of a stream, telling receivers to stop using the
+// source right away instead of after a timeout.
+func AppendSACN(dst []byte, source SACNSource, universe uint16, sequence byte, terminated bool, data []byte) []byte {
+	size := sacnHeaderSize + len(data)
+
+	// Root layer.
+	dst = binary.BigEndian.AppendUint16(dst, sacnPreambleSize)
+	dst = binary.BigEndian.AppendUint16(dst, 0) // Postamble size.
+	dst = append(dst, acnPacketID[:]...)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(sacnFlags|(size-sacnRootOffset)))
+	dst = binary.BigEndian.AppendUint32(dst, sacnVectorRoot)
+	dst = append(dst, source.CID[:]...)
+
+	// Framing layer.
+	dst = binary.BigEndian.AppendUint16(dst, uint16(sacnFlags|(size-sacnFrameOffset)))
+	dst = binary.BigEndian.AppendUint32(dst, sacnVectorFrame)
+	var name [sacnSourceName]byte
+	copy(name[:sacnSourceName-1], source.Name)
+	dst = append(dst, name[:]...)
+	var options byte
+	if terminated {
+		options = sacnTerminated
+	}
+	dst = append(dst, source.Priority, 0, 0, sequence, options) // Priority, sync address, sequence, options.
+	dst = binary.BigEndian.AppendUint16(dst, universe)
+
+	// DMP layer.
+	dst = binary.BigEndian.AppendUint16(dst, uint16(sacnFlags|(size-sacnDMPOffset)))
+	dst = append(dst, sacnVectorDMP, 0xa1)      // Vector, address and data type.
+	dst = binary.BigEndian.AppendUint16(dst, 0) // First property address.
+	dst = binary.BigEndian.AppendUint16(dst, 1) // Address increment.
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(data)+1))
+	dst = append(dst, 0) // DMX start code.
+	return append(dst, data...)
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: MIT
+package dmx
+
+import (
+	"audio/internal/analysis"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// recordingSender keeps a copy of every packet sent.
+type recordingSender struct{ packets [][]byte }
+
+func (r *recordingSender) Send(packet []byte) error {
+	r.packets = append(r.packets, append([]byte(nil), packet...))
+	return nil
+}
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return float64(2 * (len(s) - 1)) }
+
+func TestAppendArtDMX(t *testing.T) {
+	t.Parallel()
+	packet := AppendArtDMX(nil, 0x1234, 7, []byte{1, 2, 3})
+	want := []byte{
+		'A', 'r', 't', '-', 'N', 'e', 't', 0,
+		0x00, 0x50, // OpDmx, little-endian.
+		0, 14, // Protocol version.
+		7, 0, 0x34, 0x12, // Sequence, physical, SubUni, Net.
+		0, 4, // Length, padded to an even number.
+		1, 2, 3, 0,
+	}
+	if !bytes.Equal(packet, want) {
+		t.Errorf("packet =\n% x\nwant\n% x", packet, want)
+	}
+}
+
+func TestAppendSACN(t *testing.T) {
+	t.Parallel()
+	source := NewSACNSource("stage left", 150)
+	if source != NewSACNSource("stage left", 150) {
+		t.Error("CID changes between calls")
+	}
+	data := make([]byte, Slots)
+	data[0], data[511] = 10, 20
+	packet := AppendSACN(nil, source, 7, 42, true, data)
+
+	if len(packet) != 638 {
+		t.Fatalf("packet length = %d, want 638", len(packet))
+	}
+	checks := []struct {
+		name        string
+		offset      int
+		got, wanted uint32
+	}{
+		{"root flags and length", 16, uint32(binary.BigEndian.Uint16(packet[16:])), 0x7000 | 622},
+		{"root vector", 18, binary.BigEndian.Uint32(packet[18:]), 4},
+		{"framing flags and length", 38, uint32(binary.BigEndian.Uint16(packet[38:])), 0x7000 | 600},
+		{"framing vector", 40, binary.BigEndian.Uint32(packet[40:]), 2},
+		{"priority", 108, uint32(packet[108]), 150},
+		{"sequence", 111, uint32(packet[111]), 42},
+		{"options", 112, uint32(packet[112]), 0x40},
+		{"universe", 113, uint32(binary.BigEndian.Uint16(packet[113:])), 7},
+		{"DMP flags and length", 115, uint32(binary.BigEndian.Uint16(packet[115:])), 0x7000 | 523},
+		{"property count", 123, uint32(binary.BigEndian.Uint16(packet[123:])), 513},
+		{"start code", 125, uint32(packet[125]), 0},
+		{"first slot", 126, uint32(packet[126]), 10},
+		{"last slot", 637, uint32(packet[637]), 20},
+	}
+	for _, c := range checks {
+		if c.got != c.wanted {
+			t.Errorf("%s (offset %d) = %#x, want %#x", c.name, c.offset, c.got, c.wanted)
+		}
+	}
+	if !bytes.HasPrefix(packet[44:], []byte("stage left\x00")) {
+		t.Errorf("source name = %q", packet[44:108])
+	}
+}
+
+func TestDefaultTarget(t *testing.T) {
+	t.Parallel()
+	if target, err := DefaultTarget(SACN, 258); err != nil || target != "239.255.1.2:5568" {
+		t.Errorf("DefaultTarget(sacn, 258) = %q, %v", target, err)
+	}
+	if target, err := DefaultTarget(ArtNet, 0); err != nil || target != "255.255.255.255:6454" {
+		t.Errorf("DefaultTarget(artnet, 0) = %q, %v", target, err)
+	}
+	if _, err := DefaultTarget(SACN, 0); err == nil {
+		t.Error("DefaultTarget accepted sACN universe 0")
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+	levels, err := analysis.NewLevelProcessor(spectrum{0, 1, 2, 3, 4}, []float64{0, 2, 4})
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+	levels.Process([]int32{math.MaxInt32, -math.MaxInt32}) // Full scale: 0 dBFS.
+	features := analysis.Features{Levels: levels, Events: analysis.NewEventBus()}
+
+	sender := &recordingSender{}
+	p, err := NewPublisher(time.Second, sender, features, Options{
+		Protocol: ArtNet,
+		Universe: 1,
+		Mappings: []Mapping{
+			{Channel: 1, Source: "level.rms"},
+			{Channel: 2, Source: "level.rms", Output: []float64{255, 0}},                  // Inverted.
+			{Channel: 3, Source: "level.rms", Input: []float64{-60, 20}, Curve: "square"}, // (60/80)² = 0.5625.
+			{Channel: 4, Source: "event.chord_change", Release: time.Second},
+			{Channel: 10, Source: "level.peak", Input: []float64{-120, 120}, Fine: true}, // Half: 0x7fff.
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher error: %v", err)
+	}
+
+	now := time.Now()
+	p.fired[analysis.EventChordChange] = true
+	p.publish(now)
+	p.publish(now.Add(time.Second)) // The flash decays to 1/e.
+	p.publish(now.Add(2 * time.Second))
+
+	if len(sender.packets) != 3 {
+		t.Fatalf("sent %d packets, want 3", len(sender.packets))
+	}
+	first, second, third := sender.packets[0][18:], sender.packets[1][18:], sender.packets[2][18:]
+	if got := first[:4]; !bytes.Equal(got, []byte{255, 0, 143, 255}) {
+		t.Errorf("channels 1-4 = %v, want [255 0 143 255]", got)
+	}
+	if got := first[9:11]; !bytes.Equal(got, []byte{0x7f, 0xff}) && !bytes.Equal(got, []byte{0x80, 0x00}) {
+		t.Errorf("fine channels 10-11 = % x, want the middle 7f ff or 80 00", got)
+	}
+	if second[3] != 94 || third[3] != 35 {
+		t.Errorf("event channel decays as %d, %d, want 94, 35", second[3], third[3])
+	}
+	if sender.packets[0][12] != 1 || sender.packets[1][12] != 2 {
+		t.Errorf("sequence numbers = %d, %d, want 1, 2", sender.packets[0][12], sender.packets[1][12])
+	}
+}
+
+func TestPublisher_OnsetAndBeat(t *testing.T) {
+	t.Parallel()
+	src := make(spectrum, 5)
+	onset, err := analysis.NewOnsetProcessor(src, 100, 1.5, 0)
+	if err != nil {
+		t.Fatalf("NewOnsetProcessor error: %v", err)
+	}
+	defer onset.Close()
+	tempo, err := analysis.NewTempoProcessor(onset, 60, 180, 3*time.Second)
+	if err != nil {
+		t.Fatalf("NewTempoProcessor error: %v", err)
+	}
+	defer tempo.Close()
+
+	sender := &recordingSender{}
+	p, err := NewPublisher(time.Second, sender, analysis.Features{Onset: onset, Tempo: tempo}, Options{
+		Protocol: ArtNet,
+		Universe: 1,
+		Mappings: []Mapping{
+			{Channel: 1, Source: "onset"},
+			{Channel: 2, Source: "beat.phase"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher error: %v", err)
+	}
+
+	// A hit every 50 frames: 120 BPM.
+	for frame := range 400 {
+		level := 0.0
+		if frame%50 == 0 {
+			level = 1
+		}
+		for i := range src {
+			src[i] = level
+		}
+		onset.Process(nil)
+		tempo.Process(nil)
+	}
+	if bpm, _ := tempo.GetTempo(); math.Abs(bpm-120) > 2 {
+		t.Fatalf("tempo = %.1f BPM, want 120", bpm)
+	}
+
+	now := time.Now()
+	p.publish(now)
+	p.publish(now.Add(time.Second)) // No new onset.
+	first, second := sender.packets[0][18:], sender.packets[1][18:]
+	if first[0] != 255 || second[0] != 0 {
+		t.Errorf("onset channel = %d, %d, want 255, 0", first[0], second[0])
+	}
+	if want := byte(math.Round(255 * tempo.GetBeatPhase())); first[1] != want {
+		t.Errorf("beat phase channel = %d, want %d", first[1], want)
+	}
+}
+
+func TestPublisher_Close(t *testing.T) {
+	t.Parallel()
+	sender := &recordingSender{}
+	p, err := NewPublisher(time.Millisecond, sender, analysis.Features{Events: analysis.NewEventBus()}, Options{
+		Protocol: SACN,
+		Universe: 1,
+		Mappings: []Mapping{{Channel: 1, Source: "event.speech_start"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher error: %v", err)
+	}
+	p.Start()
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if len(sender.packets) < 3 {
+		t.Fatalf("sent %d packets, want at least the 3 terminated ones", len(sender.packets))
+	}
+	for _, packet := range sender.packets[len(sender.packets)-3:] {
+		if packet[112] != sacnTerminated {
+			t.Errorf("final packet options = %#x, want Stream_Terminated", packet[112])
+		}
+	}
+}
+
+func TestPublisher_Errors(t *testing.T) {
+	t.Parallel()
+	levels, err := analysis.NewLevelProcessor(spectrum{0, 1, 2, 3, 4}, []float64{0, 2, 4})
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+	features := analysis.Features{Levels: levels}
+
+	tests := []struct {
+		name     string
+		options  Options
+		features analysis.Features
+	}{
+		{"no mappings", Options{Protocol: ArtNet}, features},
+		{"unknown protocol", Options{Protocol: "dmx512", Mappings: []Mapping{{Channel: 1, Source: "level.rms"}}}, features},
+		{"sACN universe 0", Options{Protocol: SACN, Mappings: []Mapping{{Channel: 1, Source: "level.rms"}}}, features},
+		{"unknown source", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "beat.count"}}}, features},
+		{"disabled processor", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "vad.probability"}}}, features},
+		{"disabled tempo", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "beat.phase"}}}, features},
+		{"disabled onset", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "onset"}}}, features},
+		{"negative band", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "band.-1"}}}, features},
+		{"missing band", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "band.2"}}}, features},
+		{"channel 0", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 0, Source: "level.rms"}}}, features},
+		{"fine channel 512", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 512, Source: "level.rms", Fine: true}}}, features},
+		{"overlap", Options{Protocol: ArtNet, Mappings: []Mapping{
+			{Channel: 1, Source: "level.rms", Fine: true},
+			{Channel: 2, Source: "level.peak"},
+		}}, features},
+		{"unknown curve", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "level.rms", Curve: "log"}}}, features},
+		{"output range", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "level.rms", Output: []float64{0, 300}}}}, features},
+		{"empty input range", Options{Protocol: ArtNet, Mappings: []Mapping{{Channel: 1, Source: "level.rms", Input: []float64{1, 1}}}}, features},
+	}
+	for _, tt := range tests {
+		if _, err := NewPublisher(time.Second, &recordingSender{}, tt.features, tt.options); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: MIT
+package dmx
+
+import (
+	"audio/internal/analysis"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mapping drives one DMX channel (or a coarse/fine pair) from one feature. The feature
+// value is scaled from Input to 0..1 and clamped, smoothed by Release, shaped by Curve
+// and finally scaled to Output.
+type Mapping struct {
+	Channel int           // DMX channel, 1-512 (the fine channel follows it).
+	Source  string        // Feature to read, see Sources.
+	Input   []float64     // Input range [low, high] (empty uses the source's natural range).
+	Output  []float64     // Output range [low, high] in DMX units (empty uses [0, 255]; reversed inverts).
+	Curve   string        // Response curve, see Curves (empty uses "linear").
+	Release time.Duration // Time constant of the fall back after a rise (0 follows the input).
+	Fine    bool          // 16 bit value: Channel holds the coarse byte, Channel+1 the fine byte.
+}
+
+// Sources lists the features a mapping can read, with their natural input range.
+// "band.N" and "chroma.N" take a 0-based index (chroma starts at C). "onset" is 1 on the
+// tick after an onset and "event.NAME" on the tick after an event named NAME (e.g.
+// speech_start, chord_change), and both are 0 otherwise, so combine them with a release
+// to flash. "beat.phase" rises from 0 on each beat towards 1 just before the next one.
+var Sources = map[string][2]float64{
+	"level.rms":             {-60, 0},
+	"level.peak":            {-60, 0},
+	"band.N":                {-60, 0},
+	"hpss.harmonic":         {0, 1},
+	"hpss.percussive":       {0, 1},
+	"hpss.percussive_ratio": {0, 1},
+	"vad.probability":       {0, 1},
+	"vad.speaking":          {0, 1},
+	"chord.confidence":      {0, 1},
+	"chroma.N":              {0, 1},
+	"onset":                 {0, 1},
+	"beat.phase":            {0, 1},
+	"event.NAME":            {0, 1},
+}
+
+// Curves maps curve names to functions shaping a value in 0..1.
+var Curves = map[string]func(float64) float64{
+	"linear": func(x float64) float64 { return x },
+	"square": func(x float64) float64 { return x * x },             // Perceptually even dimming.
+	"cube":   func(x float64) float64 { return x * x * x },         // Stays dark, then bursts.
+	"sqrt":   math.Sqrt,                                            // Lifts low values.
+	"smooth": func(x float64) float64 { return x * x * (3 - 2*x) }, // Smoothstep, soft at both ends.
+}
+
+// channelMapping is a validated mapping with its reader and smoothing state.
+type channelMapping struct {
+	offset      int                        // Slot index of the (coarse) channel.
+	fine        bool                       // Write a coarse/fine pair.
+	read        func(p *Publisher) float64 // Returns the raw feature value.
+	event       string                     // Event name for event sources.
+	inLow       float64
+	inHigh      float64
+	outLow      float64
+	outHigh     float64
+	curve       func(float64) float64
+	release     time.Duration
+	level       float64 // Smoothed value in 0..1.
+	initialized bool    // Whether level holds a value yet.
+}
+
+// newChannelMapping validates m against the available features.
+func newChannelMapping(m Mapping, features analysis.Features) (*channelMapping, error) {
+	limit := Slots
+	if m.Fine {
+		limit--
+	}
+	if m.Channel < 1 || m.Channel > limit {
+		return nil, fmt.Errorf("channel %d out of range (1-%d)", m.Channel, limit)
+	}
+	c := &channelMapping{offset: m.Channel - 1, fine: m.Fine, release: max(m.Release, 0), outLow: 0, outHigh: 255}
+
+	name, arg, _ := strings.Cut(m.Source, ".")
+	key := m.Source
+	index := -1
+	if n, err := strconv.Atoi(arg); err == nil && n >= 0 {
+		index = n
+		key = name + ".N"
+	}
+	var missing bool
+	switch key {
+	case "level.rms":
+		missing = features.Levels == nil
+		c.read = func(p *Publisher) float64 { rms, _ := p.features.Levels.GetLevels(); return rms }
+	case "level.peak":
+		missing = features.Levels == nil
+		c.read = func(p *Publisher) float64 { _, peak := p.features.Levels.GetLevels(); return peak }
+	case "band.N":
+		missing = features.Levels == nil
+		if !missing && index >= features.Levels.BandCount() {
+			return nil, fmt.Errorf("source %q: only %d bands are configured", m.Source, features.Levels.BandCount())
+		}
+		c.read = func(p *Publisher) float64 { return p.bands[index] }
+	case "hpss.harmonic":
+		missing = features.HPSS == nil
+		c.read = func(p *Publisher) float64 { harmonic, _ := p.features.HPSS.GetEnergies(); return harmonic }
+	case "hpss.percussive":
+		missing = features.HPSS == nil
+		c.read = func(p *Publisher) float64 { _, percussive := p.features.HPSS.GetEnergies(); return percussive }
+	case "hpss.percussive_ratio":
+		missing = features.HPSS == nil
+		c.read = func(p *Publisher) float64 {
+			harmonic, percussive := p.features.HPSS.GetEnergies()
+			if harmonic+percussive <= 0 {
+				return 0
+			}
+			return percussive / (harmonic + percussive)
+		}
+	case "vad.probability":
+		missing = features.VAD == nil
+		c.read = func(p *Publisher) float64 { return p.features.VAD.GetSpeechProbability() }
+	case "vad.speaking":
+		missing = features.VAD == nil
+		c.read = func(p *Publisher) float64 {
+			if p.features.VAD.IsSpeaking() {
+				return 1
+			}
+			return 0
+		}
+	case "chord.confidence":
+		missing = features.Chord == nil
+		c.read = func(p *Publisher) float64 { _, confidence := p.features.Chord.GetChord(); return confidence }
+	case "chroma.N":
+		missing = features.Chroma == nil
+		if index > 11 {
+			return nil, fmt.Errorf("source %q: chroma index must be 0-11", m.Source)
+		}
+		c.read = func(p *Publisher) float64 { return p.chroma[index] }
+	case "onset":
+		missing = features.Onset == nil
+		c.read = func(p *Publisher) float64 {
+			if p.onset {
+				return 1
+			}
+			return 0
+		}
+	case "beat.phase":
+		missing = features.Tempo == nil
+		c.read = func(p *Publisher) float64 { return p.features.Tempo.GetBeatPhase() }
+	default:
+		if name != "event" || arg == "" {
+			return nil, fmt.Errorf("unknown source %q", m.Source)
+		}
+		missing = features.Events == nil
+		key = "event.NAME"
+		c.event = arg
+		c.read = func(p *Publisher) float64 {
+			if p.fired[c.event] {
+				return 1
+			}
+			return 0
+		}
+	}
+	if missing {
+		return nil, fmt.Errorf("source %q: the processor it reads is disabled", m.Source)
+	}
+
+	c.inLow, c.inHigh = Sources[key][0], Sources[key][1]
+	if len(m.Input) > 0 {
+		if len(m.Input) != 2 || m.Input[0] == m.Input[1] {
+			return nil, fmt.Errorf("input range %v must be two different values", m.Input)
+		}
+		c.inLow, c.inHigh = m.Input[0], m.Input[1]
+	}
+	if len(m.Output) > 0 {
+		if len(m.Output) != 2 || min(m.Output[0], m.Output[1]) < 0 || max(m.Output[0], m.Output[1]) > 255 {
+			return nil, fmt.Errorf("output range %v must be two values in 0-255", m.Output)
+		}
+		c.outLow, c.outHigh = m.Output[0], m.Output[1]
+	}
+	curve := m.Curve
+	if curve == "" {
+		curve = "linear"
+	}
+	if c.curve = Curves[curve]; c.curve == nil {
+		return nil, fmt.Errorf("unknown curve %q", m.Curve)
+	}
+	return c, nil
+}
+
+// update reads the feature, advances the smoothing by dt and writes the channel value
+// into slots.
+func (c *channelMapping) update(p *Publisher, dt time.Duration, slots []byte) {
+	x := (c.read(p) - c.inLow) / (c.inHigh - c.inLow)
+	if math.IsNaN(x) {
+		x = 0
+	}
+	x = min(max(x, 0), 1)
+
+	if c.initialized && x < c.level && c.release > 0 {
+		x += (c.level - x) * math.Exp(-float64(dt)/float64(c.release))
+	}
+	c.level, c.initialized = x, true
+
+	out := c.outLow + c.curve(x)*(c.outHigh-c.outLow) // 0..255
+	if c.fine {
+		v := uint16(math.Round(out * 257)) // 255 * 257 = 65535.
+		slots[c.offset], slots[c.offset+1] = byte(v>>8), byte(v)
+		return
+	}
+	slots[c.offset] = byte(math.Round(out))
+}
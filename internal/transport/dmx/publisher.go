@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: MIT
+package dmx
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Sender sends one encoded packet. It is satisfied by the UDP sender of the udp
+// transport.
+type Sender interface {
+	Send(packet []byte) error
+}
+
+// Options controls the universe produced by a Publisher.
+type Options struct {
+	Protocol   Protocol  // ArtNet or SACN.
+	Universe   int       // Art-Net port address (0-32767) or sACN universe (1-63999).
+	SourceName string    // sACN source name (empty uses "phase4").
+	Priority   int       // sACN priority, 1-200 (0 uses DefaultPriority).
+	Mappings   []Mapping // Channels to drive; unmapped channels stay at 0.
+}
+
+// Publisher periodically evaluates the mappings and sends the universe as one Art-Net or
+// sACN packet. It runs in a separate goroutine managed by Start and Stop, like the other
+// publishers. DMX receivers hold the last values they received, so the full universe is
+// sent on every tick.
+type Publisher struct {
+	sender   Sender            // Packet sender.
+	features analysis.Features // Processors to read from.
+	interval time.Duration     // Interval between packets.
+	protocol Protocol          // Packet format.
+	universe uint16            // Universe number in the packets.
+	source   SACNSource        // sACN source identity.
+	mappings []*channelMapping // Validated mappings, in configuration order.
+
+	ticker   *time.Ticker   // Ticker that triggers sending.
+	doneChan chan struct{}  // Channel used to signal the publisher goroutine to stop.
+	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
+	wg       sync.WaitGroup // Waits for the publisher goroutine to finish during Stop.
+	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
+
+	// State of the publisher goroutine.
+	slots    [Slots]byte     // Channel values.
+	bands    []float64       // Band levels of the current tick.
+	chroma   [12]float64     // Chroma vector of the current tick.
+	fired    map[string]bool // Events received since the previous tick.
+	onsets   uint64          // Onset count at the previous tick.
+	onset    bool            // Whether an onset was detected since the previous tick.
+	sequence byte            // Sequence number of the latest packet.
+	last     time.Time       // Time of the latest tick.
+	packet   []byte          // Reusable buffer for the encoded packet.
+
+	counters transport.Counters // Packets sent, for the metrics endpoint.
+}
+
+// NewPublisher creates a publisher sending the mapped universe through sender every
+// interval. If the provided interval is invalid (<= 0), it defaults to 25ms (40Hz, about
+// the refresh rate of a full DMX universe on the wire).
+func NewPublisher(interval time.Duration, sender Sender, features analysis.Features, options Options) (*Publisher, error) {
+	if sender == nil {
+		return nil, fmt.Errorf("DMXPublisher: sender cannot be nil")
+	}
+	if interval <= 0 {
+		interval = 25 * time.Millisecond
+		fmt.Printf("DMXPublisher: Invalid interval provided, defaulting to %s\n", interval)
+	}
+	if err := checkUniverse(options.Protocol, options.Universe); err != nil {
+		return nil, fmt.Errorf("DMXPublisher: %w", err)
+	}
+	if options.SourceName == "" {
+		options.SourceName = "phase4"
+	}
+	if options.Priority == 0 {
+		options.Priority = DefaultPriority
+	}
+	if options.Priority < 1 || options.Priority > 200 {
+		return nil, fmt.Errorf("DMXPublisher: sACN priority %d out of range (1-200)", options.Priority)
+	}
+	if len(options.Mappings) == 0 {
+		return nil, fmt.Errorf("DMXPublisher: no channel mappings configured")
+	}
+
+	p := &Publisher{
+		sender:   sender,
+		features: features,
+		interval: interval,
+		protocol: options.Protocol,
+		universe: uint16(options.Universe),
+		source:   NewSACNSource(options.SourceName, byte(options.Priority)),
+		fired:    make(map[string]bool),
+	}
+	if features.Levels != nil {
+		p.bands = make([]float64, features.Levels.BandCount())
+	}
+	if features.Onset != nil {
+		p.onsets, _ = features.Onset.GetOnsets()
+	}
+
+	used := make(map[int]int) // Slot offset to the mapping index using it.
+	for i, m := range options.Mappings {
+		c, err := newChannelMapping(m, features)
+		if err != nil {
+			return nil, fmt.Errorf("DMXPublisher: mapping %d (channel %d): %w", i+1, m.Channel, err)
+		}
+		offsets := []int{c.offset}
+		if c.fine {
+			offsets = append(offsets, c.offset+1)
+		}
+		for _, offset := range offsets {
+			if other, ok := used[offset]; ok {
+				return nil, fmt.Errorf("DMXPublisher: mapping %d and %d both drive channel %d", other+1, i+1, offset+1)
+			}
+			used[offset] = i
+		}
+		p.mappings = append(p.mappings, c)
+	}
+
+	fmt.Printf("DMXPublisher: Initializing (Protocol: %s, Universe: %d, Interval: %s, Mappings: %d)\n",
+		options.Protocol, options.Universe, interval, len(p.mappings))
+	return p, nil
+}
+
+// Start begins the periodic publishing process.
+// It is safe to call Start multiple times; subsequent calls are no-ops if already started.
+func (p *Publisher) Start() {
+	p.mu.Lock()
+	if p.ticker != nil {
+		p.mu.Unlock()
+		fmt.Printf("DMXPublisher: Start called but already running.\n")
+		return
+	}
+
+	p.ticker = time.NewTicker(p.interval)
+	p.doneChan = make(chan struct{})
+	p.stopOnce = sync.Once{}
+
+	// Capture local variables for the goroutine to avoid data races on p.ticker/p.doneChan
+	ticker := p.ticker
+	doneChan := p.doneChan
+
+	p.mu.Unlock()
+
+	var events <-chan analysis.Event
+	cancel := func() {}
+	if p.features.Events != nil && p.usesEvents() {
+		events, cancel = p.features.Events.Subscribe(16)
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer cancel()
+		fmt.Printf("DMXPublisher: Publisher goroutine started (Interval: %s)\n", p.interval)
+		for {
+			select {
+			case now := <-ticker.C:
+				p.publish(now)
+			case e, ok := <-events:
+				if !ok {
+					events = nil // Bus closed, keep sending the other channels.
+					continue
+				}
+				p.fired[e.Name] = true
+			case <-doneChan:
+				fmt.Printf("DMXPublisher: Publisher goroutine received stop signal.\n")
+				return
+			}
+		}
+	}()
+}
+
+// Stop signals the publisher goroutine to terminate and waits for it to exit.
+// It is safe to call Stop multiple times; subsequent calls are no-ops.
+func (p *Publisher) Stop() error {
+	p.mu.Lock()
+	if p.ticker == nil {
+		p.mu.Unlock()
+		return nil
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.doneChan)
+		p.ticker.Stop()
+		p.ticker = nil
+	})
+
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	fmt.Printf("DMXPublisher: Publisher goroutine finished.\n")
+	return nil
+}
+
+// usesEvents reports whether any mapping reads an event.
+func (p *Publisher) usesEvents() bool {
+	for _, c := range p.mappings {
+		if c.event != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// publish evaluates every mapping and sends the universe.
+func (p *Publisher) publish(now time.Time) {
+	var dt time.Duration
+	if !p.last.IsZero() {
+		dt = now.Sub(p.last)
+	}
+	p.last = now
+
+	if p.features.Levels != nil {
+		p.features.Levels.GetBandsInto(p.bands)
+	}
+	if p.features.Chroma != nil {
+		p.features.Chroma.GetChromaInto(&p.chroma)
+	}
+	if p.features.Onset != nil {
+		count, _ := p.features.Onset.GetOnsets()
+		p.onset = count != p.onsets
+		p.onsets = count
+	}
+	for _, c := range p.mappings {
+		c.update(p, dt, p.slots[:])
+	}
+	clear(p.fired)
+
+	p.send(false)
+}
+
+// send encodes the universe with the next sequence number and sends it.
+func (p *Publisher) send(terminated bool) {
+	p.sequence++
+	switch p.protocol {
+	case ArtNet:
+		if p.sequence == 0 {
+			p.sequence = 1 // Zero disables reordering on the nodes.
+		}
+		p.packet = AppendArtDMX(p.packet[:0], p.universe, p.sequence, p.slots[:])
+	default:
+		p.packet = AppendSACN(p.packet[:0], p.source, p.universe, p.sequence, terminated, p.slots[:])
+	}
+	p.counters.Record(len(p.packet), p.sender.Send(p.packet)) // Errors are logged by the sender.
+}
+
+// Stats returns the number of packets sent and failed so far. It is safe to call while
+// the publisher runs.
+func (p *Publisher) Stats() transport.Stats {
+	return p.counters.Load()
+}
+
+// Close implements the io.Closer interface. It stops the publisher goroutine; with sACN
+// it then sends the stream termination packets so receivers release the universe
+// immediately.
+func (p *Publisher) Close() error {
+	fmt.Printf("DMXPublisher: Close called, stopping publisher...\n")
+	err := p.Stop()
+	if p.protocol == SACN {
+		for range 3 { // E1.31 asks for three terminated packets.
+			p.send(true)
+		}
+	}
+	return err
+}
+
+// Ensure Publisher satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Publisher)(nil)
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: MIT
+package local
+
+import (
+	"audio/pkg/protocol"
+	"audio/pkg/shm"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return 48000 }
+
+// checkPacket verifies a spectrum packet sent for spectrum{0, 1, 2, 3, 4}.
+func checkPacket(t *testing.T, packet []byte) {
+	t.Helper()
+	header, payload, err := protocol.ParseHeader(packet)
+	if err != nil {
+		t.Fatalf("ParseHeader error: %v", err)
+	}
+	if header.Type != protocol.MessageSpectrum || header.Channel != 7 || header.FFTSize != 8 {
+		t.Errorf("header = %+v", header)
+	}
+	if mags, err := protocol.ParseSpectrum(payload, nil); err != nil || len(mags) != 5 || mags[4] != 4 {
+		t.Errorf("ParseSpectrum = %v, %v", mags, err)
+	}
+}
+
+func TestDatagramSender(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "consumer.sock")
+	sender, err := NewDatagramSender(path, false)
+	if err != nil {
+		t.Fatalf("NewDatagramSender error: %v", err)
+	}
+	defer sender.Close()
+
+	// Nobody is listening yet, the packet is dropped.
+	if err := sender.Send([]byte("lost")); err == nil {
+		t.Error("Send without a consumer succeeded")
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("ListenUnixgram error: %v", err)
+	}
+	defer conn.Close()
+	sender.nextDial = time.Time{} // Skip the reconnect delay.
+
+	publisher, err := NewSocketPublisher(time.Millisecond, sender, spectrum{0, 1, 2, 3, 4}, 7)
+	if err != nil {
+		t.Fatalf("NewSocketPublisher error: %v", err)
+	}
+	publisher.publish()
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	checkPacket(t, buf[:n])
+}
+
+func TestStreamServer(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "engine.sock")
+	server, err := NewStreamServer(path, 1)
+	if err != nil {
+		t.Fatalf("NewStreamServer error: %v", err)
+	}
+	defer server.Close()
+	publisher, err := NewSocketPublisher(time.Millisecond, server, spectrum{0, 1, 2, 3, 4}, 7)
+	if err != nil {
+		t.Fatalf("NewSocketPublisher error: %v", err)
+	}
+	publisher.Start()
+	defer publisher.Close()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// Packets follow each other on the stream, delimited by their header.
+	for range 3 {
+		packet := make([]byte, protocol.HeaderSize)
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			t.Fatalf("read header: %v", err)
+		}
+		payloadLen := binary.BigEndian.Uint16(packet[protocol.HeaderSize-2:])
+		packet = append(packet, make([]byte, payloadLen)...)
+		if _, err := io.ReadFull(conn, packet[protocol.HeaderSize:]); err != nil {
+			t.Fatalf("read payload: %v", err)
+		}
+		checkPacket(t, packet)
+	}
+
+	// A second consumer exceeds the limit and is disconnected.
+	second, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer second.Close()
+	_ = second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("second consumer read error = %v, want EOF", err)
+	}
+}
+
+func TestSharedMemoryPublisher(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "phase4.shm")
+	publisher, err := NewSharedMemoryPublisher(path, time.Millisecond, spectrum{0, 1, 2, 3, 4}, SharedMemoryOptions{Slots: 4, Channel: 7})
+	if err != nil {
+		t.Fatalf("NewSharedMemoryPublisher error: %v", err)
+	}
+	publisher.publish()
+
+	r, err := shm.Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer r.Close()
+	if info := r.Info(); info.FFTSize != 8 || info.Channel != 7 || info.Slots != 4 {
+		t.Errorf("Info = %+v", info)
+	}
+	frame, err := r.ReadLatest(nil)
+	if err != nil || frame.Number != 1 || len(frame.Magnitudes) != 5 || frame.Magnitudes[4] != 4 {
+		t.Errorf("ReadLatest = %+v, %v", frame, err)
+	}
+
+	if err := publisher.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if !r.Closed() {
+		t.Error("reader doesn't see the buffer closed")
+	}
+}
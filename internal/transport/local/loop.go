@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+
+// Package local provides transports for consumers on the same host: Unix domain sockets
+// carrying the UDP packet format, and a shared-memory ring buffer (see audio/pkg/shm).
+package local
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// loop calls a function on every tick of a ticker between Start and Stop.
+type loop struct {
+	name     string        // Name used in log messages.
+	interval time.Duration // Interval between calls.
+	tick     func()        // Function called on every tick.
+
+	ticker   *time.Ticker   // Ticker that triggers calls.
+	doneChan chan struct{}  // Channel used to signal the goroutine to stop.
+	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
+	wg       sync.WaitGroup // Waits for the goroutine to finish during Stop.
+	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
+}
+
+// Start begins calling tick. It is safe to call Start multiple times; subsequent calls
+// are no-ops if already started.
+func (l *loop) Start() {
+	l.mu.Lock()
+	if l.ticker != nil {
+		l.mu.Unlock()
+		fmt.Printf("%s: Start called but already running.\n", l.name)
+		return
+	}
+
+	l.ticker = time.NewTicker(l.interval)
+	l.doneChan = make(chan struct{})
+	l.stopOnce = sync.Once{}
+
+	// Capture local variables for the goroutine to avoid data races on l.ticker/l.doneChan
+	ticker := l.ticker
+	doneChan := l.doneChan
+
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		for {
+			select {
+			case <-ticker.C:
+				l.tick()
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops calling tick and waits for a call in progress to finish. It is safe to
+// call Stop multiple times; subsequent calls are no-ops.
+func (l *loop) Stop() error {
+	l.mu.Lock()
+	if l.ticker == nil {
+		l.mu.Unlock()
+		return nil
+	}
+
+	l.stopOnce.Do(func() {
+		close(l.doneChan)
+		l.ticker.Stop()
+		l.ticker = nil
+	})
+
+	l.mu.Unlock()
+	l.wg.Wait()
+	return nil
+}
+
+// defaultInterval returns interval, or 16ms (~60Hz) if it is invalid.
+func defaultInterval(name string, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		interval = 16 * time.Millisecond // Default to ~60Hz if invalid
+		fmt.Printf("%s: Invalid interval provided, defaulting to %s\n", name, interval)
+	}
+	return interval
+}
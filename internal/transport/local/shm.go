@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: MIT
+package local
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport"
+	"audio/pkg/shm"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultSharedMemoryPath returns the default location of the shared-memory buffer:
+// /dev/shm/phase4 where /dev/shm exists (Linux), otherwise a file in the temp directory.
+func DefaultSharedMemoryPath() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		return "/dev/shm/phase4"
+	}
+	return filepath.Join(os.TempDir(), "phase4.shm")
+}
+
+// SharedMemoryOptions holds settings for the shared-memory buffer.
+type SharedMemoryOptions struct {
+	Slots   int    // Number of spectra kept in the ring (<= 0 uses shm.DefaultSlots).
+	Channel uint16 // Channel ID written to the buffer header.
+}
+
+// SharedMemoryPublisher periodically writes the spectrum to a shared-memory ring buffer
+// that readers on the same host map with audio/pkg/shm.
+type SharedMemoryPublisher struct {
+	loop
+	writer  *shm.Writer                // Buffer writer.
+	fftProc analysis.FFTResultProvider // The spectrum provider to fetch magnitude data from.
+
+	magBuffer []float64 // Buffer to receive float64 magnitudes.
+	f32Buffer []float32 // Buffer to hold float32 magnitudes for the buffer.
+
+	counters transport.Counters // Spectra written, for the metrics endpoint.
+}
+
+// NewSharedMemoryPublisher creates the buffer at path (empty uses
+// DefaultSharedMemoryPath) and a publisher writing to it every interval. If the
+// provided interval is invalid (<= 0), it defaults to 16ms (~60Hz).
+func NewSharedMemoryPublisher(path string, interval time.Duration, fftProc analysis.FFTResultProvider, options SharedMemoryOptions) (*SharedMemoryPublisher, error) {
+	if fftProc == nil {
+		return nil, fmt.Errorf("SharedMemoryPublisher: FFT processor cannot be nil")
+	}
+	if path == "" {
+		path = DefaultSharedMemoryPath()
+	}
+
+	bins := fftProc.GetFFTSize()/2 + 1
+	writer, err := shm.Create(path, shm.WriterOptions{
+		Slots:      options.Slots,
+		MaxBins:    bins,
+		SampleRate: fftProc.GetSampleRate(),
+		FFTSize:    fftProc.GetFFTSize(),
+		Channel:    options.Channel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SharedMemoryPublisher: %w", err)
+	}
+
+	p := &SharedMemoryPublisher{
+		writer:    writer,
+		fftProc:   fftProc,
+		magBuffer: make([]float64, bins),
+		f32Buffer: make([]float32, bins),
+	}
+	p.loop = loop{name: "SharedMemoryPublisher", interval: defaultInterval("SharedMemoryPublisher", interval), tick: p.publish}
+	fmt.Printf("SharedMemoryPublisher: Writing to %s (Interval: %s, FFT Bins: %d, Slots: %d)\n",
+		path, p.interval, bins, options.Slots)
+	return p, nil
+}
+
+// publish writes the latest spectrum to the buffer.
+func (p *SharedMemoryPublisher) publish() {
+	if err := p.fftProc.GetMagnitudesInto(p.magBuffer); err != nil {
+		return // Skip this update
+	}
+	for i, v := range p.magBuffer {
+		p.f32Buffer[i] = float32(v)
+	}
+	_, err := p.writer.Write(time.Now().UnixNano(), p.f32Buffer)
+	p.counters.Record(4*len(p.f32Buffer), err)
+	if err != nil {
+		fmt.Printf("SharedMemoryPublisher: Write error: %v\n", err)
+	}
+}
+
+// Stats returns the number of spectra written and failed so far. Bytes counts the
+// magnitudes only. It is safe to call while the publisher runs.
+func (p *SharedMemoryPublisher) Stats() transport.Stats {
+	return p.counters.Load()
+}
+
+// Close implements the io.Closer interface. It stops the publisher and removes the buffer.
+func (p *SharedMemoryPublisher) Close() error {
+	fmt.Printf("SharedMemoryPublisher: Close called, stopping publisher...\n")
+	_ = p.Stop()
+	return p.writer.Close()
+}
+
+// Ensure SharedMemoryPublisher satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*SharedMemoryPublisher)(nil)
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+package local
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport"
+	"audio/pkg/protocol"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Sender delivers packets to local consumers.
+type Sender interface {
+	Send(packet []byte) error
+	Close() error
+}
+
+// SocketPublisher periodically sends the spectrum to a Unix domain socket Sender, as the
+// same versioned packets UDP carries (see audio/pkg/protocol). Unix sockets carry far
+// larger datagrams than a network, so messages are only fragmented when their payload
+// exceeds the header's 64 KiB payload length field (spectra of more than 16383 bins).
+type SocketPublisher struct {
+	loop
+	sender  Sender                     // Where packets are sent.
+	fftProc analysis.FFTResultProvider // The spectrum provider to fetch magnitude data from.
+	channel uint16                     // Channel ID written to the header.
+
+	sequence      uint32    // Sequence number of the latest message.
+	magBuffer     []float64 // Buffer to receive float64 magnitudes.
+	f32Buffer     []float32 // Buffer to hold float32 magnitudes for packing.
+	payloadBuffer []byte    // Reusable buffer for the message payload (fragmented messages only).
+	packetBuffer  []byte    // Reusable buffer for constructing packets.
+
+	counters transport.Counters // Packets sent, for the metrics endpoint.
+}
+
+// NewSocketPublisher creates a publisher sending the spectrum to sender every interval.
+// If the provided interval is invalid (<= 0), it defaults to 16ms (~60Hz).
+func NewSocketPublisher(interval time.Duration, sender Sender, fftProc analysis.FFTResultProvider, channel uint16) (*SocketPublisher, error) {
+	if sender == nil {
+		return nil, fmt.Errorf("SocketPublisher: sender cannot be nil")
+	}
+	if fftProc == nil {
+		return nil, fmt.Errorf("SocketPublisher: FFT processor cannot be nil")
+	}
+
+	bins := fftProc.GetFFTSize()/2 + 1
+	p := &SocketPublisher{
+		sender:       sender,
+		fftProc:      fftProc,
+		channel:      channel,
+		magBuffer:    make([]float64, bins),
+		f32Buffer:    make([]float32, bins),
+		packetBuffer: make([]byte, 0, protocol.HeaderSize+min(protocol.SpectrumPayloadSize(bins), math.MaxUint16)),
+	}
+	p.loop = loop{name: "SocketPublisher", interval: defaultInterval("SocketPublisher", interval), tick: p.publish}
+	fmt.Printf("SocketPublisher: Initializing (Interval: %s, FFT Bins: %d, Channel: %d)\n", p.interval, bins, channel)
+	return p, nil
+}
+
+// publish sends the latest spectrum as one packet, or as fragments if it is too large.
+func (p *SocketPublisher) publish() {
+	if err := p.fftProc.GetMagnitudesInto(p.magBuffer); err != nil {
+		return // Skip this update
+	}
+	for i, v := range p.magBuffer {
+		p.f32Buffer[i] = float32(v)
+	}
+
+	p.sequence++
+	header := protocol.Header{
+		Type:       protocol.MessageSpectrum,
+		Sequence:   p.sequence,
+		Timestamp:  time.Now().UnixNano(),
+		SampleRate: uint32(p.fftProc.GetSampleRate()),
+		FFTSize:    uint32(p.fftProc.GetFFTSize()),
+		Channel:    p.channel,
+	}
+
+	payloadLen := protocol.SpectrumPayloadSize(len(p.f32Buffer))
+	if payloadLen <= math.MaxUint16 {
+		header.PayloadLength = uint16(payloadLen)
+		p.packetBuffer = protocol.AppendHeader(p.packetBuffer[:0], header)
+		p.packetBuffer = protocol.AppendSpectrum(p.packetBuffer, p.f32Buffer)
+		p.counters.Record(len(p.packetBuffer), p.sender.Send(p.packetBuffer))
+		return
+	}
+
+	p.payloadBuffer = protocol.AppendSpectrum(p.payloadBuffer[:0], p.f32Buffer)
+	chunkSize := math.MaxUint16 - protocol.FragmentHeaderSize
+	count := (len(p.payloadBuffer) + chunkSize - 1) / chunkSize
+	for i := range count {
+		chunk := p.payloadBuffer[i*chunkSize : min((i+1)*chunkSize, len(p.payloadBuffer))]
+		p.packetBuffer = protocol.AppendFragment(p.packetBuffer[:0], header, protocol.FragmentHeader{
+			FrameID:     p.sequence,
+			Index:       uint16(i),
+			Count:       uint16(count),
+			TotalLength: uint32(len(p.payloadBuffer)),
+		}, chunk)
+		p.counters.Record(len(p.packetBuffer), p.sender.Send(p.packetBuffer))
+	}
+}
+
+// Stats returns the number of packets sent and failed so far. It is safe to call while
+// the publisher runs.
+func (p *SocketPublisher) Stats() transport.Stats {
+	return p.counters.Load()
+}
+
+// Close implements the io.Closer interface. It stops the publisher; the sender is
+// closed by its owner.
+func (p *SocketPublisher) Close() error {
+	fmt.Printf("SocketPublisher: Close called, stopping publisher...\n")
+	return p.Stop()
+}
+
+// Ensure SocketPublisher satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*SocketPublisher)(nil)
@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: MIT
+package local
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Timing and buffering for Unix domain sockets.
+const (
+	datagramWriteTimeout = time.Millisecond // Maximum time a consumer with a full receive queue can block a send.
+	streamWriteTimeout   = time.Second      // Maximum time a stalled stream consumer can block its writer.
+	reconnectDelay       = time.Second      // Minimum time between connection attempts.
+	streamClientBuffer   = 16               // Packets queued per stream consumer before packets are dropped.
+)
+
+// DatagramSender sends packets as datagrams to a consumer's "unixgram" socket. The
+// consumer binds the socket and can be started and restarted independently of the
+// engine: the sender connects on the first send and reconnects after errors, at most
+// once a second. Packets sent while no consumer is listening, or while its receive
+// queue is full, are dropped.
+type DatagramSender struct {
+	path     string        // Path of the consumer's socket.
+	debug    bool          // Log connection attempts and errors.
+	mu       sync.Mutex    // Protects the fields below.
+	conn     *net.UnixConn // Current connection, nil while disconnected.
+	nextDial time.Time     // Earliest time of the next connection attempt.
+	closed   bool          // Whether Close has been called.
+}
+
+// NewDatagramSender creates a sender for the socket at path. No connection is made
+// until the first packet is sent.
+func NewDatagramSender(path string, debug bool) (*DatagramSender, error) {
+	if path == "" {
+		return nil, fmt.Errorf("unix socket path cannot be empty")
+	}
+	fmt.Printf("Unix Datagram Sender: Sending to %s (Debug logging: %v)\n", path, debug)
+	return &DatagramSender{path: path, debug: debug}, nil
+}
+
+// Send writes packet as one datagram, connecting first if needed. It is safe for concurrent use.
+func (s *DatagramSender) Send(packet []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("unix datagram sender is closed")
+	}
+	if s.conn == nil {
+		if time.Now().Before(s.nextDial) {
+			return fmt.Errorf("unix datagram sender not connected to %s", s.path)
+		}
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: s.path, Net: "unixgram"})
+		if err != nil {
+			s.nextDial = time.Now().Add(reconnectDelay)
+			if s.debug {
+				fmt.Printf("Unix Datagram Sender: Connect to %s failed: %v\n", s.path, err)
+			}
+			return fmt.Errorf("failed to connect to unix socket %s: %w", s.path, err)
+		}
+		fmt.Printf("Unix Datagram Sender: Connected to %s\n", s.path)
+		s.conn = conn
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(datagramWriteTimeout))
+	if _, err := s.conn.Write(packet); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("unix socket %s is full, packet dropped", s.path) // Slow consumer, keep the connection.
+		}
+		// The consumer went away; its next socket is a new file, so connect again.
+		if s.debug {
+			fmt.Printf("Unix Datagram Sender: Send to %s failed, reconnecting: %v\n", s.path, err)
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+		s.nextDial = time.Now().Add(reconnectDelay)
+		return fmt.Errorf("failed to send unix datagram: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection. Further sends fail. It is safe to call multiple times.
+func (s *DatagramSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	fmt.Printf("Unix Datagram Sender: Closing connection to %s\n", s.path)
+	err := s.conn.Close()
+	s.conn = nil
+	if err != nil {
+		return fmt.Errorf("failed to close unix datagram connection: %w", err)
+	}
+	return nil
+}
+
+// StreamServer listens on a "unix" stream socket and sends every packet to all
+// connected consumers. Packets are self-delimiting on the stream: consumers read the
+// 32 byte header, then PayloadLength bytes of payload. Each consumer has its own queue
+// and writer, so a slow consumer drops packets without holding up the others.
+type StreamServer struct {
+	path       string                     // Path of the listening socket.
+	maxClients int                        // Maximum number of consumers (<= 0 for no limit).
+	listener   *net.UnixListener          // Listening socket.
+	mu         sync.Mutex                 // Protects clients and closed.
+	clients    map[*streamClient]struct{} // Connected consumers.
+	closed     bool                       // Whether Close has been called.
+	wg         sync.WaitGroup             // Waits for the accept loop and writers during Close.
+}
+
+// streamClient is a consumer connected to a StreamServer.
+type streamClient struct {
+	conn  *net.UnixConn // Connection to the consumer.
+	queue chan []byte   // Packets waiting to be written.
+	done  chan struct{} // Closed when the consumer is removed.
+}
+
+// NewStreamServer listens on path, replacing a stale socket left by a previous run.
+func NewStreamServer(path string, maxClients int) (*StreamServer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("unix socket path cannot be empty")
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		_ = os.Remove(path)
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %s: %w", path, err)
+	}
+
+	s := &StreamServer{
+		path:       path,
+		maxClients: maxClients,
+		listener:   listener,
+		clients:    make(map[*streamClient]struct{}),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	fmt.Printf("Unix Stream Server: Listening on %s (Max clients: %d)\n", path, maxClients)
+	return s, nil
+}
+
+// Addr returns the address of the listening socket.
+func (s *StreamServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// acceptLoop accepts consumers until the listener is closed.
+func (s *StreamServer) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("Unix Stream Server: Accept error: %v\n", err)
+			}
+			return
+		}
+
+		c := &streamClient{conn: conn, queue: make(chan []byte, streamClientBuffer), done: make(chan struct{})}
+		s.mu.Lock()
+		if s.closed || (s.maxClients > 0 && len(s.clients) >= s.maxClients) {
+			s.mu.Unlock()
+			fmt.Printf("Unix Stream Server: Rejecting consumer, too many clients\n")
+			_ = conn.Close()
+			continue
+		}
+		s.clients[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		fmt.Printf("Unix Stream Server: Consumer connected on %s\n", s.path)
+		go s.writeLoop(c)
+	}
+}
+
+// writeLoop writes queued packets to c until it is removed or a write fails.
+func (s *StreamServer) writeLoop(c *streamClient) {
+	defer s.wg.Done()
+	defer s.remove(c)
+	for {
+		select {
+		case <-c.done:
+			return
+		case packet := <-c.queue:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := c.conn.Write(packet); err != nil {
+				fmt.Printf("Unix Stream Server: Consumer disconnected: %v\n", err)
+				return
+			}
+		}
+	}
+}
+
+// remove disconnects c. It is safe to call multiple times.
+func (s *StreamServer) remove(c *streamClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	delete(s.clients, c)
+	close(c.done)
+	_ = c.conn.Close()
+}
+
+// Send queues packet for every connected consumer, dropping it for consumers whose
+// queue is full. It is safe for concurrent use.
+func (s *StreamServer) Send(packet []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("unix stream server is closed")
+	}
+	if len(s.clients) == 0 {
+		return nil
+	}
+
+	// Copied once, the same bytes are shared by every consumer's queue.
+	packet = bytes.Clone(packet)
+	for c := range s.clients {
+		select {
+		case c.queue <- packet:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close stops accepting consumers, disconnects them and removes the socket file.
+// It is safe to call multiple times.
+func (s *StreamServer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	fmt.Printf("Unix Stream Server: Closing %s\n", s.path)
+	err := s.listener.Close() // Also removes the socket file.
+	s.mu.Lock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c.done)
+		_ = c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to close unix socket %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Ensure the senders satisfy the Sender interface at compile time.
+var (
+	_ Sender = (*DatagramSender)(nil)
+	_ Sender = (*StreamServer)(nil)
+)
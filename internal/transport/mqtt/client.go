@@ -0,0 +1,306 @@
+// SPDX-License-Identifier: MIT
+package mqtt
+
+import (
+	"audio/internal/transport"
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timing for broker connections.
+const (
+	dialTimeout      = 2 * time.Second  // Maximum time spent connecting, including the CONNACK.
+	writeTimeout     = time.Second      // Maximum time a stalled broker can block a publish.
+	reconnectDelay   = 2 * time.Second  // Minimum time between connection attempts.
+	defaultKeepAlive = 30 * time.Second // Keep alive used when ClientOptions.KeepAlive is zero.
+)
+
+// ClientOptions holds settings for the broker connection.
+type ClientOptions struct {
+	ProtocolVersion byte          // ProtocolV311 (default) or ProtocolV5.
+	ClientID        string        // Client identifier, unique per broker.
+	Username        string        // Optional user name.
+	Password        string        // Optional password (requires a user name with MQTT 3.1.1).
+	KeepAlive       time.Duration // Maximum time between packets, pinged when idle (0 uses 30s).
+	Will            *Message      // Optional message the broker publishes if the client disappears.
+	Debug           bool          // Log connection attempts and errors.
+}
+
+// Client is a publish-only MQTT client. The connection is made on the first publish and
+// re-established after errors, at most once every couple of seconds, so the broker can
+// be restarted independently of the engine. Messages published while disconnected are
+// dropped; QoS 1 messages are acknowledged by the broker but not resent after a
+// reconnect.
+type Client struct {
+	address   string        // Broker host and port.
+	options   ClientOptions // Connection settings.
+	onConnect func() []Message
+
+	mu        sync.Mutex  // Protects the fields below.
+	conn      *connection // Current connection, nil while disconnected.
+	nextDial  time.Time   // Earliest time of the next connection attempt.
+	packetID  uint16      // Identifier of the latest QoS 1 message.
+	buf       []byte      // Reusable buffer for encoded packets.
+	closed    bool        // Whether Close has been called.
+	published transport.Counters
+	acked     atomic.Uint64
+}
+
+// connection is one network connection to the broker with its reader and pinger.
+type connection struct {
+	conn      net.Conn
+	done      chan struct{} // Closed when the connection is dropped.
+	lastWrite time.Time     // Time of the latest packet sent, protected by Client.mu.
+	wg        sync.WaitGroup
+}
+
+// NewClient creates a client for the broker at address ("host:port"). No connection is
+// made until the first publish.
+func NewClient(address string, options ClientOptions) (*Client, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker address '%s': %w", address, err)
+	}
+	switch options.ProtocolVersion {
+	case 0:
+		options.ProtocolVersion = ProtocolV311
+	case ProtocolV311, ProtocolV5:
+	default:
+		return nil, fmt.Errorf("unsupported MQTT protocol version %d (use 4 for 3.1.1 or 5)", options.ProtocolVersion)
+	}
+	if options.KeepAlive <= 0 {
+		options.KeepAlive = defaultKeepAlive
+	}
+	if options.Password != "" && options.Username == "" && options.ProtocolVersion == ProtocolV311 {
+		return nil, fmt.Errorf("MQTT 3.1.1 requires a user name with a password")
+	}
+	if options.Will != nil && options.Will.QoS > 1 {
+		return nil, fmt.Errorf("MQTT will QoS %d is not supported", options.Will.QoS)
+	}
+	fmt.Printf("MQTT Client: Publishing to %s (Client ID: %q, Protocol: %d)\n", address, options.ClientID, options.ProtocolVersion)
+	return &Client{address: address, options: options}, nil
+}
+
+// OnConnect sets a function returning messages to publish right after every successful
+// connection, before any other message (e.g. availability and discovery messages).
+func (c *Client) OnConnect(f func() []Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onConnect = f
+}
+
+// Publish sends messages, connecting first if needed. It is safe for concurrent use.
+func (c *Client) Publish(messages ...Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("MQTT client is closed")
+	}
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			for range messages {
+				c.published.Drop()
+			}
+			return err
+		}
+	}
+	for _, m := range messages {
+		if err := c.publish(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the number of messages published, failed and dropped while disconnected,
+// and the number of QoS 1 messages acknowledged.
+func (c *Client) Stats() (stats transport.Stats, acked uint64) {
+	return c.published.Load(), c.acked.Load()
+}
+
+// connect dials the broker and waits for its CONNACK. c.mu must be held.
+func (c *Client) connect() error {
+	if time.Now().Before(c.nextDial) {
+		return fmt.Errorf("MQTT client not connected to %s", c.address)
+	}
+	c.nextDial = time.Now().Add(reconnectDelay)
+
+	conn, err := net.DialTimeout("tcp", c.address, dialTimeout)
+	if err != nil {
+		if c.options.Debug {
+			fmt.Printf("MQTT Client: Connect to %s failed: %v\n", c.address, err)
+		}
+		return fmt.Errorf("failed to connect to MQTT broker %s: %w", c.address, err)
+	}
+
+	c.buf, err = appendConnect(c.buf[:0], connectPacket{
+		version:   c.options.ProtocolVersion,
+		clientID:  c.options.ClientID,
+		username:  c.options.Username,
+		password:  c.options.Password,
+		keepAlive: uint16(min(c.options.KeepAlive/time.Second, 0xffff)),
+		will:      c.options.Will,
+	})
+	if err == nil {
+		_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+		if _, err = conn.Write(c.buf); err == nil {
+			reader := bufio.NewReader(conn)
+			var first byte
+			var body []byte
+			if first, body, err = readPacket(reader); err == nil {
+				err = parseConnack(first, body)
+			}
+			if err == nil {
+				_ = conn.SetDeadline(time.Time{})
+				c.conn = &connection{conn: conn, done: make(chan struct{}), lastWrite: time.Now()}
+				c.conn.wg.Add(2)
+				go c.readLoop(c.conn, reader)
+				go c.pingLoop(c.conn)
+			}
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		fmt.Printf("MQTT Client: Connect to %s failed: %v\n", c.address, err)
+		return fmt.Errorf("failed to connect to MQTT broker %s: %w", c.address, err)
+	}
+	fmt.Printf("MQTT Client: Connected to %s\n", c.address)
+
+	if c.onConnect != nil {
+		for _, m := range c.onConnect() {
+			if err := c.publish(m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// publish writes one PUBLISH packet. c.mu must be held and c.conn set.
+func (c *Client) publish(m Message) error {
+	var id uint16
+	if m.QoS > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1 // Zero is not a valid packet identifier.
+		}
+		id = c.packetID
+	}
+	var err error
+	c.buf, err = appendPublish(c.buf[:0], c.options.ProtocolVersion, m, id)
+	if err != nil {
+		return err
+	}
+	err = c.write(c.buf)
+	c.published.Record(len(c.buf), err)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", m.Topic, err)
+	}
+	return nil
+}
+
+// write sends an encoded packet, dropping the connection on errors. c.mu must be held
+// and c.conn set.
+func (c *Client) write(packet []byte) error {
+	conn := c.conn
+	_ = conn.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.conn.Write(packet); err != nil {
+		// A partial write leaves the stream out of sync, so always start over.
+		fmt.Printf("MQTT Client: Send to %s failed, reconnecting: %v\n", c.address, err)
+		c.drop(conn)
+		return err
+	}
+	conn.lastWrite = time.Now()
+	return nil
+}
+
+// drop closes conn if it is still the current connection. c.mu must be held.
+func (c *Client) drop(conn *connection) {
+	if c.conn != conn {
+		return
+	}
+	c.conn = nil
+	close(conn.done)
+	_ = conn.conn.Close()
+}
+
+// readLoop handles packets from the broker until the connection is dropped.
+func (c *Client) readLoop(conn *connection, r *bufio.Reader) {
+	defer conn.wg.Done()
+	for {
+		first, body, err := readPacket(r)
+		if err == nil {
+			switch first >> 4 {
+			case packetPuback:
+				if len(body) >= 2 && binary.BigEndian.Uint16(body) != 0 {
+					c.acked.Add(1)
+				}
+				continue
+			case packetPingresp:
+				continue
+			case packetDisconnect:
+				err = fmt.Errorf("disconnected by broker")
+			default:
+				err = fmt.Errorf("unexpected packet type %d", first>>4)
+			}
+		}
+
+		c.mu.Lock()
+		if c.conn == conn {
+			fmt.Printf("MQTT Client: Connection to %s lost: %v\n", c.address, err)
+			c.drop(conn)
+		}
+		c.mu.Unlock()
+		return
+	}
+}
+
+// pingLoop sends PINGREQ packets when nothing else was sent for half the keep alive.
+func (c *Client) pingLoop(conn *connection) {
+	defer conn.wg.Done()
+	ticker := time.NewTicker(c.options.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.done:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			if c.conn == conn && now.Sub(conn.lastWrite) >= c.options.KeepAlive/2 {
+				_ = c.write([]byte{packetPingreq << 4, 0})
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Close disconnects from the broker. Further publishes fail. It is safe to call
+// multiple times.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	if conn != nil {
+		fmt.Printf("MQTT Client: Disconnecting from %s\n", c.address)
+		_ = c.write([]byte{packetDisconnect << 4, 0}) // A clean disconnect discards the will.
+		c.drop(conn)
+	}
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.wg.Wait()
+	}
+	return nil
+}
+
+// Ensure Client satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Client)(nil)
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Home Assistant MQTT discovery: every entity is announced with a retained config
+// message on <discovery prefix>/<component>/<node ID>/<object ID>/config, pointing at
+// the feature's state topic and a template extracting its value from the JSON payload.
+// All entities belong to one device and share the engine's availability topic.
+
+// discoveryDevice groups the entities in Home Assistant.
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Model        string   `json:"model"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// discoveryConfig is the payload of a discovery message.
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	ValueTemplate     string          `json:"value_template"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+// discoveryEntity describes one entity derived from a feature.
+type discoveryEntity struct {
+	feature   string // Feature providing the state.
+	component string // "sensor" or "binary_sensor".
+	objectID  string // Entity ID within the device.
+	config    discoveryConfig
+}
+
+// discoveryMessages returns the discovery messages of the enabled features.
+func (p *Publisher) discoveryMessages() []Message {
+	var entities []discoveryEntity
+	measurement := func(feature, objectID, name, template, unit, icon string) discoveryEntity {
+		return discoveryEntity{feature: feature, component: "sensor", objectID: objectID, config: discoveryConfig{
+			Name: name, ValueTemplate: template, UnitOfMeasurement: unit, StateClass: "measurement", Icon: icon,
+		}}
+	}
+
+	entities = append(entities,
+		measurement(FeatureLevel, "rms_level", "RMS level", "{{ value_json.rms }}", "dB", "mdi:volume-high"),
+		measurement(FeatureLevel, "peak_level", "Peak level", "{{ value_json.peak }}", "dB", "mdi:volume-high"),
+	)
+	if levels := p.features.Levels; levels != nil {
+		edges := levels.BandEdges()
+		for i := range levels.BandCount() {
+			entities = append(entities, measurement(FeatureBands,
+				fmt.Sprintf("band_%d", i),
+				fmt.Sprintf("Band %g-%g Hz", edges[i], edges[i+1]),
+				fmt.Sprintf("{{ value_json.levels[%d] }}", i),
+				"dB", "mdi:equalizer"))
+		}
+	}
+	entities = append(entities,
+		measurement(FeatureHPSS, "harmonic_energy", "Harmonic energy", "{{ value_json.harmonic }}", "", "mdi:music-note"),
+		measurement(FeatureHPSS, "percussive_energy", "Percussive energy", "{{ value_json.percussive }}", "", "mdi:drum"),
+		discoveryEntity{feature: FeatureVAD, component: "binary_sensor", objectID: "speech", config: discoveryConfig{
+			Name: "Speech", ValueTemplate: "{{ 'ON' if value_json.speaking else 'OFF' }}", Icon: "mdi:account-voice",
+		}},
+		measurement(FeatureVAD, "speech_probability", "Speech probability", "{{ (value_json.probability * 100) | round(0) }}", "%", "mdi:account-voice"),
+		discoveryEntity{feature: FeatureChord, component: "sensor", objectID: "chord", config: discoveryConfig{
+			Name: "Chord", ValueTemplate: "{{ value_json.label }}", Icon: "mdi:music",
+		}},
+		measurement(FeatureTempo, "bpm", "Tempo", "{{ value_json.bpm }}", "BPM", "mdi:metronome"),
+	)
+
+	device := discoveryDevice{
+		Identifiers:  []string{p.options.NodeID},
+		Name:         "Phase4 (" + p.options.NodeID + ")",
+		Model:        "Audio analysis engine",
+		Manufacturer: "Phase4",
+	}
+	messages := make([]Message, 0, len(entities))
+	for _, e := range entities {
+		topic, ok := p.topics[e.feature]
+		if !ok {
+			continue
+		}
+		e.config.UniqueID = p.options.NodeID + "_" + e.objectID
+		e.config.StateTopic = topic
+		e.config.AvailabilityTopic = StatusTopic(p.options.Prefix)
+		e.config.Device = device
+		payload, err := json.Marshal(e.config)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, Message{
+			Topic:   strings.Join([]string{p.options.DiscoveryPrefix, e.component, p.options.NodeID, e.objectID, "config"}, "/"),
+			Payload: payload,
+			QoS:     p.options.QoS,
+			Retain:  true,
+		})
+	}
+	return messages
+}
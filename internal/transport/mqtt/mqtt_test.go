@@ -0,0 +1,362 @@
+// SPDX-License-Identifier: MIT
+package mqtt
+
+import (
+	"audio/internal/analysis"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBroker is a minimal in-process MQTT broker that accepts every connection and
+// records CONNECT and PUBLISH packets.
+type testBroker struct {
+	listener net.Listener
+	mu       sync.Mutex
+	connects []testConnect
+	messages chan Message
+	conns    []net.Conn
+}
+
+type testConnect struct {
+	version   byte
+	clientID  string
+	username  string
+	will      Message
+	keepAlive uint16
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	b := &testBroker{listener: listener, messages: make(chan Message, 256)}
+	t.Cleanup(b.close)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) addr() string { return b.listener.Addr().String() }
+
+// dropConnections disconnects every client, as a broker restart would.
+func (b *testBroker) dropConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *testBroker) close() {
+	b.listener.Close()
+	b.dropConnections()
+}
+
+// str reads a length-prefixed string from body at *off.
+func str(body []byte, off *int) string {
+	n := int(binary.BigEndian.Uint16(body[*off:]))
+	s := string(body[*off+2 : *off+2+n])
+	*off += 2 + n
+	return s
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var version byte
+	for {
+		first, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch first >> 4 {
+		case packetConnect:
+			off := 0
+			if str(body, &off) != "MQTT" {
+				return
+			}
+			c := testConnect{version: body[off]}
+			flags := body[off+1]
+			c.keepAlive = binary.BigEndian.Uint16(body[off+2:])
+			off += 4
+			if c.version == ProtocolV5 {
+				off++ // Empty properties.
+			}
+			c.clientID = str(body, &off)
+			if flags&flagWill != 0 {
+				if c.version == ProtocolV5 {
+					off++
+				}
+				c.will = Message{Topic: str(body, &off), QoS: flags >> 3 & 3, Retain: flags&flagWillRetain != 0}
+				c.will.Payload = []byte(str(body, &off))
+			}
+			if flags&flagUsername != 0 {
+				c.username = str(body, &off)
+			}
+			version = c.version
+			b.mu.Lock()
+			b.connects = append(b.connects, c)
+			b.mu.Unlock()
+			if version == ProtocolV5 {
+				conn.Write([]byte{0x20, 3, 0, 0, 0})
+			} else {
+				conn.Write([]byte{0x20, 2, 0, 0})
+			}
+		case packetPublish:
+			off := 0
+			m := Message{Topic: str(body, &off), QoS: first >> 1 & 3, Retain: first&1 != 0}
+			if m.QoS > 0 {
+				id := body[off : off+2]
+				off += 2
+				conn.Write([]byte{0x40, 2, id[0], id[1]})
+			}
+			if version == ProtocolV5 {
+				off++ // Empty properties.
+			}
+			m.Payload = body[off:]
+			b.messages <- m
+		case packetPingreq:
+			conn.Write([]byte{0xd0, 0})
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+// next returns the next published message on topic, skipping others.
+func (b *testBroker) next(t *testing.T, topic string) Message {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case m := <-b.messages:
+			if m.Topic == topic {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("no message on %s", topic)
+		}
+	}
+}
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return 48000 }
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+	for _, version := range []byte{ProtocolV311, ProtocolV5} {
+		broker := newTestBroker(t)
+		client, err := NewClient(broker.addr(), ClientOptions{
+			ProtocolVersion: version,
+			ClientID:        "phase4-test",
+			Username:        "venue",
+			Password:        "secret",
+			Will:            &Message{Topic: StatusTopic("room"), Payload: []byte(StatusOffline), QoS: 1, Retain: true},
+		})
+		if err != nil {
+			t.Fatalf("NewClient error: %v", err)
+		}
+		levels, err := analysis.NewLevelProcessor(spectrum{0, 1, 2, 3, 4}, []float64{20, 200, 2000})
+		if err != nil {
+			t.Fatalf("NewLevelProcessor error: %v", err)
+		}
+		bus := analysis.NewEventBus()
+		publisher, err := NewPublisher(5*time.Millisecond, client, analysis.Features{Levels: levels, Events: bus}, Options{
+			Prefix:    "room",
+			Topics:    map[string]string{FeatureBands: ""},
+			QoS:       1,
+			Retain:    true,
+			Discovery: true,
+			NodeID:    "hall",
+		})
+		if err != nil {
+			t.Fatalf("NewPublisher error: %v", err)
+		}
+		publisher.Start()
+
+		if m := broker.next(t, "room/status"); string(m.Payload) != StatusOnline || !m.Retain {
+			t.Errorf("status = %+v, want retained online", m)
+		}
+		m := broker.next(t, "homeassistant/sensor/hall/rms_level/config")
+		var config discoveryConfig
+		if err := json.Unmarshal(m.Payload, &config); err != nil || !m.Retain ||
+			config.StateTopic != "room/level" || config.UniqueID != "hall_rms_level" || config.AvailabilityTopic != "room/status" {
+			t.Errorf("discovery = %s (%v)", m.Payload, err)
+		}
+
+		m = broker.next(t, "room/level")
+		var level levelPayload
+		if err := json.Unmarshal(m.Payload, &level); err != nil || level.RMS != analysis.SilenceDB || m.QoS != 1 || !m.Retain {
+			t.Errorf("level = %+v %s (%v)", m, m.Payload, err)
+		}
+
+		bus.Publish(analysis.Event{Source: "vad", Name: analysis.EventSpeechStart, Value: 0.9, Time: time.Now()})
+		if m := broker.next(t, "room/event"); m.Retain || !strings.Contains(string(m.Payload), analysis.EventSpeechStart) {
+			t.Errorf("event = %+v %s", m, m.Payload)
+		}
+
+		// The client reconnects after the broker drops the connection.
+		broker.dropConnections()
+		client.mu.Lock()
+		client.nextDial = time.Time{}
+		client.mu.Unlock()
+		broker.next(t, "room/status")
+
+		if err := publisher.Close(); err != nil {
+			t.Errorf("Close error: %v", err)
+		}
+		if m := broker.next(t, "room/status"); string(m.Payload) != StatusOffline {
+			t.Errorf("status after Close = %s, want offline", m.Payload)
+		}
+		client.Close()
+
+		broker.mu.Lock()
+		c := broker.connects[0]
+		broker.mu.Unlock()
+		if c.version != version || c.clientID != "phase4-test" || c.username != "venue" || c.keepAlive != 30 ||
+			c.will.Topic != "room/status" || string(c.will.Payload) != StatusOffline || !c.will.Retain || c.will.QoS != 1 {
+			t.Errorf("connect = %+v", c)
+		}
+		if _, ok := publisher.topics[FeatureBands]; ok {
+			t.Error("bands topic not disabled")
+		}
+	}
+}
+
+// pulses is an onset envelope with a pulse every period frames at 100 frames per second.
+type pulses struct {
+	period, frame int
+}
+
+func (p *pulses) GetOnsetStrength() float64 {
+	p.frame++
+	if p.frame%p.period == 0 {
+		return 1
+	}
+	return 0
+}
+
+func (p *pulses) GetFrameRate() float64 { return 100 }
+
+func TestPublisher_Tempo(t *testing.T) {
+	t.Parallel()
+	broker := newTestBroker(t)
+	client, err := NewClient(broker.addr(), ClientOptions{})
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+	tempo, err := analysis.NewTempoProcessor(&pulses{period: 50}, 60, 180, 4*time.Second)
+	if err != nil {
+		t.Fatalf("NewTempoProcessor error: %v", err)
+	}
+	for range 800 {
+		tempo.Process(nil)
+	}
+	publisher, err := NewPublisher(5*time.Millisecond, client, analysis.Features{Tempo: tempo}, Options{
+		Prefix:    "room",
+		Discovery: true,
+		NodeID:    "hall",
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher error: %v", err)
+	}
+	publisher.Start()
+	defer publisher.Close()
+
+	m := broker.next(t, "homeassistant/sensor/hall/bpm/config")
+	var config discoveryConfig
+	if err := json.Unmarshal(m.Payload, &config); err != nil ||
+		config.StateTopic != "room/bpm" || config.UnitOfMeasurement != "BPM" || config.ValueTemplate != "{{ value_json.bpm }}" {
+		t.Errorf("discovery = %s (%v)", m.Payload, err)
+	}
+
+	// A pulse every 50 frames at 100 frames per second is 120 BPM.
+	m = broker.next(t, "room/bpm")
+	var got tempoPayload
+	if err := json.Unmarshal(m.Payload, &got); err != nil || math.Abs(got.BPM-120) > 1 || got.Confidence <= 0 {
+		t.Errorf("bpm = %s (%v), want 120", m.Payload, err)
+	}
+}
+
+func TestRemainingLength(t *testing.T) {
+	t.Parallel()
+	for n, want := range map[int][]byte{
+		0:         {0x00},
+		127:       {0x7f},
+		128:       {0x80, 0x01},
+		16383:     {0xff, 0x7f},
+		2097152:   {0x80, 0x80, 0x80, 0x01},
+		268435455: {0xff, 0xff, 0xff, 0x7f},
+	} {
+		if got := appendRemainingLength(nil, n); string(got) != string(want) {
+			t.Errorf("appendRemainingLength(%d) = %x, want %x", n, got, want)
+		}
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	t.Parallel()
+	if _, err := NewClient("127.0.0.1:1883", ClientOptions{ProtocolVersion: 3}); err == nil {
+		t.Error("NewClient accepted protocol version 3")
+	}
+	if _, err := NewClient("127.0.0.1:1883", ClientOptions{Password: "secret"}); err == nil {
+		t.Error("NewClient accepted a password without user name for MQTT 3.1.1")
+	}
+
+	// A refused connection is reported and retried only after the reconnect delay.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readPacket(bufio.NewReader(conn))
+		conn.Write([]byte{0x20, 2, 0, 5}) // Not authorized.
+	}()
+	defer listener.Close()
+	client, err := NewClient(listener.Addr().String(), ClientOptions{})
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer client.Close()
+	if err := client.Publish(Message{Topic: "a", Payload: []byte("b")}); err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("Publish error = %v, want not authorized", err)
+	}
+	if err := client.Publish(Message{Topic: "a", Payload: []byte("b")}); err == nil || !strings.Contains(err.Error(), "not connected") {
+		t.Errorf("second Publish error = %v, want not connected", err)
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: MIT
+
+// Package mqtt publishes low-rate analysis features to an MQTT broker (MQTT 3.1.1 or 5),
+// with Home Assistant discovery messages so automations can use them without a bridge.
+// Only what a publishing client needs is implemented: CONNECT, PUBLISH with QoS 0 and
+// 1, PINGREQ and DISCONNECT.
+package mqtt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Protocol versions, as sent in the CONNECT packet.
+const (
+	ProtocolV311 byte = 4 // MQTT 3.1.1.
+	ProtocolV5   byte = 5 // MQTT 5.
+)
+
+// Control packet types (high nibble of the first header byte).
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetPuback     byte = 4
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+// CONNECT flags.
+const (
+	flagCleanSession byte = 1 << 1
+	flagWill         byte = 1 << 2
+	flagWillRetain   byte = 1 << 5
+	flagPassword     byte = 1 << 6
+	flagUsername     byte = 1 << 7
+)
+
+// maxRemainingLength is the largest packet body the variable length encoding allows.
+const maxRemainingLength = 268_435_455
+
+// Message is an application message to publish.
+type Message struct {
+	Topic   string // Topic name, without wildcards.
+	Payload []byte // Message body.
+	QoS     byte   // Quality of service: 0 (at most once) or 1 (at least once).
+	Retain  bool   // Ask the broker to keep the message for new subscribers.
+}
+
+// connectPacket holds the fields of a CONNECT packet.
+type connectPacket struct {
+	version   byte
+	clientID  string
+	username  string
+	password  string
+	keepAlive uint16 // Seconds.
+	will      *Message
+}
+
+// appendRemainingLength appends n in the variable length encoding.
+func appendRemainingLength(dst []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+		if n == 0 {
+			return dst
+		}
+	}
+}
+
+// appendString appends a UTF-8 string or binary data with its uint16 length.
+func appendString(dst []byte, s string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...)
+}
+
+// appendBytes appends binary data with its uint16 length.
+func appendBytes(dst []byte, b []byte) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(b)))
+	return append(dst, b...)
+}
+
+// appendPacket appends a packet with the given first header byte and body.
+func appendPacket(dst []byte, first byte, body []byte) []byte {
+	dst = append(dst, first)
+	dst = appendRemainingLength(dst, len(body))
+	return append(dst, body...)
+}
+
+// appendConnect appends a CONNECT packet. Sessions are always clean: a publisher has
+// no subscriptions to resume.
+func appendConnect(dst []byte, p connectPacket) ([]byte, error) {
+	if len(p.clientID) > math.MaxUint16 || len(p.username) > math.MaxUint16 || len(p.password) > math.MaxUint16 {
+		return dst, errors.New("mqtt: connect field too long")
+	}
+	flags := flagCleanSession
+	if p.will != nil {
+		flags |= flagWill | p.will.QoS<<3
+		if p.will.Retain {
+			flags |= flagWillRetain
+		}
+	}
+	if p.username != "" {
+		flags |= flagUsername
+	}
+	if p.password != "" {
+		flags |= flagPassword
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, p.version, flags)
+	body = binary.BigEndian.AppendUint16(body, p.keepAlive)
+	if p.version == ProtocolV5 {
+		body = append(body, 0) // No properties.
+	}
+	body = appendString(body, p.clientID)
+	if p.will != nil {
+		if p.version == ProtocolV5 {
+			body = append(body, 0) // No will properties.
+		}
+		body = appendString(body, p.will.Topic)
+		body = appendBytes(body, p.will.Payload)
+	}
+	if p.username != "" {
+		body = appendString(body, p.username)
+	}
+	if p.password != "" {
+		body = appendString(body, p.password)
+	}
+	return appendPacket(dst, packetConnect<<4, body), nil
+}
+
+// appendPublish appends a PUBLISH packet. id is only sent for QoS 1.
+func appendPublish(dst []byte, version byte, m Message, id uint16) ([]byte, error) {
+	if len(m.Topic) == 0 || len(m.Topic) > math.MaxUint16 {
+		return dst, fmt.Errorf("mqtt: invalid topic length %d", len(m.Topic))
+	}
+	if m.QoS > 1 {
+		return dst, fmt.Errorf("mqtt: QoS %d is not supported", m.QoS)
+	}
+	size := 2 + len(m.Topic) + len(m.Payload)
+	if m.QoS > 0 {
+		size += 2
+	}
+	if version == ProtocolV5 {
+		size++
+	}
+	if size > maxRemainingLength {
+		return dst, fmt.Errorf("mqtt: message too large (%d bytes)", size)
+	}
+
+	first := packetPublish<<4 | m.QoS<<1
+	if m.Retain {
+		first |= 1
+	}
+	dst = append(dst, first)
+	dst = appendRemainingLength(dst, size)
+	dst = appendString(dst, m.Topic)
+	if m.QoS > 0 {
+		dst = binary.BigEndian.AppendUint16(dst, id)
+	}
+	if version == ProtocolV5 {
+		dst = append(dst, 0) // No properties.
+	}
+	return append(dst, m.Payload...), nil
+}
+
+// readPacket reads one packet and returns its first header byte and body.
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, nil, err
+	}
+	first := b[0]
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length += int(b[0]&0x7f) * multiplier
+		if b[0]&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return first, body, nil
+}
+
+// parseConnack returns an error unless body is a CONNACK accepting the connection.
+func parseConnack(first byte, body []byte) error {
+	if first>>4 != packetConnack || len(body) < 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", first>>4)
+	}
+	if code := body[1]; code != 0 {
+		return fmt.Errorf("mqtt: connection refused: %s", connackReason(code))
+	}
+	return nil
+}
+
+// connackReason describes a CONNACK return code (MQTT 3.1.1) or reason code (MQTT 5).
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2, 0x85:
+		return "client identifier not valid"
+	case 3, 0x88:
+		return "server unavailable"
+	case 4, 0x86:
+		return "bad user name or password"
+	case 5, 0x87:
+		return "not authorized"
+	case 0x84:
+		return "unsupported protocol version"
+	default:
+		return fmt.Sprintf("code 0x%02x", code)
+	}
+}
@@ -0,0 +1,332 @@
+// SPDX-License-Identifier: MIT
+package mqtt
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Feature names, used as keys for topic overrides. Unless overridden, each feature is
+// published to Prefix + "/" + name as a JSON object.
+const (
+	FeatureLevel = "level" // {"rms": dBFS, "peak": dBFS}
+	FeatureBands = "bands" // {"edges": [Hz...], "levels": [dBFS...]}, lowest band first.
+	FeatureHPSS  = "hpss"  // {"harmonic": energy, "percussive": energy}
+	FeatureVAD   = "vad"   // {"speaking": bool, "probability": 0..1}
+	FeatureChord = "chord" // {"label": "Am", "confidence": 0..1}
+	FeatureTempo = "bpm"   // {"bpm": beats per minute, "confidence": 0..1}
+	FeatureEvent = "event" // Detected events as they happen: {"source", "name", "label", "value", "timestamp"}
+)
+
+// Topic and discovery defaults.
+const (
+	DefaultPrefix          = "phase4"        // Topic prefix used when Options.Prefix is empty.
+	DefaultDiscoveryPrefix = "homeassistant" // Home Assistant's default discovery prefix.
+	StatusOnline           = "online"        // Availability payload while the engine is connected.
+	StatusOffline          = "offline"       // Availability payload after the engine disconnects.
+)
+
+// Publisher options.
+type Options struct {
+	Prefix          string            // Topic prefix of every feature (empty uses DefaultPrefix).
+	Topics          map[string]string // Per-feature topic overrides; an empty topic disables the feature.
+	QoS             byte              // QoS of feature messages (0 or 1).
+	Retain          bool              // Retain feature messages (events are never retained).
+	Discovery       bool              // Publish Home Assistant discovery messages on every connection.
+	DiscoveryPrefix string            // Discovery topic prefix (empty uses DefaultDiscoveryPrefix).
+	NodeID          string            // Device identifier in discovery topics and unique IDs (empty uses "phase4").
+}
+
+// StatusTopic returns the availability topic for a topic prefix (empty uses
+// DefaultPrefix). Configure it as the client's will with StatusOffline, retained, so
+// subscribers see the engine go offline when the connection is lost.
+func StatusTopic(prefix string) string {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/status"
+}
+
+// Publisher periodically reads the configured analysis features and publishes them as
+// JSON messages, plus detected events as soon as they happen. It runs in a separate
+// goroutine managed by Start and Stop, like the other publishers.
+type Publisher struct {
+	client   *Client           // Broker connection.
+	features analysis.Features // Processors to read from.
+	interval time.Duration     // Interval between updates.
+	options  Options           // Topic options.
+	topics   map[string]string // Topic of every enabled feature.
+
+	ticker   *time.Ticker   // Ticker that triggers publishing.
+	doneChan chan struct{}  // Channel used to signal the publisher goroutine to stop.
+	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
+	wg       sync.WaitGroup // Waits for the publisher goroutine to finish during Stop.
+	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
+}
+
+// JSON payloads of the features.
+type (
+	levelPayload struct {
+		RMS  float64 `json:"rms"`
+		Peak float64 `json:"peak"`
+	}
+	bandsPayload struct {
+		Edges  []float64 `json:"edges"`
+		Levels []float64 `json:"levels"`
+	}
+	hpssPayload struct {
+		Harmonic   float64 `json:"harmonic"`
+		Percussive float64 `json:"percussive"`
+	}
+	vadPayload struct {
+		Speaking    bool    `json:"speaking"`
+		Probability float64 `json:"probability"`
+	}
+	chordPayload struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+	}
+	tempoPayload struct {
+		BPM        float64 `json:"bpm"`
+		Confidence float64 `json:"confidence"`
+	}
+	eventPayload struct {
+		Source    string  `json:"source"`
+		Name      string  `json:"name"`
+		Label     string  `json:"label,omitempty"`
+		Value     float64 `json:"value"`
+		Timestamp int64   `json:"timestamp"` // Unix time in nanoseconds.
+	}
+)
+
+// NewPublisher creates a publisher sending the given features through client every
+// interval. If the provided interval is invalid (<= 0), it defaults to 1s.
+func NewPublisher(interval time.Duration, client *Client, features analysis.Features, options Options) (*Publisher, error) {
+	if client == nil {
+		return nil, fmt.Errorf("MQTTPublisher: client cannot be nil")
+	}
+	if interval <= 0 {
+		interval = time.Second
+		fmt.Printf("MQTTPublisher: Invalid interval provided, defaulting to %s\n", interval)
+	}
+	if options.QoS > 1 {
+		return nil, fmt.Errorf("MQTTPublisher: QoS %d is not supported", options.QoS)
+	}
+	if options.Prefix == "" {
+		options.Prefix = DefaultPrefix
+	}
+	options.Prefix = strings.TrimSuffix(options.Prefix, "/")
+	if options.DiscoveryPrefix == "" {
+		options.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if options.NodeID == "" {
+		options.NodeID = "phase4"
+	}
+
+	p := &Publisher{
+		client:   client,
+		features: features,
+		interval: interval,
+		options:  options,
+		topics:   make(map[string]string),
+	}
+	available := map[string]bool{
+		FeatureLevel: features.Levels != nil,
+		FeatureBands: features.Levels != nil,
+		FeatureHPSS:  features.HPSS != nil,
+		FeatureVAD:   features.VAD != nil,
+		FeatureChord: features.Chord != nil,
+		FeatureTempo: features.Tempo != nil,
+		FeatureEvent: features.Events != nil,
+	}
+	for name := range options.Topics {
+		if _, ok := available[name]; !ok {
+			return nil, fmt.Errorf("MQTTPublisher: unknown feature %q in topics", name)
+		}
+	}
+	for name, enabled := range available {
+		topic := options.Prefix + "/" + name
+		if override, ok := options.Topics[name]; ok {
+			topic = override
+		}
+		if strings.ContainsAny(topic, "+#") {
+			return nil, fmt.Errorf("MQTTPublisher: topic %q for %s must not contain wildcards", topic, name)
+		}
+		if enabled && topic != "" {
+			p.topics[name] = topic
+		}
+	}
+
+	client.OnConnect(p.connectMessages)
+	fmt.Printf("MQTTPublisher: Initializing (Interval: %s, Topics: %v, Discovery: %v)\n", interval, p.topics, options.Discovery)
+	return p, nil
+}
+
+// Start begins the periodic publishing process.
+// It is safe to call Start multiple times; subsequent calls are no-ops if already started.
+func (p *Publisher) Start() {
+	p.mu.Lock()
+	if p.ticker != nil {
+		p.mu.Unlock()
+		fmt.Printf("MQTTPublisher: Start called but already running.\n")
+		return
+	}
+
+	p.ticker = time.NewTicker(p.interval)
+	p.doneChan = make(chan struct{})
+	p.stopOnce = sync.Once{}
+
+	// Capture local variables for the goroutine to avoid data races on p.ticker/p.doneChan
+	ticker := p.ticker
+	doneChan := p.doneChan
+
+	p.mu.Unlock()
+
+	var events <-chan analysis.Event
+	cancel := func() {}
+	if _, ok := p.topics[FeatureEvent]; ok {
+		events, cancel = p.features.Events.Subscribe(16)
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer cancel()
+		for {
+			select {
+			case <-ticker.C:
+				p.publish()
+			case e, ok := <-events:
+				if !ok {
+					events = nil // Bus closed, keep publishing features.
+					continue
+				}
+				p.publishEvent(e)
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop gracefully signals the publisher goroutine to terminate and waits for it to exit.
+// It is safe to call Stop multiple times; subsequent calls are no-ops.
+func (p *Publisher) Stop() error {
+	p.mu.Lock()
+	if p.ticker == nil {
+		p.mu.Unlock()
+		return nil
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.doneChan)
+		p.ticker.Stop()
+		p.ticker = nil
+	})
+
+	p.mu.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+// publish sends the latest value of every enabled feature.
+func (p *Publisher) publish() {
+	messages := make([]Message, 0, len(p.topics))
+	add := func(name string, v any) {
+		topic, ok := p.topics[name]
+		if !ok {
+			return
+		}
+		payload, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		messages = append(messages, Message{Topic: topic, Payload: payload, QoS: p.options.QoS, Retain: p.options.Retain})
+	}
+
+	if levels := p.features.Levels; levels != nil {
+		rms, peak := levels.GetLevels()
+		add(FeatureLevel, levelPayload{RMS: roundDB(rms), Peak: roundDB(peak)})
+
+		bands := make([]float64, levels.BandCount())
+		levels.GetBandsInto(bands)
+		for i, v := range bands {
+			bands[i] = roundDB(v)
+		}
+		add(FeatureBands, bandsPayload{Edges: levels.BandEdges(), Levels: bands})
+	}
+	if hpss := p.features.HPSS; hpss != nil {
+		harmonic, percussive := hpss.GetEnergies()
+		add(FeatureHPSS, hpssPayload{Harmonic: harmonic, Percussive: percussive})
+	}
+	if vad := p.features.VAD; vad != nil {
+		add(FeatureVAD, vadPayload{Speaking: vad.IsSpeaking(), Probability: vad.GetSpeechProbability()})
+	}
+	if chord := p.features.Chord; chord != nil {
+		label, confidence := chord.GetChord()
+		add(FeatureChord, chordPayload{Label: label, Confidence: confidence})
+	}
+	if tempo := p.features.Tempo; tempo != nil {
+		bpm, confidence := tempo.GetTempo()
+		add(FeatureTempo, tempoPayload{BPM: math.Round(bpm*10) / 10, Confidence: confidence})
+	}
+
+	if len(messages) > 0 {
+		_ = p.client.Publish(messages...) // Errors are logged by the client; the next tick retries.
+	}
+}
+
+// publishEvent sends a detected event.
+func (p *Publisher) publishEvent(e analysis.Event) {
+	payload, err := json.Marshal(eventPayload{
+		Source:    e.Source,
+		Name:      e.Name,
+		Label:     e.Label,
+		Value:     e.Value,
+		Timestamp: e.Time.UnixNano(),
+	})
+	if err != nil {
+		return
+	}
+	_ = p.client.Publish(Message{Topic: p.topics[FeatureEvent], Payload: payload, QoS: p.options.QoS})
+}
+
+// connectMessages returns the availability and discovery messages published on every
+// connection.
+func (p *Publisher) connectMessages() []Message {
+	messages := []Message{{Topic: StatusTopic(p.options.Prefix), Payload: []byte(StatusOnline), QoS: p.options.QoS, Retain: true}}
+	if p.options.Discovery {
+		messages = append(messages, p.discoveryMessages()...)
+	}
+	return messages
+}
+
+// Stats returns the counters of the client, shared with anything else publishing
+// through it.
+func (p *Publisher) Stats() transport.Stats {
+	stats, _ := p.client.Stats()
+	return stats
+}
+
+// Close implements the io.Closer interface. It stops the publisher and marks the engine
+// offline; the client is closed by its owner.
+func (p *Publisher) Close() error {
+	fmt.Printf("MQTTPublisher: Close called, stopping publisher...\n")
+	err := p.Stop()
+	_ = p.client.Publish(Message{Topic: StatusTopic(p.options.Prefix), Payload: []byte(StatusOffline), QoS: p.options.QoS, Retain: true})
+	return err
+}
+
+// roundDB rounds a level to 0.1 dB, plenty for automations and much shorter in JSON.
+func roundDB(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// Ensure Publisher satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Publisher)(nil)
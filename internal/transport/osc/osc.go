@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: MIT
+
+// Package osc implements an Open Sound Control 1.0 encoder and a publisher sending
+// analysis results as OSC messages over UDP or TCP.
+//
+// OSC packets are either a message (an address pattern, a type tag string and the
+// arguments) or a bundle (the string "#bundle", a 64 bit NTP time tag and a list of
+// size-prefixed elements). Strings and blobs are padded with zeros to a multiple of four
+// bytes and all numbers are big-endian. Over TCP every packet is prefixed with its size
+// as an int32, as specified by OSC 1.0 for stream transports.
+package osc
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// TimeTag is an OSC time tag: an NTP timestamp with seconds since 1900-01-01 in the high
+// 32 bits and fractions of a second in the low 32 bits.
+type TimeTag uint64
+
+// Immediately is the special time tag asking the receiver to act on a bundle on arrival.
+const Immediately TimeTag = 1
+
+// ntpEpochOffset is the number of seconds between 1900-01-01 and 1970-01-01.
+const ntpEpochOffset = 2208988800
+
+// NewTimeTag converts t to an OSC time tag.
+func NewTimeTag(t time.Time) TimeTag {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return TimeTag(seconds<<32 | fraction)
+}
+
+// Time converts the time tag back to a time.Time.
+func (t TimeTag) Time() time.Time {
+	seconds := int64(t>>32) - ntpEpochOffset
+	nanos := (uint64(t&0xFFFFFFFF)*uint64(time.Second) + 1<<31) >> 32
+	return time.Unix(seconds, int64(nanos))
+}
+
+// bundleTag starts every bundle.
+const bundleTag = "#bundle"
+
+// Message builds a single OSC message. Arguments are appended with the typed methods,
+// and the encoded message is written with AppendTo. A Message can be reused with Reset
+// to build messages without allocating once its buffers have grown.
+type Message struct {
+	address string
+	tags    []byte // Type tags, starting with ','.
+	args    []byte // Encoded arguments.
+}
+
+// NewMessage returns an empty message for address.
+func NewMessage(address string) *Message {
+	m := &Message{}
+	m.Reset(address)
+	return m
+}
+
+// Reset clears the arguments and sets a new address.
+func (m *Message) Reset(address string) {
+	m.address = address
+	m.tags = append(m.tags[:0], ',')
+	m.args = m.args[:0]
+}
+
+// Address returns the address pattern of the message.
+func (m *Message) Address() string {
+	return m.address
+}
+
+// Int32 appends an int32 argument (type tag 'i').
+func (m *Message) Int32(v int32) *Message {
+	m.tags = append(m.tags, 'i')
+	m.args = binary.BigEndian.AppendUint32(m.args, uint32(v))
+	return m
+}
+
+// Float32 appends a float32 argument (type tag 'f').
+func (m *Message) Float32(v float32) *Message {
+	m.tags = append(m.tags, 'f')
+	m.args = binary.BigEndian.AppendUint32(m.args, math.Float32bits(v))
+	return m
+}
+
+// Float32s appends every value of vs as a separate float32 argument. This is how most
+// OSC hosts (TouchDesigner, Max/MSP, SuperCollider) expect arrays of numbers.
+func (m *Message) Float32s(vs []float32) *Message {
+	for _, v := range vs {
+		m.Float32(v)
+	}
+	return m
+}
+
+// String appends a string argument (type tag 's').
+func (m *Message) String(s string) *Message {
+	m.tags = append(m.tags, 's')
+	m.args = AppendString(m.args, s)
+	return m
+}
+
+// Blob appends a blob argument (type tag 'b').
+func (m *Message) Blob(b []byte) *Message {
+	m.tags = append(m.tags, 'b')
+	m.args = AppendBlob(m.args, b)
+	return m
+}
+
+// Float32Blob appends vs as a single blob of big-endian float32 values, a compact
+// alternative to Float32s for large arrays such as spectra.
+func (m *Message) Float32Blob(vs []float32) *Message {
+	m.tags = append(m.tags, 'b')
+	m.args = binary.BigEndian.AppendUint32(m.args, uint32(4*len(vs)))
+	for _, v := range vs {
+		m.args = binary.BigEndian.AppendUint32(m.args, math.Float32bits(v))
+	}
+	return m
+}
+
+// Size returns the number of bytes AppendTo writes.
+func (m *Message) Size() int {
+	return paddedSize(len(m.address)) + paddedSize(len(m.tags)) + len(m.args)
+}
+
+// AppendTo appends the encoded message to dst and returns the extended buffer.
+func (m *Message) AppendTo(dst []byte) []byte {
+	dst = AppendString(dst, m.address)
+	dst = appendPadded(dst, m.tags)
+	return append(dst, m.args...)
+}
+
+// AppendBundleHeader appends the start of a bundle with time tag t to dst. Elements are
+// added after it with AppendBundleMessage.
+func AppendBundleHeader(dst []byte, t TimeTag) []byte {
+	dst = AppendString(dst, bundleTag)
+	return binary.BigEndian.AppendUint64(dst, uint64(t))
+}
+
+// AppendBundleMessage appends m to a bundle started with AppendBundleHeader.
+func AppendBundleMessage(dst []byte, m *Message) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(m.Size()))
+	return m.AppendTo(dst)
+}
+
+// AppendString appends s as an OSC string: the bytes, a terminating zero and padding to
+// a multiple of four bytes.
+func AppendString(dst []byte, s string) []byte {
+	dst = append(dst, s...)
+	return append(dst, make([]byte, paddedSize(len(s))-len(s))...)
+}
+
+// AppendBlob appends b as an OSC blob: an int32 size, the bytes and padding.
+func AppendBlob(dst []byte, b []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(b)))
+	dst = append(dst, b...)
+	return append(dst, make([]byte, (4-len(b)%4)%4)...)
+}
+
+// appendPadded appends b followed by a terminating zero and padding, like AppendString.
+func appendPadded(dst []byte, b []byte) []byte {
+	dst = append(dst, b...)
+	return append(dst, make([]byte, paddedSize(len(b))-len(b))...)
+}
+
+// paddedSize returns the encoded size of an OSC string of n bytes, including the
+// terminating zero and padding.
+func paddedSize(n int) int {
+	return (n + 4) &^ 3
+}
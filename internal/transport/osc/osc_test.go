@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: MIT
+package osc
+
+import (
+	"audio/internal/analysis"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMessage_Encoding(t *testing.T) {
+	t.Parallel()
+	got := NewMessage("/ab").Int32(1).Float32(0.5).String("hi").Blob([]byte{7}).AppendTo(nil)
+	want := []byte{
+		'/', 'a', 'b', 0,
+		',', 'i', 'f', 's', 'b', 0, 0, 0,
+		0, 0, 0, 1,
+		0x3f, 0, 0, 0,
+		'h', 'i', 0, 0,
+		0, 0, 0, 1, 7, 0, 0, 0,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encoded message =\n% x\nwant\n% x", got, want)
+	}
+	if m := NewMessage("/four").Float32(1); m.Size() != len(m.AppendTo(nil)) {
+		t.Errorf("Size() = %d, encoded %d bytes", m.Size(), len(m.AppendTo(nil)))
+	}
+}
+
+func TestTimeTag(t *testing.T) {
+	t.Parallel()
+	if tag := NewTimeTag(time.Unix(0, 0)); tag != TimeTag(ntpEpochOffset<<32) {
+		t.Errorf("Unix epoch time tag = %#x", uint64(tag))
+	}
+	// Half a second is exactly half the fraction range.
+	if tag := NewTimeTag(time.Unix(1, 5e8)); uint32(tag) != 1<<31 {
+		t.Errorf("fraction = %#x, want 0x80000000", uint32(tag))
+	}
+	now := time.Unix(1700000000, 123456789)
+	if back := NewTimeTag(now).Time(); back.Sub(now).Abs() > time.Nanosecond {
+		t.Errorf("round trip = %v, want %v", back, now)
+	}
+}
+
+func TestAppendBundle(t *testing.T) {
+	t.Parallel()
+	m := NewMessage("/x").Float32(1)
+	b := AppendBundleHeader(nil, Immediately)
+	b = AppendBundleMessage(b, m)
+
+	if !bytes.HasPrefix(b, []byte("#bundle\x00")) {
+		t.Fatalf("bundle does not start with #bundle: % x", b)
+	}
+	if tag := binary.BigEndian.Uint64(b[8:16]); tag != uint64(Immediately) {
+		t.Errorf("time tag = %d, want %d", tag, Immediately)
+	}
+	if size := binary.BigEndian.Uint32(b[16:20]); int(size) != m.Size() || len(b) != 20+m.Size() {
+		t.Errorf("element size = %d, bundle length %d, message size %d", size, len(b), m.Size())
+	}
+}
+
+// recordingSender keeps a copy of every packet sent.
+type recordingSender struct{ packets [][]byte }
+
+func (r *recordingSender) Send(packet []byte) error {
+	r.packets = append(r.packets, append([]byte(nil), packet...))
+	return nil
+}
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return float64(2 * (len(s) - 1)) }
+
+func TestPublisher_Publish(t *testing.T) {
+	t.Parallel()
+	src := spectrum{0, 1, 2, 3, 4}
+	levels, err := analysis.NewLevelProcessor(src, []float64{0, 2, 4})
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+
+	sender := &recordingSender{}
+	p, err := NewPublisher(time.Second, sender, analysis.Features{Spectrum: src, Levels: levels}, Options{
+		Addresses: map[string]string{FeatureBands: "", FeatureFFT: "/spectrum"},
+		Blob:      true,
+	})
+	if err != nil {
+		t.Fatalf("NewPublisher error: %v", err)
+	}
+	p.publish(time.Now())
+
+	if len(sender.packets) != 2 {
+		t.Fatalf("sent %d packets, want 2 (fft, level)", len(sender.packets))
+	}
+	blob := make([]byte, 0, 20)
+	for _, v := range src {
+		blob = binary.BigEndian.AppendUint32(blob, math.Float32bits(float32(v)))
+	}
+	want := NewMessage("/spectrum").Blob(blob).AppendTo(nil)
+	if !bytes.Equal(sender.packets[0], want) {
+		t.Errorf("spectrum packet =\n% x\nwant\n% x", sender.packets[0], want)
+	}
+	if !bytes.HasPrefix(sender.packets[1], []byte("/phase4/level\x00\x00\x00,ff\x00")) {
+		t.Errorf("level packet = %q", sender.packets[1])
+	}
+
+	// The same messages as a single bundle.
+	sender.packets = nil
+	p.options.Bundle = true
+	now := time.Now()
+	p.publish(now)
+	if len(sender.packets) != 1 {
+		t.Fatalf("sent %d packets, want 1 bundle", len(sender.packets))
+	}
+	if tag := TimeTag(binary.BigEndian.Uint64(sender.packets[0][8:16])); tag != NewTimeTag(now) {
+		t.Errorf("bundle time tag = %v, want %v", tag.Time(), now)
+	}
+
+	if _, err := NewPublisher(time.Second, sender, analysis.Features{}, Options{Addresses: map[string]string{"tempo": "/t"}}); err == nil {
+		t.Error("expected error for unknown feature, got nil")
+	}
+}
+
+func TestPublisher_OnsetAndTempo(t *testing.T) {
+	t.Parallel()
+	src := make(spectrum, 5)
+	onset, err := analysis.NewOnsetProcessor(src, 100, 1.5, 0)
+	if err != nil {
+		t.Fatalf("NewOnsetProcessor error: %v", err)
+	}
+	defer onset.Close()
+	tempo, err := analysis.NewTempoProcessor(onset, 60, 180, 2*time.Second)
+	if err != nil {
+		t.Fatalf("NewTempoProcessor error: %v", err)
+	}
+	defer tempo.Close()
+
+	sender := &recordingSender{}
+	p, err := NewPublisher(time.Second, sender, analysis.Features{Onset: onset, Tempo: tempo}, Options{})
+	if err != nil {
+		t.Fatalf("NewPublisher error: %v", err)
+	}
+
+	// Silence, then a hit.
+	for range 20 {
+		onset.Process(nil)
+	}
+	copy(src, []float64{1, 1, 1, 1, 1})
+	onset.Process(nil)
+
+	p.publish(time.Now())
+	if len(sender.packets) != 3 {
+		t.Fatalf("sent %d packets, want 3 (onset, bpm, beat)", len(sender.packets))
+	}
+	for i, prefix := range []string{"/phase4/onset\x00\x00\x00,f\x00\x00", "/phase4/bpm\x00,ff\x00", "/phase4/beat\x00\x00\x00\x00,fi\x00"} {
+		if !bytes.HasPrefix(sender.packets[i], []byte(prefix)) {
+			t.Errorf("packet %d = %q, want prefix %q", i, sender.packets[i], prefix)
+		}
+	}
+
+	// The onset is sent once; the tempo every tick.
+	sender.packets = nil
+	p.publish(time.Now())
+	if len(sender.packets) != 2 {
+		t.Errorf("sent %d packets on the next tick, want 2 (bpm, beat)", len(sender.packets))
+	}
+}
+
+func TestTCPSender_Framing(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	sender, err := NewTCPSender(ln.Addr().String(), false)
+	if err != nil {
+		t.Fatalf("NewTCPSender error: %v", err)
+	}
+	defer sender.Close()
+
+	packet := NewMessage("/t").Int32(42).AppendTo(nil)
+	if err := sender.Send(packet); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	got := make([]byte, 4+len(packet))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if size := binary.BigEndian.Uint32(got); int(size) != len(packet) || !bytes.Equal(got[4:], packet) {
+		t.Errorf("framed packet = % x, want size %d and % x", got, len(packet), packet)
+	}
+}
@@ -0,0 +1,366 @@
+// SPDX-License-Identifier: MIT
+package osc
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Feature names, used as keys for address overrides. Unless overridden, each feature
+// is sent to Prefix + "/" + name.
+const (
+	FeatureFFT    = "fft"    // Spectrum magnitudes: float arguments or one float32 blob.
+	FeatureLevel  = "level"  // RMS and peak level in dBFS: f f.
+	FeatureBands  = "bands"  // Band levels in dBFS, lowest band first: f...
+	FeatureHPSS   = "hpss"   // Harmonic and percussive energy: f f.
+	FeatureVAD    = "vad"    // Speech probability and state (0 or 1): f i.
+	FeatureChord  = "chord"  // Chord label and confidence: s f.
+	FeatureChroma = "chroma" // Chroma vector, C first: 12 x f.
+	FeaturePeaks  = "peaks"  // Peak frequency (Hz) and magnitude pairs, strongest first: f f ...
+	FeatureOnset  = "onset"  // Spectral flux of an onset, sent once per tick with new onsets: f.
+	FeatureBPM    = "bpm"    // Tempo in BPM (0 if unknown) and confidence: f f.
+	FeatureBeat   = "beat"   // Beat phase, 0 on the beat rising towards 1, and beat count: f i.
+)
+
+// DefaultPrefix is the address prefix used when Options.Prefix is empty.
+const DefaultPrefix = "/phase4"
+
+// Sender sends one encoded OSC packet. It is satisfied by the UDP sender of the udp
+// transport and by TCPSender.
+type Sender interface {
+	Send(packet []byte) error
+}
+
+// Options controls the messages produced by a Publisher.
+type Options struct {
+	Prefix    string            // Address prefix of every feature (empty uses DefaultPrefix).
+	Addresses map[string]string // Per-feature address overrides; an empty address disables the feature.
+	Blob      bool              // Send the spectrum as one float32 blob instead of float arguments.
+	Bundle    bool              // Send all messages of a tick as one bundle with an OSC time tag.
+}
+
+// Publisher periodically reads the configured analysis features and sends them as OSC
+// messages. Features whose processor is nil are skipped. It runs in a separate goroutine
+// managed by Start and Stop, like the UDP publisher.
+type Publisher struct {
+	sender   Sender            // Packet sender (UDP or TCP).
+	features analysis.Features // Processors to read from.
+	interval time.Duration     // Interval between ticks.
+	options  Options           // Message options.
+	messages []*featureMessage // One message per enabled feature, in send order.
+
+	ticker   *time.Ticker   // Ticker that triggers sending.
+	doneChan chan struct{}  // Channel used to signal the publisher goroutine to stop.
+	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
+	wg       sync.WaitGroup // Waits for the publisher goroutine to finish during Stop.
+	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
+
+	// Pre-allocated buffers to reduce allocations in the hot path (publish).
+	magBuffer    []float64               // Spectrum magnitudes.
+	f32Buffer    []float32               // Spectrum or band levels as float32.
+	bandBuffer   []float64               // Band levels.
+	peakBuffer   []analysis.SpectralPeak // Spectral peaks.
+	packetBuffer []byte                  // Reusable buffer for the encoded packet.
+
+	onsets uint64 // Onset count at the previous tick, to send each onset once.
+
+	counters transport.Counters // Packets sent, for the metrics endpoint.
+}
+
+// featureMessage pairs a reusable message with the function filling its arguments.
+// fill returns false when no data is available and the message should be skipped.
+type featureMessage struct {
+	msg  *Message
+	fill func(m *Message) bool
+}
+
+// NewPublisher creates a publisher sending the given features through sender every interval.
+// If the provided interval is invalid (<= 0), it defaults to 16ms (~60Hz).
+func NewPublisher(interval time.Duration, sender Sender, features analysis.Features, options Options) (*Publisher, error) {
+	if sender == nil {
+		return nil, fmt.Errorf("OSCPublisher: sender cannot be nil")
+	}
+	if interval <= 0 {
+		interval = 16 * time.Millisecond // Default to ~60Hz if invalid
+		fmt.Printf("OSCPublisher: Invalid interval provided, defaulting to %s\n", interval)
+	}
+	if options.Prefix == "" {
+		options.Prefix = DefaultPrefix
+	}
+	options.Prefix = strings.TrimSuffix(options.Prefix, "/")
+	if !strings.HasPrefix(options.Prefix, "/") {
+		return nil, fmt.Errorf("OSCPublisher: address prefix %q must start with '/'", options.Prefix)
+	}
+
+	p := &Publisher{
+		sender:   sender,
+		features: features,
+		interval: interval,
+		options:  options,
+	}
+
+	// Register the available features, in a fixed order.
+	known := map[string]bool{}
+	add := func(name string, available bool, fill func(m *Message) bool) error {
+		known[name] = true
+		if !available {
+			return nil
+		}
+		address := options.Prefix + "/" + name
+		if override, ok := options.Addresses[name]; ok {
+			if override == "" {
+				return nil // Disabled.
+			}
+			if !strings.HasPrefix(override, "/") {
+				return fmt.Errorf("OSCPublisher: address %q for %s must start with '/'", override, name)
+			}
+			address = override
+		}
+		p.messages = append(p.messages, &featureMessage{msg: NewMessage(address), fill: fill})
+		return nil
+	}
+
+	var bins, bands, peaks int
+	if features.Spectrum != nil {
+		bins = features.Spectrum.GetFFTSize()/2 + 1
+	}
+	if features.Levels != nil {
+		bands = features.Levels.BandCount()
+	}
+	if features.Peaks != nil {
+		peaks = features.Peaks.MaxPeaks()
+	}
+	p.magBuffer = make([]float64, bins)
+	p.f32Buffer = make([]float32, max(bins, bands))
+	p.bandBuffer = make([]float64, bands)
+	p.peakBuffer = make([]analysis.SpectralPeak, peaks)
+
+	for _, err := range []error{
+		add(FeatureFFT, features.Spectrum != nil, p.fillSpectrum),
+		add(FeatureLevel, features.Levels != nil, p.fillLevel),
+		add(FeatureBands, features.Levels != nil, p.fillBands),
+		add(FeatureHPSS, features.HPSS != nil, p.fillHPSS),
+		add(FeatureVAD, features.VAD != nil, p.fillVAD),
+		add(FeatureChord, features.Chord != nil, p.fillChord),
+		add(FeatureChroma, features.Chroma != nil, p.fillChroma),
+		add(FeaturePeaks, features.Peaks != nil, p.fillPeaks),
+		add(FeatureOnset, features.Onset != nil, p.fillOnset),
+		add(FeatureBPM, features.Tempo != nil, p.fillBPM),
+		add(FeatureBeat, features.Tempo != nil, p.fillBeat),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name := range options.Addresses {
+		if !known[name] {
+			return nil, fmt.Errorf("OSCPublisher: unknown feature %q in address overrides", name)
+		}
+	}
+
+	addresses := make([]string, len(p.messages))
+	for i, fm := range p.messages {
+		addresses[i] = fm.msg.Address()
+	}
+	sort.Strings(addresses)
+	fmt.Printf("OSCPublisher: Initializing (Interval: %s, Bundle: %v, Blob: %v, Addresses: %s)\n",
+		interval, options.Bundle, options.Blob, strings.Join(addresses, " "))
+
+	return p, nil
+}
+
+// Start begins the periodic publishing process.
+// It is safe to call Start multiple times; subsequent calls are no-ops if already started.
+func (p *Publisher) Start() {
+	p.mu.Lock()
+	if p.ticker != nil {
+		p.mu.Unlock()
+		fmt.Printf("OSCPublisher: Start called but already running.\n")
+		return
+	}
+
+	p.ticker = time.NewTicker(p.interval)
+	p.doneChan = make(chan struct{})
+	p.stopOnce = sync.Once{}
+
+	// Capture local variables for the goroutine to avoid data races on p.ticker/p.doneChan
+	ticker := p.ticker
+	doneChan := p.doneChan
+
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fmt.Printf("OSCPublisher: Publisher goroutine started (Interval: %s)\n", p.interval)
+		for {
+			select {
+			case now := <-ticker.C:
+				p.publish(now)
+			case <-doneChan:
+				fmt.Printf("OSCPublisher: Publisher goroutine received stop signal.\n")
+				return
+			}
+		}
+	}()
+}
+
+// Stop signals the publisher goroutine to terminate and waits for it to exit.
+// It is safe to call Stop multiple times; subsequent calls are no-ops.
+func (p *Publisher) Stop() error {
+	p.mu.Lock()
+	if p.ticker == nil {
+		p.mu.Unlock()
+		fmt.Printf("OSCPublisher: Stop called but not running.\n")
+		return nil
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.doneChan)
+		p.ticker.Stop()
+		p.ticker = nil
+	})
+
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	fmt.Printf("OSCPublisher: Publisher goroutine finished.\n")
+	return nil
+}
+
+// publish fills every feature message and sends them, either one packet per message or
+// as a single bundle timestamped with now.
+func (p *Publisher) publish(now time.Time) {
+	if p.options.Bundle {
+		p.packetBuffer = AppendBundleHeader(p.packetBuffer[:0], NewTimeTag(now))
+		empty := true
+		for _, fm := range p.messages {
+			fm.msg.Reset(fm.msg.Address())
+			if fm.fill(fm.msg) {
+				p.packetBuffer = AppendBundleMessage(p.packetBuffer, fm.msg)
+				empty = false
+			}
+		}
+		if !empty {
+			p.counters.Record(len(p.packetBuffer), p.sender.Send(p.packetBuffer)) // Errors are logged by the sender.
+		}
+		return
+	}
+
+	for _, fm := range p.messages {
+		fm.msg.Reset(fm.msg.Address())
+		if fm.fill(fm.msg) {
+			p.packetBuffer = fm.msg.AppendTo(p.packetBuffer[:0])
+			p.counters.Record(len(p.packetBuffer), p.sender.Send(p.packetBuffer)) // Errors are logged by the sender.
+		}
+	}
+}
+
+func (p *Publisher) fillSpectrum(m *Message) bool {
+	if err := p.features.Spectrum.GetMagnitudesInto(p.magBuffer); err != nil {
+		return false
+	}
+	f32 := p.f32Buffer[:len(p.magBuffer)]
+	for i, v := range p.magBuffer {
+		f32[i] = float32(v)
+	}
+	if p.options.Blob {
+		m.Float32Blob(f32)
+	} else {
+		m.Float32s(f32)
+	}
+	return true
+}
+
+func (p *Publisher) fillLevel(m *Message) bool {
+	rms, peak := p.features.Levels.GetLevels()
+	m.Float32(float32(rms)).Float32(float32(peak))
+	return true
+}
+
+func (p *Publisher) fillBands(m *Message) bool {
+	n := p.features.Levels.GetBandsInto(p.bandBuffer)
+	for _, v := range p.bandBuffer[:n] {
+		m.Float32(float32(v))
+	}
+	return n > 0
+}
+
+func (p *Publisher) fillHPSS(m *Message) bool {
+	harmonic, percussive := p.features.HPSS.GetEnergies()
+	m.Float32(float32(harmonic)).Float32(float32(percussive))
+	return true
+}
+
+func (p *Publisher) fillVAD(m *Message) bool {
+	speaking := int32(0)
+	if p.features.VAD.IsSpeaking() {
+		speaking = 1
+	}
+	m.Float32(float32(p.features.VAD.GetSpeechProbability())).Int32(speaking)
+	return true
+}
+
+func (p *Publisher) fillChord(m *Message) bool {
+	label, confidence := p.features.Chord.GetChord()
+	m.String(label).Float32(float32(confidence))
+	return true
+}
+
+func (p *Publisher) fillChroma(m *Message) bool {
+	var chroma [12]float64
+	p.features.Chroma.GetChromaInto(&chroma)
+	for _, v := range chroma {
+		m.Float32(float32(v))
+	}
+	return true
+}
+
+func (p *Publisher) fillPeaks(m *Message) bool {
+	n := p.features.Peaks.GetPeaksInto(p.peakBuffer)
+	for _, peak := range p.peakBuffer[:n] {
+		m.Float32(float32(peak.Frequency)).Float32(float32(peak.Magnitude))
+	}
+	return true
+}
+
+func (p *Publisher) fillOnset(m *Message) bool {
+	count, strength := p.features.Onset.GetOnsets()
+	if count == p.onsets {
+		return false
+	}
+	p.onsets = count
+	m.Float32(float32(strength))
+	return true
+}
+
+func (p *Publisher) fillBPM(m *Message) bool {
+	bpm, confidence := p.features.Tempo.GetTempo()
+	m.Float32(float32(bpm)).Float32(float32(confidence))
+	return true
+}
+
+func (p *Publisher) fillBeat(m *Message) bool {
+	m.Float32(float32(p.features.Tempo.GetBeatPhase())).Int32(int32(p.features.Tempo.GetBeats()))
+	return true
+}
+
+// Stats returns the number of packets sent and failed so far. It is safe to call while
+// the publisher runs.
+func (p *Publisher) Stats() transport.Stats {
+	return p.counters.Load()
+}
+
+// Close implements the io.Closer interface. It gracefully stops the publisher goroutine.
+func (p *Publisher) Close() error {
+	fmt.Printf("OSCPublisher: Close called, stopping publisher...\n")
+	return p.Stop()
+}
+
+// Ensure Publisher satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Publisher)(nil)
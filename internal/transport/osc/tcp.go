@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MIT
+package osc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timing for TCP connections.
+const (
+	tcpDialTimeout    = time.Second     // Maximum time spent connecting.
+	tcpWriteTimeout   = time.Second     // Maximum time a stalled receiver can block a send.
+	tcpReconnectDelay = 2 * time.Second // Minimum time between connection attempts.
+)
+
+// TCPSender sends OSC packets over a TCP stream, each prefixed with its size as an int32
+// (OSC 1.0 stream framing). The connection is made on the first send and re-established
+// after errors, at most once every couple of seconds, so the receiver can be started and
+// restarted independently of the engine. Packets sent while disconnected are dropped.
+type TCPSender struct {
+	address  string     // Target host and port.
+	debug    bool       // Log connection attempts and errors.
+	mu       sync.Mutex // Protects the fields below.
+	conn     net.Conn   // Current connection, nil while disconnected.
+	nextDial time.Time  // Earliest time of the next connection attempt.
+	frame    []byte     // Reusable buffer for the size prefix and packet.
+	closed   bool       // Whether Close has been called.
+}
+
+// NewTCPSender creates a sender for the given "host:port" address. No connection is
+// made until the first packet is sent.
+func NewTCPSender(address string, debug bool) (*TCPSender, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid OSC TCP address '%s': %w", address, err)
+	}
+	fmt.Printf("OSC TCP Sender: Sending to %s (Debug logging: %v)\n", address, debug)
+	return &TCPSender{address: address, debug: debug}, nil
+}
+
+// Send writes packet to the stream, connecting first if needed. It is safe for concurrent use.
+func (s *TCPSender) Send(packet []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("OSC TCP sender is closed")
+	}
+	if s.conn == nil {
+		if time.Now().Before(s.nextDial) {
+			return fmt.Errorf("OSC TCP sender not connected to %s", s.address)
+		}
+		conn, err := net.DialTimeout("tcp", s.address, tcpDialTimeout)
+		if err != nil {
+			s.nextDial = time.Now().Add(tcpReconnectDelay)
+			if s.debug {
+				fmt.Printf("OSC TCP Sender: Connect to %s failed: %v\n", s.address, err)
+			}
+			return fmt.Errorf("failed to connect to OSC TCP target %s: %w", s.address, err)
+		}
+		fmt.Printf("OSC TCP Sender: Connected to %s\n", s.address)
+		s.conn = conn
+	}
+
+	s.frame = binary.BigEndian.AppendUint32(s.frame[:0], uint32(len(packet)))
+	s.frame = append(s.frame, packet...)
+	_ = s.conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+	if _, err := s.conn.Write(s.frame); err != nil {
+		// A partial write leaves the stream out of sync, so always start over.
+		fmt.Printf("OSC TCP Sender: Send to %s failed, reconnecting: %v\n", s.address, err)
+		_ = s.conn.Close()
+		s.conn = nil
+		s.nextDial = time.Now().Add(tcpReconnectDelay)
+		return fmt.Errorf("failed to send OSC packet: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection. Further sends fail. It is safe to call multiple times.
+func (s *TCPSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	fmt.Printf("OSC TCP Sender: Closing connection to %s\n", s.address)
+	err := s.conn.Close()
+	s.conn = nil
+	if err != nil {
+		return fmt.Errorf("failed to close OSC TCP connection: %w", err)
+	}
+	return nil
+}
+
+// Ensure TCPSender satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*TCPSender)(nil)
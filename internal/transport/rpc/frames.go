@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+package rpc
+
+import (
+	"audio/internal/analysis"
+	apiv1 "audio/pkg/api/v1"
+)
+
+// availableFrameTypes returns the frame types the features can produce, in FrameType order.
+func availableFrameTypes(features analysis.Features) []apiv1.FrameType {
+	var types []apiv1.FrameType
+	for _, f := range []struct {
+		t  apiv1.FrameType
+		ok bool
+	}{
+		{apiv1.FrameType_FRAME_TYPE_SPECTRUM, features.Spectrum != nil},
+		{apiv1.FrameType_FRAME_TYPE_LEVELS, features.Levels != nil},
+		{apiv1.FrameType_FRAME_TYPE_BANDS, features.Levels != nil},
+		{apiv1.FrameType_FRAME_TYPE_HPSS, features.HPSS != nil},
+		{apiv1.FrameType_FRAME_TYPE_VAD, features.VAD != nil},
+		{apiv1.FrameType_FRAME_TYPE_CHORD, features.Chord != nil},
+		{apiv1.FrameType_FRAME_TYPE_CHROMA, features.Chroma != nil},
+		{apiv1.FrameType_FRAME_TYPE_PEAKS, features.Peaks != nil},
+		{apiv1.FrameType_FRAME_TYPE_EVENT, features.Events != nil},
+	} {
+		if f.ok {
+			types = append(types, f.t)
+		}
+	}
+	return types
+}
+
+// featureFrame reads the latest value of a periodic frame type (any type but
+// FRAME_TYPE_EVENT) into a new frame, or returns false if the feature is not available.
+func featureFrame(features analysis.Features, t apiv1.FrameType, sequence uint64, timestamp int64) (*apiv1.Frame, bool) {
+	frame := &apiv1.Frame{Sequence: sequence, TimestampNs: timestamp}
+	switch t {
+	case apiv1.FrameType_FRAME_TYPE_SPECTRUM:
+		if features.Spectrum == nil {
+			return nil, false
+		}
+		magnitudes := features.Spectrum.GetMagnitudes()
+		f32 := make([]float32, len(magnitudes))
+		for i, v := range magnitudes {
+			f32[i] = float32(v)
+		}
+		frame.Payload = &apiv1.Frame_Spectrum{Spectrum: &apiv1.Spectrum{Magnitudes: f32}}
+	case apiv1.FrameType_FRAME_TYPE_LEVELS:
+		if features.Levels == nil {
+			return nil, false
+		}
+		rms, peak := features.Levels.GetLevels()
+		frame.Payload = &apiv1.Frame_Levels{Levels: &apiv1.Levels{RmsDbfs: rms, PeakDbfs: peak}}
+	case apiv1.FrameType_FRAME_TYPE_BANDS:
+		if features.Levels == nil {
+			return nil, false
+		}
+		levels := make([]float64, features.Levels.BandCount())
+		features.Levels.GetBandsInto(levels)
+		frame.Payload = &apiv1.Frame_Bands{Bands: &apiv1.Bands{EdgesHz: features.Levels.BandEdges(), LevelsDbfs: levels}}
+	case apiv1.FrameType_FRAME_TYPE_HPSS:
+		if features.HPSS == nil {
+			return nil, false
+		}
+		harmonic, percussive := features.HPSS.GetEnergies()
+		frame.Payload = &apiv1.Frame_Hpss{Hpss: &apiv1.HPSS{HarmonicEnergy: harmonic, PercussiveEnergy: percussive}}
+	case apiv1.FrameType_FRAME_TYPE_VAD:
+		if features.VAD == nil {
+			return nil, false
+		}
+		frame.Payload = &apiv1.Frame_Vad{Vad: &apiv1.VoiceActivity{
+			Probability: features.VAD.GetSpeechProbability(),
+			Speaking:    features.VAD.IsSpeaking(),
+		}}
+	case apiv1.FrameType_FRAME_TYPE_CHORD:
+		if features.Chord == nil {
+			return nil, false
+		}
+		label, confidence := features.Chord.GetChord()
+		frame.Payload = &apiv1.Frame_Chord{Chord: &apiv1.Chord{Label: label, Confidence: confidence}}
+	case apiv1.FrameType_FRAME_TYPE_CHROMA:
+		if features.Chroma == nil {
+			return nil, false
+		}
+		var values [12]float64
+		features.Chroma.GetChromaInto(&values)
+		frame.Payload = &apiv1.Frame_Chroma{Chroma: &apiv1.Chroma{Values: values[:]}}
+	case apiv1.FrameType_FRAME_TYPE_PEAKS:
+		if features.Peaks == nil {
+			return nil, false
+		}
+		peaks := features.Peaks.GetPeaks()
+		values := make([]*apiv1.Peak, len(peaks))
+		for i, p := range peaks {
+			values[i] = &apiv1.Peak{FrequencyHz: p.Frequency, Magnitude: p.Magnitude, BandwidthHz: p.Bandwidth}
+		}
+		frame.Payload = &apiv1.Frame_Peaks{Peaks: &apiv1.Peaks{Peaks: values}}
+	default:
+		return nil, false
+	}
+	return frame, true
+}
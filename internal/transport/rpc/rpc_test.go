@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: MIT
+package rpc
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/config"
+	"audio/internal/transport/udp"
+	apiv1 "audio/pkg/api/v1"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return 48000 }
+
+// newTestClient starts a server on a random local port and returns a client connected
+// to it. Both are closed when the test ends.
+func newTestClient(t *testing.T, features analysis.Features, options Options) (*Server, apiv1.EngineClient) {
+	t.Helper()
+	s, err := NewServer("127.0.0.1:0", 5*time.Millisecond, features, options)
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.Start()
+
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, apiv1.NewEngineClient(conn)
+}
+
+func testFeatures(t *testing.T) analysis.Features {
+	t.Helper()
+	src := spectrum{0, 1, 2, 3, 4}
+	levels, err := analysis.NewLevelProcessor(src, nil)
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+	return analysis.Features{Spectrum: src, Levels: levels, Events: analysis.NewEventBus()}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	features := testFeatures(t)
+	_, client := newTestClient(t, features, Options{Channel: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	stream, err := client.Subscribe(ctx, &apiv1.SubscribeRequest{Types: []apiv1.FrameType{
+		apiv1.FrameType_FRAME_TYPE_SPECTRUM,
+		apiv1.FrameType_FRAME_TYPE_LEVELS,
+		apiv1.FrameType_FRAME_TYPE_EVENT,
+	}})
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv error: %v", err)
+	}
+	info := first.GetInfo()
+	if info == nil || info.SampleRate != 48000 || info.FftSize != 8 || info.Channel != 2 || len(info.Available) != 4 {
+		t.Fatalf("first frame = %v, want stream info", first)
+	}
+
+	var gotSpectrum, gotLevels bool
+	for !gotSpectrum || !gotLevels {
+		frame, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv error: %v", err)
+		}
+		switch p := frame.Payload.(type) {
+		case *apiv1.Frame_Spectrum:
+			gotSpectrum = len(p.Spectrum.Magnitudes) == 5 && p.Spectrum.Magnitudes[4] == 4 && frame.Sequence > 0
+		case *apiv1.Frame_Levels:
+			gotLevels = p.Levels.RmsDbfs == analysis.SilenceDB
+		default:
+			t.Fatalf("unexpected frame %v", frame)
+		}
+	}
+
+	features.Events.Publish(analysis.Event{Source: "vad", Name: analysis.EventSpeechStart, Value: 0.9, Time: time.Now()})
+	for {
+		frame, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv error: %v", err)
+		}
+		if e := frame.GetEvent(); e != nil {
+			if e.Source != "vad" || e.Name != analysis.EventSpeechStart {
+				t.Errorf("event = %v", e)
+			}
+			break
+		}
+	}
+}
+
+func TestSubscribe_Errors(t *testing.T) {
+	t.Parallel()
+	_, client := newTestClient(t, testFeatures(t), Options{MaxSubscribers: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Disabled frame types are rejected.
+	stream, err := client.Subscribe(ctx, &apiv1.SubscribeRequest{Types: []apiv1.FrameType{apiv1.FrameType_FRAME_TYPE_CHORD}})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("chord subscription error = %v, want InvalidArgument", err)
+	}
+
+	// A second subscriber exceeds the limit.
+	first, err := client.Subscribe(ctx, &apiv1.SubscribeRequest{})
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+	if _, err := first.Recv(); err != nil {
+		t.Fatalf("Recv error: %v", err)
+	}
+	second, err := client.Subscribe(ctx, &apiv1.SubscribeRequest{})
+	if err == nil {
+		_, err = second.Recv()
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second subscription error = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestStatusAndConfig(t *testing.T) {
+	t.Parallel()
+	cfg := &config.Config{Audio: config.AudioConfig{SampleRate: 48000, FramesPerBuffer: 512, InputChannels: 1, FFTWindow: "Hann"}}
+	s, client := newTestClient(t, testFeatures(t), Options{Config: cfg, TransportStats: func() []udp.PublisherStats {
+		return []udp.PublisherStats{{
+			SenderStats: udp.SenderStats{Target: "127.0.0.1:9090", Packets: 3, Errors: map[udp.ErrorClass]uint64{udp.ErrorRefused: 2}, Paused: true},
+			Messages:    3,
+		}}
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	st, err := client.GetStatus(ctx, &apiv1.GetStatusRequest{})
+	if err != nil {
+		t.Fatalf("GetStatus error: %v", err)
+	}
+	if !st.Streaming || st.Subscribers != 0 || st.Stream.GetFftSize() != 8 {
+		t.Errorf("status = %v", st)
+	}
+	if tr := st.GetTransports(); len(tr) != 1 || tr[0].Target != "127.0.0.1:9090" || tr[0].Packets != 3 ||
+		tr[0].Errors["refused"] != 2 || !tr[0].Paused || tr[0].LastErrorAtNs != 0 {
+		t.Errorf("transports = %v", tr)
+	}
+	_ = s.Stop()
+	if st, err = client.GetStatus(ctx, &apiv1.GetStatusRequest{}); err != nil || st.Streaming {
+		t.Errorf("status after Stop = %v (%v), want not streaming", st, err)
+	}
+
+	c, err := client.GetConfig(ctx, &apiv1.GetConfigRequest{})
+	if err != nil {
+		t.Fatalf("GetConfig error: %v", err)
+	}
+	if c.SampleRate != cfg.Audio.SampleRate || c.FftWindow != cfg.Audio.FFTWindow || !strings.Contains(c.Yaml, "sample_rate:") {
+		t.Errorf("config = %v", c)
+	}
+}
@@ -0,0 +1,458 @@
+// SPDX-License-Identifier: MIT
+
+// Package rpc serves the Phase4 gRPC API defined in audio/pkg/api/v1: server-streaming
+// subscriptions to typed analysis frames, and unary calls for status and configuration.
+package rpc
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/config"
+	"audio/internal/transport"
+	"audio/internal/transport/udp"
+	apiv1 "audio/pkg/api/v1"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Buffering for subscribers.
+const (
+	subscriberFrameBuffer = 32 // Frames queued per subscriber before frames are dropped.
+	subscriberEventBuffer = 16 // Events queued per subscriber before events are dropped.
+)
+
+// Options holds settings for the server.
+type Options struct {
+	Channel        uint16         // Channel ID reported in StreamInfo.
+	MaxSubscribers int            // Maximum number of concurrent Subscribe calls (<= 0 for no limit).
+	Config         *config.Config // Configuration returned by GetConfig (nil reports an empty config).
+
+	// TransportStats, if set, reports the UDP transport counters in GetStatus.
+	TransportStats func() []udp.PublisherStats
+}
+
+// Server implements the apiv1.EngineServer service. Periodic frames are produced on a
+// ticker between Start and Stop; the listener stays open until Close.
+type Server struct {
+	apiv1.UnimplementedEngineServer
+
+	features   analysis.Features // Processors to read from.
+	available  []apiv1.FrameType // Frame types the features can produce.
+	interval   time.Duration     // Interval between periodic updates.
+	options    Options           // Server options.
+	listener   net.Listener      // Bound listener.
+	grpcServer *grpc.Server      // gRPC server.
+	startedAt  time.Time         // When the server was created.
+	closing    chan struct{}     // Closed by Close to end Subscribe calls.
+	closeOnce  sync.Once         // Ensures closing is closed once.
+
+	subsMu sync.Mutex               // Protects subs.
+	subs   map[*subscriber]struct{} // Active Subscribe calls.
+
+	sequence atomic.Uint64      // Sequence number of the latest update.
+	counters transport.Counters // Frames sent to and dropped for all subscribers.
+
+	ticker   *time.Ticker   // Ticker that triggers updates.
+	doneChan chan struct{}  // Channel used to signal the update goroutine to stop.
+	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
+	wg       sync.WaitGroup // Waits for the update goroutine to finish during Stop.
+	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
+	due      []*subscriber  // Subscribers receiving the current update, only used by the update goroutine.
+}
+
+// subscriber is an active Subscribe call.
+type subscriber struct {
+	types       map[apiv1.FrameType]bool // Subscribed periodic frame types.
+	minInterval time.Duration            // Minimum time between updates, 0 for no limit.
+	lastSent    time.Time                // Time of the latest update queued.
+	frames      chan *apiv1.Frame        // Frames waiting to be sent.
+}
+
+// NewServer binds address (e.g. "127.0.0.1:50051") and starts serving gRPC calls.
+// Subscriptions are idle until Start is called. If the provided interval is invalid
+// (<= 0), it defaults to 16ms (~60Hz).
+func NewServer(address string, interval time.Duration, features analysis.Features, options Options) (*Server, error) {
+	if features.Spectrum == nil {
+		return nil, fmt.Errorf("rpc: spectrum provider cannot be nil")
+	}
+	if interval <= 0 {
+		interval = 16 * time.Millisecond // Default to ~60Hz if invalid
+		fmt.Printf("rpc: Invalid interval provided, defaulting to %s\n", interval)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("rpc: failed to listen on %s: %w", address, err)
+	}
+
+	s := &Server{
+		features:   features,
+		available:  availableFrameTypes(features),
+		interval:   interval,
+		options:    options,
+		listener:   listener,
+		grpcServer: grpc.NewServer(),
+		startedAt:  time.Now(),
+		closing:    make(chan struct{}),
+		subs:       make(map[*subscriber]struct{}),
+	}
+	apiv1.RegisterEngineServer(s.grpcServer, s)
+
+	go func() {
+		if err := s.grpcServer.Serve(listener); err != nil {
+			fmt.Printf("rpc: gRPC server error: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("rpc: Serving gRPC on %s (Interval: %s, Types: %v)\n", listener.Addr(), interval, s.available)
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Start begins producing periodic frames for subscribers.
+// It is safe to call Start multiple times; subsequent calls are no-ops if already started.
+func (s *Server) Start() {
+	s.mu.Lock()
+	if s.ticker != nil {
+		s.mu.Unlock()
+		fmt.Printf("rpc: Start called but already running.\n")
+		return
+	}
+
+	s.ticker = time.NewTicker(s.interval)
+	s.doneChan = make(chan struct{})
+	s.stopOnce = sync.Once{}
+
+	// Capture local variables for the goroutine to avoid data races on s.ticker/s.doneChan
+	ticker := s.ticker
+	doneChan := s.doneChan
+
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.broadcast(now)
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops producing periodic frames. Subscriptions stay open.
+// It is safe to call Stop multiple times; subsequent calls are no-ops.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	if s.ticker == nil {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.stopOnce.Do(func() {
+		close(s.doneChan)
+		s.ticker.Stop()
+		s.ticker = nil
+	})
+
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// streaming reports whether periodic frames are being produced.
+func (s *Server) streaming() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ticker != nil
+}
+
+// Close ends all subscriptions and stops the gRPC server.
+func (s *Server) Close() error {
+	fmt.Printf("rpc: Close called, stopping gRPC server...\n")
+	_ = s.Stop()
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		s.grpcServer.Stop()
+	}
+	return nil
+}
+
+// Subscribe implements apiv1.EngineServer. It sends StreamInfo, then periodic frames of
+// the requested types and detected events until the client cancels or the server closes.
+func (s *Server) Subscribe(req *apiv1.SubscribeRequest, stream grpc.ServerStreamingServer[apiv1.Frame]) error {
+	sub := &subscriber{
+		types:  make(map[apiv1.FrameType]bool),
+		frames: make(chan *apiv1.Frame, subscriberFrameBuffer),
+	}
+	wantEvents := false
+	types := req.GetTypes()
+	if len(types) == 0 {
+		types = []apiv1.FrameType{apiv1.FrameType_FRAME_TYPE_SPECTRUM}
+	}
+	for _, t := range types {
+		if !s.isAvailable(t) {
+			return status.Errorf(codes.InvalidArgument, "frame type %s is not enabled on this server", t)
+		}
+		if t == apiv1.FrameType_FRAME_TYPE_EVENT {
+			wantEvents = true
+			continue
+		}
+		sub.types[t] = true
+	}
+	if rate := req.GetMaxRate(); rate < 0 {
+		return status.Error(codes.InvalidArgument, "max_rate cannot be negative")
+	} else if rate > 0 {
+		sub.minInterval = time.Duration(float64(time.Second) / rate)
+	}
+
+	if !s.addSubscriber(sub) {
+		return status.Error(codes.ResourceExhausted, "too many subscribers")
+	}
+	defer s.removeSubscriber(sub)
+
+	remote := "unknown"
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remote = p.Addr.String()
+	}
+	fmt.Printf("rpc: Subscriber connected from %s (Types: %v, Max rate: %g)\n", remote, types, req.GetMaxRate())
+	defer fmt.Printf("rpc: Subscriber %s disconnected\n", remote)
+
+	var events <-chan analysis.Event
+	if wantEvents && s.features.Events != nil {
+		ch, cancel := s.features.Events.Subscribe(subscriberEventBuffer)
+		defer cancel()
+		events = ch
+	}
+
+	info := &apiv1.Frame{
+		Sequence:    s.sequence.Load(),
+		TimestampNs: time.Now().UnixNano(),
+		Payload:     &apiv1.Frame_Info{Info: s.streamInfo()},
+	}
+	if err := s.send(stream, info); err != nil {
+		return err
+	}
+
+	for {
+		var frame *apiv1.Frame
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.closing:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case frame = <-sub.frames:
+		case e, ok := <-events:
+			if !ok {
+				events = nil // Bus closed, keep sending periodic frames.
+				continue
+			}
+			frame = &apiv1.Frame{
+				Sequence:    s.sequence.Load(),
+				TimestampNs: e.Time.UnixNano(),
+				Payload: &apiv1.Frame_Event{Event: &apiv1.Event{
+					Source: e.Source,
+					Name:   e.Name,
+					Label:  e.Label,
+					Value:  e.Value,
+				}},
+			}
+		}
+		if err := s.send(stream, frame); err != nil {
+			return err
+		}
+	}
+}
+
+// send sends frame on stream and counts it.
+func (s *Server) send(stream grpc.ServerStreamingServer[apiv1.Frame], frame *apiv1.Frame) error {
+	err := stream.Send(frame)
+	s.counters.Record(proto.Size(frame), err)
+	return err
+}
+
+// Stats returns the frames sent to and dropped for all subscribers since the server
+// started, and the number of active subscribers. Bytes counts the encoded frames,
+// without gRPC and HTTP/2 framing.
+func (s *Server) Stats() transport.Stats {
+	stats := s.counters.Load()
+	s.subsMu.Lock()
+	stats.Clients = len(s.subs)
+	s.subsMu.Unlock()
+	return stats
+}
+
+// GetStatus implements apiv1.EngineServer.
+func (s *Server) GetStatus(context.Context, *apiv1.GetStatusRequest) (*apiv1.Status, error) {
+	stats := s.Stats()
+	st := &apiv1.Status{
+		Streaming:     s.streaming(),
+		StartedAtNs:   s.startedAt.UnixNano(),
+		Subscribers:   uint32(stats.Clients),
+		FramesSent:    stats.Messages,
+		FramesDropped: stats.Dropped,
+		Stream:        s.streamInfo(),
+	}
+	if s.options.TransportStats != nil {
+		for _, t := range s.options.TransportStats() {
+			st.Transports = append(st.Transports, transportStats(t))
+		}
+	}
+	return st, nil
+}
+
+// transportStats converts the counters of a UDP stream.
+func transportStats(t udp.PublisherStats) *apiv1.TransportStats {
+	msg := &apiv1.TransportStats{
+		Target:        t.Target,
+		Subscriber:    t.Subscriber,
+		Messages:      t.Messages,
+		Skipped:       t.Skipped,
+		FramesDropped: t.FramesDropped,
+		Packets:       t.Packets,
+		Bytes:         t.Bytes,
+		Dropped:       t.Dropped,
+		Errors:        make(map[string]uint64, len(t.Errors)),
+		Paused:        t.Paused,
+		LastError:     t.LastError,
+	}
+	for class, n := range t.Errors {
+		msg.Errors[string(class)] = n
+	}
+	if !t.LastErrorTime.IsZero() {
+		msg.LastErrorAtNs = t.LastErrorTime.UnixNano()
+	}
+	return msg
+}
+
+// GetConfig implements apiv1.EngineServer.
+func (s *Server) GetConfig(context.Context, *apiv1.GetConfigRequest) (*apiv1.Config, error) {
+	cfg := s.options.Config
+	if cfg == nil {
+		return &apiv1.Config{}, nil
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode config: %v", err)
+	}
+	return &apiv1.Config{
+		SampleRate:      cfg.Audio.SampleRate,
+		FramesPerBuffer: uint32(cfg.Audio.FramesPerBuffer),
+		InputChannels:   uint32(cfg.Audio.InputChannels),
+		FftWindow:       cfg.Audio.FFTWindow,
+		Yaml:            string(data),
+	}, nil
+}
+
+// addSubscriber registers sub, or returns false if the subscriber limit is reached.
+func (s *Server) addSubscriber(sub *subscriber) bool {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if s.options.MaxSubscribers > 0 && len(s.subs) >= s.options.MaxSubscribers {
+		return false
+	}
+	s.subs[sub] = struct{}{}
+	return true
+}
+
+// removeSubscriber unregisters sub.
+func (s *Server) removeSubscriber(sub *subscriber) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	delete(s.subs, sub)
+}
+
+// streamInfo describes the stream and the available frame types.
+func (s *Server) streamInfo() *apiv1.StreamInfo {
+	return &apiv1.StreamInfo{
+		SampleRate: s.features.Spectrum.GetSampleRate(),
+		FftSize:    uint32(s.features.Spectrum.GetFFTSize()),
+		Channel:    uint32(s.options.Channel),
+		Available:  s.available,
+		UpdateRate: float64(time.Second) / float64(s.interval),
+	}
+}
+
+// isAvailable reports whether frames of type t can be produced.
+func (s *Server) isAvailable(t apiv1.FrameType) bool {
+	for _, a := range s.available {
+		if a == t {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcast builds the frames subscribers due for an update asked for, once per type,
+// and queues them. Subscribers that fall behind drop frames.
+func (s *Server) broadcast(now time.Time) {
+	s.subsMu.Lock()
+	s.due = s.due[:0]
+	wanted := make(map[apiv1.FrameType]bool)
+	for sub := range s.subs {
+		if sub.minInterval > 0 && now.Sub(sub.lastSent) < sub.minInterval-s.interval/2 {
+			continue
+		}
+		sub.lastSent = now
+		s.due = append(s.due, sub)
+		for t := range sub.types {
+			wanted[t] = true
+		}
+	}
+	s.subsMu.Unlock()
+
+	if len(s.due) == 0 {
+		return
+	}
+
+	sequence := s.sequence.Add(1)
+	frames := make(map[apiv1.FrameType]*apiv1.Frame, len(wanted))
+	for t := range wanted {
+		if frame, ok := featureFrame(s.features, t, sequence, now.UnixNano()); ok {
+			frames[t] = frame
+		}
+	}
+
+	for _, sub := range s.due {
+		for _, t := range s.available {
+			frame, ok := frames[t]
+			if !ok || !sub.types[t] {
+				continue
+			}
+			select {
+			case sub.frames <- frame:
+			default:
+				s.counters.Drop()
+			}
+		}
+	}
+	clear(s.due)
+}
+
+// Ensure Server implements the generated service interface at compile time.
+var _ apiv1.EngineServer = (*Server)(nil)
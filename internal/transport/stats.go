@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: MIT
+
+// Package transport holds what the transport packages share: the counters each of them
+// keeps of what it sends, which the metrics endpoint reports per transport.
+package transport
+
+import "sync/atomic"
+
+// Stats is a snapshot of a transport's Counters.
+type Stats struct {
+	Messages uint64 // Messages sent; servers count every copy written to a client.
+	Bytes    uint64 // Bytes sent, as written to the socket, file or stream.
+	Dropped  uint64 // Messages discarded because a client or target fell behind.
+	Errors   uint64 // Failed sends.
+	Clients  int    // Connected clients, for transports that serve them.
+}
+
+// Counters counts the messages a transport sends. The zero value is ready to use, and
+// all methods are safe for concurrent use and don't allocate.
+type Counters struct {
+	messages atomic.Uint64
+	bytes    atomic.Uint64
+	dropped  atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// Record counts one message of size bytes as sent if err is nil, or as failed.
+func (c *Counters) Record(size int, err error) {
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.messages.Add(1)
+	c.bytes.Add(uint64(size))
+}
+
+// Drop counts one message discarded before it could be sent.
+func (c *Counters) Drop() {
+	c.dropped.Add(1)
+}
+
+// Load returns the current counts. Clients is left for the caller to fill.
+func (c *Counters) Load() Stats {
+	return Stats{
+		Messages: c.messages.Load(),
+		Bytes:    c.bytes.Load(),
+		Dropped:  c.dropped.Load(),
+		Errors:   c.errors.Load(),
+	}
+}
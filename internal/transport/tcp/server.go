@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: MIT
+
+// Package tcp streams spectrum packets to TCP clients. Every packet carries the same
+// binary format as UDP (see audio/pkg/protocol), prefixed with its length as a uint32.
+package tcp
+
+import (
+	"audio/internal/transport"
+	"audio/pkg/protocol"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueueSize is the number of frames queued per client when ServerOptions.QueueSize
+// is zero.
+const DefaultQueueSize = 64
+
+// writeTimeout is the maximum time a stalled client can block its writer before it is
+// disconnected.
+const writeTimeout = 5 * time.Second
+
+// ServerOptions controls a Server.
+type ServerOptions struct {
+	MaxClients int  // Maximum number of clients (<= 0 for no limit).
+	QueueSize  int  // Frames queued per client before the oldest is dropped (0 uses DefaultQueueSize).
+	Debug      bool // Log every connection.
+}
+
+// ClientStats holds the counters of one connected client.
+type ClientStats struct {
+	Address   string    // Remote address of the client.
+	Connected time.Time // Time the client connected.
+	Frames    uint64    // Frames written to the client.
+	Bytes     uint64    // Bytes written, including length prefixes.
+	Dropped   uint64    // Frames dropped because the client's queue was full.
+}
+
+// Server listens for TCP clients and sends every packet to all of them as a
+// length-prefixed frame. Each client has a bounded queue and its own writer; when a
+// client falls behind, its oldest queued frames are dropped, so a slow client never
+// blocks the publisher or the other clients.
+type Server struct {
+	listener net.Listener         // Listening socket.
+	options  ServerOptions        // Limits, with defaults applied.
+	mu       sync.Mutex           // Protects clients and closed.
+	clients  map[*client]struct{} // Connected clients.
+	closed   bool                 // Whether Close has been called.
+	wg       sync.WaitGroup       // Waits for the accept loop and writers during Close.
+	counters transport.Counters   // Frames written to all clients, past and present.
+}
+
+// client is a connection to a Server.
+type client struct {
+	conn      net.Conn      // Connection to the client.
+	connected time.Time     // Time the client connected.
+	queue     chan []byte   // Frames waiting to be written.
+	done      chan struct{} // Closed when the client is removed.
+
+	frames  atomic.Uint64 // Frames written.
+	bytes   atomic.Uint64 // Bytes written.
+	dropped atomic.Uint64 // Frames dropped from the queue.
+}
+
+// NewServer listens on address ("host:port", ":port" for all interfaces).
+func NewServer(address string, options ServerOptions) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on TCP address '%s': %w", address, err)
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+
+	s := &Server{
+		listener: listener,
+		options:  options,
+		clients:  make(map[*client]struct{}),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	fmt.Printf("TCP Server: Listening on %s (Max clients: %d, Queue: %d frames)\n",
+		listener.Addr(), options.MaxClients, options.QueueSize)
+	return s, nil
+}
+
+// Addr returns the address of the listening socket.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// acceptLoop accepts clients until the listener is closed.
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("TCP Server: Accept error: %v\n", err)
+			}
+			return
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.SetNoDelay(true) // Frames are written whole; don't hold them back.
+		}
+
+		c := &client{
+			conn:      conn,
+			connected: time.Now(),
+			queue:     make(chan []byte, s.options.QueueSize),
+			done:      make(chan struct{}),
+		}
+		s.mu.Lock()
+		if s.closed || (s.options.MaxClients > 0 && len(s.clients) >= s.options.MaxClients) {
+			s.mu.Unlock()
+			fmt.Printf("TCP Server: Rejecting %s, too many clients\n", conn.RemoteAddr())
+			_ = conn.Close()
+			continue
+		}
+		s.clients[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		if s.options.Debug {
+			fmt.Printf("TCP Server: Client %s connected\n", conn.RemoteAddr())
+		}
+		go s.writeLoop(c)
+	}
+}
+
+// writeLoop writes queued frames to c until it is removed or a write fails.
+func (s *Server) writeLoop(c *client) {
+	defer s.wg.Done()
+	defer s.remove(c)
+	for {
+		select {
+		case <-c.done:
+			return
+		case frame := <-c.queue:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			_, err := c.conn.Write(frame)
+			s.counters.Record(len(frame), err)
+			if err != nil {
+				fmt.Printf("TCP Server: Client %s disconnected after %d frames (%d dropped): %v\n",
+					c.conn.RemoteAddr(), c.frames.Load(), c.dropped.Load(), err)
+				return
+			}
+			c.frames.Add(1)
+			c.bytes.Add(uint64(len(frame)))
+		}
+	}
+}
+
+// remove disconnects c. It is safe to call multiple times.
+func (s *Server) remove(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	delete(s.clients, c)
+	close(c.done)
+	_ = c.conn.Close()
+}
+
+// Send queues packet, as one length-prefixed frame, for every connected client. A client
+// whose queue is full loses its oldest frame to make room. It never blocks on a client
+// and is safe for concurrent use.
+func (s *Server) Send(packet []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("TCP server is closed")
+	}
+	if len(s.clients) == 0 {
+		return nil
+	}
+
+	// Framed once, the same bytes are shared by every client's queue.
+	frame := protocol.AppendFrame(make([]byte, 0, protocol.FrameLengthSize+len(packet)), packet)
+	for c := range s.clients {
+		select {
+		case c.queue <- frame:
+			continue
+		default:
+		}
+		// Full: drop the oldest frame, unless the writer has just taken it. Only Send fills
+		// the queue, under mu, so there is room either way.
+		select {
+		case <-c.queue:
+			c.dropped.Add(1)
+			s.counters.Drop()
+		default:
+		}
+		c.queue <- frame
+	}
+	return nil
+}
+
+// Stats returns the counters of every connected client.
+func (s *Server) Stats() []ClientStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]ClientStats, 0, len(s.clients))
+	for c := range s.clients {
+		stats = append(stats, ClientStats{
+			Address:   c.conn.RemoteAddr().String(),
+			Connected: c.connected,
+			Frames:    c.frames.Load(),
+			Bytes:     c.bytes.Load(),
+			Dropped:   c.dropped.Load(),
+		})
+	}
+	return stats
+}
+
+// Totals returns the frames written to and dropped for all clients since the server
+// started, including those that have disconnected, and the number of connected clients.
+func (s *Server) Totals() transport.Stats {
+	stats := s.counters.Load()
+	s.mu.Lock()
+	stats.Clients = len(s.clients)
+	s.mu.Unlock()
+	return stats
+}
+
+// Close stops accepting clients and disconnects them. It is safe to call multiple times.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	fmt.Printf("TCP Server: Closing %s\n", s.listener.Addr())
+	err := s.listener.Close()
+	s.mu.Lock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c.done)
+		_ = c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to close TCP listener: %w", err)
+	}
+	return nil
+}
+
+// Ensure Server satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Server)(nil)
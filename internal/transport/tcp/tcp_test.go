@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: MIT
+package tcp
+
+import (
+	"audio/pkg/protocol"
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, options ServerOptions) *Server {
+	t.Helper()
+	s, err := NewServer("127.0.0.1:0", options)
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// waitClients waits until n clients are connected to s.
+func waitClients(t *testing.T, s *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Stats()) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients connected, want %d", len(s.Stats()), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaxClients: 2})
+
+	var readers []*bufio.Reader
+	for range 2 {
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial error: %v", err)
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		readers = append(readers, bufio.NewReader(conn))
+	}
+	waitClients(t, s, 2)
+
+	// A third client is over the limit and disconnected.
+	extra, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer extra.Close()
+	_ = extra.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := extra.Read(make([]byte, 1)); err == nil {
+		t.Error("client over the limit was not disconnected")
+	}
+
+	packets := [][]byte{{1, 2, 3}, make([]byte, 60000), {}}
+	for _, packet := range packets {
+		if err := s.Send(packet); err != nil {
+			t.Fatalf("Send error: %v", err)
+		}
+	}
+	for i, r := range readers {
+		for j, want := range packets {
+			got, err := protocol.ReadFrame(r, nil)
+			if err != nil {
+				t.Fatalf("client %d: ReadFrame %d error: %v", i, j, err)
+			}
+			if len(got) != len(want) || (len(want) > 0 && got[0] != want[0]) {
+				t.Errorf("client %d: frame %d has %d bytes, want %d", i, j, len(got), len(want))
+			}
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	if _, err := protocol.ReadFrame(readers[0], nil); err == nil {
+		t.Error("client still connected after Close")
+	}
+	if err := s.Send([]byte{1}); err == nil {
+		t.Error("Send after Close succeeded")
+	}
+}
+
+func TestServer_DropOldest(t *testing.T) {
+	s := newTestServer(t, ServerOptions{QueueSize: 4})
+
+	// A client without a writer never drains its queue, like one that stopped reading.
+	local, remote := net.Pipe()
+	defer remote.Close()
+	stalled := &client{conn: local, queue: make(chan []byte, 4), done: make(chan struct{})}
+	s.mu.Lock()
+	s.clients[stalled] = struct{}{}
+	s.mu.Unlock()
+
+	for i := range 10 {
+		done := make(chan error, 1)
+		go func() { done <- s.Send([]byte{byte(i)}) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Send error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Send blocked on a stalled client")
+		}
+	}
+
+	if got := stalled.dropped.Load(); got != 6 {
+		t.Errorf("dropped = %d, want 6", got)
+	}
+	// The newest frames are kept, in order.
+	for want := byte(6); want < 10; want++ {
+		frame := <-stalled.queue
+		if len(frame) != protocol.FrameLengthSize+1 || frame[protocol.FrameLengthSize] != want {
+			t.Errorf("queued frame = %v, want packet %d", frame, want)
+		}
+	}
+}
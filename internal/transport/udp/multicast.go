@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+package udp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// listenMulticast opens an unconnected socket for sending to a multicast group. A
+// connected socket would not do: the OS picks the route and source address when the
+// socket is connected, before the multicast interface can be set, so packets would leave
+// by the default route. With MulticastInterface set, the socket is bound to an address
+// of that interface, so packets carry it as their source.
+func listenMulticast(group *net.UDPAddr, options UDPSenderOptions) (*net.UDPConn, error) {
+	network := "udp6"
+	if group.IP.To4() != nil {
+		network = "udp4"
+	}
+	local := &net.UDPAddr{}
+	var ifi *net.Interface
+	if options.MulticastInterface != "" {
+		var err error
+		ifi, err = net.InterfaceByName(options.MulticastInterface)
+		if err != nil {
+			return nil, fmt.Errorf("unknown multicast interface %q: %w", options.MulticastInterface, err)
+		}
+		if ip := interfaceAddr(ifi, network == "udp4"); ip != nil {
+			local.IP = ip
+			if ip.IsLinkLocalUnicast() {
+				local.Zone = ifi.Name
+			}
+		}
+	}
+
+	conn, err := net.ListenUDP(network, local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	if err := configureMulticast(conn, group, ifi, options); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// interfaceAddr returns an IPv4 or IPv6 unicast address of ifi, preferring global IPv6
+// addresses over link-local ones, or nil if it has none.
+func interfaceAddr(ifi *net.Interface, v4 bool) net.IP {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	var linkLocal net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() != nil) != v4 {
+			continue
+		}
+		if ipnet.IP.IsLinkLocalUnicast() {
+			linkLocal = ipnet.IP
+			continue
+		}
+		return ipnet.IP
+	}
+	return linkLocal
+}
+
+// configureMulticast applies the multicast TTL/hop limit, outgoing interface (if ifi is
+// set) and loopback settings to a socket sending to a multicast group. IPv4 and IPv6 use
+// different socket options, so the group address decides which set is used.
+func configureMulticast(conn *net.UDPConn, group *net.UDPAddr, ifi *net.Interface, options UDPSenderOptions) error {
+	if group.IP.To4() != nil {
+		pc := ipv4.NewPacketConn(conn)
+		if options.MulticastTTL > 0 {
+			if err := pc.SetMulticastTTL(options.MulticastTTL); err != nil {
+				return fmt.Errorf("failed to set multicast TTL: %w", err)
+			}
+		}
+		if ifi != nil {
+			if err := pc.SetMulticastInterface(ifi); err != nil {
+				return fmt.Errorf("failed to set multicast interface: %w", err)
+			}
+		}
+		if err := pc.SetMulticastLoopback(options.MulticastLoopback); err != nil {
+			return fmt.Errorf("failed to set multicast loopback: %w", err)
+		}
+		return nil
+	}
+
+	pc := ipv6.NewPacketConn(conn)
+	if options.MulticastTTL > 0 {
+		if err := pc.SetMulticastHopLimit(options.MulticastTTL); err != nil {
+			return fmt.Errorf("failed to set multicast hop limit: %w", err)
+		}
+	}
+	if ifi != nil {
+		if err := pc.SetMulticastInterface(ifi); err != nil {
+			return fmt.Errorf("failed to set multicast interface: %w", err)
+		}
+	}
+	if err := pc.SetMulticastLoopback(options.MulticastLoopback); err != nil {
+		return fmt.Errorf("failed to set multicast loopback: %w", err)
+	}
+	return nil
+}
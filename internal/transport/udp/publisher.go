@@ -3,39 +3,100 @@ package udp
 
 import (
 	"audio/internal/analysis"
-	"bytes"
-	"encoding/binary"
+	"audio/pkg/protocol"
 	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// UDPPublisher periodically fetches analysis results (specifically FFT magnitudes),
+// UDPPublisherOptions holds optional settings controlling the packet format.
+type UDPPublisherOptions struct {
+	Legacy        bool   // Send the legacy header-less layout instead of the versioned protocol.
+	Channel       uint16 // Channel ID written to the header, identifies this stream on a shared port.
+	MaxPacketSize int    // Largest datagram sent; bigger messages are fragmented (<= 0 uses DefaultMaxPacketSize).
+
+	// Frames, if set, makes the publisher send once per completed analysis frame instead
+	// of on a timer, stamped with the frame's capture time. Decimation sends only every
+	// Decimation-th frame (<= 1 sends all).
+	Frames     *analysis.FrameBus
+	Decimation int
+
+	// Encoding selects a compact MessageSpectrumEncoded payload (quantized dB, deltas,
+	// compression). The zero value sends plain MessageSpectrum payloads.
+	Encoding protocol.SpectrumEncoding
+
+	// Sealer, if set, signs or encrypts every datagram (see FlagSigned and FlagEncrypted
+	// in audio/pkg/protocol). The security trailer counts towards MaxPacketSize.
+	Sealer *protocol.Sealer
+}
+
+// PacketSender delivers the packets of a UDPPublisher. It is usually a UDPSender; stream
+// transports (e.g. the TCP server) implement it as well.
+type PacketSender interface {
+	Send(packet []byte) error
+}
+
+// PublisherStats holds the counters of a UDPPublisher and its sender.
+type PublisherStats struct {
+	SenderStats
+	Subscriber    bool   // Whether the publisher serves a subscription rather than a configured target.
+	Messages      uint64 // Messages built and handed to the sender (one or more datagrams each).
+	Skipped       uint64 // Ticks or frames skipped because no matching spectrum was available.
+	FramesDropped uint64 // Frames missed in frame mode because the publisher fell behind.
+}
+
+// DefaultMaxPacketSize keeps datagrams below a 1500 byte Ethernet MTU once IP and UDP
+// headers (IPv4 or IPv6) are added, so they are never fragmented at the IP layer.
+const DefaultMaxPacketSize = 1400
+
+// Upper bounds of UDPPublisherOptions.MaxPacketSize.
+const (
+	// MaxPacketSize is the largest packet the header can describe: its payload length
+	// is 16 bits. Stream transports use it to send unfragmented messages.
+	MaxPacketSize = protocol.HeaderSize + math.MaxUint16
+	// MaxDatagramSize is the largest UDP payload over IPv4 (65535 less the IP and UDP
+	// headers), the limit when sending through a UDPSender.
+	MaxDatagramSize = 65507
+)
+
+// UDPPublisher periodically fetches analysis results (a magnitude spectrum),
 // packs them into a defined binary format, and sends them over UDP using a UDPSender.
-// It runs in a separate goroutine managed by Start and Stop methods.
+// It runs in a separate goroutine managed by Start and Stop methods, triggered either by
+// a ticker or, with UDPPublisherOptions.Frames, by completed analysis frames.
 type UDPPublisher struct {
-	sender   *UDPSender             // The underlying UDP sender instance.
-	fftProc  *analysis.FFTProcessor // The FFT processor to fetch magnitude data from.
-	interval time.Duration          // The interval at which packets are sent.
-
-	ticker   *time.Ticker   // Ticker that triggers packet sending.
+	sender   PacketSender               // The underlying sender, usually a UDPSender.
+	fftProc  analysis.FFTResultProvider // The spectrum provider to fetch magnitude data from.
+	interval time.Duration              // The interval at which packets are sent.
+	options  UDPPublisherOptions        // Packet format options.
+	encoder  *protocol.SpectrumEncoder  // Encoder of MessageSpectrumEncoded payloads, nil for plain spectra.
+	limit    int                        // Largest packet before sealing: MaxPacketSize less the security trailer.
+
+	ticker   *time.Ticker   // Ticker that triggers packet sending (also set in frame mode, to mark the publisher running).
 	doneChan chan struct{}  // Channel used to signal the publisher goroutine to stop.
 	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
 	wg       sync.WaitGroup // Waits for the publisher goroutine to finish during Stop.
 	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
 
 	sequenceNum uint32 // Monotonically increasing sequence number for packets.
+	lastFrame   uint64 // Index of the latest frame received in frame mode, plus one (0 before the first).
+
+	messages      atomic.Uint64 // Messages built, see PublisherStats.
+	skipped       atomic.Uint64 // Ticks or frames skipped.
+	framesDropped atomic.Uint64 // Frames missed in frame mode.
 
 	// Pre-allocated buffers to reduce allocations in the hot path (buildAndSendPacket).
-	udpMagBuffer []float64     // Buffer to receive float64 magnitudes from FFTProcessor.
-	udpF32Buffer []float32     // Buffer to hold float32 magnitudes for binary packing.
-	packetBuffer *bytes.Buffer // Reusable buffer for constructing the binary packet.
+	udpMagBuffer  []float64 // Buffer to receive float64 magnitudes from FFTProcessor.
+	udpF32Buffer  []float32 // Buffer to hold float32 magnitudes for binary packing.
+	payloadBuffer []byte    // Reusable buffer for the message payload (fragmented or encoded messages only).
+	packetBuffer  []byte    // Reusable buffer for constructing the binary packet.
 }
 
 // NewUDPPublisher creates and initializes a new UDPPublisher.
-// It requires a valid UDPSender and FFTProcessor.
+// It requires a valid sender and spectrum provider (an FFTProcessor or a view derived from one).
 // If the provided interval is invalid (<= 0), it defaults to 16ms (~60Hz).
-func NewUDPPublisher(interval time.Duration, sender *UDPSender, fftProc *analysis.FFTProcessor) (*UDPPublisher, error) {
+func NewUDPPublisher(interval time.Duration, sender PacketSender, fftProc analysis.FFTResultProvider, options UDPPublisherOptions) (*UDPPublisher, error) {
 	if sender == nil {
 		return nil, fmt.Errorf("UDPPublisher: UDP sender cannot be nil")
 	}
@@ -48,17 +109,80 @@ func NewUDPPublisher(interval time.Duration, sender *UDPSender, fftProc *analysi
 		fmt.Printf("UDPPublisher: Invalid interval provided, defaulting to %s\n", interval)
 	}
 
+	if options.MaxPacketSize <= 0 {
+		options.MaxPacketSize = DefaultMaxPacketSize
+	}
+	if options.MaxPacketSize > MaxPacketSize {
+		return nil, fmt.Errorf("UDPPublisher: max packet size %d exceeds the protocol limit of %d bytes", options.MaxPacketSize, MaxPacketSize)
+	}
+	if _, ok := sender.(*UDPSender); ok && options.MaxPacketSize > MaxDatagramSize {
+		return nil, fmt.Errorf("UDPPublisher: max packet size %d exceeds the UDP limit of %d bytes", options.MaxPacketSize, MaxDatagramSize)
+	}
+	options.Decimation = max(options.Decimation, 1)
+	limit := options.MaxPacketSize
+	if options.Sealer != nil {
+		if options.Legacy {
+			return nil, fmt.Errorf("UDPPublisher: legacy layout can't be signed or encrypted")
+		}
+		limit -= options.Sealer.Overhead()
+	}
+
 	// Determine required buffer size based on FFT size (N/2 + 1 bins)
 	requiredLen := fftProc.GetFFTSize()/2 + 1
-	fmt.Printf("UDPPublisher: Initializing (Interval: %s, FFT Bins: %d)\n", interval, requiredLen)
+	payloadLen := protocol.SpectrumPayloadSize(requiredLen)
+	var encoder *protocol.SpectrumEncoder
+	if !options.Encoding.Plain() {
+		if options.Legacy {
+			return nil, fmt.Errorf("UDPPublisher: legacy layout doesn't support spectrum encodings")
+		}
+		var err error
+		if encoder, err = protocol.NewSpectrumEncoder(options.Encoding); err != nil {
+			return nil, fmt.Errorf("UDPPublisher: %w", err)
+		}
+		// Upper bound: float32 samples that don't compress, plus codec framing.
+		payloadLen = protocol.EncodedHeaderSize + 4*requiredLen + 64
+	}
+	packetLen := protocol.HeaderSize + payloadLen
+	var payloadBuffer []byte
+	if options.Legacy {
+		if requiredLen > math.MaxUint16 {
+			return nil, fmt.Errorf("UDPPublisher: legacy layout cannot carry %d bins", requiredLen)
+		}
+		packetLen = protocol.LegacyHeaderSize + 4*requiredLen
+		if packetLen > limit {
+			fmt.Printf("UDPPublisher: Legacy packets (%d bytes) exceed max packet size %d and cannot be fragmented\n",
+				packetLen, options.MaxPacketSize)
+		}
+	} else if packetLen > limit {
+		chunkSize := limit - protocol.HeaderSize - protocol.FragmentHeaderSize
+		if chunkSize <= 0 {
+			return nil, fmt.Errorf("UDPPublisher: max packet size %d is too small to carry fragments", options.MaxPacketSize)
+		}
+		if (payloadLen+chunkSize-1)/chunkSize > math.MaxUint16 {
+			return nil, fmt.Errorf("UDPPublisher: %d bins need too many fragments at max packet size %d", requiredLen, options.MaxPacketSize)
+		}
+		packetLen = limit
+	}
+	if !options.Legacy && (encoder != nil || protocol.HeaderSize+payloadLen > limit) {
+		payloadBuffer = make([]byte, 0, payloadLen) // Staged before encoding into packets or fragments.
+	}
+	if options.Sealer != nil {
+		packetLen += options.Sealer.Overhead()
+	}
+	fmt.Printf("UDPPublisher: Initializing (Interval: %s, FFT Bins: %d, Legacy: %v, Channel: %d, MaxPacket: %d, Encoded: %v, Frames: %v/%d, Sealed: %v)\n",
+		interval, requiredLen, options.Legacy, options.Channel, options.MaxPacketSize, encoder != nil, options.Frames != nil, options.Decimation, options.Sealer != nil)
 
 	return &UDPPublisher{
-		sender:       sender,
-		fftProc:      fftProc,
-		interval:     interval,
-		udpMagBuffer: make([]float64, requiredLen), // Pre-allocate based on FFT size
-		udpF32Buffer: make([]float32, requiredLen), // Pre-allocate based on FFT size
-		packetBuffer: new(bytes.Buffer),            // Initialize the reusable packet buffer
+		sender:        sender,
+		fftProc:       fftProc,
+		interval:      interval,
+		options:       options,
+		encoder:       encoder,
+		limit:         limit,
+		udpMagBuffer:  make([]float64, requiredLen), // Pre-allocate based on FFT size
+		udpF32Buffer:  make([]float32, requiredLen), // Pre-allocate based on FFT size
+		payloadBuffer: payloadBuffer,
+		packetBuffer:  make([]byte, 0, packetLen), // Pre-allocate the reusable packet buffer
 		// mu, sequenceNum are zero-value ready
 		// ticker, doneChan, stopOnce, wg are initialized in Start/Stop
 	}, nil
@@ -88,6 +212,38 @@ func (p *UDPPublisher) Start() {
 
 	p.mu.Unlock() // Unlock before starting the potentially long-running goroutine
 
+	if p.options.Frames != nil {
+		// Frame mode: the ticker only marks the publisher as running.
+		ticker.Stop()
+		frames, cancel := p.options.Frames.Subscribe(4)
+		p.lastFrame = 0 // Frames before this run aren't missed.
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer cancel()
+			fmt.Printf("UDPPublisher: Publisher goroutine started (every %d frame(s))\n", p.options.Decimation)
+			for {
+				select {
+				case frame, ok := <-frames:
+					if !ok {
+						return // The frame bus was closed.
+					}
+					if p.lastFrame > 0 && frame.Index > p.lastFrame {
+						p.framesDropped.Add(frame.Index - p.lastFrame)
+					}
+					p.lastFrame = frame.Index + 1
+					if frame.Index%uint64(p.options.Decimation) == 0 {
+						p.buildAndSendPacket(&frame)
+					}
+				case <-doneChan:
+					fmt.Printf("UDPPublisher: Publisher goroutine received stop signal.\n")
+					return
+				}
+			}
+		}()
+		return
+	}
+
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
@@ -96,7 +252,7 @@ func (p *UDPPublisher) Start() {
 			select {
 			case <-ticker.C:
 				// Time to send a packet
-				p.buildAndSendPacket()
+				p.buildAndSendPacket(nil)
 			case <-doneChan:
 				// Stop signal received
 				fmt.Printf("UDPPublisher: Publisher goroutine received stop signal.\n")
@@ -135,41 +291,41 @@ func (p *UDPPublisher) Stop() error {
 	return nil
 }
 
-/*
-UDP Packet Structure (BigEndian) - See visual diagram below
-
-+-----------------------------------------------------------------------------+
-| Field             | Data Type      | Size (Bytes) | Description             |
-|-------------------|----------------|--------------|-------------------------|
-| Sequence Number   | uint32         | 4            | Monotonically increasing|
-| Timestamp         | int64          | 8            | Nanoseconds since epoch |
-| Magnitude Count   | uint16         | 2            | Number of floats (N)    |
-| Magnitudes        | []float32      | N * 4        | Array of FFT magnitudes |
-+-----------------------------------------------------------------------------+
-
-Visual Layout:
-
-|<---- 4 Bytes ---->|<------ 8 Bytes ------>|<-- 2 Bytes -->|<----- N * 4 Bytes ----->|
-+-------------------+-----------------------+---------------+-------------------------+
-|  Sequence Number  |       Timestamp       |   Magnitude   |       Magnitudes        |
-|      (uint32)     |        (int64)        |     Count     |      (N * float32)      |
-|                   |                       |     (uint16)  |                         |
-+-------------------+-----------------------+---------------+-------------------------+
-*/
-
-// buildAndSendPacket is the core function executed on each ticker interval.
-// It performs the following steps:
-// 1. Fetches the latest FFT magnitudes from the processor.
+// Packets use the versioned layout defined in audio/pkg/protocol: a 32 byte header
+// (magic, version, message type, flags, sequence, timestamp, sample rate, FFT size,
+// channel ID, payload length) followed by a MessageSpectrum payload, or a
+// MessageSpectrumEncoded payload when UDPPublisherOptions.Encoding selects a compact
+// encoding. Messages larger than MaxPacketSize are split into chunks with a fragment
+// header (frame ID, chunk index, chunk count, total length) that receivers reassemble
+// with pkg/client. With UDPPublisherOptions.Legacy the original header-less layout
+// (sequence, timestamp, uint16 count, magnitudes) is sent instead for receivers that
+// predate the header.
+
+// buildAndSendPacket is the core function executed on each ticker interval, or for each
+// frame in frame mode. It performs the following steps:
+// 1. Fetches the latest FFT magnitudes from the processor (those of frame, if set).
 // 2. Converts magnitudes from float64 to float32.
-// 3. Packs the sequence number, timestamp, count, and magnitudes into a binary buffer.
-// 4. Sends the resulting packet using the UDPSender.
-func (p *UDPPublisher) buildAndSendPacket() {
+// 3. Packs the header and magnitudes into a binary buffer (versioned or legacy layout).
+// 4. Sends the resulting packet (or its fragments) using the UDPSender.
+func (p *UDPPublisher) buildAndSendPacket(frame *analysis.Frame) {
 	// --- 1. Fetch Data ---
 
 	// Use GetMagnitudesInto to avoid allocations within the FFT processor.
-	err := p.fftProc.GetMagnitudesInto(p.udpMagBuffer)
+	var err error
+	if provider, ok := p.fftProc.(analysis.FrameResultProvider); ok && frame != nil {
+		var index uint64
+		index, err = provider.GetFrameInto(p.udpMagBuffer)
+		if err == nil && index != frame.Index {
+			// The next callback already replaced the spectrum; it is sent when its own
+			// frame notification arrives, so nothing is sent with the wrong timestamp.
+			p.skipped.Add(1)
+			return
+		}
+	} else {
+		err = p.fftProc.GetMagnitudesInto(p.udpMagBuffer)
+	}
 	if err != nil {
-		//fmt.Errorf("UDPPublisher: Error getting magnitudes: %v", err)
+		p.skipped.Add(1)
 		return // Skip sending this packet
 	}
 
@@ -191,48 +347,98 @@ func (p *UDPPublisher) buildAndSendPacket() {
 	// --- 3. Pack Data ---
 
 	// Prepare metadata for the packet header.
-	p.sequenceNum++                               // Increment sequence number for this packet.
-	timestamp := time.Now().UnixNano()            // Get current time for the timestamp.
-	magnitudeCount := uint16(len(p.udpF32Buffer)) // Get the number of magnitude values.
+	p.messages.Add(1)
+	p.sequenceNum++                    // Increment sequence number for this packet.
+	timestamp := time.Now().UnixNano() // Get current time for the timestamp.
+	if frame != nil {
+		timestamp = frame.Time.UnixNano() // Capture time of the analysed audio.
+	}
 
-	// Reset the reusable buffer before writing new packet data.
-	p.packetBuffer.Reset()
+	// Reuse the pre-allocated buffers, appending never grows them past their initial capacity.
+	if p.options.Legacy {
+		p.packetBuffer, err = protocol.AppendLegacySpectrum(p.packetBuffer[:0], p.sequenceNum, timestamp, p.udpF32Buffer)
+		if err != nil {
+			return // Skip sending this packet
+		}
+		p.send(p.packetBuffer)
+		return
+	}
 
-	// Write header fields (Sequence, Timestamp, Count) using BigEndian byte order.
-	// Chain error checks for cleaner code.
-	err = binary.Write(p.packetBuffer, binary.BigEndian, p.sequenceNum)
-	if err == nil {
-		err = binary.Write(p.packetBuffer, binary.BigEndian, timestamp)
+	header := protocol.Header{
+		Type:       protocol.MessageSpectrum,
+		Sequence:   p.sequenceNum,
+		Timestamp:  timestamp,
+		SampleRate: uint32(p.fftProc.GetSampleRate()),
+		FFTSize:    uint32(p.fftProc.GetFFTSize()),
+		Channel:    p.options.Channel,
 	}
-	if err == nil {
-		err = binary.Write(p.packetBuffer, binary.BigEndian, magnitudeCount)
+	payloadLen := protocol.SpectrumPayloadSize(len(p.udpF32Buffer))
+	if p.encoder != nil {
+		header.Type = protocol.MessageSpectrumEncoded
+		p.payloadBuffer = p.encoder.AppendPayload(p.payloadBuffer[:0], p.sequenceNum, p.fftProc.GetFFTSize(), p.udpF32Buffer)
+		payloadLen = len(p.payloadBuffer)
 	}
 
-	// Write payload (Magnitudes) using BigEndian byte order.
-	if err == nil {
-		err = binary.Write(p.packetBuffer, binary.BigEndian, p.udpF32Buffer)
-	}
+	// --- 4. Send Data ---
 
-	if err != nil {
-		// fmt.Errorf("UDPPublisher: Error packing data into binary buffer: %v", err)
-		return // Skip sending this packet
+	// Common case: the whole message fits in one datagram.
+	if protocol.HeaderSize+payloadLen <= p.limit {
+		header.PayloadLength = uint16(payloadLen)
+		p.packetBuffer = protocol.AppendHeader(p.packetBuffer[:0], header)
+		if p.encoder != nil {
+			p.packetBuffer = append(p.packetBuffer, p.payloadBuffer...)
+			p.send(p.packetBuffer)
+			return
+		}
+		p.packetBuffer = protocol.AppendSpectrum(p.packetBuffer, p.udpF32Buffer)
+		p.send(p.packetBuffer)
+		return
 	}
 
-	// --- 4. Send Data ---
+	// Otherwise split the payload into chunks, each carrying a copy of the header.
+	if p.encoder == nil {
+		p.payloadBuffer = protocol.AppendSpectrum(p.payloadBuffer[:0], p.udpF32Buffer)
+	}
+	chunkSize := p.limit - protocol.HeaderSize - protocol.FragmentHeaderSize
+	count := (len(p.payloadBuffer) + chunkSize - 1) / chunkSize
+	for i := range count {
+		chunk := p.payloadBuffer[i*chunkSize : min((i+1)*chunkSize, len(p.payloadBuffer))]
+		p.packetBuffer = protocol.AppendFragment(p.packetBuffer[:0], header, protocol.FragmentHeader{
+			FrameID:     p.sequenceNum,
+			Index:       uint16(i),
+			Count:       uint16(count),
+			TotalLength: uint32(len(p.payloadBuffer)),
+		}, chunk)
+		p.send(p.packetBuffer)
+	}
+}
 
-	// Get the packed bytes from the buffer.
-	packetBytes := p.packetBuffer.Bytes()
+// send transmits a single datagram using the underlying sender, sealing it first if
+// a Sealer is configured.
+func (p *UDPPublisher) send(packetBytes []byte) {
+	if p.options.Sealer != nil {
+		var err error
+		if packetBytes, err = p.options.Sealer.Seal(packetBytes); err != nil {
+			fmt.Printf("UDPPublisher: Failed to seal packet %d: %v\n", p.sequenceNum, err)
+			return
+		}
+	}
+	// Send the packet using the underlying sender. Errors are counted and, where
+	// appropriate, logged by the sender (see UDPSender.Stats).
+	_ = p.sender.Send(packetBytes)
+}
 
-	// Send the packet using the underlying sender.
-	err = p.sender.Send(packetBytes)
-	if err != nil {
-		// Error logging is handled within sender.Send based on its debug flag.
-		// No need to log the same error again here unless more context is needed.
-		// fmt.Errorf("UDPPublisher: Error sending packet %d: %v", p.sequenceNum, err)
-	} else {
-		// Log successful sends only at Debug level to avoid flooding logs.
-		fmt.Printf("UDPPublisher: Sent packet %d (%d bytes)\n", p.sequenceNum, len(packetBytes))
+// Stats returns a snapshot of the publisher's and, for a UDPSender, its sender's counters.
+func (p *UDPPublisher) Stats() PublisherStats {
+	stats := PublisherStats{
+		Messages:      p.messages.Load(),
+		Skipped:       p.skipped.Load(),
+		FramesDropped: p.framesDropped.Load(),
+	}
+	if sender, ok := p.sender.(*UDPSender); ok {
+		stats.SenderStats = sender.Stats()
 	}
+	return stats
 }
 
 // Close implements the io.Closer interface. It gracefully stops the publisher goroutine.
@@ -4,31 +4,99 @@ package udp
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"net"
-	"os"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 )
 
+// Backoff after repeated "connection refused" errors, see UDPSender.Send.
+const (
+	RefusedThreshold = 5                      // Refusals that pause the sender, and successful sends in a row that forgive them.
+	MinBackoff       = 500 * time.Millisecond // First pause.
+	MaxBackoff       = 30 * time.Second       // Longest pause.
+)
+
+// errorLogInterval is the shortest time between two logged send errors other than
+// refusals; errors in between are counted and reported with the next logged one.
+const errorLogInterval = 10 * time.Second
+
+// ErrPaused is returned by Send while the sender pauses because the target refuses packets.
+var ErrPaused = errors.New("UDP sender paused: target refuses packets")
+
+// ErrorClass classifies send errors in SenderStats.
+type ErrorClass string
+
+// Send error classes.
+const (
+	ErrorRefused     ErrorClass = "refused"     // Nothing listens at the target (ECONNREFUSED).
+	ErrorUnreachable ErrorClass = "unreachable" // No route to the target host or network.
+	ErrorTooLarge    ErrorClass = "too_large"   // Datagram exceeds what the path or socket allows (EMSGSIZE).
+	ErrorNoBuffer    ErrorClass = "no_buffer"   // Socket buffer full (ENOBUFS, EAGAIN).
+	ErrorPermission  ErrorClass = "permission"  // Rejected locally, e.g. by a firewall (EPERM, EACCES).
+	ErrorClosed      ErrorClass = "closed"      // The socket was closed.
+	ErrorOther       ErrorClass = "other"       // Anything else.
+)
+
+// SenderStats holds the counters of a UDPSender.
+type SenderStats struct {
+	Target        string                // Target address.
+	Packets       uint64                // Datagrams sent.
+	Bytes         uint64                // Size of the datagrams sent.
+	Dropped       uint64                // Datagrams not sent because the sender was paused.
+	Errors        map[ErrorClass]uint64 // Failed sends by class.
+	Paused        bool                  // Whether the sender currently pauses.
+	LastError     string                // Latest send error, empty if there was none.
+	LastErrorTime time.Time             // Time of the latest send error.
+}
+
 // UDPSender handles sending data packets over a UDP connection.
 // It uses a "connected" UDP socket (via net.DialUDP) for potentially
 // better performance and simpler sending logic, as the destination address
-// is fixed upon creation. It also handles graceful closing and provides
-// conditional logging for common network errors like "connection refused".
+// is fixed upon creation (multicast groups are sent to from an unconnected socket
+// instead, see NewUDPSender). It also handles graceful closing, counts packets and errors,
+// and backs off while the target refuses packets ("connection refused").
 type UDPSender struct {
-	conn       *net.UDPConn // The underlying "connected" UDP socket.
+	conn       *net.UDPConn // The underlying UDP socket, "connected" unless the target is multicast.
 	targetAddr *net.UDPAddr // The resolved target UDP address.
+	multicast  bool         // Whether the target is a multicast group, sent to from an unconnected socket.
 	mu         sync.Mutex   // Protects conn and closed status during concurrent access (e.g., Send vs Close).
 	closed     bool         // Flag indicating if the sender has been closed.
 	debug      bool         // Controls logging verbosity, specifically for connection refused errors.
+
+	// Counters and backoff state, protected by mu.
+	packets     uint64
+	bytes       uint64
+	dropped     uint64
+	errors      map[ErrorClass]uint64
+	lastErr     error
+	lastErrTime time.Time
+	refused     int           // Refusals since the last RefusedThreshold successful sends in a row.
+	okRun       int           // Successful sends in a row.
+	probing     bool          // Whether a pause ended and the target hasn't accepted enough packets yet.
+	backoff     time.Duration // Length of the latest pause.
+	pausedUntil time.Time     // End of the current pause, zero when sending.
+	lastLogged  time.Time     // Time of the latest logged error other than a refusal.
+	suppressed  int           // Errors other than refusals not logged since lastLogged.
+}
+
+// UDPSenderOptions holds optional socket settings, only used for multicast targets.
+type UDPSenderOptions struct {
+	MulticastTTL       int    // TTL (IPv4) or hop limit (IPv6) for multicast packets (<= 0 uses the OS default of 1).
+	MulticastInterface string // Name of the interface to send multicast packets on (empty uses the OS default).
+	MulticastLoopback  bool   // Deliver multicast packets to listeners on this host as well.
 }
 
 // NewUDPSender creates and initializes a new UDPSender targeting the specified address.
 // It resolves the address string and establishes a "connected" UDP socket using net.DialUDP.
+// If the address is an IPv4 or IPv6 multicast group, an unconnected socket is opened
+// instead and the multicast options are applied to it before the first packet is routed.
 // The debug flag controls whether transient "connection refused" errors are logged (at Debug level)
-// or suppressed during Send operations. Other errors are always logged at Error level.
-func NewUDPSender(targetAddress string, debug bool) (*UDPSender, error) {
+// or suppressed during Send operations. Other errors are always logged at Error level, at most
+// once every 10 seconds, with the number of errors suppressed in between.
+func NewUDPSender(targetAddress string, debug bool, options UDPSenderOptions) (*UDPSender, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", targetAddress)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve UDP target address '%s': %w", targetAddress, err)
@@ -38,76 +106,170 @@ func NewUDPSender(targetAddress string, debug bool) (*UDPSender, error) {
 	// This associates the remote address with the socket, allowing the use of Write()
 	// instead of WriteToUDP(), and potentially enabling the OS to report ICMP errors
 	// like "connection refused" (though this behavior can vary by OS).
-	conn, err := net.DialUDP("udp", nil, udpAddr)
-	if err != nil {
+	// Multicast groups don't refuse packets, and their socket options must be set before
+	// a route is chosen, so they use an unconnected socket instead.
+	multicast := udpAddr.IP.IsMulticast()
+	var conn *net.UDPConn
+	if multicast {
+		if conn, err = listenMulticast(udpAddr, options); err != nil {
+			return nil, fmt.Errorf("failed to configure multicast for target '%s': %w", targetAddress, err)
+		}
+		fmt.Printf("UDP Sender: Multicast configured for %s from %s (TTL: %d, Interface: %q, Loopback: %v)\n",
+			udpAddr, conn.LocalAddr(), options.MulticastTTL, options.MulticastInterface, options.MulticastLoopback)
+	} else if conn, err = net.DialUDP("udp", nil, udpAddr); err != nil {
 		return nil, fmt.Errorf("failed to dial UDP for target '%s': %w", targetAddress, err)
 	}
-	fmt.Printf("UDP Sender: Connection established to %s (Debug logging: %v)\n", conn.RemoteAddr().String(), debug)
+	fmt.Printf("UDP Sender: Connection established to %s (Debug logging: %v)\n", udpAddr, debug)
 
 	return &UDPSender{
 		conn:       conn,
 		targetAddr: udpAddr,
+		multicast:  multicast,
 		debug:      debug,
-		// mu, closed have zero values (unlocked, false)
+		errors:     make(map[ErrorClass]uint64),
+		// mu, closed and the remaining counters have zero values (unlocked, false)
 	}, nil
 }
 
 // Send transmits the given byte slice as a single UDP packet to the pre-configured target address.
-// It is safe for concurrent use.  If a "connection refused" error occurs (e.g., ICMP port unreachable),
-// it is only logged if the debug flag was set to true during initialization; otherwise, it's suppressed
-// to avoid log spam.  Other network errors encountered during sending are always logged at the Error level.
+// It is safe for concurrent use. Every packet and failure is counted (see Stats), with
+// errors classified by ClassifyError. Errors are logged at most once every 10 seconds,
+// except "connection refused" errors (e.g., ICMP port unreachable), which are only logged
+// if the debug flag was set during initialization; after
+// RefusedThreshold of them without RefusedThreshold successful sends in a row in between,
+// the sender pauses, dropping packets with ErrPaused, and probes the target again after a
+// backoff that doubles up to MaxBackoff while it keeps refusing. Once RefusedThreshold
+// probes in a row went through, the receiver is considered back and the backoff is reset.
 func (s *UDPSender) Send(data []byte) error {
 	s.mu.Lock() // Lock to prevent racing with Close()
+	defer s.mu.Unlock()
 	// Check if the sender has been closed already.
 	if s.closed {
-		s.mu.Unlock()
 		// Return an error immediately if closed.
 		return fmt.Errorf("UDP sender is closed")
 	}
 
+	now := time.Now()
+	if !s.pausedUntil.IsZero() {
+		if now.Before(s.pausedUntil) {
+			s.dropped++
+			return ErrPaused
+		}
+		s.pausedUntil = time.Time{} // Backoff elapsed: probe the target.
+		s.probing = true
+	}
+
 	// Use Write() on the "connected" UDP socket.
 	// This sends the data directly to the target address associated during DialUDP.
-	_, err := s.conn.Write(data)
-	s.mu.Unlock() // Unlock immediately after the network operation.
-
-	// Handle potential errors from the Write operation.
+	var err error
+	if s.multicast {
+		_, err = s.conn.WriteToUDP(data, s.targetAddr)
+	} else {
+		_, err = s.conn.Write(data)
+	}
 	if err != nil {
-		isConnRefused := false
-		// Check specifically for "connection refused" errors. This often indicates
-		// that no process is listening on the target port, causing the OS to send
-		// back an ICMP Port Unreachable message, which Go surfaces as ECONNREFUSED.
-		if opError, ok := err.(*net.OpError); ok {
-			if sysErr, ok := opError.Err.(*os.SyscallError); ok {
-				// Use errors.Is for robust checking against the specific syscall error.
-				if errors.Is(sysErr.Err, syscall.ECONNREFUSED) {
-					isConnRefused = true
-				}
-			}
-			// Fallback: Check the error string as well, as error wrapping might vary.
-			if !isConnRefused && strings.Contains(opError.Err.Error(), "connection refused") {
-				isConnRefused = true
-			}
-		}
-
-		// Conditional logging based on error type and debug flag.
-		if isConnRefused {
-			// Only log "connection refused" if debug mode is enabled.
+		class := ClassifyError(err)
+		s.errors[class]++
+		s.lastErr, s.lastErrTime = err, now
+		if class == ErrorRefused {
+			// Refusals are reported for an earlier packet: the OS learns from an ICMP
+			// message that nothing listens at the target and fails the next write, so
+			// with a steady stream at most every other write fails.
 			if s.debug {
 				fmt.Printf("UDP Sender: Send error (connection refused): %v\n", err)
 			}
-			// If not debugging, suppress this specific error to avoid log noise.
-		} else {
-			// Log all other types of send errors at the Error level.
-			return fmt.Errorf("UDP Sender: Send error: %v", err)
+			s.refused++
+			s.okRun = 0
+			if s.probing || s.refused >= RefusedThreshold {
+				s.pause(now)
+			}
+		} else if s.shouldLog(now) {
+			if s.suppressed > 0 {
+				fmt.Printf("UDP Sender: %d more send errors to %s were not logged\n", s.suppressed, s.targetAddr)
+				s.suppressed = 0
+			}
+			fmt.Printf("UDP Sender: Send error to %s (%s): %v\n", s.targetAddr, class, err)
 		}
 		// Wrap the original error and return it to the caller.
 		return fmt.Errorf("failed to send UDP packet: %w", err)
 	}
 
-	// No error occurred.
+	s.packets++
+	s.bytes += uint64(len(data))
+	if s.okRun++; s.okRun >= RefusedThreshold {
+		s.refused = 0
+		if s.probing {
+			fmt.Printf("UDP Sender: %s accepts packets again, resuming\n", s.targetAddr)
+			s.probing, s.backoff = false, 0
+		}
+	}
 	return nil
 }
 
+// shouldLog reports whether an error other than a refusal at now should be logged,
+// counting it as suppressed if not. Called with mu held.
+func (s *UDPSender) shouldLog(now time.Time) bool {
+	if !s.lastLogged.IsZero() && now.Sub(s.lastLogged) < errorLogInterval {
+		s.suppressed++
+		return false
+	}
+	s.lastLogged = now
+	return true
+}
+
+// pause stops sending until the backoff has elapsed, doubling it. Called with mu held.
+func (s *UDPSender) pause(now time.Time) {
+	s.backoff = min(max(2*s.backoff, MinBackoff), MaxBackoff)
+	s.pausedUntil = now.Add(s.backoff)
+	s.refused, s.okRun = 0, 0
+	if !s.probing || s.debug {
+		fmt.Printf("UDP Sender: %s refuses packets, pausing for %s\n", s.targetAddr, s.backoff)
+	}
+	s.probing = false
+}
+
+// Stats returns a snapshot of the sender's counters.
+func (s *UDPSender) Stats() SenderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := SenderStats{
+		Target:  s.targetAddr.String(),
+		Packets: s.packets,
+		Bytes:   s.bytes,
+		Dropped: s.dropped,
+		Errors:  maps.Clone(s.errors),
+		Paused:  !s.pausedUntil.IsZero() && time.Now().Before(s.pausedUntil),
+	}
+	if s.lastErr != nil {
+		stats.LastError, stats.LastErrorTime = s.lastErr.Error(), s.lastErrTime
+	}
+	return stats
+}
+
+// ClassifyError returns the class of a send error.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case errors.Is(err, net.ErrClosed):
+		return ErrorClosed
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTDOWN), errors.Is(err, syscall.ENETDOWN):
+		return ErrorUnreachable
+	case errors.Is(err, syscall.EMSGSIZE):
+		return ErrorTooLarge
+	case errors.Is(err, syscall.ENOBUFS), errors.Is(err, syscall.EAGAIN):
+		return ErrorNoBuffer
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+		return ErrorPermission
+	case strings.Contains(err.Error(), "connection refused"):
+		// Fallback, as error wrapping might vary.
+		return ErrorRefused
+	default:
+		return ErrorOther
+	}
+}
+
 // Close closes the underlying UDP connection.
 // It ensures the connection is closed only once and is safe for concurrent use.
 // Returns an error if closing the connection fails.
@@ -126,7 +288,7 @@ func (s *UDPSender) Close() error {
 
 	// Check if the connection exists before trying to close it.
 	if s.conn != nil {
-		fmt.Printf("UDP Sender: Closing connection to %s\n", s.conn.RemoteAddr().String())
+		fmt.Printf("UDP Sender: Closing connection to %s\n", s.targetAddr)
 		// Close the UDP socket.
 		err := s.conn.Close()
 		s.conn = nil // Set to nil after closing to prevent further use.
@@ -0,0 +1,448 @@
+// SPDX-License-Identifier: MIT
+package udp
+
+import (
+	"audio/internal/analysis"
+	"audio/pkg/protocol"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults and limits for subscriptions.
+const (
+	DefaultLease          = 10 * time.Second       // Longest lease granted when SubscriptionOptions.Lease is unset.
+	DefaultMaxSubscribers = 16                     // Subscribers accepted when SubscriptionOptions.MaxSubscribers is unset.
+	MinLease              = time.Second            // Shortest lease granted on request.
+	MinInterval           = 5 * time.Millisecond   // Shortest interval granted on request.
+	expiryCheckInterval   = 250 * time.Millisecond // How often expired leases are looked for.
+	challengeRate         = 64                     // Challenges sent per second at most, to addresses that haven't echoed a cookie.
+)
+
+// SubscriptionOptions controls a SubscriptionServer.
+type SubscriptionOptions struct {
+	Lease          time.Duration       // Longest lease granted (<= 0 uses DefaultLease).
+	MaxSubscribers int                 // Subscribers served at once (<= 0 uses DefaultMaxSubscribers).
+	Interval       time.Duration       // Interval for subscribers that don't ask for one.
+	Publisher      UDPPublisherOptions // Packet options of the subscriber streams (Encoding enables MessageSpectrumEncoded, Sealer also seals acks).
+	Debug          bool                // Passed to the subscriber senders.
+
+	// Opener, if set, authenticates control messages; those that don't open are ignored.
+	// It is only used by the server's receive goroutine.
+	Opener *protocol.Opener
+}
+
+// SubscriptionServer accepts subscriptions on a control port (see the subscription
+// messages in audio/pkg/protocol). Every subscriber gets its own UDPPublisher streaming
+// the spectrum to the address the subscription came from, at the interval it asked for,
+// until its lease expires without renewal or it unsubscribes. Subscribers are served
+// while the server is started.
+//
+// Streaming only starts once the subscriber has echoed the cookie the server sent to its
+// address, which proves that it receives there. Until then it only gets that challenge,
+// no larger than its request and at most challengeRate per second across all addresses,
+// so requests with a forged source address can't turn the server into an amplifier.
+type SubscriptionServer struct {
+	conn     *net.UDPConn
+	spectrum analysis.FFTResultProvider
+	options  SubscriptionOptions
+	types    []protocol.MessageType // Spectrum message types offered, in order of preference.
+	secret   []byte                 // Key of the cookies, random per server.
+
+	challenges      int       // Challenges sent since challengeWindow, used by receive only.
+	challengeWindow time.Time // Start of the current second of challenges.
+
+	running  bool           // Whether the server goroutines are running.
+	doneChan chan struct{}  // Signals the expiry goroutine to stop.
+	wg       sync.WaitGroup // Waits for the server goroutines during Stop.
+	mu       sync.Mutex     // Protects running, doneChan and subs.
+
+	subs map[string]*subscriber // Active subscribers by address.
+}
+
+// subscriber is one active subscription.
+type subscriber struct {
+	sender    *UDPSender
+	publisher *UDPPublisher
+	interval  time.Duration
+	messages  protocol.MessageType
+	expires   time.Time
+}
+
+// NewSubscriptionServer listens for subscriptions on address and serves spectrum to
+// subscribers once started.
+func NewSubscriptionServer(address string, spectrum analysis.FFTResultProvider, options SubscriptionOptions) (*SubscriptionServer, error) {
+	if spectrum == nil {
+		return nil, fmt.Errorf("SubscriptionServer: spectrum provider cannot be nil")
+	}
+	if options.Lease <= 0 {
+		options.Lease = DefaultLease
+	}
+	if options.MaxSubscribers <= 0 {
+		options.MaxSubscribers = DefaultMaxSubscribers
+	}
+	if options.Interval <= 0 {
+		options.Interval = 16 * time.Millisecond
+	}
+	if options.Publisher.Legacy {
+		return nil, fmt.Errorf("SubscriptionServer: legacy layout can't be subscribed to")
+	}
+	options.Publisher.Frames = nil // Subscribers choose their own rate.
+
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("SubscriptionServer: failed to resolve control address '%s': %w", address, err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("SubscriptionServer: failed to listen on '%s': %w", address, err)
+	}
+
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SubscriptionServer: failed to create cookie key: %w", err)
+	}
+
+	types := []protocol.MessageType{protocol.MessageSpectrum}
+	if !options.Publisher.Encoding.Plain() {
+		types = []protocol.MessageType{protocol.MessageSpectrumEncoded, protocol.MessageSpectrum}
+	}
+	fmt.Printf("SubscriptionServer: Listening on %s (Lease: %s, MaxSubscribers: %d, Types: %v, Authenticated: %v)\n",
+		conn.LocalAddr(), options.Lease, options.MaxSubscribers, types, options.Opener != nil)
+
+	return &SubscriptionServer{
+		conn:     conn,
+		spectrum: spectrum,
+		options:  options,
+		types:    types,
+		secret:   secret,
+		subs:     make(map[string]*subscriber),
+	}, nil
+}
+
+// Addr returns the control address the server listens on.
+func (s *SubscriptionServer) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Subscribers returns the addresses of the active subscribers, sorted.
+func (s *SubscriptionServer) Subscribers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]string, 0, len(s.subs))
+	for addr := range s.subs {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+// Stats returns the counters of the subscriber streams, sorted by address.
+func (s *SubscriptionServer) Stats() []PublisherStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]PublisherStats, 0, len(s.subs))
+	for _, sub := range s.subs {
+		st := sub.publisher.Stats()
+		st.Subscriber = true
+		stats = append(stats, st)
+	}
+	slices.SortFunc(stats, func(a, b PublisherStats) int { return strings.Compare(a.Target, b.Target) })
+	return stats
+}
+
+// Start begins accepting subscriptions. It is safe to call Start multiple times;
+// subsequent calls are no-ops if already started.
+func (s *SubscriptionServer) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		fmt.Printf("SubscriptionServer: Start called but already running.\n")
+		return
+	}
+	s.running = true
+	s.doneChan = make(chan struct{})
+	_ = s.conn.SetReadDeadline(time.Time{}) // Undo the deadline set by Stop.
+
+	s.wg.Add(2)
+	go s.receive()
+	go s.expire(s.doneChan)
+}
+
+// Stop stops accepting subscriptions and ends all of them; subscribers have to subscribe
+// again after the next Start. It is safe to call Stop multiple times.
+func (s *SubscriptionServer) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	close(s.doneChan)
+	_ = s.conn.SetReadDeadline(time.Now()) // Unblock receive.
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for addr, sub := range s.subs {
+		if err := sub.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.subs, addr)
+	}
+	return firstErr
+}
+
+// receive handles control messages until Stop or Close.
+func (s *SubscriptionServer) receive() {
+	defer s.wg.Done()
+	buf := make([]byte, maxControlSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, os.ErrDeadlineExceeded) {
+				return
+			}
+			continue // Transient errors (e.g. ICMP reports on some systems).
+		}
+		packet := buf[:n]
+		if s.options.Opener != nil {
+			if packet, err = s.options.Opener.Open(packet, time.Now()); err != nil {
+				if s.options.Debug {
+					fmt.Printf("SubscriptionServer: Ignoring control message from %s: %v\n", addr, err)
+				}
+				continue
+			}
+		}
+		header, payload, err := protocol.ParseHeader(packet)
+		if err != nil {
+			continue
+		}
+		switch header.Type {
+		case protocol.MessageSubscribe:
+			request, err := protocol.ParseSubscription(payload)
+			if err != nil {
+				continue
+			}
+			cookie := s.cookie(addr)
+			if request.Cookie != cookie {
+				s.challenge(addr, cookie, n)
+				continue
+			}
+			granted := s.subscribe(addr, request)
+			if granted.Lease > 0 {
+				granted.Cookie = cookie
+			}
+			s.ack(addr, granted)
+		case protocol.MessageUnsubscribe:
+			// Only subscribers are answered; anyone else could name any address.
+			if s.unsubscribe(addr) {
+				s.ack(addr, protocol.Subscription{})
+			}
+		}
+	}
+}
+
+// maxControlSize is the largest control datagram read; subscriptions are tiny.
+const maxControlSize = 1024
+
+// subscribe adds or renews the subscription of addr and returns what was granted.
+func (s *SubscriptionServer) subscribe(addr *net.UDPAddr, request protocol.Subscription) protocol.Subscription {
+	// Grant the preferred spectrum type the subscriber asked for (any when it lists none).
+	var messages protocol.MessageType
+	for _, t := range s.types {
+		if len(request.Types) == 0 || slices.Contains(request.Types, t) {
+			messages = t
+			break
+		}
+	}
+	if messages == 0 {
+		return protocol.Subscription{}
+	}
+	interval := s.options.Interval
+	if request.Interval > 0 {
+		interval = max(request.Interval, MinInterval)
+	}
+	lease := s.options.Lease
+	if request.Lease > 0 {
+		lease = min(lease, max(request.Lease, MinLease))
+	}
+	granted := protocol.Subscription{Interval: interval, Lease: lease, Types: []protocol.MessageType{messages}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := addr.String()
+	if sub, ok := s.subs[key]; ok {
+		if sub.interval == interval && sub.messages == messages {
+			sub.expires = time.Now().Add(lease)
+			return granted
+		}
+		// Changed settings: replace the stream.
+		if err := sub.close(); err != nil {
+			fmt.Printf("SubscriptionServer: Error closing stream to %s: %v\n", key, err)
+		}
+		delete(s.subs, key)
+	}
+	if len(s.subs) >= s.options.MaxSubscribers {
+		fmt.Printf("SubscriptionServer: Rejecting %s, %d subscribers already\n", key, len(s.subs))
+		return protocol.Subscription{}
+	}
+
+	sender, err := NewUDPSender(key, s.options.Debug, UDPSenderOptions{})
+	if err != nil {
+		fmt.Printf("SubscriptionServer: Rejecting %s: %v\n", key, err)
+		return protocol.Subscription{}
+	}
+	options := s.options.Publisher
+	if messages == protocol.MessageSpectrum {
+		options.Encoding = protocol.SpectrumEncoding{}
+	}
+	publisher, err := NewUDPPublisher(interval, sender, s.spectrum, options)
+	if err != nil {
+		sender.Close()
+		fmt.Printf("SubscriptionServer: Rejecting %s: %v\n", key, err)
+		return protocol.Subscription{}
+	}
+	publisher.Start()
+	s.subs[key] = &subscriber{
+		sender:    sender,
+		publisher: publisher,
+		interval:  interval,
+		messages:  messages,
+		expires:   time.Now().Add(lease),
+	}
+	fmt.Printf("SubscriptionServer: Streaming %s to %s every %s (lease %s)\n", messages, key, interval, lease)
+	return granted
+}
+
+// unsubscribe ends the subscription of addr and reports whether it had one.
+func (s *SubscriptionServer) unsubscribe(addr *net.UDPAddr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := addr.String()
+	sub, ok := s.subs[key]
+	if !ok {
+		return false
+	}
+	if err := sub.close(); err != nil {
+		fmt.Printf("SubscriptionServer: Error closing stream to %s: %v\n", key, err)
+	}
+	delete(s.subs, key)
+	fmt.Printf("SubscriptionServer: %s unsubscribed\n", key)
+	return true
+}
+
+// cookie returns the cookie of addr: a keyed hash of the address, so the server needn't
+// remember the addresses it challenged. It is never zero, which means no cookie.
+func (s *SubscriptionServer) cookie(addr *net.UDPAddr) uint64 {
+	mac := hmac.New(sha256.New, s.secret)
+	address, _ := addr.AddrPort().MarshalBinary()
+	mac.Write(address)
+	return max(binary.BigEndian.Uint64(mac.Sum(nil)), 1)
+}
+
+// challenge answers a subscription without the right cookie with the cookie of addr,
+// unless the answer would be larger than the request of requestSize bytes or the rate
+// of challenges is exhausted.
+func (s *SubscriptionServer) challenge(addr *net.UDPAddr, cookie uint64, requestSize int) {
+	if now := time.Now(); now.Sub(s.challengeWindow) >= time.Second {
+		s.challengeWindow, s.challenges = now, 0
+	}
+	if s.challenges >= challengeRate {
+		if s.options.Debug {
+			fmt.Printf("SubscriptionServer: Not challenging %s, too many challenges\n", addr)
+		}
+		return
+	}
+	packet, err := s.control(protocol.Subscription{Cookie: cookie})
+	if err != nil || len(packet) > requestSize {
+		if s.options.Debug {
+			fmt.Printf("SubscriptionServer: Not challenging %s (request of %d bytes, error %v)\n", addr, requestSize, err)
+		}
+		return
+	}
+	s.challenges++
+	if _, err := s.conn.WriteToUDP(packet, addr); err != nil {
+		fmt.Printf("SubscriptionServer: Failed to challenge %s: %v\n", addr, err)
+	}
+}
+
+// ack answers a control message with the granted subscription.
+func (s *SubscriptionServer) ack(addr *net.UDPAddr, granted protocol.Subscription) {
+	packet, err := s.control(granted)
+	if err != nil {
+		fmt.Printf("SubscriptionServer: Failed to seal acknowledgement to %s: %v\n", addr, err)
+		return
+	}
+	if _, err := s.conn.WriteToUDP(packet, addr); err != nil {
+		fmt.Printf("SubscriptionServer: Failed to acknowledge %s: %v\n", addr, err)
+	}
+}
+
+// control builds an acknowledgement carrying granted, sealed if a Sealer is configured.
+func (s *SubscriptionServer) control(granted protocol.Subscription) ([]byte, error) {
+	packet := protocol.AppendControl(nil, protocol.MessageSubscribeAck, granted)
+	if sealer := s.options.Publisher.Sealer; sealer != nil {
+		return sealer.Seal(packet)
+	}
+	return packet, nil
+}
+
+// expire ends subscriptions whose lease ran out, until done is closed.
+func (s *SubscriptionServer) expire(done <-chan struct{}) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(min(expiryCheckInterval, s.options.Lease/4))
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, sub := range s.subs {
+				if now.After(sub.expires) {
+					if err := sub.close(); err != nil {
+						fmt.Printf("SubscriptionServer: Error closing stream to %s: %v\n", key, err)
+					}
+					delete(s.subs, key)
+					fmt.Printf("SubscriptionServer: Lease of %s expired\n", key)
+				}
+			}
+			s.mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// close stops the subscriber's stream.
+func (sub *subscriber) close() error {
+	err := sub.publisher.Close()
+	if senderErr := sub.sender.Close(); err == nil {
+		err = senderErr
+	}
+	return err
+}
+
+// Close stops the server and closes the control socket.
+func (s *SubscriptionServer) Close() error {
+	fmt.Printf("SubscriptionServer: Close called, stopping ...\n")
+	err := s.Stop()
+	if closeErr := s.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Ensure SubscriptionServer satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*SubscriptionServer)(nil)
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: MIT
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return 48000 }
+
+// senderFunc is a PacketSender that isn't a UDPSender.
+type senderFunc func(packet []byte) error
+
+func (f senderFunc) Send(packet []byte) error { return f(packet) }
+
+func TestNewUDPPublisher_MaxPacketSize(t *testing.T) {
+	sender, err := NewUDPSender("127.0.0.1:9", false, UDPSenderOptions{})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+	stream := senderFunc(func([]byte) error { return nil })
+
+	for _, tc := range []struct {
+		name   string
+		sender PacketSender
+		size   int
+		ok     bool
+	}{
+		{"datagram limit", sender, MaxDatagramSize, true},
+		{"above datagram limit", sender, MaxDatagramSize + 1, false},
+		{"stream at protocol limit", stream, MaxPacketSize, true},
+		{"above protocol limit", stream, MaxPacketSize + 1, false},
+	} {
+		p, err := NewUDPPublisher(time.Second, tc.sender, make(spectrum, 9), UDPPublisherOptions{MaxPacketSize: tc.size})
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: NewUDPPublisher(%d) error = %v, want ok %v", tc.name, tc.size, err, tc.ok)
+		}
+		if p != nil {
+			p.Close()
+		}
+	}
+}
+
+func TestNewUDPSender_MulticastInterface(t *testing.T) {
+	ifi, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	group := &net.UDPAddr{IP: net.IPv4(239, 255, 42, 99), Port: 0}
+	listener, err := net.ListenMulticastUDP("udp4", ifi, group)
+	if err != nil {
+		t.Skipf("can't join a multicast group on %s: %v", ifi.Name, err)
+	}
+	defer listener.Close()
+	group.Port = listener.LocalAddr().(*net.UDPAddr).Port
+
+	sender, err := NewUDPSender(group.String(), false, UDPSenderOptions{MulticastInterface: ifi.Name, MulticastLoopback: true})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+
+	// The socket must be bound to the interface, not routed by the default route.
+	if local := sender.conn.LocalAddr().(*net.UDPAddr); !local.IP.IsLoopback() {
+		t.Errorf("socket bound to %s, want an address of %s", local, ifi.Name)
+	}
+
+	// The packet leaves by the interface, where the listener joined the group.
+	if err := sender.Send([]byte("hello")); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	_ = listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 16)
+	n, source, err := listener.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("packet not received on %s: %v", ifi.Name, err)
+	}
+	if string(buf[:n]) != "hello" || !source.IP.IsLoopback() {
+		t.Errorf("received %q from %s, want \"hello\" from a loopback address", buf[:n], source)
+	}
+}
+
+func TestUDPSender_ErrorLogRateLimit(t *testing.T) {
+	t.Parallel()
+	sender, err := NewUDPSender("127.0.0.1:9", false, UDPSenderOptions{})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+	sender.conn.Close() // Every send now fails with ErrorClosed.
+
+	for range 3 {
+		if err := sender.Send([]byte{1}); err == nil {
+			t.Fatal("Send on a closed socket succeeded")
+		}
+	}
+	if sender.lastLogged.IsZero() || sender.suppressed != 2 {
+		t.Errorf("after 3 errors: logged at %v, suppressed %d, want the first logged and 2 suppressed", sender.lastLogged, sender.suppressed)
+	}
+
+	sender.lastLogged = sender.lastLogged.Add(-errorLogInterval)
+	_ = sender.Send([]byte{1})
+	if sender.suppressed != 0 {
+		t.Errorf("suppressed = %d after the interval, want 0 (logged)", sender.suppressed)
+	}
+	if got := sender.Stats().Errors[ErrorClosed]; got != 4 {
+		t.Errorf("closed errors = %d, want 4", got)
+	}
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: MIT
+package web
+
+import (
+	"audio/internal/analysis"
+	"fmt"
+	"strings"
+)
+
+// MessageType identifies one kind of message a client can subscribe to.
+type MessageType int
+
+// Message types. Spectrum frames are the same v1 protocol packets the UDP publisher
+// sends (binary mode) or their JSON equivalent; the others are always JSON.
+const (
+	MessageSpectrum MessageType = iota // Magnitude spectrum.
+	MessageLevel                       // RMS and peak level (dBFS).
+	MessageBands                       // Band levels (dBFS).
+	MessageHPSS                        // Harmonic and percussive energies.
+	MessageVAD                         // Speech probability and state.
+	MessageChord                       // Recognised chord.
+	MessageChroma                      // Chroma vector.
+	MessagePeaks                       // Spectral peaks.
+	messageTypeCount
+)
+
+// messageTypeNames are the names used in JSON messages, subscriptions and URLs.
+var messageTypeNames = [messageTypeCount]string{
+	"spectrum", "level", "bands", "hpss", "vad", "chord", "chroma", "peaks",
+}
+
+// messageTypeAliases are alternative names accepted by ParseMessageType.
+var messageTypeAliases = map[string]MessageType{
+	"fft":    MessageSpectrum,
+	"levels": MessageLevel,
+}
+
+// String returns the name of the message type.
+func (t MessageType) String() string {
+	if t < 0 || t >= messageTypeCount {
+		return fmt.Sprintf("MessageType(%d)", int(t))
+	}
+	return messageTypeNames[t]
+}
+
+// ParseMessageType converts a message type name (e.g. "level") or alias (e.g. "fft") to
+// its MessageType.
+func ParseMessageType(name string) (MessageType, error) {
+	for i, n := range messageTypeNames {
+		if strings.EqualFold(name, n) {
+			return MessageType(i), nil
+		}
+	}
+	if t, ok := messageTypeAliases[strings.ToLower(name)]; ok {
+		return t, nil
+	}
+	return 0, fmt.Errorf("unknown message type %q (options: %s)", name, strings.Join(messageTypeNames[:], ", "))
+}
+
+// messageSet is a bit set of message types.
+type messageSet uint32
+
+func (s messageSet) has(t MessageType) bool { return s&(1<<t) != 0 }
+func (s messageSet) with(t MessageType) messageSet {
+	return s | 1<<t
+}
+
+// parseMessageSet parses a list of message type names. Each entry may itself be a comma
+// separated list, as in the types URL parameter.
+func parseMessageSet(names []string) (messageSet, error) {
+	var set messageSet
+	for _, entry := range names {
+		for name := range strings.SplitSeq(entry, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			t, err := ParseMessageType(name)
+			if err != nil {
+				return 0, err
+			}
+			set = set.with(t)
+		}
+	}
+	return set, nil
+}
+
+// available returns the message types that can be produced from features.
+func available(features analysis.Features) messageSet {
+	var set messageSet
+	for t, ok := range map[MessageType]bool{
+		MessageSpectrum: features.Spectrum != nil,
+		MessageLevel:    features.Levels != nil,
+		MessageBands:    features.Levels != nil,
+		MessageHPSS:     features.HPSS != nil,
+		MessageVAD:      features.VAD != nil,
+		MessageChord:    features.Chord != nil,
+		MessageChroma:   features.Chroma != nil,
+		MessagePeaks:    features.Peaks != nil,
+	} {
+		if ok {
+			set = set.with(t)
+		}
+	}
+	return set
+}
+
+// names returns the names of the message types in the set, in MessageType order.
+func (s messageSet) names() []string {
+	names := make([]string, 0, messageTypeCount)
+	for t := range messageTypeCount {
+		if s.has(t) {
+			names = append(names, t.String())
+		}
+	}
+	return names
+}
+
+// JSON message bodies. Every message carries its type and a Unix timestamp in nanoseconds.
+
+// HelloMessage is sent to every client once connected.
+type HelloMessage struct {
+	Type       string   `json:"type"` // "hello"
+	Version    int      `json:"version"`
+	Format     string   `json:"format"`      // "binary" or "json".
+	SampleRate float64  `json:"sample_rate"` // Sample rate of the analysed audio (Hz).
+	FFTSize    int      `json:"fft_size"`    // FFT size of the spectrum.
+	Available  []string `json:"available"`   // Message types this server produces.
+	Subscribed []string `json:"subscribed"`  // Message types sent to this client.
+	MaxRate    float64  `json:"max_rate"`    // Client message rate limit (per second, 0 for none).
+}
+
+// EventMessage carries an event detected by an analysis processor.
+type EventMessage struct {
+	Type      string  `json:"type"` // "event"
+	Timestamp int64   `json:"timestamp"`
+	Source    string  `json:"source"`          // Processor that detected the event (e.g. "vad").
+	Name      string  `json:"name"`            // Event name (e.g. "speech_start").
+	Label     string  `json:"label,omitempty"` // Optional label (e.g. a chord name).
+	Value     float64 `json:"value"`           // Strength or confidence.
+}
+
+// ErrorMessage reports an invalid control message or request.
+type ErrorMessage struct {
+	Type  string `json:"type"` // "error"
+	Error string `json:"error"`
+}
+
+// SpectrumMessage is the JSON form of a spectrum packet.
+type SpectrumMessage struct {
+	Type       string    `json:"type"` // "spectrum"
+	Timestamp  int64     `json:"timestamp"`
+	Sequence   uint32    `json:"sequence"`
+	SampleRate float64   `json:"sample_rate"`
+	FFTSize    int       `json:"fft_size"`
+	Channel    uint16    `json:"channel"`
+	Magnitudes []float32 `json:"magnitudes"`
+}
+
+// LevelMessage carries the RMS and peak sample level.
+type LevelMessage struct {
+	Type      string  `json:"type"` // "level"
+	Timestamp int64   `json:"timestamp"`
+	RMS       float64 `json:"rms"`  // dBFS
+	Peak      float64 `json:"peak"` // dBFS
+}
+
+// BandsMessage carries the band levels, lowest band first.
+type BandsMessage struct {
+	Type      string    `json:"type"` // "bands"
+	Timestamp int64     `json:"timestamp"`
+	Edges     []float64 `json:"edges"`  // Band edges (Hz), one more than levels.
+	Levels    []float64 `json:"levels"` // dBFS
+}
+
+// HPSSMessage carries the harmonic and percussive energies.
+type HPSSMessage struct {
+	Type       string  `json:"type"` // "hpss"
+	Timestamp  int64   `json:"timestamp"`
+	Harmonic   float64 `json:"harmonic"`
+	Percussive float64 `json:"percussive"`
+}
+
+// VADMessage carries the voice activity state.
+type VADMessage struct {
+	Type        string  `json:"type"` // "vad"
+	Timestamp   int64   `json:"timestamp"`
+	Probability float64 `json:"probability"`
+	Speaking    bool    `json:"speaking"`
+}
+
+// ChordMessage carries the recognised chord ("N" for none).
+type ChordMessage struct {
+	Type       string  `json:"type"` // "chord"
+	Timestamp  int64   `json:"timestamp"`
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ChromaMessage carries the chroma vector, C first.
+type ChromaMessage struct {
+	Type      string      `json:"type"` // "chroma"
+	Timestamp int64       `json:"timestamp"`
+	Values    [12]float64 `json:"values"`
+}
+
+// PeaksMessage carries the spectral peaks, strongest first.
+type PeaksMessage struct {
+	Type      string      `json:"type"` // "peaks"
+	Timestamp int64       `json:"timestamp"`
+	Peaks     []PeakValue `json:"peaks"`
+}
+
+// PeakValue is one spectral peak in a PeaksMessage.
+type PeakValue struct {
+	Frequency float64 `json:"frequency"` // Hz
+	Magnitude float64 `json:"magnitude"`
+	Bandwidth float64 `json:"bandwidth"` // Hz
+}
+
+// featureMessage reads the latest value of a feature (any type but MessageSpectrum) and
+// returns its JSON message body, or false if the feature is not available.
+func featureMessage(features analysis.Features, t MessageType, timestamp int64) (any, bool) {
+	switch t {
+	case MessageLevel:
+		if features.Levels == nil {
+			return nil, false
+		}
+		rms, peak := features.Levels.GetLevels()
+		return LevelMessage{Type: t.String(), Timestamp: timestamp, RMS: rms, Peak: peak}, true
+	case MessageBands:
+		if features.Levels == nil {
+			return nil, false
+		}
+		levels := make([]float64, features.Levels.BandCount())
+		features.Levels.GetBandsInto(levels)
+		return BandsMessage{Type: t.String(), Timestamp: timestamp, Edges: features.Levels.BandEdges(), Levels: levels}, true
+	case MessageHPSS:
+		if features.HPSS == nil {
+			return nil, false
+		}
+		harmonic, percussive := features.HPSS.GetEnergies()
+		return HPSSMessage{Type: t.String(), Timestamp: timestamp, Harmonic: harmonic, Percussive: percussive}, true
+	case MessageVAD:
+		if features.VAD == nil {
+			return nil, false
+		}
+		return VADMessage{
+			Type:        t.String(),
+			Timestamp:   timestamp,
+			Probability: features.VAD.GetSpeechProbability(),
+			Speaking:    features.VAD.IsSpeaking(),
+		}, true
+	case MessageChord:
+		if features.Chord == nil {
+			return nil, false
+		}
+		label, confidence := features.Chord.GetChord()
+		return ChordMessage{Type: t.String(), Timestamp: timestamp, Label: label, Confidence: confidence}, true
+	case MessageChroma:
+		if features.Chroma == nil {
+			return nil, false
+		}
+		msg := ChromaMessage{Type: t.String(), Timestamp: timestamp}
+		features.Chroma.GetChromaInto(&msg.Values)
+		return msg, true
+	case MessagePeaks:
+		if features.Peaks == nil {
+			return nil, false
+		}
+		peaks := features.Peaks.GetPeaks()
+		values := make([]PeakValue, len(peaks))
+		for i, p := range peaks {
+			values[i] = PeakValue{Frequency: p.Frequency, Magnitude: p.Magnitude, Bandwidth: p.Bandwidth}
+		}
+		return PeaksMessage{Type: t.String(), Timestamp: timestamp, Peaks: values}, true
+	default:
+		return nil, false
+	}
+}
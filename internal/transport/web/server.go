@@ -0,0 +1,390 @@
+// SPDX-License-Identifier: MIT
+
+// Package web serves analysis results to browsers and other HTTP clients from an
+// embedded HTTP server. Browsers cannot receive UDP, so the WebSocket endpoint streams
+// the same spectrum frames the UDP publisher sends, alongside JSON feature messages.
+package web
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport"
+	"audio/pkg/protocol"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Options holds settings for the server.
+type Options struct {
+	Channel        uint16   // Channel ID written to binary spectrum packets.
+	MaxClients     int      // Maximum number of concurrent WebSocket and SSE clients (<= 0 for no limit).
+	AllowedOrigins []string // Origins allowed to open a WebSocket; empty allows any origin.
+}
+
+// Server is an embedded HTTP server streaming analysis results. Frames are produced on
+// a ticker between Start and Stop; the listener stays open until Close.
+//
+// Endpoints:
+//
+//	GET /ws               WebSocket stream, see handleWebSocket.
+//	GET /events           Server-Sent Events stream, see handleEvents.
+//	GET /snapshot         Latest value of every message type, see handleSnapshot.
+//	GET /snapshot/{type}  Latest value of one message type (e.g. /snapshot/fft).
+type Server struct {
+	features   analysis.Features // Processors to read from.
+	available  messageSet        // Message types the features can produce.
+	interval   time.Duration     // Interval between frames.
+	options    Options           // Server options.
+	listener   net.Listener      // Bound listener.
+	httpServer *http.Server      // HTTP server serving mux.
+	mux        *http.ServeMux    // Request router.
+	upgrader   websocket.Upgrader
+
+	clientsMu sync.Mutex           // Protects clients and sseCount.
+	clients   map[*client]struct{} // Connected WebSocket clients.
+	sseCount  int                  // Connected SSE clients.
+	serveDone chan struct{}        // Closed when the HTTP server has returned.
+	closing   chan struct{}        // Closed by Close to end long-lived responses (SSE).
+	closeOnce sync.Once            // Ensures closing is closed once.
+
+	ticker   *time.Ticker   // Ticker that triggers frames.
+	doneChan chan struct{}  // Channel used to signal the frame goroutine to stop.
+	stopOnce sync.Once      // Ensures the stop logic runs only once per Start/Stop cycle.
+	wg       sync.WaitGroup // Waits for the frame goroutine to finish during Stop.
+	mu       sync.Mutex     // Protects access to ticker and doneChan during Start/Stop.
+
+	sequence atomic.Uint32      // Sequence number of the latest spectrum frame.
+	counters transport.Counters // Messages written to WebSocket and SSE clients.
+
+	// Frame state, only touched by the frame goroutine.
+	magBuffer []float64 // Spectrum magnitudes.
+	f32Buffer []float32 // Spectrum magnitudes as float32.
+	due       []*client // Clients receiving the current frame.
+}
+
+// NewServer binds address (e.g. ":8080") and starts serving HTTP requests. Streams
+// are idle until Start is called. If the provided interval is invalid (<= 0), it
+// defaults to 16ms (~60Hz).
+func NewServer(address string, interval time.Duration, features analysis.Features, options Options) (*Server, error) {
+	if features.Spectrum == nil {
+		return nil, fmt.Errorf("web: spectrum provider cannot be nil")
+	}
+	if interval <= 0 {
+		interval = 16 * time.Millisecond // Default to ~60Hz if invalid
+		fmt.Printf("web: Invalid interval provided, defaulting to %s\n", interval)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("web: failed to listen on %s: %w", address, err)
+	}
+
+	bins := features.Spectrum.GetFFTSize()/2 + 1
+	s := &Server{
+		features:  features,
+		available: available(features),
+		interval:  interval,
+		options:   options,
+		listener:  listener,
+		mux:       http.NewServeMux(),
+		clients:   make(map[*client]struct{}),
+		serveDone: make(chan struct{}),
+		closing:   make(chan struct{}),
+		magBuffer: make([]float64, bins),
+		f32Buffer: make([]float32, bins),
+	}
+	s.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 4096,
+		CheckOrigin:     s.checkOrigin,
+	}
+	s.mux.HandleFunc("GET /ws", s.handleWebSocket)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	s.mux.HandleFunc("GET /snapshot", s.handleSnapshot)
+	s.mux.HandleFunc("GET /snapshot/{type}", s.handleSnapshot)
+	s.httpServer = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		defer close(s.serveDone)
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("web: HTTP server error: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("web: Serving on http://%s (Interval: %s, Types: %v)\n", listener.Addr(), interval, s.available.names())
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// allowCORS sets the CORS header for browser requests from an allowed origin, so
+// dashboards served elsewhere can poll the snapshot and event endpoints.
+func (s *Server) allowCORS(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" && s.checkOrigin(r) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+}
+
+// checkOrigin allows WebSocket requests from the configured origins. Requests without an
+// Origin header come from non-browser clients and are always allowed.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || len(s.options.AllowedOrigins) == 0 || slices.Contains(s.options.AllowedOrigins, origin)
+}
+
+// Start begins producing frames for connected clients.
+// It is safe to call Start multiple times; subsequent calls are no-ops if already started.
+func (s *Server) Start() {
+	s.mu.Lock()
+	if s.ticker != nil {
+		s.mu.Unlock()
+		fmt.Printf("web: Start called but already running.\n")
+		return
+	}
+
+	s.ticker = time.NewTicker(s.interval)
+	s.doneChan = make(chan struct{})
+	s.stopOnce = sync.Once{}
+
+	// Capture local variables for the goroutine to avoid data races on s.ticker/s.doneChan
+	ticker := s.ticker
+	doneChan := s.doneChan
+
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.broadcast(now)
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops producing frames. Clients stay connected.
+// It is safe to call Stop multiple times; subsequent calls are no-ops.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	if s.ticker == nil {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.stopOnce.Do(func() {
+		close(s.doneChan)
+		s.ticker.Stop()
+		s.ticker = nil
+	})
+
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Stats returns the messages written to and dropped for all WebSocket and SSE clients
+// since the server started, and the number of connected clients.
+func (s *Server) Stats() transport.Stats {
+	stats := s.counters.Load()
+	s.clientsMu.Lock()
+	stats.Clients = len(s.clients) + s.sseCount
+	s.clientsMu.Unlock()
+	return stats
+}
+
+// Close stops producing frames, disconnects all clients and shuts the HTTP server down.
+func (s *Server) Close() error {
+	fmt.Printf("web: Close called, shutting down server...\n")
+	_ = s.Stop()
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	s.clientsMu.Lock()
+	for c := range s.clients {
+		c.close()
+	}
+	s.clientsMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := s.httpServer.Shutdown(ctx)
+	<-s.serveDone
+	if err != nil {
+		return fmt.Errorf("web: failed to shut down HTTP server: %w", err)
+	}
+	return nil
+}
+
+// broadcast builds the messages that clients due for an update subscribed to and hands
+// the frame to each of them. Slow clients drop frames rather than delay the others.
+func (s *Server) broadcast(now time.Time) {
+	var types messageSet
+	var binarySpectrum, jsonSpectrum bool
+
+	s.clientsMu.Lock()
+	s.due = s.due[:0]
+	for c := range s.clients {
+		set, binary, ok := c.due(now, s.interval)
+		if !ok {
+			continue
+		}
+		s.due = append(s.due, c)
+		types |= set
+		if set.has(MessageSpectrum) {
+			binarySpectrum = binarySpectrum || binary
+			jsonSpectrum = jsonSpectrum || !binary
+		}
+	}
+	s.clientsMu.Unlock()
+
+	if len(s.due) == 0 {
+		return
+	}
+
+	f := &frame{}
+	timestamp := now.UnixNano()
+	if types.has(MessageSpectrum) {
+		s.buildSpectrum(f, timestamp, binarySpectrum, jsonSpectrum)
+	}
+	for t := MessageSpectrum + 1; t < messageTypeCount; t++ {
+		if !types.has(t) {
+			continue
+		}
+		if msg, ok := featureMessage(s.features, t, timestamp); ok {
+			f.messages[t], _ = json.Marshal(msg)
+		}
+	}
+
+	for _, c := range s.due {
+		c.enqueue(f)
+	}
+	clear(s.due)
+}
+
+// buildSpectrum adds the binary packets and/or the JSON message for the latest spectrum to f.
+func (s *Server) buildSpectrum(f *frame, timestamp int64, binary, jsonMessage bool) {
+	if err := s.features.Spectrum.GetMagnitudesInto(s.magBuffer); err != nil {
+		return
+	}
+	magnitudes := s.f32Buffer
+	if jsonMessage {
+		magnitudes = make([]float32, len(s.magBuffer)) // Kept alive by the JSON message.
+	}
+	for i, v := range s.magBuffer {
+		magnitudes[i] = float32(v)
+	}
+	sequence := s.sequence.Add(1)
+
+	if binary {
+		f.packets = appendSpectrumPackets(nil, protocol.Header{
+			Type:       protocol.MessageSpectrum,
+			Sequence:   sequence,
+			Timestamp:  timestamp,
+			SampleRate: uint32(s.features.Spectrum.GetSampleRate()),
+			FFTSize:    uint32(s.features.Spectrum.GetFFTSize()),
+			Channel:    s.options.Channel,
+		}, magnitudes)
+	}
+	if jsonMessage {
+		f.messages[MessageSpectrum], _ = json.Marshal(SpectrumMessage{
+			Type:       MessageSpectrum.String(),
+			Timestamp:  timestamp,
+			Sequence:   sequence,
+			SampleRate: s.features.Spectrum.GetSampleRate(),
+			FFTSize:    s.features.Spectrum.GetFFTSize(),
+			Channel:    s.options.Channel,
+			Magnitudes: magnitudes,
+		})
+	}
+}
+
+// appendSpectrumPackets encodes magnitudes as v1 protocol packets. WebSocket messages
+// have no size limit, so a spectrum is only fragmented when its payload does not fit the
+// 16 bit payload length of the header (more than ~16k bins).
+func appendSpectrumPackets(dst [][]byte, h protocol.Header, magnitudes []float32) [][]byte {
+	payloadLen := protocol.SpectrumPayloadSize(len(magnitudes))
+	if payloadLen <= math.MaxUint16 {
+		h.PayloadLength = uint16(payloadLen)
+		packet := make([]byte, 0, protocol.HeaderSize+payloadLen)
+		packet = protocol.AppendHeader(packet, h)
+		return append(dst, protocol.AppendSpectrum(packet, magnitudes))
+	}
+
+	payload := protocol.AppendSpectrum(make([]byte, 0, payloadLen), magnitudes)
+	chunkSize := math.MaxUint16 - protocol.FragmentHeaderSize
+	count := (len(payload) + chunkSize - 1) / chunkSize
+	for i := range count {
+		chunk := payload[i*chunkSize : min((i+1)*chunkSize, len(payload))]
+		dst = append(dst, protocol.AppendFragment(nil, h, protocol.FragmentHeader{
+			FrameID:     h.Sequence,
+			Index:       uint16(i),
+			Count:       uint16(count),
+			TotalLength: uint32(len(payload)),
+		}, chunk))
+	}
+	return dst
+}
+
+// full reports whether the streaming client limit is reached. clientsMu must be held.
+func (s *Server) full() bool {
+	return s.options.MaxClients > 0 && len(s.clients)+s.sseCount >= s.options.MaxClients
+}
+
+// addClient registers c, or returns false if the server is full.
+func (s *Server) addClient(c *client) bool {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if s.full() {
+		return false
+	}
+	s.clients[c] = struct{}{}
+	return true
+}
+
+// acquireStream counts a new SSE client, or returns false if the server is full.
+func (s *Server) acquireStream() bool {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if s.full() {
+		return false
+	}
+	s.sseCount++
+	return true
+}
+
+// releaseStream counts an SSE client as disconnected.
+func (s *Server) releaseStream() {
+	s.clientsMu.Lock()
+	s.sseCount--
+	s.clientsMu.Unlock()
+}
+
+// removeClient unregisters c.
+func (s *Server) removeClient(c *client) {
+	s.clientsMu.Lock()
+	delete(s.clients, c)
+	s.clientsMu.Unlock()
+}
+
+// Ensure Server satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Server)(nil)
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// handleSnapshot returns the latest value of one message type as JSON, or of every
+// available type as an object keyed by type name when no type is given. Types use the
+// same names as the WebSocket stream, plus the aliases "fft" and "levels":
+//
+//	curl http://127.0.0.1:8080/snapshot/levels
+//	{"type":"level","timestamp":1718000000000000000,"rms":-23.4,"peak":-9.1}
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.allowCORS(w, r)
+	timestamp := time.Now().UnixNano()
+
+	name := r.PathValue("type")
+	if name == "" {
+		all := make(map[string]any)
+		for t := range messageTypeCount {
+			if !s.available.has(t) {
+				continue
+			}
+			if msg, ok := s.snapshotMessage(t, timestamp); ok {
+				all[t.String()] = msg
+			}
+		}
+		writeJSON(w, http.StatusOK, all)
+		return
+	}
+
+	t, err := ParseMessageType(name)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	msg, ok := s.snapshotMessage(t, timestamp)
+	if !s.available.has(t) || !ok {
+		writeJSON(w, http.StatusNotFound, ErrorMessage{Type: "error", Error: fmt.Sprintf("%s is not enabled on this server", t)})
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// snapshotMessage returns the JSON body for the latest value of t, or false if it is
+// not available.
+func (s *Server) snapshotMessage(t MessageType, timestamp int64) (any, bool) {
+	if t != MessageSpectrum {
+		return featureMessage(s.features, t, timestamp)
+	}
+
+	magnitudes := s.features.Spectrum.GetMagnitudes()
+	f32 := make([]float32, len(magnitudes))
+	for i, v := range magnitudes {
+		f32[i] = float32(v)
+	}
+	return SpectrumMessage{
+		Type:       t.String(),
+		Timestamp:  timestamp,
+		Sequence:   s.sequence.Load(),
+		SampleRate: s.features.Spectrum.GetSampleRate(),
+		FFTSize:    s.features.Spectrum.GetFFTSize(),
+		Channel:    s.options.Channel,
+		Magnitudes: f32,
+	}, true
+}
+
+// writeJSON writes v as an uncached JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: MIT
+package web
+
+import (
+	"audio/internal/analysis"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Timing and buffering for Server-Sent Events streams.
+const (
+	sseDefaultInterval = time.Second      // Default interval between feature updates.
+	sseHeartbeat       = 15 * time.Second // Interval between keep-alive comments.
+	sseWriteTimeout    = 5 * time.Second  // Maximum time a single write may block.
+	sseEventBuffer     = 32               // Detected events queued per client before events are dropped.
+)
+
+// handleEvents streams low-rate feature updates and detected events as Server-Sent
+// Events. Each message uses its type as the SSE event name ("level", "vad", ...) and
+// detected events (speech start, chord changes, ...) arrive as "event" messages:
+//
+//	event: level
+//	data: {"type":"level","timestamp":1718000000000000000,"rms":-23.4,"peak":-9.1}
+//
+// URL query parameters:
+//
+//	types     Comma separated message types (default: every available type but spectrum).
+//	interval  Time between updates, e.g. "250ms" or "2s" (default 1s, at least the server interval).
+//	events    Set to "false" to receive feature updates only.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.allowCORS(w, r)
+
+	query := r.URL.Query()
+	types := s.available &^ messageSet(0).with(MessageSpectrum)
+	if names, ok := query["types"]; ok {
+		var err error
+		if types, err = parseMessageSet(names); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if missing := types &^ s.available; missing != 0 {
+			http.Error(w, fmt.Sprintf("message types not enabled on this server: %v", missing.names()), http.StatusBadRequest)
+			return
+		}
+	}
+	interval := sseDefaultInterval
+	if v := query.Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid interval", http.StatusBadRequest)
+			return
+		}
+		interval = max(d, s.interval)
+	}
+	wantEvents := true
+	if v := query.Get("events"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid events", http.StatusBadRequest)
+			return
+		}
+		wantEvents = b
+	}
+
+	if !s.acquireStream() {
+		http.Error(w, "too many clients", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.releaseStream()
+
+	var events <-chan analysis.Event
+	if wantEvents && s.features.Events != nil {
+		ch, cancel := s.features.Events.Subscribe(sseEventBuffer)
+		defer cancel()
+		events = ch
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream.
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	update := time.NewTicker(interval)
+	defer update.Stop()
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	fmt.Printf("web: SSE client connected from %s (Types: %v, Interval: %s)\n", r.RemoteAddr, types.names(), interval)
+	defer fmt.Printf("web: SSE client %s disconnected\n", r.RemoteAddr)
+
+	writeUpdate := func(now time.Time) error {
+		for t := range messageTypeCount {
+			if !types.has(t) {
+				continue
+			}
+			if msg, ok := s.snapshotMessage(t, now.UnixNano()); ok {
+				if err := s.writeSSE(w, t.String(), msg); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	// Send the current values right away rather than after the first interval.
+	err := writeUpdate(time.Now())
+
+	for err == nil {
+		_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case now := <-update.C:
+			err = writeUpdate(now)
+		case e, ok := <-events:
+			if !ok {
+				events = nil // Bus closed, keep sending updates.
+				continue
+			}
+			err = s.writeSSE(w, "event", EventMessage{
+				Type:      "event",
+				Timestamp: e.Time.UnixNano(),
+				Source:    e.Source,
+				Name:      e.Name,
+				Label:     e.Label,
+				Value:     e.Value,
+			})
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		}
+	}
+}
+
+// writeSSE writes v as one Server-Sent Event named event.
+func (s *Server) writeSSE(w io.Writer, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	n, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	s.counters.Record(n, err)
+	return err
+}
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: MIT
+package web
+
+import (
+	"audio/internal/analysis"
+	"audio/pkg/protocol"
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return 48000 }
+
+// newTestServer starts a server on a random local port, closed when the test ends.
+func newTestServer(t *testing.T, options Options) *Server {
+	t.Helper()
+	src := spectrum{0, 1, 2, 3, 4}
+	levels, err := analysis.NewLevelProcessor(src, nil)
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+	s, err := NewServer("127.0.0.1:0", 5*time.Millisecond, analysis.Features{Spectrum: src, Levels: levels}, options)
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.Start()
+	return s
+}
+
+// dial connects to the server's WebSocket endpoint with the given query string.
+func dial(t *testing.T, s *Server, query string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+s.Addr().String()+"/ws"+query, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	return conn
+}
+
+// readJSON reads the next text message into v.
+func readJSON(t *testing.T, conn *websocket.Conn, v any) {
+	t.Helper()
+	kind, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if kind != websocket.TextMessage {
+		t.Fatalf("message type = %d, want text", kind)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+}
+
+func TestWebSocket_Binary(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, Options{Channel: 3})
+	conn := dial(t, s, "")
+
+	var hello HelloMessage
+	readJSON(t, conn, &hello)
+	if hello.Format != "binary" || hello.FFTSize != 8 || len(hello.Subscribed) != 1 || hello.Subscribed[0] != "spectrum" {
+		t.Errorf("hello = %+v", hello)
+	}
+
+	kind, packet, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if kind != websocket.BinaryMessage {
+		t.Fatalf("message type = %d, want binary", kind)
+	}
+	header, payload, err := protocol.ParseHeader(packet)
+	if err != nil {
+		t.Fatalf("ParseHeader error: %v", err)
+	}
+	if header.Type != protocol.MessageSpectrum || header.Channel != 3 || header.SampleRate != 48000 {
+		t.Errorf("header = %+v", header)
+	}
+	mags, err := protocol.ParseSpectrum(payload, nil)
+	if err != nil || len(mags) != 5 || mags[4] != 4 {
+		t.Errorf("ParseSpectrum = %v, %v", mags, err)
+	}
+}
+
+func TestWebSocket_JSONSubscription(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, Options{})
+	conn := dial(t, s, "?format=json&types=level")
+
+	var hello HelloMessage
+	readJSON(t, conn, &hello)
+	if hello.Format != "json" || len(hello.Subscribed) != 1 || hello.Subscribed[0] != "level" {
+		t.Fatalf("hello = %+v", hello)
+	}
+	var level LevelMessage
+	readJSON(t, conn, &level)
+	if level.Type != "level" || level.RMS != analysis.SilenceDB {
+		t.Errorf("level = %+v", level)
+	}
+
+	// Switch to the spectrum and check for the new hello before the first spectrum.
+	if err := conn.WriteJSON(map[string]any{"subscribe": []string{"spectrum"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for {
+		var msg map[string]any
+		readJSON(t, conn, &msg)
+		if msg["type"] == "hello" {
+			break
+		}
+	}
+	var spec SpectrumMessage
+	readJSON(t, conn, &spec)
+	if spec.Type != "spectrum" || len(spec.Magnitudes) != 5 || spec.Sequence == 0 {
+		t.Errorf("spectrum = %+v", spec)
+	}
+
+	// Disabled message types are rejected.
+	if err := conn.WriteJSON(map[string]any{"subscribe": []string{"chord"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for {
+		var msg map[string]any
+		readJSON(t, conn, &msg)
+		if msg["type"] == "error" {
+			break
+		}
+	}
+}
+
+func TestWebSocket_MaxClients(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, Options{MaxClients: 1})
+	first := dial(t, s, "")
+	var hello HelloMessage
+	readJSON(t, first, &hello)
+
+	second := dial(t, s, "")
+	if _, _, err := second.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("second client read error = %v, want close 1013", err)
+	}
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	t.Parallel()
+	c := &client{types: messageSet(0).with(MessageSpectrum)}
+	rate := 10.0
+	if err := c.apply(controlMessage{MaxRate: &rate}, available(analysis.Features{Spectrum: spectrum{0, 0}})); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+
+	// At a 10ms tick, a 10 per second client gets every tenth frame.
+	start := time.Now()
+	sent := 0
+	for i := range 100 {
+		if _, _, ok := c.due(start.Add(time.Duration(i)*10*time.Millisecond), 10*time.Millisecond); ok {
+			sent++
+		}
+	}
+	if sent != 10 {
+		t.Errorf("sent %d frames in one second, want 10", sent)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, Options{})
+	base := "http://" + s.Addr().String()
+
+	resp, err := http.Get(base + "/snapshot/fft")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var spec SpectrumMessage
+	err = json.NewDecoder(resp.Body).Decode(&spec)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || spec.Type != "spectrum" || len(spec.Magnitudes) != 5 {
+		t.Errorf("/snapshot/fft = %d %+v (%v)", resp.StatusCode, spec, err)
+	}
+
+	resp, err = http.Get(base + "/snapshot")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var all map[string]json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&all)
+	resp.Body.Close()
+	if err != nil || len(all) != 3 || all["level"] == nil || all["bands"] == nil {
+		t.Errorf("/snapshot keys = %v (%v), want spectrum, level, bands", all, err)
+	}
+
+	for path, want := range map[string]int{"/snapshot/levels": http.StatusOK, "/snapshot/chord": http.StatusNotFound, "/snapshot/nope": http.StatusNotFound} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+	src := spectrum{0, 1, 2, 3, 4}
+	levels, err := analysis.NewLevelProcessor(src, nil)
+	if err != nil {
+		t.Fatalf("NewLevelProcessor error: %v", err)
+	}
+	bus := analysis.NewEventBus()
+	s, err := NewServer("127.0.0.1:0", 5*time.Millisecond, analysis.Features{Spectrum: src, Levels: levels, Events: bus}, Options{})
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	defer s.Close()
+
+	resp, err := http.Get("http://" + s.Addr().String() + "/events?types=level")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (string, string) {
+		var event, data string
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return "", ""
+	}
+
+	// The current values are sent on connect.
+	if event, data := next(); event != "level" || !strings.Contains(data, `"rms"`) {
+		t.Errorf("first event = %s %s, want level", event, data)
+	}
+
+	// Detected events follow as they are published.
+	bus.Publish(analysis.Event{Source: "vad", Name: analysis.EventSpeechStart, Value: 0.9, Time: time.Now()})
+	event, data := next()
+	var msg EventMessage
+	if err := json.Unmarshal([]byte(data), &msg); err != nil || event != "event" || msg.Name != analysis.EventSpeechStart {
+		t.Errorf("event = %s %s (%v), want speech_start", event, data, err)
+	}
+}
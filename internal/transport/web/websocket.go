@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: MIT
+package web
+
+import (
+	"audio/internal/transport"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Timing and buffering for WebSocket clients.
+const (
+	wsWriteTimeout = time.Second      // Maximum time a single write may block.
+	wsPongTimeout  = 60 * time.Second // Clients are dropped if no pong arrives for this long.
+	wsPingInterval = 25 * time.Second // Interval between pings, must be below wsPongTimeout.
+	wsFrameBuffer  = 4                // Frames queued per client before frames are dropped.
+	wsMaxControl   = 4096             // Maximum size of a control message from the client.
+)
+
+// frame holds the messages produced on one tick. It is shared by all clients and must
+// not be modified once handed to them.
+type frame struct {
+	packets  [][]byte                 // Binary spectrum packets (v1 protocol).
+	messages [messageTypeCount][]byte // JSON messages, nil when not built.
+}
+
+// client is a connected WebSocket client.
+type client struct {
+	conn    *websocket.Conn
+	send    chan *frame // Frames waiting to be written.
+	control chan []byte // JSON replies to control messages, written before frames.
+	done    chan struct{}
+	once    sync.Once
+
+	counters *transport.Counters // Counters of the server.
+
+	mu          sync.Mutex    // Protects the fields below.
+	binary      bool          // Send spectra as binary packets rather than JSON.
+	types       messageSet    // Subscribed message types.
+	maxRate     float64       // Maximum frames per second, 0 for no limit.
+	minInterval time.Duration // 1/maxRate.
+	lastSent    time.Time     // Time of the latest frame queued for this client.
+	dropped     uint64        // Frames dropped because the client fell behind.
+}
+
+// controlMessage is the JSON message a client sends to change its subscription.
+// Omitted fields are left unchanged.
+type controlMessage struct {
+	Subscribe []string `json:"subscribe"` // Message types to receive, replacing the current set.
+	MaxRate   *float64 `json:"max_rate"`  // Maximum frames per second, 0 for no limit.
+	Format    string   `json:"format"`    // "binary" or "json".
+}
+
+// handleWebSocket upgrades the request to a WebSocket and streams frames to it.
+//
+// The initial subscription is taken from the URL query:
+//
+//	format    "binary" (default) sends spectra as v1 protocol packets in binary messages;
+//	          "json" sends them as SpectrumMessage. Other types are always JSON text.
+//	types     Comma separated message types (default "spectrum").
+//	max_rate  Maximum frames per second for this client (default: every frame).
+//
+// A hello message describing the stream is sent first. Clients can change their
+// subscription at any time by sending a JSON controlMessage, e.g.
+// {"subscribe": ["spectrum", "level"], "max_rate": 20}; the reply is a new hello
+// message, or an error message if the request was invalid.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	c := &client{
+		send:     make(chan *frame, wsFrameBuffer),
+		control:  make(chan []byte, 1),
+		done:     make(chan struct{}),
+		counters: &s.counters,
+		binary:   true,
+		types:    messageSet(0).with(MessageSpectrum),
+	}
+
+	query := r.URL.Query()
+	update := controlMessage{Format: query.Get("format")}
+	if types, ok := query["types"]; ok {
+		update.Subscribe = types
+	}
+	if rate := query.Get("max_rate"); rate != "" {
+		v, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			http.Error(w, "invalid max_rate", http.StatusBadRequest)
+			return
+		}
+		update.MaxRate = &v
+	}
+	if err := c.apply(update, s.available); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has already replied.
+	}
+	c.conn = conn
+	defer c.close()
+
+	if !s.addClient(c) {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many clients"),
+			time.Now().Add(wsWriteTimeout))
+		return
+	}
+	defer s.removeClient(c)
+
+	fmt.Printf("web: WebSocket client connected from %s (Types: %v)\n", r.RemoteAddr, c.types.names())
+	c.control <- s.hello(c)
+
+	go c.readLoop(s)
+	c.writeLoop()
+
+	fmt.Printf("web: WebSocket client %s disconnected (Dropped frames: %d)\n", r.RemoteAddr, c.droppedFrames())
+}
+
+// hello returns the encoded HelloMessage describing c's subscription.
+func (s *Server) hello(c *client) []byte {
+	c.mu.Lock()
+	msg := HelloMessage{
+		Type:       "hello",
+		Version:    1,
+		Format:     "json",
+		SampleRate: s.features.Spectrum.GetSampleRate(),
+		FFTSize:    s.features.Spectrum.GetFFTSize(),
+		Available:  s.available.names(),
+		Subscribed: c.types.names(),
+		MaxRate:    c.maxRate,
+	}
+	if c.binary {
+		msg.Format = "binary"
+	}
+	c.mu.Unlock()
+
+	b, _ := json.Marshal(msg)
+	return b
+}
+
+// apply validates and applies a subscription change.
+func (c *client) apply(m controlMessage, available messageSet) error {
+	var types messageSet
+	if m.Subscribe != nil {
+		var err error
+		if types, err = parseMessageSet(m.Subscribe); err != nil {
+			return err
+		}
+		if missing := types &^ available; missing != 0 {
+			return fmt.Errorf("message types not enabled on this server: %v", missing.names())
+		}
+	}
+	if m.MaxRate != nil && *m.MaxRate < 0 {
+		return fmt.Errorf("max_rate cannot be negative")
+	}
+	if m.Format != "" && m.Format != "binary" && m.Format != "json" {
+		return fmt.Errorf("unknown format %q (options: binary, json)", m.Format)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m.Subscribe != nil {
+		c.types = types
+	}
+	if m.MaxRate != nil {
+		c.maxRate = *m.MaxRate
+		c.minInterval = 0
+		if c.maxRate > 0 {
+			c.minInterval = time.Duration(float64(time.Second) / c.maxRate)
+		}
+	}
+	if m.Format != "" {
+		c.binary = m.Format == "binary"
+	}
+	return nil
+}
+
+// due reports whether c should receive a frame at now, given the server tick interval,
+// and returns its subscription. Ticks jitter, so half a tick of slack is allowed.
+func (c *client) due(now time.Time, tick time.Duration) (types messageSet, binary bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.types == 0 || (c.minInterval > 0 && now.Sub(c.lastSent) < c.minInterval-tick/2) {
+		return 0, false, false
+	}
+	c.lastSent = now
+	return c.types, c.binary, true
+}
+
+// enqueue queues f for writing, dropping it if the client is behind.
+func (c *client) enqueue(f *frame) {
+	select {
+	case c.send <- f:
+	default:
+		c.mu.Lock()
+		c.dropped++
+		c.mu.Unlock()
+		c.counters.Drop()
+	}
+}
+
+// droppedFrames returns the number of frames dropped so far.
+func (c *client) droppedFrames() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// close closes the connection, ending both loops. It is safe to call multiple times.
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.done)
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+	})
+}
+
+// readLoop handles control messages and pongs until the connection fails.
+func (c *client) readLoop(s *Server) {
+	defer c.close()
+
+	c.conn.SetReadLimit(wsMaxControl)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var m controlMessage
+		var reply []byte
+		if err := json.Unmarshal(data, &m); err != nil {
+			reply, _ = json.Marshal(ErrorMessage{Type: "error", Error: "invalid control message: " + err.Error()})
+		} else if err := c.apply(m, s.available); err != nil {
+			reply, _ = json.Marshal(ErrorMessage{Type: "error", Error: err.Error()})
+		} else {
+			reply = s.hello(c)
+		}
+
+		select {
+		case c.control <- reply:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// writeLoop writes control replies, frames and pings until the connection fails.
+func (c *client) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case reply := <-c.control:
+			err = c.write(websocket.TextMessage, reply)
+		case f := <-c.send:
+			err = c.writeFrame(f)
+		case <-ping.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		case <-c.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeFrame writes the messages of f the client subscribed to.
+func (c *client) writeFrame(f *frame) error {
+	c.mu.Lock()
+	types, binary := c.types, c.binary
+	c.mu.Unlock()
+
+	for t := range messageTypeCount {
+		if !types.has(t) {
+			continue
+		}
+		if t == MessageSpectrum && binary {
+			for _, packet := range f.packets {
+				if err := c.write(websocket.BinaryMessage, packet); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if msg := f.messages[t]; msg != nil {
+			if err := c.write(websocket.TextMessage, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write sends one message with a write deadline.
+func (c *client) write(messageType int, data []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	err := c.conn.WriteMessage(messageType, data)
+	c.counters.Record(len(data), err)
+	return err
+}
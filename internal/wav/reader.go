@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: MIT
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAVE format tags found in the "fmt " chunk.
+const (
+	formatPCM        = 0x0001
+	formatIEEEFloat  = 0x0003
+	formatExtensible = 0xFFFE
+)
+
+// File holds the decoded contents of a WAV file. Samples are interleaved and
+// left-justified to the full int32 range, matching the layout PortAudio delivers
+// to the engine's input callback, so they can be fed straight into an
+// analysis.AudioProcessor.
+type File struct {
+	SampleRate int     // Sample rate in Hz.
+	Channels   int     // Number of interleaved channels.
+	BitDepth   int     // Bits per sample of the source data.
+	Float      bool    // True if the source data was IEEE float.
+	Samples    []int32 // Interleaved samples scaled to the int32 range.
+}
+
+// Frames returns the number of sample frames (samples per channel) in the file.
+func (f *File) Frames() int {
+	if f.Channels == 0 {
+		return 0
+	}
+	return len(f.Samples) / f.Channels
+}
+
+// Mono returns the file down-mixed to a single channel by averaging all channels.
+// If the file is already mono the underlying sample slice is returned as-is.
+func (f *File) Mono() []int32 {
+	if f.Channels <= 1 {
+		return f.Samples
+	}
+	frames := f.Frames()
+	mono := make([]int32, frames)
+	for i := range frames {
+		var sum int64
+		for c := range f.Channels {
+			sum += int64(f.Samples[i*f.Channels+c])
+		}
+		mono[i] = int32(sum / int64(f.Channels))
+	}
+	return mono
+}
+
+var errNotWAV = errors.New("wav: not a RIFF/WAVE file")
+
+// Decode reads an entire WAV stream into memory. It supports 8, 16, 24 and 32 bit
+// integer PCM as well as 32 and 64 bit IEEE float data, including the
+// WAVE_FORMAT_EXTENSIBLE variants of both.
+func Decode(r io.Reader) (*File, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("wav: failed to read header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errNotWAV
+	}
+
+	var (
+		file     File
+		format   uint16
+		haveFmt  bool
+		chunkHdr [8]byte
+	)
+
+	for {
+		if _, err := io.ReadFull(r, chunkHdr[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("wav: no data chunk found")
+			}
+			return nil, fmt.Errorf("wav: failed to read chunk header: %w", err)
+		}
+		id := string(chunkHdr[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHdr[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("wav: fmt chunk too small (%d bytes)", size)
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, fmt.Errorf("wav: failed to read fmt chunk: %w", err)
+			}
+			format = binary.LittleEndian.Uint16(buf[0:2])
+			file.Channels = int(binary.LittleEndian.Uint16(buf[2:4]))
+			file.SampleRate = int(binary.LittleEndian.Uint32(buf[4:8]))
+			file.BitDepth = int(binary.LittleEndian.Uint16(buf[14:16]))
+			if format == formatExtensible {
+				if size < 26 {
+					return nil, fmt.Errorf("wav: extensible fmt chunk too small (%d bytes)", size)
+				}
+				// The first two bytes of the SubFormat GUID carry the actual format tag.
+				format = binary.LittleEndian.Uint16(buf[24:26])
+			}
+			haveFmt = true
+
+		case "data":
+			if !haveFmt {
+				return nil, fmt.Errorf("wav: data chunk before fmt chunk")
+			}
+			if err := file.decodeData(r, format, size); err != nil {
+				return nil, err
+			}
+			return &file, nil
+
+		default:
+			// Skip chunks we don't care about (LIST, fact, cue, ...).
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return nil, fmt.Errorf("wav: failed to skip %q chunk: %w", id, err)
+			}
+		}
+
+		// Chunks are word aligned, odd sized chunks carry a pad byte.
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				return nil, fmt.Errorf("wav: failed to skip pad byte: %w", err)
+			}
+		}
+	}
+}
+
+// decodeData reads the sample data chunk and converts it to left-justified int32.
+func (f *File) decodeData(r io.Reader, format uint16, size int64) error {
+	if f.Channels <= 0 {
+		return fmt.Errorf("wav: invalid channel count %d", f.Channels)
+	}
+	if f.SampleRate <= 0 {
+		return fmt.Errorf("wav: invalid sample rate %d", f.SampleRate)
+	}
+
+	switch format {
+	case formatPCM:
+		switch f.BitDepth {
+		case 8, 16, 24, 32:
+		default:
+			return fmt.Errorf("wav: unsupported PCM bit depth %d", f.BitDepth)
+		}
+	case formatIEEEFloat:
+		if f.BitDepth != 32 && f.BitDepth != 64 {
+			return fmt.Errorf("wav: unsupported float bit depth %d", f.BitDepth)
+		}
+		f.Float = true
+	default:
+		return fmt.Errorf("wav: unsupported format tag 0x%04x", format)
+	}
+
+	bytesPerSample := f.BitDepth / 8
+	// Some writers leave the data size at zero or 0xFFFFFFFF when streaming,
+	// in which case we read until EOF.
+	var data []byte
+	var err error
+	if size == 0 || size == math.MaxUint32 {
+		data, err = io.ReadAll(r)
+	} else {
+		data = make([]byte, size)
+		var n int
+		n, err = io.ReadFull(r, data)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// Tolerate truncated files, keep what we have.
+			data, err = data[:n], nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("wav: failed to read data chunk: %w", err)
+	}
+
+	count := len(data) / bytesPerSample
+	count -= count % f.Channels
+	f.Samples = make([]int32, count)
+
+	for i := range count {
+		b := data[i*bytesPerSample : (i+1)*bytesPerSample]
+		switch {
+		case f.Float && f.BitDepth == 32:
+			f.Samples[i] = floatToInt32(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		case f.Float:
+			f.Samples[i] = floatToInt32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		case f.BitDepth == 8:
+			// 8 bit PCM is unsigned.
+			f.Samples[i] = int32(int8(b[0]-0x80)) << 24
+		case f.BitDepth == 16:
+			f.Samples[i] = int32(int16(binary.LittleEndian.Uint16(b))) << 16
+		case f.BitDepth == 24:
+			f.Samples[i] = int32(uint32(b[0])<<8 | uint32(b[1])<<16 | uint32(b[2])<<24)
+		default:
+			f.Samples[i] = int32(binary.LittleEndian.Uint32(b))
+		}
+	}
+
+	return nil
+}
+
+// floatToInt32 converts a [-1.0, 1.0] float sample to the int32 range, clipping out of range values.
+func floatToInt32(v float64) int32 {
+	v = math.Round(v * 0x80000000)
+	if v >= math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if v <= math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(v)
+}
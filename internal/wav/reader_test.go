@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: MIT
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+// buildWAV assembles a minimal WAV stream with the given format and raw sample data.
+func buildWAV(t *testing.T, format uint16, channels, sampleRate, bitDepth int, data []byte, extra ...[]byte) []byte {
+	t.Helper()
+	var fmtChunk bytes.Buffer
+	blockAlign := channels * bitDepth / 8
+	binary.Write(&fmtChunk, binary.LittleEndian, format)
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(channels))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(bitDepth))
+
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	for _, chunk := range extra {
+		body.Write(chunk)
+	}
+	body.WriteString("fmt ")
+	binary.Write(&body, binary.LittleEndian, uint32(fmtChunk.Len()))
+	body.Write(fmtChunk.Bytes())
+	body.WriteString("data")
+	binary.Write(&body, binary.LittleEndian, uint32(len(data)))
+	body.Write(data)
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func TestDecode_PCM16Stereo(t *testing.T) {
+	t.Parallel()
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, []int16{16384, -16384, math.MaxInt16, math.MinInt16})
+
+	// A LIST chunk with an odd size checks chunk skipping and pad byte handling.
+	list := []byte{'L', 'I', 'S', 'T', 3, 0, 0, 0, 'a', 'b', 'c', 0}
+	f, err := Decode(bytes.NewReader(buildWAV(t, formatPCM, 2, 48000, 16, data.Bytes(), list)))
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if f.SampleRate != 48000 || f.Channels != 2 || f.BitDepth != 16 || f.Float {
+		t.Errorf("unexpected format: %+v", f)
+	}
+	if f.Frames() != 2 {
+		t.Fatalf("Frames() = %d, want 2", f.Frames())
+	}
+	want := []int32{16384 << 16, -16384 << 16, math.MaxInt16 << 16, math.MinInt16 << 16}
+	for i, v := range want {
+		if f.Samples[i] != v {
+			t.Errorf("Samples[%d] = %d, want %d", i, f.Samples[i], v)
+		}
+	}
+	mono := f.Mono()
+	if len(mono) != 2 || mono[0] != 0 {
+		t.Errorf("Mono() = %v, want [0 ...]", mono)
+	}
+}
+
+func TestDecode_Float32(t *testing.T) {
+	t.Parallel()
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, []float32{0.5, -1.0, 2.0})
+
+	f, err := Decode(bytes.NewReader(buildWAV(t, formatIEEEFloat, 1, 44100, 32, data.Bytes())))
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	want := []int32{1 << 30, math.MinInt32, math.MaxInt32}
+	for i, v := range want {
+		if f.Samples[i] != v {
+			t.Errorf("Samples[%d] = %d, want %d", i, f.Samples[i], v)
+		}
+	}
+}
+
+func TestDecode_PCM24(t *testing.T) {
+	t.Parallel()
+	// 0x400000 (half scale) and 0xC00000 (negative half scale), little endian.
+	data := []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xC0}
+	f, err := Decode(bytes.NewReader(buildWAV(t, formatPCM, 1, 44100, 24, data)))
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if f.Samples[0] != 1<<30 || f.Samples[1] != -(1<<30) {
+		t.Errorf("Samples = %v, want [%d %d]", f.Samples, 1<<30, -(1 << 30))
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		input  []byte
+		substr string
+	}{
+		{"Empty", nil, "failed to read header"},
+		{"Not RIFF", []byte("RIFX\x00\x00\x00\x00WAVE"), "not a RIFF/WAVE file"},
+		{"Unsupported depth", buildWAV(t, formatPCM, 1, 44100, 12, []byte{0, 0}), "unsupported PCM bit depth"},
+		{"Unsupported format", buildWAV(t, 0x0055, 1, 44100, 16, []byte{0, 0}), "unsupported format tag"},
+		{"No data", []byte("RIFF\x04\x00\x00\x00WAVE"), "no data chunk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.substr) {
+				t.Errorf("Decode error = %v, want substring %q", err, tt.substr)
+			}
+		})
+	}
+}
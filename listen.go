@@ -0,0 +1,326 @@
+// SPDX-License-Identifier: MIT
+package main
+
+import (
+	"audio/internal/config"
+	"audio/pkg/client"
+	"audio/pkg/discovery"
+	"audio/pkg/protocol"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// barLevels are the characters of the spectrum bar graph, from silent to full scale.
+var barLevels = []rune(" ▁▂▃▄▅▆▇█")
+
+// listenRecord is one spectrum in JSON output mode.
+type listenRecord struct {
+	Source     string    `json:"source"`
+	Channel    uint16    `json:"channel"`
+	Sequence   uint32    `json:"sequence"`
+	Timestamp  time.Time `json:"timestamp"`
+	LatencyMS  float64   `json:"latency_ms"`
+	SampleRate uint32    `json:"sample_rate"`
+	FFTSize    uint32    `json:"fft_size"`
+	Magnitudes []float32 `json:"magnitudes,omitempty"`
+}
+
+// runListen implements the "listen" command. It receives the engine's UDP stream (or,
+// with -tcp, its TCP stream) with pkg/client and prints, every interval, the latest spectrum as a bar graph together
+// with the packet rate, loss and latency. With -json it writes every spectrum as one
+// JSON object per line instead, to check the transport end to end.
+func runListen(configPath string, args []string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	defaultAddress := ":9090"
+	if _, port, err := net.SplitHostPort(cfg.Transport.UDPTargetAddress); err == nil {
+		defaultAddress = ":" + port
+	}
+
+	fs := flag.NewFlagSet("listen", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s listen [flags] [address]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Address defaults to the port of udp_target_address (%s); use a group address to join multicast.\n", defaultAddress)
+		fs.PrintDefaults()
+	}
+	jsonOutput := fs.Bool("json", false, "Write every spectrum as a JSON line instead of the monitor")
+	noMagnitudes := fs.Bool("no-magnitudes", false, "Omit magnitudes from JSON output")
+	interval := fs.Duration("interval", 500*time.Millisecond, "Interval between monitor updates")
+	bars := fs.Int("bars", 64, "Number of bars in the spectrum graph")
+	logFrequency := fs.Bool("log", true, "Use a logarithmic frequency axis for the bars")
+	minDB := fs.Float64("min-db", -100, "Level in dBFS shown as an empty bar")
+	maxDB := fs.Float64("max-db", 0, "Level in dBFS shown as a full bar")
+	legacy := fs.Bool("legacy", false, "Also accept packets in the legacy header-less layout")
+	multicastInterface := fs.String("interface", "", "Interface to join a multicast group on")
+	subscribe := fs.String("subscribe", "", "Engine control address (udp_control_address) to subscribe at instead of waiting for a configured stream")
+	subscribeInterval := fs.Duration("subscribe-interval", 0, "Interval between spectra to ask for when subscribing (0 uses the engine default)")
+	key := fs.String("key", "", "Hex encoded pre-shared key the engine signs packets with (defaults to udp_key)")
+	keyFile := fs.String("key-file", "", "File holding the hex encoded pre-shared key (defaults to udp_key_file)")
+	maxAge := fs.Duration("max-age", 0, "With a key, reject packets timestamped further than this from the local clock (0 uses 30s, negative disables)")
+	tcp := fs.String("tcp", "", "Engine TCP stream address (transport.tcp.listen_address) to connect to instead of receiving UDP")
+	browse := fs.Bool("browse", false, "List the engines advertised on the local network (transport.mdns) and exit")
+	engineName := fs.String("engine", "", "Instance name of an advertised engine to subscribe to, or to connect to over TCP if it takes no subscriptions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *browse {
+		return listEngines(*multicastInterface)
+	}
+	if *engineName != "" {
+		if *subscribe != "" || *tcp != "" || fs.NArg() > 0 {
+			return fmt.Errorf("-engine can't be combined with -subscribe, -tcp or a listen address")
+		}
+		service, err := findEngine(*engineName, *multicastInterface)
+		if err != nil {
+			return err
+		}
+		switch {
+		case service.ControlAddress() != "":
+			*subscribe = service.ControlAddress()
+		case service.TCPAddress() != "":
+			*tcp = service.TCPAddress()
+		default:
+			return fmt.Errorf("engine %q accepts neither subscriptions nor TCP clients", *engineName)
+		}
+		fmt.Fprintf(os.Stderr, "listen: Found engine %q at %s\n", service.Instance, service.Host)
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one address, got %d", fs.NArg())
+	}
+	address := defaultAddress
+	if *subscribe != "" {
+		address = ":0" // The engine streams to whichever port the subscription comes from.
+	}
+	if fs.NArg() == 1 {
+		address = fs.Arg(0)
+	}
+	if *bars <= 0 || *interval <= 0 || *maxDB <= *minDB {
+		return fmt.Errorf("bars and interval must be positive and max-db above min-db")
+	}
+	if *tcp != "" && (*subscribe != "" || fs.NArg() > 0) {
+		return fmt.Errorf("-tcp can't be combined with -subscribe or a listen address")
+	}
+
+	options := client.Options{
+		DecoderOptions: client.DecoderOptions{Legacy: *legacy},
+		Buffer:         64,
+		Interface:      *multicastInterface,
+		Subscribe:      *subscribe,
+		Subscription:   protocol.Subscription{Interval: *subscribeInterval},
+	}
+	if *key != "" || *keyFile != "" {
+		cfg.Transport.UDPKey, cfg.Transport.UDPKeyFile = *key, *keyFile
+	}
+	secret, err := cfg.Transport.UDPSecurityKey()
+	if err != nil {
+		return err
+	}
+	if secret != nil && *tcp == "" { // The TCP stream isn't signed.
+		if options.Opener, err = protocol.NewOpener(secret, *maxAge); err != nil {
+			return err
+		}
+		// Subscriptions only need signing; the engine decides whether to encrypt.
+		if options.Sealer, err = protocol.NewSealer(secret, false); err != nil {
+			return err
+		}
+	}
+
+	var c *client.Client
+	if *tcp != "" {
+		c, err = client.Dial(*tcp, options)
+	} else {
+		c, err = client.Listen(address, options)
+	}
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if *tcp != "" {
+		fmt.Fprintf(os.Stderr, "listen: Receiving from %s. Press Ctrl+C to stop.\n", *tcp)
+	} else {
+		fmt.Fprintf(os.Stderr, "listen: Receiving on %s. Press Ctrl+C to stop.\n", c.Addr())
+	}
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigterm)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	encoder := json.NewEncoder(os.Stdout)
+	var latest *client.Spectrum
+	previous, previousTime := c.Stats(), time.Now()
+	for {
+		select {
+		case s, ok := <-c.Spectra():
+			if !ok {
+				return nil
+			}
+			if !*jsonOutput {
+				latest = &s
+				continue
+			}
+			record := listenRecord{
+				Source:     s.Source.String(),
+				Channel:    s.Channel,
+				Sequence:   s.Sequence,
+				Timestamp:  s.Timestamp,
+				LatencyMS:  float64(s.Latency().Microseconds()) / 1000,
+				SampleRate: s.SampleRate,
+				FFTSize:    s.FFTSize,
+				Magnitudes: s.Magnitudes,
+			}
+			if *noMagnitudes {
+				record.Magnitudes = nil
+			}
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("failed to write JSON: %w", err)
+			}
+		case now := <-ticker.C:
+			if *jsonOutput {
+				continue
+			}
+			stats := c.Stats()
+			fmt.Println(monitorLine(stats, previous, now.Sub(previousTime), latest))
+			if latest != nil {
+				fmt.Println(barGraph(latest, *bars, *logFrequency, *minDB, *maxDB))
+			}
+			previous, previousTime = stats, now
+		case <-sigterm:
+			printListenSummary(c.Stats())
+			return nil
+		}
+	}
+}
+
+// monitorLine describes the latest spectrum and the traffic since the previous update.
+func monitorLine(stats, previous client.Stats, elapsed time.Duration, latest *client.Spectrum) string {
+	var messages, lost, prevMessages, prevLost uint64
+	for _, st := range stats.Streams {
+		messages, lost = messages+st.Messages, lost+st.Lost
+	}
+	for _, st := range previous.Streams {
+		prevMessages, prevLost = prevMessages+st.Messages, prevLost+st.Lost
+	}
+	seconds := elapsed.Seconds()
+	packetRate := float64(stats.Packets-previous.Packets) / seconds
+	byteRate := float64(stats.Bytes-previous.Bytes) / seconds
+	messageRate := float64(messages-prevMessages) / seconds
+	lossRate := 0.0
+	if total := (messages - prevMessages) + (lost - prevLost); total > 0 {
+		lossRate = 100 * float64(lost-prevLost) / float64(total)
+	}
+
+	if latest == nil {
+		return fmt.Sprintf("waiting for spectra (%.1f pkt/s, %d errors, %d rejected)", packetRate, stats.Errors, stats.Rejected)
+	}
+	return fmt.Sprintf("ch %d seq %d ts %s | %.1f msg/s %.1f pkt/s %.1f kB/s | drop %.1f%% | latency %.2fms | errors %d rejected %d",
+		latest.Channel, latest.Sequence, latest.Timestamp.Format("15:04:05.000"),
+		messageRate, packetRate, byteRate/1000, lossRate, float64(latest.Latency().Microseconds())/1000, stats.Errors, stats.Rejected)
+}
+
+// barGraph renders the spectrum as one line of bars. Each bar shows the loudest bin in
+// its frequency range, in dBFS (a full-scale sine is 0 dBFS).
+func barGraph(s *client.Spectrum, bars int, logFrequency bool, minDB, maxDB float64) string {
+	bins := len(s.Magnitudes)
+	if bins < 2 {
+		return ""
+	}
+	fftSize := s.FFTSize
+	if fftSize == 0 {
+		fftSize = uint32(2 * (bins - 1)) // Legacy packets don't carry the FFT size.
+	}
+	scale := 2.0 / float64(fftSize)
+	bars = min(bars, bins-1)
+
+	var b strings.Builder
+	b.WriteRune('|')
+	for i := range bars {
+		// Bin range of the bar, skipping the DC bin.
+		lo, hi := 1+i*(bins-1)/bars, 1+(i+1)*(bins-1)/bars
+		if logFrequency {
+			lo = int(math.Pow(float64(bins), float64(i)/float64(bars)))
+			hi = max(int(math.Pow(float64(bins), float64(i+1)/float64(bars))), lo+1)
+		}
+		peak := 0.0
+		for _, m := range s.Magnitudes[min(lo, bins-1):min(hi, bins)] {
+			peak = max(peak, float64(m))
+		}
+		level := (20*math.Log10(max(peak*scale, 1e-12)) - minDB) / (maxDB - minDB)
+		index := int(math.Round(min(max(level, 0), 1) * float64(len(barLevels)-1)))
+		b.WriteRune(barLevels[index])
+	}
+	b.WriteRune('|')
+	return b.String()
+}
+
+// printListenSummary prints the statistics of every stream received.
+func printListenSummary(stats client.Stats) {
+	fmt.Fprintf(os.Stderr, "\nlisten: %d packets, %d errors, %d rejected, %d incomplete, %d skipped\n",
+		stats.Packets, stats.Errors, stats.Rejected, stats.Incomplete, stats.Skipped)
+	for _, st := range stats.Streams {
+		fmt.Fprintf(os.Stderr, "listen: %s channel %d: %d messages, %d lost (%.2f%%), %d reordered, %d duplicates, latency %s min / %s mean / %s max\n",
+			st.Source, st.Channel, st.Messages, st.Lost, 100*st.LossRate(), st.Reordered, st.Duplicates,
+			st.Latency.Min, st.Latency.Mean, st.Latency.Max)
+	}
+}
+
+// browseEngines returns the engines advertised on the local network.
+func browseEngines(multicastInterface string) ([]discovery.Service, error) {
+	services, err := discovery.Browse(context.Background(), discovery.BrowseOptions{Interface: multicastInterface})
+	if err != nil {
+		return nil, fmt.Errorf("failed to browse for engines: %w", err)
+	}
+	return services, nil
+}
+
+// listEngines prints the engines advertised on the local network.
+func listEngines(multicastInterface string) error {
+	services, err := browseEngines(multicastInterface)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		fmt.Println("No engines found.")
+		return nil
+	}
+	for _, s := range services {
+		fmt.Printf("%s (%s %v)\n", s.Instance, strings.TrimSuffix(s.Host, "."), s.Addrs)
+		fmt.Printf("  protocol v%d, %d Hz, FFT %d, channel %d, encoding %s, security %s\n",
+			s.Info.ProtocolVersion, s.Info.SampleRate, s.Info.FFTSize, s.Info.Channel, s.Info.Encoding, s.Info.Security)
+		if address := s.ControlAddress(); address != "" {
+			fmt.Printf("  subscribe: %s\n", address)
+		}
+		if address := s.TCPAddress(); address != "" {
+			fmt.Printf("  tcp:       %s\n", address)
+		}
+	}
+	return nil
+}
+
+// findEngine browses for the engine advertised as instance (case-insensitive).
+func findEngine(instance, multicastInterface string) (discovery.Service, error) {
+	services, err := browseEngines(multicastInterface)
+	if err != nil {
+		return discovery.Service{}, err
+	}
+	for _, s := range services {
+		if strings.EqualFold(s.Instance, instance) {
+			return s, nil
+		}
+	}
+	return discovery.Service{}, fmt.Errorf("engine %q not found on the local network", instance)
+}
@@ -49,6 +49,18 @@ func main() {
 				os.Exit(1)
 			}
 			return
+		case "spectrogram":
+			if err := runSpectrogram(*configPath, flag.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error rendering spectrogram: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "listen":
+			if err := runListen(*configPath, flag.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error listening: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		default:
 			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", flag.Args()[0])
 			os.Exit(1)
@@ -0,0 +1,8 @@
+// SPDX-License-Identifier: MIT
+
+// Package apiv1 contains the protobuf messages and gRPC service of the Phase4 API,
+// generated from phase4.proto. Clients in other languages generate their own stubs from
+// the same file.
+package apiv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative phase4.proto
@@ -0,0 +1,1590 @@
+// SPDX-License-Identifier: MIT
+
+// Schema of the Phase4 gRPC API. Frames carry the same data as the UDP spectrum packets
+// and the JSON messages of the HTTP server, with typed fields for every analysis feature.
+//
+// Regenerate the Go code after editing with:
+//
+//   go generate ./pkg/api/v1
+//
+// Compatibility: fields and enum values are only ever added. Breaking changes go to a
+// new package (phase4.v2).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: phase4.proto
+
+package apiv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// FrameType identifies the kinds of frames a client can subscribe to.
+type FrameType int32
+
+const (
+	FrameType_FRAME_TYPE_UNSPECIFIED FrameType = 0
+	FrameType_FRAME_TYPE_SPECTRUM    FrameType = 1 // Magnitude spectrum.
+	FrameType_FRAME_TYPE_LEVELS      FrameType = 2 // RMS and peak level.
+	FrameType_FRAME_TYPE_BANDS       FrameType = 3 // Band levels.
+	FrameType_FRAME_TYPE_HPSS        FrameType = 4 // Harmonic and percussive energies.
+	FrameType_FRAME_TYPE_VAD         FrameType = 5 // Voice activity.
+	FrameType_FRAME_TYPE_CHORD       FrameType = 6 // Recognised chord.
+	FrameType_FRAME_TYPE_CHROMA      FrameType = 7 // Chroma vector.
+	FrameType_FRAME_TYPE_PEAKS       FrameType = 8 // Spectral peaks.
+	FrameType_FRAME_TYPE_EVENT       FrameType = 9 // Detected events (speech start, chord change, ...).
+)
+
+// Enum value maps for FrameType.
+var (
+	FrameType_name = map[int32]string{
+		0: "FRAME_TYPE_UNSPECIFIED",
+		1: "FRAME_TYPE_SPECTRUM",
+		2: "FRAME_TYPE_LEVELS",
+		3: "FRAME_TYPE_BANDS",
+		4: "FRAME_TYPE_HPSS",
+		5: "FRAME_TYPE_VAD",
+		6: "FRAME_TYPE_CHORD",
+		7: "FRAME_TYPE_CHROMA",
+		8: "FRAME_TYPE_PEAKS",
+		9: "FRAME_TYPE_EVENT",
+	}
+	FrameType_value = map[string]int32{
+		"FRAME_TYPE_UNSPECIFIED": 0,
+		"FRAME_TYPE_SPECTRUM":    1,
+		"FRAME_TYPE_LEVELS":      2,
+		"FRAME_TYPE_BANDS":       3,
+		"FRAME_TYPE_HPSS":        4,
+		"FRAME_TYPE_VAD":         5,
+		"FRAME_TYPE_CHORD":       6,
+		"FRAME_TYPE_CHROMA":      7,
+		"FRAME_TYPE_PEAKS":       8,
+		"FRAME_TYPE_EVENT":       9,
+	}
+)
+
+func (x FrameType) Enum() *FrameType {
+	p := new(FrameType)
+	*p = x
+	return p
+}
+
+func (x FrameType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FrameType) Descriptor() protoreflect.EnumDescriptor {
+	return file_phase4_proto_enumTypes[0].Descriptor()
+}
+
+func (FrameType) Type() protoreflect.EnumType {
+	return &file_phase4_proto_enumTypes[0]
+}
+
+func (x FrameType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FrameType.Descriptor instead.
+func (FrameType) EnumDescriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{0}
+}
+
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Frame types to receive. Empty subscribes to the spectrum only.
+	Types []FrameType `protobuf:"varint,1,rep,packed,name=types,proto3,enum=phase4.v1.FrameType" json:"types,omitempty"`
+	// Maximum number of periodic updates per second, 0 for every update the server
+	// produces. Events are never rate limited.
+	MaxRate       float64 `protobuf:"fixed64,2,opt,name=max_rate,json=maxRate,proto3" json:"max_rate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_phase4_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeRequest) GetTypes() []FrameType {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *SubscribeRequest) GetMaxRate() float64 {
+	if x != nil {
+		return x.MaxRate
+	}
+	return 0
+}
+
+// Frame is one message of a Subscribe stream.
+type Frame struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sequence number of the update the frame belongs to. Frames produced on the same
+	// tick share a sequence number; events carry the sequence of the latest tick.
+	Sequence uint64 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Time the values were read (Unix time in nanoseconds).
+	TimestampNs int64 `protobuf:"varint,2,opt,name=timestamp_ns,json=timestampNs,proto3" json:"timestamp_ns,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Frame_Info
+	//	*Frame_Spectrum
+	//	*Frame_Levels
+	//	*Frame_Bands
+	//	*Frame_Hpss
+	//	*Frame_Vad
+	//	*Frame_Chord
+	//	*Frame_Chroma
+	//	*Frame_Peaks
+	//	*Frame_Event
+	Payload       isFrame_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_phase4_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Frame) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Frame) GetTimestampNs() int64 {
+	if x != nil {
+		return x.TimestampNs
+	}
+	return 0
+}
+
+func (x *Frame) GetPayload() isFrame_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Frame) GetInfo() *StreamInfo {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Info); ok {
+			return x.Info
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetSpectrum() *Spectrum {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Spectrum); ok {
+			return x.Spectrum
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetLevels() *Levels {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Levels); ok {
+			return x.Levels
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetBands() *Bands {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Bands); ok {
+			return x.Bands
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetHpss() *HPSS {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Hpss); ok {
+			return x.Hpss
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetVad() *VoiceActivity {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Vad); ok {
+			return x.Vad
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetChord() *Chord {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Chord); ok {
+			return x.Chord
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetChroma() *Chroma {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Chroma); ok {
+			return x.Chroma
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetPeaks() *Peaks {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Peaks); ok {
+			return x.Peaks
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetEvent() *Event {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Event); ok {
+			return x.Event
+		}
+	}
+	return nil
+}
+
+type isFrame_Payload interface {
+	isFrame_Payload()
+}
+
+type Frame_Info struct {
+	Info *StreamInfo `protobuf:"bytes,10,opt,name=info,proto3,oneof"`
+}
+
+type Frame_Spectrum struct {
+	Spectrum *Spectrum `protobuf:"bytes,11,opt,name=spectrum,proto3,oneof"`
+}
+
+type Frame_Levels struct {
+	Levels *Levels `protobuf:"bytes,12,opt,name=levels,proto3,oneof"`
+}
+
+type Frame_Bands struct {
+	Bands *Bands `protobuf:"bytes,13,opt,name=bands,proto3,oneof"`
+}
+
+type Frame_Hpss struct {
+	Hpss *HPSS `protobuf:"bytes,14,opt,name=hpss,proto3,oneof"`
+}
+
+type Frame_Vad struct {
+	Vad *VoiceActivity `protobuf:"bytes,15,opt,name=vad,proto3,oneof"`
+}
+
+type Frame_Chord struct {
+	Chord *Chord `protobuf:"bytes,16,opt,name=chord,proto3,oneof"`
+}
+
+type Frame_Chroma struct {
+	Chroma *Chroma `protobuf:"bytes,17,opt,name=chroma,proto3,oneof"`
+}
+
+type Frame_Peaks struct {
+	Peaks *Peaks `protobuf:"bytes,18,opt,name=peaks,proto3,oneof"`
+}
+
+type Frame_Event struct {
+	Event *Event `protobuf:"bytes,19,opt,name=event,proto3,oneof"`
+}
+
+func (*Frame_Info) isFrame_Payload() {}
+
+func (*Frame_Spectrum) isFrame_Payload() {}
+
+func (*Frame_Levels) isFrame_Payload() {}
+
+func (*Frame_Bands) isFrame_Payload() {}
+
+func (*Frame_Hpss) isFrame_Payload() {}
+
+func (*Frame_Vad) isFrame_Payload() {}
+
+func (*Frame_Chord) isFrame_Payload() {}
+
+func (*Frame_Chroma) isFrame_Payload() {}
+
+func (*Frame_Peaks) isFrame_Payload() {}
+
+func (*Frame_Event) isFrame_Payload() {}
+
+// StreamInfo describes the analysed audio and the frames the server can produce.
+type StreamInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SampleRate    float64                `protobuf:"fixed64,1,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`            // Sample rate of the analysed audio (Hz).
+	FftSize       uint32                 `protobuf:"varint,2,opt,name=fft_size,json=fftSize,proto3" json:"fft_size,omitempty"`                      // FFT size; spectra have fft_size/2+1 bins.
+	Channel       uint32                 `protobuf:"varint,3,opt,name=channel,proto3" json:"channel,omitempty"`                                     // Channel ID of the stream, as in UDP packet headers.
+	Available     []FrameType            `protobuf:"varint,4,rep,packed,name=available,proto3,enum=phase4.v1.FrameType" json:"available,omitempty"` // Frame types enabled on this server.
+	UpdateRate    float64                `protobuf:"fixed64,5,opt,name=update_rate,json=updateRate,proto3" json:"update_rate,omitempty"`            // Periodic updates per second produced by the server.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamInfo) Reset() {
+	*x = StreamInfo{}
+	mi := &file_phase4_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamInfo) ProtoMessage() {}
+
+func (x *StreamInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamInfo.ProtoReflect.Descriptor instead.
+func (*StreamInfo) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StreamInfo) GetSampleRate() float64 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *StreamInfo) GetFftSize() uint32 {
+	if x != nil {
+		return x.FftSize
+	}
+	return 0
+}
+
+func (x *StreamInfo) GetChannel() uint32 {
+	if x != nil {
+		return x.Channel
+	}
+	return 0
+}
+
+func (x *StreamInfo) GetAvailable() []FrameType {
+	if x != nil {
+		return x.Available
+	}
+	return nil
+}
+
+func (x *StreamInfo) GetUpdateRate() float64 {
+	if x != nil {
+		return x.UpdateRate
+	}
+	return 0
+}
+
+// Spectrum is a magnitude spectrum, bin k at k*sample_rate/fft_size Hz.
+type Spectrum struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Magnitudes    []float32              `protobuf:"fixed32,1,rep,packed,name=magnitudes,proto3" json:"magnitudes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Spectrum) Reset() {
+	*x = Spectrum{}
+	mi := &file_phase4_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Spectrum) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Spectrum) ProtoMessage() {}
+
+func (x *Spectrum) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Spectrum.ProtoReflect.Descriptor instead.
+func (*Spectrum) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Spectrum) GetMagnitudes() []float32 {
+	if x != nil {
+		return x.Magnitudes
+	}
+	return nil
+}
+
+type Levels struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RmsDbfs       float64                `protobuf:"fixed64,1,opt,name=rms_dbfs,json=rmsDbfs,proto3" json:"rms_dbfs,omitempty"`
+	PeakDbfs      float64                `protobuf:"fixed64,2,opt,name=peak_dbfs,json=peakDbfs,proto3" json:"peak_dbfs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Levels) Reset() {
+	*x = Levels{}
+	mi := &file_phase4_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Levels) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Levels) ProtoMessage() {}
+
+func (x *Levels) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Levels.ProtoReflect.Descriptor instead.
+func (*Levels) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Levels) GetRmsDbfs() float64 {
+	if x != nil {
+		return x.RmsDbfs
+	}
+	return 0
+}
+
+func (x *Levels) GetPeakDbfs() float64 {
+	if x != nil {
+		return x.PeakDbfs
+	}
+	return 0
+}
+
+type Bands struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EdgesHz       []float64              `protobuf:"fixed64,1,rep,packed,name=edges_hz,json=edgesHz,proto3" json:"edges_hz,omitempty"`          // Band edges, one more entry than levels.
+	LevelsDbfs    []float64              `protobuf:"fixed64,2,rep,packed,name=levels_dbfs,json=levelsDbfs,proto3" json:"levels_dbfs,omitempty"` // Lowest band first.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Bands) Reset() {
+	*x = Bands{}
+	mi := &file_phase4_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Bands) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Bands) ProtoMessage() {}
+
+func (x *Bands) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Bands.ProtoReflect.Descriptor instead.
+func (*Bands) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Bands) GetEdgesHz() []float64 {
+	if x != nil {
+		return x.EdgesHz
+	}
+	return nil
+}
+
+func (x *Bands) GetLevelsDbfs() []float64 {
+	if x != nil {
+		return x.LevelsDbfs
+	}
+	return nil
+}
+
+type HPSS struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	HarmonicEnergy   float64                `protobuf:"fixed64,1,opt,name=harmonic_energy,json=harmonicEnergy,proto3" json:"harmonic_energy,omitempty"`
+	PercussiveEnergy float64                `protobuf:"fixed64,2,opt,name=percussive_energy,json=percussiveEnergy,proto3" json:"percussive_energy,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *HPSS) Reset() {
+	*x = HPSS{}
+	mi := &file_phase4_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HPSS) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HPSS) ProtoMessage() {}
+
+func (x *HPSS) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HPSS.ProtoReflect.Descriptor instead.
+func (*HPSS) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HPSS) GetHarmonicEnergy() float64 {
+	if x != nil {
+		return x.HarmonicEnergy
+	}
+	return 0
+}
+
+func (x *HPSS) GetPercussiveEnergy() float64 {
+	if x != nil {
+		return x.PercussiveEnergy
+	}
+	return 0
+}
+
+type VoiceActivity struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Probability   float64                `protobuf:"fixed64,1,opt,name=probability,proto3" json:"probability,omitempty"` // Speech probability (0..1).
+	Speaking      bool                   `protobuf:"varint,2,opt,name=speaking,proto3" json:"speaking,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoiceActivity) Reset() {
+	*x = VoiceActivity{}
+	mi := &file_phase4_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoiceActivity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoiceActivity) ProtoMessage() {}
+
+func (x *VoiceActivity) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoiceActivity.ProtoReflect.Descriptor instead.
+func (*VoiceActivity) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *VoiceActivity) GetProbability() float64 {
+	if x != nil {
+		return x.Probability
+	}
+	return 0
+}
+
+func (x *VoiceActivity) GetSpeaking() bool {
+	if x != nil {
+		return x.Speaking
+	}
+	return false
+}
+
+type Chord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Label         string                 `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"` // Chord name, e.g. "Am7", or "N" for no chord.
+	Confidence    float64                `protobuf:"fixed64,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chord) Reset() {
+	*x = Chord{}
+	mi := &file_phase4_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chord) ProtoMessage() {}
+
+func (x *Chord) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chord.ProtoReflect.Descriptor instead.
+func (*Chord) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Chord) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Chord) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+type Chroma struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []float64              `protobuf:"fixed64,1,rep,packed,name=values,proto3" json:"values,omitempty"` // 12 pitch class weights, C first.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chroma) Reset() {
+	*x = Chroma{}
+	mi := &file_phase4_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chroma) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chroma) ProtoMessage() {}
+
+func (x *Chroma) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chroma.ProtoReflect.Descriptor instead.
+func (*Chroma) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Chroma) GetValues() []float64 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type Peaks struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peaks         []*Peak                `protobuf:"bytes,1,rep,name=peaks,proto3" json:"peaks,omitempty"` // Strongest first.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Peaks) Reset() {
+	*x = Peaks{}
+	mi := &file_phase4_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Peaks) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Peaks) ProtoMessage() {}
+
+func (x *Peaks) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Peaks.ProtoReflect.Descriptor instead.
+func (*Peaks) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Peaks) GetPeaks() []*Peak {
+	if x != nil {
+		return x.Peaks
+	}
+	return nil
+}
+
+type Peak struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FrequencyHz   float64                `protobuf:"fixed64,1,opt,name=frequency_hz,json=frequencyHz,proto3" json:"frequency_hz,omitempty"`
+	Magnitude     float64                `protobuf:"fixed64,2,opt,name=magnitude,proto3" json:"magnitude,omitempty"`
+	BandwidthHz   float64                `protobuf:"fixed64,3,opt,name=bandwidth_hz,json=bandwidthHz,proto3" json:"bandwidth_hz,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Peak) Reset() {
+	*x = Peak{}
+	mi := &file_phase4_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Peak) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Peak) ProtoMessage() {}
+
+func (x *Peak) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Peak.ProtoReflect.Descriptor instead.
+func (*Peak) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Peak) GetFrequencyHz() float64 {
+	if x != nil {
+		return x.FrequencyHz
+	}
+	return 0
+}
+
+func (x *Peak) GetMagnitude() float64 {
+	if x != nil {
+		return x.Magnitude
+	}
+	return 0
+}
+
+func (x *Peak) GetBandwidthHz() float64 {
+	if x != nil {
+		return x.BandwidthHz
+	}
+	return 0
+}
+
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"` // Processor that detected the event, e.g. "vad".
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`     // Event name, e.g. "speech_start".
+	Label         string                 `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`   // Optional label, e.g. a chord name.
+	Value         float64                `protobuf:"fixed64,4,opt,name=value,proto3" json:"value,omitempty"` // Strength or confidence.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_phase4_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Event) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Event) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Event) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Event) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_phase4_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{13}
+}
+
+type Status struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Streaming     bool                   `protobuf:"varint,1,opt,name=streaming,proto3" json:"streaming,omitempty"`                              // Whether the audio stream is running.
+	StartedAtNs   int64                  `protobuf:"varint,2,opt,name=started_at_ns,json=startedAtNs,proto3" json:"started_at_ns,omitempty"`     // When the gRPC server started (Unix time in nanoseconds).
+	Subscribers   uint32                 `protobuf:"varint,3,opt,name=subscribers,proto3" json:"subscribers,omitempty"`                          // Number of active Subscribe calls.
+	FramesSent    uint64                 `protobuf:"varint,4,opt,name=frames_sent,json=framesSent,proto3" json:"frames_sent,omitempty"`          // Frames sent to all subscribers.
+	FramesDropped uint64                 `protobuf:"varint,5,opt,name=frames_dropped,json=framesDropped,proto3" json:"frames_dropped,omitempty"` // Frames dropped for subscribers that fell behind.
+	Stream        *StreamInfo            `protobuf:"bytes,6,opt,name=stream,proto3" json:"stream,omitempty"`
+	Transports    []*TransportStats      `protobuf:"bytes,7,rep,name=transports,proto3" json:"transports,omitempty"` // UDP streams, configured targets first.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_phase4_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Status) GetStreaming() bool {
+	if x != nil {
+		return x.Streaming
+	}
+	return false
+}
+
+func (x *Status) GetStartedAtNs() int64 {
+	if x != nil {
+		return x.StartedAtNs
+	}
+	return 0
+}
+
+func (x *Status) GetSubscribers() uint32 {
+	if x != nil {
+		return x.Subscribers
+	}
+	return 0
+}
+
+func (x *Status) GetFramesSent() uint64 {
+	if x != nil {
+		return x.FramesSent
+	}
+	return 0
+}
+
+func (x *Status) GetFramesDropped() uint64 {
+	if x != nil {
+		return x.FramesDropped
+	}
+	return 0
+}
+
+func (x *Status) GetStream() *StreamInfo {
+	if x != nil {
+		return x.Stream
+	}
+	return nil
+}
+
+func (x *Status) GetTransports() []*TransportStats {
+	if x != nil {
+		return x.Transports
+	}
+	return nil
+}
+
+// TransportStats holds the counters of one UDP stream.
+type TransportStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        string                 `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`                                                                            // Destination address.
+	Subscriber    bool                   `protobuf:"varint,2,opt,name=subscriber,proto3" json:"subscriber,omitempty"`                                                                   // Whether the stream serves a subscription.
+	Messages      uint64                 `protobuf:"varint,3,opt,name=messages,proto3" json:"messages,omitempty"`                                                                       // Messages built (one or more datagrams each).
+	Skipped       uint64                 `protobuf:"varint,4,opt,name=skipped,proto3" json:"skipped,omitempty"`                                                                         // Messages skipped because no matching spectrum was available.
+	FramesDropped uint64                 `protobuf:"varint,5,opt,name=frames_dropped,json=framesDropped,proto3" json:"frames_dropped,omitempty"`                                        // Analysis frames missed because the publisher fell behind.
+	Packets       uint64                 `protobuf:"varint,6,opt,name=packets,proto3" json:"packets,omitempty"`                                                                         // Datagrams sent.
+	Bytes         uint64                 `protobuf:"varint,7,opt,name=bytes,proto3" json:"bytes,omitempty"`                                                                             // Size of the datagrams sent.
+	Dropped       uint64                 `protobuf:"varint,8,opt,name=dropped,proto3" json:"dropped,omitempty"`                                                                         // Datagrams not sent while paused.
+	Errors        map[string]uint64      `protobuf:"bytes,9,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Failed sends by class, e.g. "refused", "unreachable", "too_large".
+	Paused        bool                   `protobuf:"varint,10,opt,name=paused,proto3" json:"paused,omitempty"`                                                                          // Whether sending pauses because the target refuses packets.
+	LastError     string                 `protobuf:"bytes,11,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`                                                    // Latest send error, empty if there was none.
+	LastErrorAtNs int64                  `protobuf:"varint,12,opt,name=last_error_at_ns,json=lastErrorAtNs,proto3" json:"last_error_at_ns,omitempty"`                                   // Time of the latest send error (Unix time in nanoseconds).
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransportStats) Reset() {
+	*x = TransportStats{}
+	mi := &file_phase4_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransportStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransportStats) ProtoMessage() {}
+
+func (x *TransportStats) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransportStats.ProtoReflect.Descriptor instead.
+func (*TransportStats) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *TransportStats) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *TransportStats) GetSubscriber() bool {
+	if x != nil {
+		return x.Subscriber
+	}
+	return false
+}
+
+func (x *TransportStats) GetMessages() uint64 {
+	if x != nil {
+		return x.Messages
+	}
+	return 0
+}
+
+func (x *TransportStats) GetSkipped() uint64 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *TransportStats) GetFramesDropped() uint64 {
+	if x != nil {
+		return x.FramesDropped
+	}
+	return 0
+}
+
+func (x *TransportStats) GetPackets() uint64 {
+	if x != nil {
+		return x.Packets
+	}
+	return 0
+}
+
+func (x *TransportStats) GetBytes() uint64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *TransportStats) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *TransportStats) GetErrors() map[string]uint64 {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *TransportStats) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *TransportStats) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *TransportStats) GetLastErrorAtNs() int64 {
+	if x != nil {
+		return x.LastErrorAtNs
+	}
+	return 0
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_phase4_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{16}
+}
+
+type Config struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	SampleRate      float64                `protobuf:"fixed64,1,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	FramesPerBuffer uint32                 `protobuf:"varint,2,opt,name=frames_per_buffer,json=framesPerBuffer,proto3" json:"frames_per_buffer,omitempty"`
+	InputChannels   uint32                 `protobuf:"varint,3,opt,name=input_channels,json=inputChannels,proto3" json:"input_channels,omitempty"`
+	FftWindow       string                 `protobuf:"bytes,4,opt,name=fft_window,json=fftWindow,proto3" json:"fft_window,omitempty"`
+	// The complete effective configuration as YAML.
+	Yaml          string `protobuf:"bytes,10,opt,name=yaml,proto3" json:"yaml,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	mi := &file_phase4_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_phase4_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_phase4_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Config) GetSampleRate() float64 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *Config) GetFramesPerBuffer() uint32 {
+	if x != nil {
+		return x.FramesPerBuffer
+	}
+	return 0
+}
+
+func (x *Config) GetInputChannels() uint32 {
+	if x != nil {
+		return x.InputChannels
+	}
+	return 0
+}
+
+func (x *Config) GetFftWindow() string {
+	if x != nil {
+		return x.FftWindow
+	}
+	return ""
+}
+
+func (x *Config) GetYaml() string {
+	if x != nil {
+		return x.Yaml
+	}
+	return ""
+}
+
+var File_phase4_proto protoreflect.FileDescriptor
+
+const file_phase4_proto_rawDesc = "" +
+	"\n" +
+	"\fphase4.proto\x12\tphase4.v1\"Y\n" +
+	"\x10SubscribeRequest\x12*\n" +
+	"\x05types\x18\x01 \x03(\x0e2\x14.phase4.v1.FrameTypeR\x05types\x12\x19\n" +
+	"\bmax_rate\x18\x02 \x01(\x01R\amaxRate\"\x88\x04\n" +
+	"\x05Frame\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12!\n" +
+	"\ftimestamp_ns\x18\x02 \x01(\x03R\vtimestampNs\x12+\n" +
+	"\x04info\x18\n" +
+	" \x01(\v2\x15.phase4.v1.StreamInfoH\x00R\x04info\x121\n" +
+	"\bspectrum\x18\v \x01(\v2\x13.phase4.v1.SpectrumH\x00R\bspectrum\x12+\n" +
+	"\x06levels\x18\f \x01(\v2\x11.phase4.v1.LevelsH\x00R\x06levels\x12(\n" +
+	"\x05bands\x18\r \x01(\v2\x10.phase4.v1.BandsH\x00R\x05bands\x12%\n" +
+	"\x04hpss\x18\x0e \x01(\v2\x0f.phase4.v1.HPSSH\x00R\x04hpss\x12,\n" +
+	"\x03vad\x18\x0f \x01(\v2\x18.phase4.v1.VoiceActivityH\x00R\x03vad\x12(\n" +
+	"\x05chord\x18\x10 \x01(\v2\x10.phase4.v1.ChordH\x00R\x05chord\x12+\n" +
+	"\x06chroma\x18\x11 \x01(\v2\x11.phase4.v1.ChromaH\x00R\x06chroma\x12(\n" +
+	"\x05peaks\x18\x12 \x01(\v2\x10.phase4.v1.PeaksH\x00R\x05peaks\x12(\n" +
+	"\x05event\x18\x13 \x01(\v2\x10.phase4.v1.EventH\x00R\x05eventB\t\n" +
+	"\apayload\"\xb7\x01\n" +
+	"\n" +
+	"StreamInfo\x12\x1f\n" +
+	"\vsample_rate\x18\x01 \x01(\x01R\n" +
+	"sampleRate\x12\x19\n" +
+	"\bfft_size\x18\x02 \x01(\rR\afftSize\x12\x18\n" +
+	"\achannel\x18\x03 \x01(\rR\achannel\x122\n" +
+	"\tavailable\x18\x04 \x03(\x0e2\x14.phase4.v1.FrameTypeR\tavailable\x12\x1f\n" +
+	"\vupdate_rate\x18\x05 \x01(\x01R\n" +
+	"updateRate\"*\n" +
+	"\bSpectrum\x12\x1e\n" +
+	"\n" +
+	"magnitudes\x18\x01 \x03(\x02R\n" +
+	"magnitudes\"@\n" +
+	"\x06Levels\x12\x19\n" +
+	"\brms_dbfs\x18\x01 \x01(\x01R\armsDbfs\x12\x1b\n" +
+	"\tpeak_dbfs\x18\x02 \x01(\x01R\bpeakDbfs\"C\n" +
+	"\x05Bands\x12\x19\n" +
+	"\bedges_hz\x18\x01 \x03(\x01R\aedgesHz\x12\x1f\n" +
+	"\vlevels_dbfs\x18\x02 \x03(\x01R\n" +
+	"levelsDbfs\"\\\n" +
+	"\x04HPSS\x12'\n" +
+	"\x0fharmonic_energy\x18\x01 \x01(\x01R\x0eharmonicEnergy\x12+\n" +
+	"\x11percussive_energy\x18\x02 \x01(\x01R\x10percussiveEnergy\"M\n" +
+	"\rVoiceActivity\x12 \n" +
+	"\vprobability\x18\x01 \x01(\x01R\vprobability\x12\x1a\n" +
+	"\bspeaking\x18\x02 \x01(\bR\bspeaking\"=\n" +
+	"\x05Chord\x12\x14\n" +
+	"\x05label\x18\x01 \x01(\tR\x05label\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x01R\n" +
+	"confidence\" \n" +
+	"\x06Chroma\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\x01R\x06values\".\n" +
+	"\x05Peaks\x12%\n" +
+	"\x05peaks\x18\x01 \x03(\v2\x0f.phase4.v1.PeakR\x05peaks\"j\n" +
+	"\x04Peak\x12!\n" +
+	"\ffrequency_hz\x18\x01 \x01(\x01R\vfrequencyHz\x12\x1c\n" +
+	"\tmagnitude\x18\x02 \x01(\x01R\tmagnitude\x12!\n" +
+	"\fbandwidth_hz\x18\x03 \x01(\x01R\vbandwidthHz\"_\n" +
+	"\x05Event\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\x01R\x05value\"\x12\n" +
+	"\x10GetStatusRequest\"\x9e\x02\n" +
+	"\x06Status\x12\x1c\n" +
+	"\tstreaming\x18\x01 \x01(\bR\tstreaming\x12\"\n" +
+	"\rstarted_at_ns\x18\x02 \x01(\x03R\vstartedAtNs\x12 \n" +
+	"\vsubscribers\x18\x03 \x01(\rR\vsubscribers\x12\x1f\n" +
+	"\vframes_sent\x18\x04 \x01(\x04R\n" +
+	"framesSent\x12%\n" +
+	"\x0eframes_dropped\x18\x05 \x01(\x04R\rframesDropped\x12-\n" +
+	"\x06stream\x18\x06 \x01(\v2\x15.phase4.v1.StreamInfoR\x06stream\x129\n" +
+	"\n" +
+	"transports\x18\a \x03(\v2\x19.phase4.v1.TransportStatsR\n" +
+	"transports\"\xc9\x03\n" +
+	"\x0eTransportStats\x12\x16\n" +
+	"\x06target\x18\x01 \x01(\tR\x06target\x12\x1e\n" +
+	"\n" +
+	"subscriber\x18\x02 \x01(\bR\n" +
+	"subscriber\x12\x1a\n" +
+	"\bmessages\x18\x03 \x01(\x04R\bmessages\x12\x18\n" +
+	"\askipped\x18\x04 \x01(\x04R\askipped\x12%\n" +
+	"\x0eframes_dropped\x18\x05 \x01(\x04R\rframesDropped\x12\x18\n" +
+	"\apackets\x18\x06 \x01(\x04R\apackets\x12\x14\n" +
+	"\x05bytes\x18\a \x01(\x04R\x05bytes\x12\x18\n" +
+	"\adropped\x18\b \x01(\x04R\adropped\x12=\n" +
+	"\x06errors\x18\t \x03(\v2%.phase4.v1.TransportStats.ErrorsEntryR\x06errors\x12\x16\n" +
+	"\x06paused\x18\n" +
+	" \x01(\bR\x06paused\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\v \x01(\tR\tlastError\x12'\n" +
+	"\x10last_error_at_ns\x18\f \x01(\x03R\rlastErrorAtNs\x1a9\n" +
+	"\vErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"\x12\n" +
+	"\x10GetConfigRequest\"\xaf\x01\n" +
+	"\x06Config\x12\x1f\n" +
+	"\vsample_rate\x18\x01 \x01(\x01R\n" +
+	"sampleRate\x12*\n" +
+	"\x11frames_per_buffer\x18\x02 \x01(\rR\x0fframesPerBuffer\x12%\n" +
+	"\x0einput_channels\x18\x03 \x01(\rR\rinputChannels\x12\x1d\n" +
+	"\n" +
+	"fft_window\x18\x04 \x01(\tR\tfftWindow\x12\x12\n" +
+	"\x04yaml\x18\n" +
+	" \x01(\tR\x04yaml*\xef\x01\n" +
+	"\tFrameType\x12\x1a\n" +
+	"\x16FRAME_TYPE_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13FRAME_TYPE_SPECTRUM\x10\x01\x12\x15\n" +
+	"\x11FRAME_TYPE_LEVELS\x10\x02\x12\x14\n" +
+	"\x10FRAME_TYPE_BANDS\x10\x03\x12\x13\n" +
+	"\x0fFRAME_TYPE_HPSS\x10\x04\x12\x12\n" +
+	"\x0eFRAME_TYPE_VAD\x10\x05\x12\x14\n" +
+	"\x10FRAME_TYPE_CHORD\x10\x06\x12\x15\n" +
+	"\x11FRAME_TYPE_CHROMA\x10\a\x12\x14\n" +
+	"\x10FRAME_TYPE_PEAKS\x10\b\x12\x14\n" +
+	"\x10FRAME_TYPE_EVENT\x10\t2\xc0\x01\n" +
+	"\x06Engine\x12<\n" +
+	"\tSubscribe\x12\x1b.phase4.v1.SubscribeRequest\x1a\x10.phase4.v1.Frame0\x01\x12;\n" +
+	"\tGetStatus\x12\x1b.phase4.v1.GetStatusRequest\x1a\x11.phase4.v1.Status\x12;\n" +
+	"\tGetConfig\x12\x1b.phase4.v1.GetConfigRequest\x1a\x11.phase4.v1.ConfigB(Z\x16audio/pkg/api/v1;apiv1\xaa\x02\rPhase4.Api.V1b\x06proto3"
+
+var (
+	file_phase4_proto_rawDescOnce sync.Once
+	file_phase4_proto_rawDescData []byte
+)
+
+func file_phase4_proto_rawDescGZIP() []byte {
+	file_phase4_proto_rawDescOnce.Do(func() {
+		file_phase4_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_phase4_proto_rawDesc), len(file_phase4_proto_rawDesc)))
+	})
+	return file_phase4_proto_rawDescData
+}
+
+var file_phase4_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_phase4_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_phase4_proto_goTypes = []any{
+	(FrameType)(0),           // 0: phase4.v1.FrameType
+	(*SubscribeRequest)(nil), // 1: phase4.v1.SubscribeRequest
+	(*Frame)(nil),            // 2: phase4.v1.Frame
+	(*StreamInfo)(nil),       // 3: phase4.v1.StreamInfo
+	(*Spectrum)(nil),         // 4: phase4.v1.Spectrum
+	(*Levels)(nil),           // 5: phase4.v1.Levels
+	(*Bands)(nil),            // 6: phase4.v1.Bands
+	(*HPSS)(nil),             // 7: phase4.v1.HPSS
+	(*VoiceActivity)(nil),    // 8: phase4.v1.VoiceActivity
+	(*Chord)(nil),            // 9: phase4.v1.Chord
+	(*Chroma)(nil),           // 10: phase4.v1.Chroma
+	(*Peaks)(nil),            // 11: phase4.v1.Peaks
+	(*Peak)(nil),             // 12: phase4.v1.Peak
+	(*Event)(nil),            // 13: phase4.v1.Event
+	(*GetStatusRequest)(nil), // 14: phase4.v1.GetStatusRequest
+	(*Status)(nil),           // 15: phase4.v1.Status
+	(*TransportStats)(nil),   // 16: phase4.v1.TransportStats
+	(*GetConfigRequest)(nil), // 17: phase4.v1.GetConfigRequest
+	(*Config)(nil),           // 18: phase4.v1.Config
+	nil,                      // 19: phase4.v1.TransportStats.ErrorsEntry
+}
+var file_phase4_proto_depIdxs = []int32{
+	0,  // 0: phase4.v1.SubscribeRequest.types:type_name -> phase4.v1.FrameType
+	3,  // 1: phase4.v1.Frame.info:type_name -> phase4.v1.StreamInfo
+	4,  // 2: phase4.v1.Frame.spectrum:type_name -> phase4.v1.Spectrum
+	5,  // 3: phase4.v1.Frame.levels:type_name -> phase4.v1.Levels
+	6,  // 4: phase4.v1.Frame.bands:type_name -> phase4.v1.Bands
+	7,  // 5: phase4.v1.Frame.hpss:type_name -> phase4.v1.HPSS
+	8,  // 6: phase4.v1.Frame.vad:type_name -> phase4.v1.VoiceActivity
+	9,  // 7: phase4.v1.Frame.chord:type_name -> phase4.v1.Chord
+	10, // 8: phase4.v1.Frame.chroma:type_name -> phase4.v1.Chroma
+	11, // 9: phase4.v1.Frame.peaks:type_name -> phase4.v1.Peaks
+	13, // 10: phase4.v1.Frame.event:type_name -> phase4.v1.Event
+	0,  // 11: phase4.v1.StreamInfo.available:type_name -> phase4.v1.FrameType
+	12, // 12: phase4.v1.Peaks.peaks:type_name -> phase4.v1.Peak
+	3,  // 13: phase4.v1.Status.stream:type_name -> phase4.v1.StreamInfo
+	16, // 14: phase4.v1.Status.transports:type_name -> phase4.v1.TransportStats
+	19, // 15: phase4.v1.TransportStats.errors:type_name -> phase4.v1.TransportStats.ErrorsEntry
+	1,  // 16: phase4.v1.Engine.Subscribe:input_type -> phase4.v1.SubscribeRequest
+	14, // 17: phase4.v1.Engine.GetStatus:input_type -> phase4.v1.GetStatusRequest
+	17, // 18: phase4.v1.Engine.GetConfig:input_type -> phase4.v1.GetConfigRequest
+	2,  // 19: phase4.v1.Engine.Subscribe:output_type -> phase4.v1.Frame
+	15, // 20: phase4.v1.Engine.GetStatus:output_type -> phase4.v1.Status
+	18, // 21: phase4.v1.Engine.GetConfig:output_type -> phase4.v1.Config
+	19, // [19:22] is the sub-list for method output_type
+	16, // [16:19] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_phase4_proto_init() }
+func file_phase4_proto_init() {
+	if File_phase4_proto != nil {
+		return
+	}
+	file_phase4_proto_msgTypes[1].OneofWrappers = []any{
+		(*Frame_Info)(nil),
+		(*Frame_Spectrum)(nil),
+		(*Frame_Levels)(nil),
+		(*Frame_Bands)(nil),
+		(*Frame_Hpss)(nil),
+		(*Frame_Vad)(nil),
+		(*Frame_Chord)(nil),
+		(*Frame_Chroma)(nil),
+		(*Frame_Peaks)(nil),
+		(*Frame_Event)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_phase4_proto_rawDesc), len(file_phase4_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_phase4_proto_goTypes,
+		DependencyIndexes: file_phase4_proto_depIdxs,
+		EnumInfos:         file_phase4_proto_enumTypes,
+		MessageInfos:      file_phase4_proto_msgTypes,
+	}.Build()
+	File_phase4_proto = out.File
+	file_phase4_proto_goTypes = nil
+	file_phase4_proto_depIdxs = nil
+}
@@ -0,0 +1,377 @@
+// SPDX-License-Identifier: MIT
+
+// Package client receives the engine's UDP stream. Client listens on a UDP address
+// (unicast or a multicast group), or with Dial connects to the engine's TCP stream, and
+// delivers decoded spectra through a channel or a callback; Decoder does the decoding
+// for programs that receive datagrams themselves.
+// Both reassemble fragmented messages and keep per-stream statistics on lost,
+// reordered and duplicated messages and on latency. With Options.Subscribe, a Client
+// registers with the engine's control port instead of relying on a configured target.
+// Engines advertised on the local network are found with audio/pkg/discovery. The wire
+// format is defined in audio/pkg/protocol.
+package client
+
+import (
+	"audio/pkg/protocol"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultBuffer is the number of spectra the channel of a Client holds when
+// Options.Buffer is zero.
+const DefaultBuffer = 16
+
+// DefaultKeepAlive is the interval between subscription renewals until the engine has
+// acknowledged a lease; afterwards a third of the granted lease is used.
+const DefaultKeepAlive = 2 * time.Second
+
+// maxDatagramSize is the largest UDP payload.
+const maxDatagramSize = 65535
+
+// dialTimeout is the maximum time Dial spends connecting.
+const dialTimeout = 5 * time.Second
+
+// Options controls a Client.
+type Options struct {
+	DecoderOptions
+
+	// Handler, if set, is called with every spectrum from the receiving goroutine
+	// instead of delivering it on the channel returned by Spectra. It must return quickly:
+	// datagrams queue up in the socket while it runs. The spectrum's magnitudes are not
+	// reused and may be retained.
+	Handler func(Spectrum)
+
+	Buffer     int    // Capacity of the spectrum channel (0 uses DefaultBuffer).
+	Interface  string // Interface to join a multicast group on (empty uses the system default, UDP only).
+	ReadBuffer int    // Socket receive buffer size in bytes (0 keeps the system default).
+
+	// Subscribe, if set, is the engine's control address ("host:port", see
+	// udp_control_address), UDP only. The client subscribes there from its socket, renews the lease
+	// until it is closed and then unsubscribes. Subscription holds the requested types,
+	// interval and lease; zero values use the engine defaults.
+	Subscribe    string
+	Subscription protocol.Subscription
+
+	// Sealer, if set, signs (or encrypts) the subscription messages, for engines that
+	// only accept authenticated ones. Acknowledgements are opened with DecoderOptions.Opener.
+	Sealer *protocol.Sealer
+}
+
+// Client receives spectra from the engine on a UDP address or a TCP connection. Spectra
+// are delivered on the channel returned by Spectra, or to Options.Handler. When the
+// channel is full, new spectra are dropped and counted in Stats().Dropped, so a slow
+// consumer never delays decoding.
+type Client struct {
+	conn    net.Conn
+	udp     *net.UDPConn // conn as a UDP socket, nil for a TCP stream.
+	handler func(Spectrum)
+	spectra chan Spectrum
+
+	mu      sync.Mutex // Protects decoder, dropped and granted.
+	decoder *Decoder
+	dropped uint64
+
+	control      *net.UDPAddr          // Engine control address (Subscribe option), nil otherwise.
+	subscription protocol.Subscription // Requested subscription.
+	sealer       *protocol.Sealer      // Seals control messages, nil to send them unsealed.
+	granted      protocol.Subscription // Latest acknowledged subscription.
+	acked        bool                  // Whether an acknowledgement has been received.
+	cookie       uint64                // Cookie the engine challenged us with, echoed in every request.
+	done         chan struct{}         // Stops the keepalive goroutine.
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Listen starts receiving on address ("host:port", ":port" for all interfaces, or a
+// multicast group and port).
+func Listen(address string, options Options) (*Client, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("client: failed to resolve listen address '%s': %w", address, err)
+	}
+
+	var conn *net.UDPConn
+	if addr.IP.IsMulticast() {
+		var ifi *net.Interface
+		if options.Interface != "" {
+			if ifi, err = net.InterfaceByName(options.Interface); err != nil {
+				return nil, fmt.Errorf("client: unknown interface %q: %w", options.Interface, err)
+			}
+		}
+		conn, err = net.ListenMulticastUDP("udp", ifi, addr)
+	} else {
+		conn, err = net.ListenUDP("udp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("client: failed to listen on '%s': %w", address, err)
+	}
+	if options.ReadBuffer > 0 {
+		if err := conn.SetReadBuffer(options.ReadBuffer); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("client: failed to set read buffer: %w", err)
+		}
+	}
+	var control *net.UDPAddr
+	if options.Subscribe != "" {
+		if control, err = net.ResolveUDPAddr("udp", options.Subscribe); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("client: failed to resolve control address '%s': %w", options.Subscribe, err)
+		}
+	}
+	return newClient(conn, control, options), nil
+}
+
+// Dial connects to the engine's TCP stream at address ("host:port", see
+// transport.tcp.listen_address) and starts receiving. The stream ends when the engine
+// closes the connection; the channel returned by Spectra is closed then.
+func Dial(address string, options Options) (*Client, error) {
+	if options.Subscribe != "" {
+		return nil, fmt.Errorf("client: subscriptions need a UDP client")
+	}
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("client: failed to connect to '%s': %w", address, err)
+	}
+	if options.ReadBuffer > 0 {
+		if err := conn.(*net.TCPConn).SetReadBuffer(options.ReadBuffer); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("client: failed to set read buffer: %w", err)
+		}
+	}
+	return newClient(conn, nil, options), nil
+}
+
+// newClient starts receiving on conn, subscribing at control if it isn't nil.
+func newClient(conn net.Conn, control *net.UDPAddr, options Options) *Client {
+	if options.Buffer <= 0 {
+		options.Buffer = DefaultBuffer
+	}
+	udp, _ := conn.(*net.UDPConn)
+	c := &Client{
+		conn:    conn,
+		udp:     udp,
+		handler: options.Handler,
+		decoder: NewDecoder(options.DecoderOptions),
+		control: control,
+		sealer:  options.Sealer,
+		done:    make(chan struct{}),
+	}
+	c.subscription = options.Subscription
+	if c.handler == nil {
+		c.spectra = make(chan Spectrum, options.Buffer)
+	}
+
+	c.wg.Add(1)
+	go c.receive()
+	if c.control != nil {
+		c.wg.Add(1)
+		go c.keepAlive()
+	}
+	return c
+}
+
+// Spectra returns the channel receiving decoded spectra. It is closed by Close, and nil
+// when a Handler is set.
+func (c *Client) Spectra() <-chan Spectrum {
+	return c.spectra
+}
+
+// Addr returns the local address the client listens on (or connected from).
+func (c *Client) Addr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Stats returns a snapshot of the statistics of all streams received so far.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.decoder.Stats()
+	stats.Dropped = c.dropped
+	return stats
+}
+
+// Subscription returns the subscription the engine acknowledged last, and false if none
+// has been acknowledged (or the Subscribe option isn't set). A granted lease of zero
+// means the engine rejected the subscription.
+func (c *Client) Subscription() (protocol.Subscription, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.granted, c.acked
+}
+
+// keepAlive subscribes at the control address and renews the subscription until Close.
+func (c *Client) keepAlive() {
+	defer c.wg.Done()
+	for {
+		// Errors are retried at the next renewal; the engine may not be up yet.
+		c.subscribe()
+
+		c.mu.Lock()
+		interval := DefaultKeepAlive
+		if c.acked && c.granted.Lease > 0 {
+			interval = c.granted.Lease / 3
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// subscribe sends the requested subscription with the latest cookie.
+func (c *Client) subscribe() {
+	c.mu.Lock()
+	request := c.subscription
+	request.Cookie = c.cookie
+	c.mu.Unlock()
+	c.sendControl(protocol.MessageSubscribe, request)
+}
+
+// sendControl sends a control message of type t to the control address. Every message
+// is built (and sealed) anew, so it has a fresh timestamp and nonce.
+func (c *Client) sendControl(t protocol.MessageType, s protocol.Subscription) {
+	packet := protocol.AppendControl(nil, t, s)
+	if c.sealer != nil {
+		var err error
+		if packet, err = c.sealer.Seal(packet); err != nil {
+			return
+		}
+	}
+	_, _ = c.udp.WriteToUDP(packet, c.control)
+}
+
+// handleAck records a subscription acknowledgement, and answers a challenge (an
+// acknowledgement with a new cookie but no lease) by subscribing again with the cookie.
+// It reports whether packet was one.
+func (c *Client) handleAck(packet []byte, source *net.UDPAddr, received time.Time) bool {
+	if c.control == nil || !protocol.IsPacket(packet) {
+		return false
+	}
+	// The message type is never encrypted, so acknowledgements are recognised before
+	// they are opened.
+	if header, _, err := protocol.ParseHeader(packet); err != nil || header.Type != protocol.MessageSubscribeAck {
+		return false
+	}
+	c.mu.Lock()
+	packet, err := c.decoder.open(packet, received)
+	c.mu.Unlock()
+	if err != nil {
+		return true
+	}
+	_, payload, err := protocol.ParseHeader(packet)
+	if err != nil {
+		return true
+	}
+	if granted, err := protocol.ParseSubscription(payload); err == nil && source.Port == c.control.Port &&
+		(c.control.IP == nil || c.control.IP.IsUnspecified() || source.IP.Equal(c.control.IP)) {
+		c.mu.Lock()
+		challenged := granted.Cookie != 0 && granted.Lease == 0 && granted.Cookie != c.cookie
+		if granted.Cookie != 0 {
+			c.cookie = granted.Cookie
+		}
+		if !challenged {
+			c.granted, c.acked = granted, true
+		}
+		c.mu.Unlock()
+		if challenged {
+			c.subscribe()
+		}
+	}
+	return true
+}
+
+// receive reads and decodes datagrams, or frames of a TCP stream, until the connection
+// is closed.
+func (c *Client) receive() {
+	defer c.wg.Done()
+	if c.spectra != nil {
+		defer close(c.spectra)
+	}
+	if c.udp == nil {
+		c.receiveStream()
+		return
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, source, err := c.udp.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue // Transient errors (e.g. ICMP reports on some systems).
+		}
+		received := time.Now()
+		if c.handleAck(buf[:n], source, received) {
+			continue
+		}
+		c.deliver(buf[:n], source, received)
+	}
+}
+
+// receiveStream reads and decodes length-prefixed packets until the stream ends.
+func (c *Client) receiveStream() {
+	r := bufio.NewReaderSize(c.conn, 64*1024)
+	source := c.conn.RemoteAddr()
+	var buf []byte
+	for {
+		packet, err := protocol.ReadFrame(r, buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+				// A broken or corrupt stream can't be resynchronised.
+				c.mu.Lock()
+				c.decoder.errors++
+				c.mu.Unlock()
+			}
+			return
+		}
+		buf = packet[:cap(packet)]
+		c.deliver(packet, source, time.Now())
+	}
+}
+
+// deliver decodes packet and hands the spectrum, if any, to the handler or channel.
+func (c *Client) deliver(packet []byte, source net.Addr, received time.Time) {
+	c.mu.Lock()
+	s, ok, _ := c.decoder.Decode(packet, source, received) // Errors are counted in Stats.
+	if ok && c.handler == nil {
+		select {
+		case c.spectra <- s:
+		default:
+			c.dropped++
+		}
+	}
+	c.mu.Unlock()
+
+	if ok && c.handler != nil {
+		c.handler(s)
+	}
+}
+
+// Close stops receiving and closes the socket, ending the subscription first if the
+// Subscribe option is set. The channel returned by Spectra is closed once the receiving
+// goroutine has exited. It is safe to call multiple times.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.control != nil {
+			c.sendControl(protocol.MessageUnsubscribe, protocol.Subscription{})
+		}
+		err = c.conn.Close()
+		c.wg.Wait()
+	})
+	return err
+}
+
+// Ensure Client satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Client)(nil)
@@ -0,0 +1,676 @@
+// SPDX-License-Identifier: MIT
+package client
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/transport/tcp"
+	"audio/internal/transport/udp"
+	"audio/pkg/protocol"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// spectrum is a fixed FFTResultProvider.
+type spectrum []float64
+
+func (s spectrum) GetMagnitudes() []float64 { return append([]float64(nil), s...) }
+func (s spectrum) GetMagnitudesInto(dst []float64) error {
+	copy(dst, s)
+	return nil
+}
+func (s spectrum) GetFrequencyForBin(bin int) float64 { return float64(bin) }
+func (s spectrum) GetFFTSize() int                    { return 2 * (len(s) - 1) }
+func (s spectrum) GetSampleRate() float64             { return 48000 }
+
+// spectrumPacket encodes a spectrum message with the given sequence number.
+func spectrumPacket(sequence uint32, timestamp time.Time, magnitudes []float32) []byte {
+	packet := protocol.AppendHeader(nil, protocol.Header{
+		Type:          protocol.MessageSpectrum,
+		Sequence:      sequence,
+		Timestamp:     timestamp.UnixNano(),
+		SampleRate:    48000,
+		FFTSize:       uint32(2 * (len(magnitudes) - 1)),
+		Channel:       3,
+		PayloadLength: uint16(protocol.SpectrumPayloadSize(len(magnitudes))),
+	})
+	return protocol.AppendSpectrum(packet, magnitudes)
+}
+
+func TestDecoder_Sequence(t *testing.T) {
+	t.Parallel()
+	d := NewDecoder(DecoderOptions{})
+	source := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 9090}
+	sent := time.Unix(100, 0)
+
+	// 5 is lost, 3 arrives late and then again.
+	var delivered []uint32
+	for i, seq := range []uint32{1, 2, 4, 3, 3, 6} {
+		received := sent.Add(time.Duration(i+1) * time.Millisecond)
+		s, ok, err := d.Decode(spectrumPacket(seq, sent, []float32{1, 2, 3}), source, received)
+		if err != nil {
+			t.Fatalf("Decode(%d) error: %v", seq, err)
+		}
+		if ok {
+			delivered = append(delivered, s.Sequence)
+			if s.Channel != 3 || s.FFTSize != 4 || len(s.Magnitudes) != 3 || s.Latency() != time.Duration(i+1)*time.Millisecond {
+				t.Errorf("Decode(%d) = %+v", seq, s)
+			}
+		}
+	}
+	if len(delivered) != 5 {
+		t.Errorf("delivered %v, want 1 2 4 3 6", delivered)
+	}
+
+	stats := d.Stats()
+	if stats.Packets != 6 || stats.Errors != 0 || len(stats.Streams) != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	st := stats.Streams[0]
+	if st.Source != "192.0.2.1:9090" || st.Channel != 3 || st.Messages != 5 || st.Lost != 1 ||
+		st.Reordered != 1 || st.Duplicates != 1 || st.LastSequence != 6 {
+		t.Errorf("StreamStats = %+v", st)
+	}
+	if st.Latency.Min != time.Millisecond || st.Latency.Max != 6*time.Millisecond || st.Latency.Last != 6*time.Millisecond {
+		t.Errorf("Latency = %+v", st.Latency)
+	}
+	if rate := st.LossRate(); rate != 1.0/6 {
+		t.Errorf("LossRate = %v, want 1/6", rate)
+	}
+}
+
+func TestDecoder_Wraparound(t *testing.T) {
+	t.Parallel()
+	d := NewDecoder(DecoderOptions{})
+	for _, seq := range []uint32{0xfffffffe, 0xffffffff, 1} {
+		if _, ok, err := d.Decode(spectrumPacket(seq, time.Now(), []float32{1, 2}), nil, time.Now()); !ok || err != nil {
+			t.Fatalf("Decode(%#x) = %v, %v", seq, ok, err)
+		}
+	}
+	if st := d.Stats().Streams[0]; st.Lost != 1 || st.Reordered != 0 || st.LastSequence != 1 {
+		t.Errorf("StreamStats = %+v, want 1 lost (sequence 0)", st)
+	}
+}
+
+func TestDecoder_Legacy(t *testing.T) {
+	t.Parallel()
+	packet, err := protocol.AppendLegacySpectrum(nil, 7, time.Now().UnixNano(), []float32{1, 2, 3})
+	if err != nil {
+		t.Fatalf("AppendLegacySpectrum error: %v", err)
+	}
+	if _, _, err := NewDecoder(DecoderOptions{}).Decode(packet, nil, time.Now()); !errors.Is(err, ErrLegacyPacket) {
+		t.Errorf("Decode error = %v, want ErrLegacyPacket", err)
+	}
+	s, ok, err := NewDecoder(DecoderOptions{Legacy: true}).Decode(packet, nil, time.Now())
+	if !ok || err != nil || s.Sequence != 7 || len(s.Magnitudes) != 3 {
+		t.Errorf("Decode = %+v, %v, %v", s, ok, err)
+	}
+}
+
+// TestClient_Publisher receives a fragmented stream from the engine's UDP publisher.
+func TestClient_Publisher(t *testing.T) {
+	t.Parallel()
+	c, err := Listen("127.0.0.1:0", Options{})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer c.Close()
+
+	sender, err := udp.NewUDPSender(c.Addr().String(), false, udp.UDPSenderOptions{})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+	src := make(spectrum, 1025)
+	for i := range src {
+		src[i] = float64(i)
+	}
+	publisher, err := udp.NewUDPPublisher(5*time.Millisecond, sender, src, udp.UDPPublisherOptions{Channel: 9, MaxPacketSize: 1000})
+	if err != nil {
+		t.Fatalf("NewUDPPublisher error: %v", err)
+	}
+	publisher.Start()
+	defer publisher.Close()
+
+	for range 3 {
+		select {
+		case s := <-c.Spectra():
+			if s.Channel != 9 || s.FFTSize != 2048 || s.SampleRate != 48000 || len(s.Magnitudes) != 1025 || s.Magnitudes[1024] != 1024 {
+				t.Fatalf("spectrum = channel %d, FFT size %d, %d magnitudes", s.Channel, s.FFTSize, len(s.Magnitudes))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no spectrum received")
+		}
+	}
+	if stats := c.Stats(); len(stats.Streams) != 1 || stats.Streams[0].Messages < 3 || stats.Errors != 0 {
+		t.Errorf("Stats = %+v", stats)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	for range c.Spectra() {
+		// Drain until the channel is closed.
+	}
+}
+
+// TestClient_Dial receives the unfragmented stream of the engine's TCP server.
+func TestClient_Dial(t *testing.T) {
+	t.Parallel()
+	server, err := tcp.NewServer("127.0.0.1:0", tcp.ServerOptions{})
+	if err != nil {
+		t.Fatalf("NewServer error: %v", err)
+	}
+	defer server.Close()
+	src := make(spectrum, 4097)
+	for i := range src {
+		src[i] = float64(i)
+	}
+	publisher, err := udp.NewUDPPublisher(5*time.Millisecond, server, src, udp.UDPPublisherOptions{
+		Channel:       4,
+		MaxPacketSize: protocol.HeaderSize + 65535,
+	})
+	if err != nil {
+		t.Fatalf("NewUDPPublisher error: %v", err)
+	}
+	publisher.Start()
+	defer publisher.Close()
+
+	if _, err := Dial(server.Addr().String(), Options{Subscribe: "127.0.0.1:9"}); err == nil {
+		t.Error("Dial with Subscribe succeeded")
+	}
+	c, err := Dial(server.Addr().String(), Options{})
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer c.Close()
+
+	for range 3 {
+		select {
+		case s := <-c.Spectra():
+			if s.Channel != 4 || len(s.Magnitudes) != 4097 || s.Magnitudes[4096] != 4096 {
+				t.Fatalf("spectrum = channel %d, %d magnitudes", s.Channel, len(s.Magnitudes))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no spectrum received")
+		}
+	}
+
+	// Closing the server ends the stream and closes the channel.
+	server.Close()
+	timeout := time.After(2 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-c.Spectra():
+		case <-timeout:
+			t.Fatal("spectrum channel not closed after the server closed")
+		}
+	}
+	if stats := c.Stats(); len(stats.Streams) != 1 || stats.Streams[0].Lost != 0 || stats.Errors != 0 {
+		t.Errorf("Stats = %+v", stats)
+	}
+}
+
+// TestClient_EncodedPublisher receives a delta-encoded, compressed dB stream.
+func TestClient_EncodedPublisher(t *testing.T) {
+	t.Parallel()
+	c, err := Listen("127.0.0.1:0", Options{})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer c.Close()
+
+	sender, err := udp.NewUDPSender(c.Addr().String(), false, udp.UDPSenderOptions{})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+	src := make(spectrum, 1025)
+	for i := range src {
+		src[i] = 1024 // 0 dBFS.
+	}
+	publisher, err := udp.NewUDPPublisher(5*time.Millisecond, sender, src, udp.UDPPublisherOptions{
+		Encoding: protocol.SpectrumEncoding{
+			Format:           protocol.FormatDB8,
+			Compression:      protocol.CompressionZstd,
+			MinDB:            -120,
+			MaxDB:            0,
+			KeyframeInterval: 4,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewUDPPublisher error: %v", err)
+	}
+	publisher.Start()
+	defer publisher.Close()
+
+	for range 6 {
+		select {
+		case s := <-c.Spectra():
+			if len(s.Magnitudes) != 1025 || s.Magnitudes[0] != 1024 {
+				t.Fatalf("spectrum = %d magnitudes, first %g", len(s.Magnitudes), s.Magnitudes[0])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no spectrum received")
+		}
+	}
+	stats := c.Stats()
+	if stats.Errors != 0 || stats.Packets == 0 || stats.Bytes/stats.Packets > 100 {
+		t.Errorf("Stats = %+v, want small packets without errors", stats)
+	}
+}
+
+// TestClient_FramePublisher receives a frame-driven stream: one message per second
+// frame, stamped with the frame's capture time.
+func TestClient_FramePublisher(t *testing.T) {
+	t.Parallel()
+	c, err := Listen("127.0.0.1:0", Options{})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer c.Close()
+
+	sender, err := udp.NewUDPSender(c.Addr().String(), false, udp.UDPSenderOptions{})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+	frames := analysis.NewFrameBus()
+	defer frames.Close()
+	publisher, err := udp.NewUDPPublisher(time.Hour, sender, spectrum{1, 2, 3}, udp.UDPPublisherOptions{Frames: frames, Decimation: 2})
+	if err != nil {
+		t.Fatalf("NewUDPPublisher error: %v", err)
+	}
+	publisher.Start()
+	defer publisher.Close()
+	time.Sleep(10 * time.Millisecond) // Let the publisher subscribe.
+
+	captured := time.Unix(1_700_000_000, 0)
+	for i := range uint64(4) {
+		frames.Publish(analysis.Frame{Index: i + 1, Time: captured.Add(time.Duration(i) * time.Millisecond)})
+		time.Sleep(time.Millisecond)
+	}
+	for _, want := range []time.Time{captured.Add(time.Millisecond), captured.Add(3 * time.Millisecond)} {
+		select {
+		case s := <-c.Spectra():
+			if !s.Timestamp.Equal(want) {
+				t.Errorf("timestamp = %s, want %s", s.Timestamp, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no spectrum received")
+		}
+	}
+	select {
+	case s := <-c.Spectra():
+		t.Errorf("unexpected spectrum %d", s.Sequence)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestClient_Subscribe registers with the engine's subscription server, receives the
+// stream and unsubscribes on Close.
+func TestClient_Subscribe(t *testing.T) {
+	t.Parallel()
+	server, err := udp.NewSubscriptionServer("127.0.0.1:0", spectrum{1, 2, 3}, udp.SubscriptionOptions{})
+	if err != nil {
+		t.Fatalf("NewSubscriptionServer error: %v", err)
+	}
+	defer server.Close()
+	server.Start()
+
+	c, err := Listen("127.0.0.1:0", Options{
+		Subscribe:    server.Addr().String(),
+		Subscription: protocol.Subscription{Interval: 10 * time.Millisecond, Lease: 3 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer c.Close()
+
+	select {
+	case s := <-c.Spectra():
+		if len(s.Magnitudes) != 3 {
+			t.Errorf("spectrum has %d magnitudes, want 3", len(s.Magnitudes))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no spectrum received")
+	}
+	granted, ok := c.Subscription()
+	if !ok || granted.Interval != 10*time.Millisecond || granted.Lease != 3*time.Second ||
+		len(granted.Types) != 1 || granted.Types[0] != protocol.MessageSpectrum {
+		t.Errorf("Subscription = %+v, %v", granted, ok)
+	}
+	if subs := server.Subscribers(); len(subs) != 1 || subs[0] != c.Addr().String() {
+		t.Errorf("Subscribers = %v, want [%s]", subs, c.Addr())
+	}
+
+	c.Close()
+	waitFor(t, func() bool { return len(server.Subscribers()) == 0 })
+}
+
+// TestSubscriptionServer_Lease lets a subscription expire without keepalive.
+func TestClient_SealedSubscribe(t *testing.T) {
+	t.Parallel()
+	key := make([]byte, 32)
+	seal := func(encrypt bool) *protocol.Sealer {
+		sealer, err := protocol.NewSealer(key, encrypt)
+		if err != nil {
+			t.Fatalf("NewSealer error: %v", err)
+		}
+		return sealer
+	}
+	open := func() *protocol.Opener {
+		opener, err := protocol.NewOpener(key, time.Second)
+		if err != nil {
+			t.Fatalf("NewOpener error: %v", err)
+		}
+		return opener
+	}
+	src := make(spectrum, 257) // Fragmented at the packet size below.
+	for i := range src {
+		src[i] = float64(i)
+	}
+	server, err := udp.NewSubscriptionServer("127.0.0.1:0", src, udp.SubscriptionOptions{
+		Publisher: udp.UDPPublisherOptions{MaxPacketSize: 300, Sealer: seal(true)},
+		Opener:    open(),
+	})
+	if err != nil {
+		t.Fatalf("NewSubscriptionServer error: %v", err)
+	}
+	defer server.Close()
+	server.Start()
+
+	// Unsealed subscriptions are ignored.
+	unsealed, err := Listen("127.0.0.1:0", Options{Subscribe: server.Addr().String()})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer unsealed.Close()
+
+	c, err := Listen("127.0.0.1:0", Options{
+		DecoderOptions: DecoderOptions{Opener: open()},
+		Subscribe:      server.Addr().String(),
+		Subscription:   protocol.Subscription{Interval: 10 * time.Millisecond},
+		Sealer:         seal(false),
+	})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer c.Close()
+
+	for range 3 {
+		select {
+		case s := <-c.Spectra():
+			if len(s.Magnitudes) != len(src) || s.Magnitudes[256] != 256 {
+				t.Fatalf("spectrum has %d magnitudes, want %d", len(s.Magnitudes), len(src))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no spectrum received")
+		}
+	}
+	if _, ok := c.Subscription(); !ok {
+		t.Error("sealed subscription not acknowledged")
+	}
+	if subs := server.Subscribers(); len(subs) != 1 || subs[0] != c.Addr().String() {
+		t.Errorf("Subscribers = %v, want [%s]", subs, c.Addr())
+	}
+	if stats := c.Stats(); stats.Rejected != 0 || stats.Errors != 0 {
+		t.Errorf("Stats = %+v, want nothing rejected", stats)
+	}
+}
+
+func TestDecoder_Rejected(t *testing.T) {
+	key := make([]byte, 16)
+	sealer, _ := protocol.NewSealer(key, false)
+	opener, _ := protocol.NewOpener(key, 0)
+	d := NewDecoder(DecoderOptions{Opener: opener})
+
+	now := time.Now()
+	packet, err := sealer.Seal(spectrumPacket(1, now, []float32{1, 2, 3}))
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+	if _, ok, err := d.Decode(append([]byte(nil), packet...), nil, now); !ok || err != nil {
+		t.Fatalf("Decode of sealed packet = %v, %v", ok, err)
+	}
+	if _, _, err := d.Decode(packet, nil, now); !errors.Is(err, protocol.ErrReplayed) {
+		t.Errorf("Decode of replayed packet error = %v, want ErrReplayed", err)
+	}
+	if _, _, err := d.Decode(spectrumPacket(2, now, []float32{1, 2, 3}), nil, now); !errors.Is(err, protocol.ErrUnauthenticated) {
+		t.Errorf("Decode of unsealed packet error = %v, want ErrUnauthenticated", err)
+	}
+	if stats := d.Stats(); stats.Rejected != 2 || stats.Errors != 0 || stats.Packets != 3 {
+		t.Errorf("Stats = %+v, want 2 rejected of 3 packets", stats)
+	}
+}
+
+func TestSubscriptionServer_Lease(t *testing.T) {
+	t.Parallel()
+	server, err := udp.NewSubscriptionServer("127.0.0.1:0", spectrum{1, 2, 3}, udp.SubscriptionOptions{Lease: 200 * time.Millisecond, MaxSubscribers: 1})
+	if err != nil {
+		t.Fatalf("NewSubscriptionServer error: %v", err)
+	}
+	defer server.Close()
+	server.Start()
+
+	var conns []*net.UDPConn
+	for range 2 {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("ListenUDP error: %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	request := func(conn *net.UDPConn, cookie uint64) (protocol.Subscription, int) {
+		t.Helper()
+		subscribe := protocol.AppendControl(nil, protocol.MessageSubscribe, protocol.Subscription{
+			Types:  []protocol.MessageType{protocol.MessageSpectrum},
+			Cookie: cookie,
+		})
+		if _, err := conn.WriteTo(subscribe, server.Addr()); err != nil {
+			t.Fatalf("WriteTo error: %v", err)
+		}
+		buf := make([]byte, maxDatagramSize)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatalf("no acknowledgement: %v", err)
+			}
+			if h, payload, err := protocol.ParseHeader(buf[:n]); err == nil && h.Type == protocol.MessageSubscribeAck {
+				granted, err := protocol.ParseSubscription(payload)
+				if err != nil {
+					t.Fatalf("ParseSubscription error: %v", err)
+				}
+				if n > len(subscribe) {
+					t.Errorf("answer of %d bytes to a request of %d", n, len(subscribe))
+				}
+				return granted, n
+			}
+		}
+	}
+	// ack subscribes conn, answering the challenge with its cookie.
+	ack := func(conn *net.UDPConn) protocol.Subscription {
+		t.Helper()
+		challenge, _ := request(conn, 0)
+		if challenge.Cookie == 0 || challenge.Lease != 0 || len(challenge.Types) != 0 {
+			t.Fatalf("challenge = %+v, want only a cookie", challenge)
+		}
+		if slices.Contains(server.Subscribers(), conn.LocalAddr().String()) {
+			t.Error("streaming before the cookie was echoed")
+		}
+		if wrong, _ := request(conn, challenge.Cookie+1); wrong.Lease != 0 || wrong.Cookie != challenge.Cookie {
+			t.Errorf("answer to a wrong cookie = %+v, want the challenge again", wrong)
+		}
+		granted, _ := request(conn, challenge.Cookie)
+		return granted
+	}
+
+	if granted := ack(conns[0]); granted.Lease != 200*time.Millisecond || granted.Cookie == 0 {
+		t.Errorf("granted %+v, want a 200ms lease and the cookie", granted)
+	}
+	if granted := ack(conns[1]); granted.Lease != 0 {
+		t.Errorf("second subscriber granted %+v, want a rejection", granted)
+	}
+	waitFor(t, func() bool { return len(server.Subscribers()) == 0 })
+	if granted := ack(conns[1]); granted.Lease == 0 {
+		t.Error("subscriber rejected after the first lease expired")
+	}
+}
+
+func TestSubscriptionServer_Challenges(t *testing.T) {
+	t.Parallel()
+	server, err := udp.NewSubscriptionServer("127.0.0.1:0", spectrum{1, 2, 3}, udp.SubscriptionOptions{})
+	if err != nil {
+		t.Fatalf("NewSubscriptionServer error: %v", err)
+	}
+	defer server.Close()
+	server.Start()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP error: %v", err)
+	}
+	defer conn.Close()
+
+	// A request shorter than the challenge, from a peer that predates cookies, isn't
+	// answered; neither are requests beyond the challenge rate.
+	short := protocol.AppendControl(nil, protocol.MessageSubscribe, protocol.Subscription{})
+	short = short[:len(short)-8]
+	conn.WriteTo(short, server.Addr())
+	subscribe := protocol.AppendControl(nil, protocol.MessageSubscribe, protocol.Subscription{Types: []protocol.MessageType{protocol.MessageSpectrum}})
+	for range 100 {
+		conn.WriteTo(subscribe, server.Addr())
+	}
+	buf := make([]byte, maxDatagramSize)
+	var challenges int
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+		challenges++
+	}
+	if challenges == 0 || challenges >= 100 {
+		t.Errorf("%d challenges to 100 requests, want a rate limited number", challenges)
+	}
+	if len(server.Subscribers()) != 0 {
+		t.Errorf("subscribers = %v without an echoed cookie", server.Subscribers())
+	}
+}
+
+func TestUDPSender_Backoff(t *testing.T) {
+	t.Parallel()
+	// Find a free port, then leave it closed so the target refuses packets.
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP error: %v", err)
+	}
+	addr := probe.LocalAddr().(*net.UDPAddr)
+	probe.Close()
+
+	sender, err := udp.NewUDPSender(addr.String(), false, udp.UDPSenderOptions{})
+	if err != nil {
+		t.Fatalf("NewUDPSender error: %v", err)
+	}
+	defer sender.Close()
+
+	// Refusals are reported by the write after the one that triggered them.
+	waitFor(t, func() bool {
+		_ = sender.Send([]byte{1})
+		return sender.Stats().Paused
+	})
+	if err := sender.Send([]byte{1}); !errors.Is(err, udp.ErrPaused) {
+		t.Errorf("Send while paused error = %v, want ErrPaused", err)
+	}
+	stats := sender.Stats()
+	if stats.Errors[udp.ErrorRefused] < udp.RefusedThreshold || stats.Dropped == 0 || stats.LastError == "" {
+		t.Errorf("stats while paused = %+v", stats)
+	}
+
+	// Once the receiver is back, sending resumes after the backoff.
+	receiver, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Skipf("port %d taken in the meantime: %v", addr.Port, err)
+	}
+	defer receiver.Close()
+	packets := stats.Packets
+	waitFor(t, func() bool {
+		_ = sender.Send([]byte{2})
+		return sender.Stats().Packets >= packets+udp.RefusedThreshold
+	})
+	if stats := sender.Stats(); stats.Paused {
+		t.Errorf("sender still paused after the receiver came back: %+v", stats)
+	}
+	buf := make([]byte, 16)
+	_ = receiver.SetReadDeadline(time.Now().Add(time.Second))
+	if n, _, err := receiver.ReadFromUDP(buf); err != nil || n != 1 || buf[0] != 2 {
+		t.Errorf("receiver got %v (%v), want the resumed packets", buf[:n], err)
+	}
+}
+
+// waitFor polls condition for up to two seconds.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient_HandlerAndDrops(t *testing.T) {
+	t.Parallel()
+	received := make(chan Spectrum, 8)
+	withHandler, err := Listen("127.0.0.1:0", Options{Handler: func(s Spectrum) { received <- s }})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer withHandler.Close()
+	if withHandler.Spectra() != nil {
+		t.Error("Spectra() is not nil with a handler")
+	}
+	slow, err := Listen("127.0.0.1:0", Options{Buffer: 1})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer slow.Close()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP error: %v", err)
+	}
+	defer conn.Close()
+	for seq := range uint32(3) {
+		packet := spectrumPacket(seq+1, time.Now(), []float32{1, 2, 3})
+		for _, c := range []*Client{withHandler, slow} {
+			if _, err := conn.WriteTo(packet, c.Addr()); err != nil {
+				t.Fatalf("WriteTo error: %v", err)
+			}
+		}
+	}
+
+	for i := range 3 {
+		select {
+		case s := <-received:
+			if s.Sequence != uint32(i+1) {
+				t.Errorf("handler got sequence %d, want %d", s.Sequence, i+1)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("handler not called")
+		}
+	}
+
+	// The slow consumer doesn't read: one spectrum waits in the channel, two are dropped.
+	deadline := time.Now().Add(2 * time.Second)
+	for slow.Stats().Packets < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := slow.Stats(); stats.Dropped != 2 || stats.Streams[0].Messages != 3 {
+		t.Errorf("Stats = %+v, want 2 dropped", stats)
+	}
+}
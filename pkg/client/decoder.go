@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: MIT
+package client
+
+import (
+	"audio/pkg/protocol"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// Spectrum is one decoded spectrum message.
+type Spectrum struct {
+	Source     net.Addr  // Address the message came from.
+	Channel    uint16    // Channel ID of the stream (0 for legacy packets).
+	Sequence   uint32    // Sequence number assigned by the engine.
+	Timestamp  time.Time // Time the engine built the message.
+	Received   time.Time // Time the last datagram of the message arrived.
+	SampleRate uint32    // Sample rate of the analysed audio (Hz, 0 for legacy packets).
+	FFTSize    uint32    // FFT size of the spectrum (points, 0 for legacy packets).
+	Magnitudes []float32 // FFTSize/2 + 1 magnitudes, lowest frequency first.
+}
+
+// Latency returns the time between the engine building the message and its arrival.
+// It is only meaningful when the clocks of both hosts are synchronised (e.g. with NTP);
+// on the same host it is exact.
+func (s Spectrum) Latency() time.Duration {
+	return s.Received.Sub(s.Timestamp)
+}
+
+// StreamStats describes the messages received from one stream: one engine publisher,
+// identified by its source address and channel ID.
+type StreamStats struct {
+	Source       string    // Source address of the stream.
+	Channel      uint16    // Channel ID of the stream.
+	Messages     uint64    // Messages delivered.
+	Lost         uint64    // Sequence numbers skipped and not received later.
+	Reordered    uint64    // Messages that arrived after a later one.
+	Duplicates   uint64    // Messages received more than once (not delivered again).
+	LastSequence uint32    // Highest sequence number received.
+	LastReceived time.Time // Arrival time of the latest message.
+	Latency      Latency   // Latency from the embedded timestamps.
+}
+
+// LossRate returns the fraction of messages lost, between 0 and 1.
+func (s StreamStats) LossRate() float64 {
+	if total := s.Messages + s.Lost; total > 0 {
+		return float64(s.Lost) / float64(total)
+	}
+	return 0
+}
+
+// Latency summarises the latency of the messages of a stream.
+type Latency struct {
+	Last time.Duration // Latency of the latest message.
+	Min  time.Duration // Lowest latency seen.
+	Max  time.Duration // Highest latency seen.
+	Mean time.Duration // Average latency.
+}
+
+// Stats describes everything a Decoder has processed.
+type Stats struct {
+	Packets    uint64        // Datagrams processed.
+	Bytes      uint64        // Size of the datagrams processed.
+	Errors     uint64        // Datagrams that could not be decoded.
+	Rejected   uint64        // Datagrams that failed authentication or were replayed (DecoderOptions.Opener only).
+	Skipped    uint64        // Messages of types this package doesn't decode.
+	Incomplete uint64        // Fragmented messages discarded with chunks missing.
+	Dropped    uint64        // Messages discarded because the consumer fell behind (Client only).
+	Streams    []StreamStats // Per-stream statistics, sorted by source and channel.
+}
+
+// ErrLegacyPacket is returned for datagrams without the versioned header when the
+// Decoder isn't configured to accept the legacy layout.
+var ErrLegacyPacket = errors.New("client: packet without protocol header (legacy layout not enabled)")
+
+// DecoderOptions controls how datagrams are decoded.
+type DecoderOptions struct {
+	Legacy bool // Accept datagrams in the legacy header-less layout.
+
+	// ReassemblerOptions limits the incomplete fragmented messages kept per Decoder.
+	ReassemblerOptions
+
+	// Opener, if set, authenticates (and decrypts) every datagram with the key shared
+	// with the engine. Datagrams that don't open, including unsealed and legacy ones, are
+	// rejected. An Opener keeps replay state, so don't share one between Decoders.
+	Opener *protocol.Opener
+}
+
+// streamKey identifies a stream.
+type streamKey struct {
+	source  string
+	channel uint16
+}
+
+// stream holds the sequence tracking state of one stream.
+type stream struct {
+	stats      StreamStats
+	started    bool          // Whether a message has been received.
+	window     uint64        // Bit i is set if LastSequence-i has been received.
+	latencySum time.Duration // Sum of all latencies, for the mean.
+
+	spectrum *protocol.SpectrumDecoder // State of encoded spectra, created on first use.
+}
+
+// windowSize is the number of sequence numbers behind the latest one that are tracked
+// to tell late messages from duplicates.
+const windowSize = 64
+
+// Decoder turns datagrams into Spectrum values, reassembling fragmented messages and
+// tracking sequence gaps, reordering and latency per stream. Use it directly when you
+// receive datagrams yourself; Client wraps it around a UDP socket.
+//
+// A Decoder is not safe for concurrent use.
+type Decoder struct {
+	options     DecoderOptions
+	reassembler *Reassembler
+	streams     map[streamKey]*stream
+	packets     uint64
+	bytes       uint64
+	errors      uint64
+	rejected    uint64
+	skipped     uint64
+}
+
+// NewDecoder creates a Decoder.
+func NewDecoder(options DecoderOptions) *Decoder {
+	return &Decoder{
+		options:     options,
+		reassembler: NewReassembler(options.ReassemblerOptions),
+		streams:     make(map[streamKey]*stream),
+	}
+}
+
+// Decode processes one datagram received from source at received. It returns the
+// decoded spectrum with ok set to true when the datagram completes a new spectrum
+// message. It returns ok false without an error for chunks of incomplete messages,
+// duplicates and message types this package doesn't decode. With DecoderOptions.Opener,
+// sealed datagrams are opened in place.
+func (d *Decoder) Decode(packet []byte, source net.Addr, received time.Time) (s Spectrum, ok bool, err error) {
+	d.packets++
+	d.bytes += uint64(len(packet))
+	if packet, err = d.open(packet, received); err != nil {
+		return Spectrum{Source: source, Received: received}, false, err
+	}
+	s, ok, err = d.decode(packet, source, received)
+	if err != nil {
+		d.errors++
+	}
+	return s, ok, err
+}
+
+// open authenticates packet if an Opener is configured, counting rejected packets.
+func (d *Decoder) open(packet []byte, received time.Time) ([]byte, error) {
+	if d.options.Opener == nil {
+		return packet, nil
+	}
+	opened, err := d.options.Opener.Open(packet, received)
+	if err != nil {
+		d.rejected++
+		return nil, fmt.Errorf("client: rejected packet: %w", err)
+	}
+	return opened, nil
+}
+
+func (d *Decoder) decode(packet []byte, source net.Addr, received time.Time) (Spectrum, bool, error) {
+	s := Spectrum{Source: source, Received: received}
+
+	if !protocol.IsPacket(packet) {
+		if !d.options.Legacy {
+			return s, false, ErrLegacyPacket
+		}
+		sequence, timestamp, magnitudes, err := protocol.ParseLegacySpectrum(packet, nil)
+		if err != nil {
+			return s, false, err
+		}
+		s.Sequence, s.Timestamp, s.Magnitudes = sequence, time.Unix(0, timestamp), magnitudes
+		return s, d.track(d.stream(source, 0), &s), nil
+	}
+
+	header, payload, ok, err := d.reassembler.Add(addrPort(source), packet)
+	if err != nil || !ok {
+		return s, false, err
+	}
+	var magnitudes []float32
+	switch header.Type {
+	case protocol.MessageSpectrum:
+		magnitudes, err = protocol.ParseSpectrum(payload, nil)
+	case protocol.MessageSpectrumEncoded:
+		// Deltas refer to the previous message of the same stream.
+		st := d.stream(source, header.Channel)
+		if st.spectrum == nil {
+			st.spectrum = protocol.NewSpectrumDecoder()
+		}
+		magnitudes, err = st.spectrum.Decode(payload, header.Sequence, header.FFTSize, nil)
+	default:
+		d.skipped++
+		return s, false, nil
+	}
+	if err != nil {
+		return s, false, err
+	}
+	s.Channel = header.Channel
+	s.Sequence = header.Sequence
+	s.Timestamp = time.Unix(0, header.Timestamp)
+	s.SampleRate = header.SampleRate
+	s.FFTSize = header.FFTSize
+	s.Magnitudes = magnitudes
+	if n := int(header.FFTSize)/2 + 1; header.FFTSize > 0 && len(magnitudes) != n {
+		return s, false, fmt.Errorf("client: spectrum has %d magnitudes, want %d for FFT size %d", len(magnitudes), n, header.FFTSize)
+	}
+	return s, d.track(d.stream(source, header.Channel), &s), nil
+}
+
+// addrPort returns the IP address and port of source, or the zero AddrPort for sources
+// that have none, such as Unix sockets.
+func addrPort(source net.Addr) netip.AddrPort {
+	switch a := source.(type) {
+	case *net.UDPAddr:
+		return a.AddrPort()
+	case *net.TCPAddr:
+		return a.AddrPort()
+	}
+	return netip.AddrPort{}
+}
+
+// stream returns the state of the stream from source on channel, creating it on first
+// use.
+func (d *Decoder) stream(source net.Addr, channel uint16) *stream {
+	var address string
+	if source != nil {
+		address = source.String()
+	}
+	key := streamKey{source: address, channel: channel}
+	st := d.streams[key]
+	if st == nil {
+		st = &stream{stats: StreamStats{Source: address, Channel: channel}}
+		d.streams[key] = st
+	}
+	return st
+}
+
+// track updates the statistics of st, the stream of s, and reports whether s should be
+// delivered (it isn't a duplicate).
+func (d *Decoder) track(st *stream, s *Spectrum) bool {
+	// Sequence numbers are compared modulo 2^32 so a wrap-around is just a step forward.
+	switch delta := int32(s.Sequence - st.stats.LastSequence); {
+	case !st.started:
+		st.started = true
+		st.window = 1
+		st.stats.LastSequence = s.Sequence
+	case delta > 0:
+		st.stats.Lost += uint64(delta - 1)
+		if delta < windowSize {
+			st.window = st.window<<uint(delta) | 1
+		} else {
+			st.window = 1
+		}
+		st.stats.LastSequence = s.Sequence
+	case delta > -windowSize:
+		bit := uint64(1) << uint(-delta)
+		if st.window&bit != 0 {
+			st.stats.Duplicates++
+			return false
+		}
+		st.window |= bit
+		st.stats.Reordered++
+		if st.stats.Lost > 0 {
+			st.stats.Lost-- // Counted as lost when the gap appeared.
+		}
+	default:
+		// Far behind: the engine restarted and its sequence numbers began again, or the
+		// message is too old to tell. Start over from this message.
+		st.window = 1
+		st.stats.LastSequence = s.Sequence
+	}
+
+	latency := s.Latency()
+	lat := &st.stats.Latency
+	if st.stats.Messages == 0 {
+		lat.Min, lat.Max = latency, latency
+	}
+	lat.Last = latency
+	lat.Min = min(lat.Min, latency)
+	lat.Max = max(lat.Max, latency)
+	st.stats.Messages++
+	st.latencySum += latency
+	lat.Mean = st.latencySum / time.Duration(st.stats.Messages)
+	st.stats.LastReceived = s.Received
+	return true
+}
+
+// Stats returns a snapshot of the decoding statistics.
+func (d *Decoder) Stats() Stats {
+	stats := Stats{
+		Packets:    d.packets,
+		Bytes:      d.bytes,
+		Errors:     d.errors,
+		Rejected:   d.rejected,
+		Skipped:    d.skipped,
+		Incomplete: d.reassembler.Dropped(),
+		Streams:    make([]StreamStats, 0, len(d.streams)),
+	}
+	for _, st := range d.streams {
+		stats.Streams = append(stats.Streams, st.stats)
+	}
+	sort.Slice(stats.Streams, func(i, j int) bool {
+		a, b := stats.Streams[i], stats.Streams[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Channel < b.Channel
+	})
+	return stats
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: MIT
+package client
+
+import (
+	"audio/pkg/protocol"
+	"fmt"
+	"net/netip"
+)
+
+// Defaults of ReassemblerOptions.
+const (
+	// DefaultMaxPending is the default number of partially received messages a
+	// Reassembler keeps before discarding the oldest.
+	DefaultMaxPending = 8
+	// DefaultMaxMessageSize is the default size limit of a reassembled message. It holds
+	// a plain float32 spectrum of a 2^20 point FFT.
+	DefaultMaxMessageSize = 4 << 20
+	// DefaultMaxPendingBytes is the default limit of the payload memory held for
+	// incomplete messages.
+	DefaultMaxPendingBytes = 4 * DefaultMaxMessageSize
+)
+
+// ReassemblerOptions limits the memory a Reassembler uses. Fragment headers are not
+// authenticated unless the packets are sealed, so these bound what forged datagrams can
+// make a receiver allocate.
+type ReassemblerOptions struct {
+	MaxPending      int // Incomplete messages kept (<= 0 uses DefaultMaxPending).
+	MaxMessageSize  int // Largest reassembled payload in bytes (<= 0 uses DefaultMaxMessageSize).
+	MaxPendingBytes int // Payload bytes held for incomplete messages (<= 0 uses DefaultMaxPendingBytes, at least MaxMessageSize).
+}
+
+// frameKey identifies a message across the streams that may share one port. Frame IDs
+// are only unique per sender, so the source is part of the key.
+type frameKey struct {
+	source  netip.AddrPort
+	channel uint16
+	msgType protocol.MessageType
+	frameID uint32
+}
+
+// pendingFrame collects the chunks of one fragmented message.
+type pendingFrame struct {
+	header   protocol.Header // Header of the first chunk received.
+	payload  []byte          // Reassembled payload, TotalLength bytes.
+	received []bool          // Which chunk indices have arrived.
+	missing  int             // Number of chunks still outstanding.
+	chunk    int             // Size of every chunk but the last.
+}
+
+// Reassembler rebuilds messages that the engine split into several datagrams (see
+// protocol.FlagFragment). Packets that aren't fragmented pass straight through.
+//
+// Only a bounded number of incomplete messages, and of bytes for them, are tracked; when
+// a chunk of a new message arrives and a limit is reached, the oldest incomplete messages
+// are discarded and counted in Dropped. Messages larger than MaxMessageSize are rejected
+// before anything is allocated for them. Since the engine sends messages in order, a
+// message that is still incomplete several messages later has lost a chunk and will
+// never complete.
+//
+// A Reassembler is not safe for concurrent use.
+type Reassembler struct {
+	options      ReassemblerOptions // Limits, with defaults applied.
+	pending      map[frameKey]*pendingFrame
+	order        []frameKey // Insertion order of pending, oldest first.
+	pendingBytes int        // Sum of the payload sizes of pending.
+	dropped      uint64
+}
+
+// NewReassembler creates a Reassembler with the given limits.
+func NewReassembler(options ReassemblerOptions) *Reassembler {
+	if options.MaxPending <= 0 {
+		options.MaxPending = DefaultMaxPending
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if options.MaxPendingBytes <= 0 {
+		options.MaxPendingBytes = DefaultMaxPendingBytes
+	}
+	options.MaxPendingBytes = max(options.MaxPendingBytes, options.MaxMessageSize)
+	return &Reassembler{
+		options: options,
+		pending: make(map[frameKey]*pendingFrame),
+	}
+}
+
+// Add processes one datagram received from source, whose chunks are only combined with
+// chunks from the same source. The zero AddrPort stands for a source that has no IP
+// address. When the datagram completes a message (or wasn't fragmented) it returns the
+// header and full payload with ok set to true. The returned header has FlagFragment
+// cleared and PayloadLength set to the reassembled size when it fits. Unfragmented
+// payloads alias packet; reassembled payloads are freshly allocated.
+func (r *Reassembler) Add(source netip.AddrPort, packet []byte) (header protocol.Header, payload []byte, ok bool, err error) {
+	header, payload, err = protocol.ParseHeader(packet)
+	if err != nil {
+		return header, nil, false, err
+	}
+	if !header.Flags.Has(protocol.FlagFragment) {
+		return header, payload, true, nil
+	}
+
+	frag, chunk, err := protocol.ParseFragmentHeader(payload)
+	if err != nil {
+		return header, nil, false, err
+	}
+
+	key := frameKey{source: source, channel: header.Channel, msgType: header.Type, frameID: frag.FrameID}
+	frame, exists := r.pending[key]
+	if !exists {
+		if frag.Count == 1 {
+			return completeHeader(header, len(chunk)), chunk, true, nil
+		}
+		if int64(frag.TotalLength) > int64(r.options.MaxMessageSize) {
+			return header, nil, false, fmt.Errorf("client: fragmented message of %d bytes exceeds the limit of %d",
+				frag.TotalLength, r.options.MaxMessageSize)
+		}
+		// The chunk size is implied by the first full chunk; every chunk but the
+		// last has the same size, and the last one isn't empty.
+		chunkSize := len(chunk)
+		if frag.Index == frag.Count-1 {
+			chunkSize = (int(frag.TotalLength) - len(chunk)) / int(frag.Count-1)
+		}
+		if chunkSize <= 0 || int(frag.TotalLength) > chunkSize*int(frag.Count) ||
+			int(frag.TotalLength) <= chunkSize*int(frag.Count-1) {
+			return header, nil, false, fmt.Errorf("client: inconsistent fragment sizes (chunk %d, total %d, count %d)",
+				len(chunk), frag.TotalLength, frag.Count)
+		}
+		frame = &pendingFrame{
+			header:   header,
+			payload:  make([]byte, frag.TotalLength),
+			received: make([]bool, frag.Count),
+			missing:  int(frag.Count),
+			chunk:    chunkSize,
+		}
+		r.track(key, frame)
+	}
+
+	if int(frag.Count) != len(frame.received) || int(frag.TotalLength) != len(frame.payload) {
+		return header, nil, false, fmt.Errorf("client: fragment of frame %d disagrees with earlier chunks", frag.FrameID)
+	}
+	if frame.received[frag.Index] {
+		return header, nil, false, nil // Duplicate chunk.
+	}
+	offset := int(frag.Index) * frame.chunk
+	if offset+len(chunk) > len(frame.payload) {
+		return header, nil, false, fmt.Errorf("client: chunk %d of frame %d overflows payload", frag.Index, frag.FrameID)
+	}
+	if frag.Index < frag.Count-1 && len(chunk) != frame.chunk {
+		// A short chunk would leave a hole in the payload that is never filled.
+		return header, nil, false, fmt.Errorf("client: chunk %d of frame %d has %d bytes, want %d",
+			frag.Index, frag.FrameID, len(chunk), frame.chunk)
+	}
+	copy(frame.payload[offset:], chunk)
+	frame.received[frag.Index] = true
+	frame.missing--
+
+	if frame.missing > 0 {
+		return header, nil, false, nil
+	}
+	r.forget(key)
+	return completeHeader(frame.header, len(frame.payload)), frame.payload, true, nil
+}
+
+// Dropped returns the number of incomplete messages discarded so far.
+func (r *Reassembler) Dropped() uint64 {
+	return r.dropped
+}
+
+// Pending returns the number of incomplete messages currently held.
+func (r *Reassembler) Pending() int {
+	return len(r.pending)
+}
+
+// track adds a new pending frame, discarding the oldest ones while a limit is reached.
+func (r *Reassembler) track(key frameKey, frame *pendingFrame) {
+	for len(r.order) > 0 && (len(r.order) >= r.options.MaxPending ||
+		r.pendingBytes+len(frame.payload) > r.options.MaxPendingBytes) {
+		r.pendingBytes -= len(r.pending[r.order[0]].payload)
+		delete(r.pending, r.order[0])
+		r.order = r.order[1:]
+		r.dropped++
+	}
+	r.pending[key] = frame
+	r.order = append(r.order, key)
+	r.pendingBytes += len(frame.payload)
+}
+
+// forget removes a completed frame from the pending set.
+func (r *Reassembler) forget(key frameKey) {
+	r.pendingBytes -= len(r.pending[key].payload)
+	delete(r.pending, key)
+	for i, k := range r.order {
+		if k == key {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// completeHeader turns the header of a chunk into the header of the whole message.
+func completeHeader(h protocol.Header, payloadLen int) protocol.Header {
+	h.Flags &^= protocol.FlagFragment
+	if payloadLen <= 0xFFFF {
+		h.PayloadLength = uint16(payloadLen)
+	} else {
+		h.PayloadLength = 0 // Doesn't fit; use len(payload).
+	}
+	return h
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: MIT
+package client
+
+import (
+	"audio/pkg/protocol"
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+var testSource = netip.MustParseAddrPort("192.0.2.1:9000")
+
+// fragment splits payload into packets of at most chunkSize payload bytes.
+func fragment(h protocol.Header, frameID uint32, payload []byte, chunkSize int) [][]byte {
+	count := (len(payload) + chunkSize - 1) / chunkSize
+	packets := make([][]byte, count)
+	for i := range count {
+		chunk := payload[i*chunkSize : min((i+1)*chunkSize, len(payload))]
+		packets[i] = protocol.AppendFragment(nil, h, protocol.FragmentHeader{
+			FrameID:     frameID,
+			Index:       uint16(i),
+			Count:       uint16(count),
+			TotalLength: uint32(len(payload)),
+		}, chunk)
+	}
+	return packets
+}
+
+func testPayload(n int) []byte {
+	payload := make([]byte, n)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+	return payload
+}
+
+func TestReassembler_OutOfOrder(t *testing.T) {
+	r := NewReassembler(ReassemblerOptions{})
+	h := protocol.Header{Type: protocol.MessageSpectrum, Sequence: 5, FFTSize: 16384}
+	payload := testPayload(10_000)
+	packets := fragment(h, 5, payload, 1000)
+
+	// Deliver in reverse order with a duplicate in the middle.
+	order := []int{9, 8, 7, 6, 5, 5, 4, 3, 2, 1}
+	for _, i := range order {
+		if _, _, ok, err := r.Add(testSource, packets[i]); ok || err != nil {
+			t.Fatalf("packet %d: ok=%v err=%v, want incomplete", i, ok, err)
+		}
+	}
+	got, data, ok, err := r.Add(testSource, packets[0])
+	if !ok || err != nil {
+		t.Fatalf("final packet: ok=%v err=%v, want complete", ok, err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Error("reassembled payload does not match original")
+	}
+	if got.Flags.Has(protocol.FlagFragment) || got.Sequence != 5 || got.FFTSize != 16384 {
+		t.Errorf("unexpected header %+v", got)
+	}
+	if r.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", r.Pending())
+	}
+}
+
+func TestReassembler_DiscardsIncomplete(t *testing.T) {
+	r := NewReassembler(ReassemblerOptions{MaxPending: 2})
+	h := protocol.Header{Type: protocol.MessageSpectrum}
+	payload := testPayload(3000)
+
+	// Frames 1 and 2 each lose their last chunk.
+	for id := uint32(1); id <= 2; id++ {
+		packets := fragment(h, id, payload, 1000)
+		for _, p := range packets[:2] {
+			r.Add(testSource, p)
+		}
+	}
+	if r.Pending() != 2 {
+		t.Fatalf("Pending() = %d, want 2", r.Pending())
+	}
+
+	// Frame 3 arrives complete; frame 1 must be discarded to make room.
+	var completed int
+	for _, p := range fragment(h, 3, payload, 1000) {
+		if _, _, ok, _ := r.Add(testSource, p); ok {
+			completed++
+		}
+	}
+	if completed != 1 {
+		t.Errorf("completed = %d, want 1", completed)
+	}
+	if r.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", r.Dropped())
+	}
+}
+
+func TestReassembler_Unfragmented(t *testing.T) {
+	r := NewReassembler(ReassemblerOptions{})
+	payload := protocol.AppendSpectrum(nil, []float32{1, 2, 3})
+	packet := protocol.AppendHeader(nil, protocol.Header{Type: protocol.MessageSpectrum, PayloadLength: uint16(len(payload))})
+	packet = append(packet, payload...)
+
+	_, data, ok, err := r.Add(testSource, packet)
+	if !ok || err != nil || !bytes.Equal(data, payload) {
+		t.Errorf("Add = %v, %v, %v; want payload passed through", data, ok, err)
+	}
+	if _, _, _, err := r.Add(testSource, []byte("garbage")); err == nil {
+		t.Error("expected error for invalid packet, got nil")
+	}
+}
+
+func TestReassembler_Limits(t *testing.T) {
+	r := NewReassembler(ReassemblerOptions{MaxMessageSize: 4000, MaxPendingBytes: 6000})
+	h := protocol.Header{Type: protocol.MessageSpectrum}
+
+	// A forged chunk announcing a huge message is rejected before anything is allocated.
+	forged := protocol.AppendFragment(nil, h, protocol.FragmentHeader{
+		FrameID: 1, Index: 0, Count: 65535, TotalLength: 65535 * 1000,
+	}, testPayload(1000))
+	if _, _, _, err := r.Add(testSource, forged); err == nil {
+		t.Error("expected error for a message over MaxMessageSize")
+	}
+	// So is one whose count doesn't match its length.
+	forged = protocol.AppendFragment(nil, h, protocol.FragmentHeader{
+		FrameID: 2, Index: 0, Count: 100, TotalLength: 3000,
+	}, testPayload(1000))
+	if _, _, _, err := r.Add(testSource, forged); err == nil {
+		t.Error("expected error for a count that doesn't match the length")
+	}
+	if r.Pending() != 0 {
+		t.Fatalf("Pending() = %d, want 0", r.Pending())
+	}
+
+	// Two incomplete 3000 byte messages fill MaxPendingBytes; a third evicts the oldest.
+	for id := uint32(3); id <= 5; id++ {
+		r.Add(testSource, fragment(h, id, testPayload(3000), 1000)[0])
+	}
+	if r.Pending() != 2 || r.Dropped() != 1 {
+		t.Errorf("Pending() = %d, Dropped() = %d; want 2, 1", r.Pending(), r.Dropped())
+	}
+}
+
+func TestReassembler_SeparatesSources(t *testing.T) {
+	r := NewReassembler(ReassemblerOptions{})
+	h := protocol.Header{Type: protocol.MessageSpectrum}
+	payload, other := testPayload(3000), bytes.Repeat([]byte{0xff}, 3000)
+	packets := fragment(h, 7, payload, 1000)
+	forged := fragment(h, 7, other, 1000)
+	source := netip.MustParseAddrPort("192.0.2.2:9000")
+
+	// A second sender using the same frame ID must not mix its chunks into the first.
+	r.Add(testSource, packets[0])
+	r.Add(source, forged[1])
+	r.Add(testSource, packets[1])
+	_, data, ok, err := r.Add(testSource, packets[2])
+	if !ok || err != nil || !bytes.Equal(data, payload) {
+		t.Fatalf("Add = ok %v, err %v; want the first sender's payload", ok, err)
+	}
+	if r.Pending() != 1 {
+		t.Errorf("Pending() = %d, want the other sender's frame", r.Pending())
+	}
+}
+
+func TestReassembler_RejectsShortChunk(t *testing.T) {
+	r := NewReassembler(ReassemblerOptions{})
+	h := protocol.Header{Type: protocol.MessageSpectrum}
+	packets := fragment(h, 1, testPayload(3000), 1000)
+	short := protocol.AppendFragment(nil, h, protocol.FragmentHeader{
+		FrameID: 1, Index: 1, Count: 3, TotalLength: 3000,
+	}, testPayload(500))
+
+	r.Add(testSource, packets[0])
+	if _, _, ok, err := r.Add(testSource, short); ok || err == nil {
+		t.Fatalf("Add(short chunk) = ok %v, err %v; want an error", ok, err)
+	}
+	r.Add(testSource, packets[1])
+	if _, _, ok, err := r.Add(testSource, packets[2]); !ok || err != nil {
+		t.Errorf("Add = ok %v, err %v; want the message completed by the full chunk", ok, err)
+	}
+}
@@ -0,0 +1,329 @@
+// SPDX-License-Identifier: MIT
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// legacyTTL is the TTL of answers to one-shot queries, which are not cached by an
+	// mDNS stack (RFC 6762, section 6.7).
+	legacyTTL = 10 * time.Second
+	// cacheFlush marks records of which the responder holds the only copy.
+	cacheFlush = 1 << 15
+	// announceDelay is the time between the two announcements on start.
+	announceDelay = time.Second
+)
+
+// servicesName is the name under which DNS-SD lists the service types of a network.
+const servicesName = "_services._dns-sd._udp.local."
+
+// AdvertiserOptions controls an Advertiser.
+type AdvertiserOptions struct {
+	Interface string // Interface to advertise on (empty uses the system default and advertises the addresses of all interfaces).
+	Host      string // Host name without ".local" (empty uses the system's host name).
+	Debug     bool   // Log every query answered.
+}
+
+// Advertiser answers mDNS queries for one instance of ServiceType until it is closed. It
+// announces the instance when created and sends a goodbye when closed, so browsers
+// see engines appear and disappear straight away. Instance names are not probed for
+// conflicts (RFC 6762, section 8); every engine on a network needs a name of its own.
+type Advertiser struct {
+	conn    *net.UDPConn
+	debug   bool
+	service dnsmessage.Name // ServiceType.local.
+	enum    dnsmessage.Name // servicesName.
+	name    dnsmessage.Name // Instance name.
+	host    dnsmessage.Name // Host name.
+	port    uint16          // SRV port.
+	txt     []string        // TXT strings.
+	addrs   []net.IP        // IPv4 addresses of the host.
+
+	done chan struct{}  // Closed by Close.
+	wg   sync.WaitGroup // Waits for the serving and announcing goroutines.
+	once sync.Once
+}
+
+// NewAdvertiser starts advertising instance with the stream description info. An empty
+// instance uses the host name.
+func NewAdvertiser(instance string, info Info, options AdvertiserOptions) (*Advertiser, error) {
+	var ifi *net.Interface
+	if options.Interface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(options.Interface); err != nil {
+			return nil, fmt.Errorf("discovery: unknown interface %q: %w", options.Interface, err)
+		}
+	}
+	host := options.Host
+	if host == "" {
+		name, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("discovery: failed to get host name: %w", err)
+		}
+		host, _, _ = strings.Cut(name, ".")
+	}
+	if instance == "" {
+		instance = host
+	}
+	addrs, err := interfaceAddrs(ifi)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newAdvertiser(instance, info, host, addrs)
+	if err != nil {
+		return nil, err
+	}
+	a.debug = options.Debug
+
+	a.conn, err = net.ListenMulticastUDP("udp4", ifi, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: failed to join the mDNS group: %w", err)
+	}
+	if ifi != nil {
+		if err := ipv4.NewPacketConn(a.conn).SetMulticastInterface(ifi); err != nil {
+			a.conn.Close()
+			return nil, fmt.Errorf("discovery: failed to set multicast interface: %w", err)
+		}
+	}
+
+	a.wg.Add(2)
+	go a.serve()
+	go a.announce()
+	fmt.Printf("discovery: Advertising %q as %s on %s (addresses %v)\n", instance, ServiceType, a.host, a.addrs)
+	return a, nil
+}
+
+// newAdvertiser prepares the records of an Advertiser, without a connection.
+func newAdvertiser(instance string, info Info, host string, addrs []net.IP) (*Advertiser, error) {
+	a := &Advertiser{
+		port:  uint16(info.ControlPort),
+		txt:   info.text(),
+		addrs: addrs,
+		done:  make(chan struct{}),
+	}
+	for _, n := range []struct {
+		name *dnsmessage.Name
+		s    string
+	}{
+		{&a.service, serviceName()},
+		{&a.enum, servicesName},
+		{&a.name, instanceName(instance)},
+		{&a.host, host + ".local."},
+	} {
+		var err error
+		if *n.name, err = newName(n.s); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// interfaceAddrs returns the IPv4 addresses of ifi, or of every interface that is up
+// if ifi is nil. Loopback addresses are only used when there are no others.
+func interfaceAddrs(ifi *net.Interface) ([]net.IP, error) {
+	interfaces := []net.Interface{}
+	if ifi != nil {
+		interfaces = append(interfaces, *ifi)
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, fmt.Errorf("discovery: failed to list interfaces: %w", err)
+		}
+		for _, i := range all {
+			if i.Flags&net.FlagUp != 0 {
+				interfaces = append(interfaces, i)
+			}
+		}
+	}
+	var addrs, loopback []net.IP
+	for _, i := range interfaces {
+		list, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range list {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			if ipnet.IP.IsLoopback() {
+				loopback = append(loopback, ipnet.IP.To4())
+			} else {
+				addrs = append(addrs, ipnet.IP.To4())
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		addrs = loopback
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("discovery: no IPv4 address to advertise")
+	}
+	return addrs, nil
+}
+
+// announce sends the records twice after start, as RFC 6762 asks for.
+func (a *Advertiser) announce() {
+	defer a.wg.Done()
+	for i := range 2 {
+		if i > 0 {
+			select {
+			case <-time.After(announceDelay):
+			case <-a.done:
+				return
+			}
+		}
+		a.send(a.response(nil, TTL, true, true), mdnsGroup)
+	}
+}
+
+// serve answers queries until the connection is closed.
+func (a *Advertiser) serve() {
+	defer a.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, source, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		a.handle(buf[:n], source)
+	}
+}
+
+// handle answers one query from source if it asks for any of the advertised records.
+func (a *Advertiser) handle(packet []byte, source *net.UDPAddr) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || header.Response || header.OpCode != 0 {
+		return
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return
+	}
+
+	// Queries from a port other than 5353 come from simple resolvers (like Browse) that
+	// only listen for a direct answer (RFC 6762, section 6.7).
+	legacy := source.Port != mdnsGroup.Port
+	unicast := legacy
+	for _, q := range questions {
+		if q.Class&cacheFlush != 0 { // The QU bit: the asker prefers a unicast answer.
+			unicast = true
+		}
+	}
+	ttl := TTL
+	if legacy {
+		ttl = legacyTTL
+	}
+	reply := a.response(questions, ttl, !legacy, false)
+	if reply == nil {
+		return
+	}
+	if legacy {
+		reply.Header.ID = header.ID
+		reply.Questions = questions
+	}
+	if a.debug {
+		fmt.Printf("discovery: Answering query from %s\n", source)
+	}
+	if unicast {
+		a.send(reply, source)
+	} else {
+		a.send(reply, mdnsGroup)
+	}
+}
+
+// response builds the answer to questions, or with all set, every record. It returns nil
+// if none of the questions is about the advertised records.
+func (a *Advertiser) response(questions []dnsmessage.Question, ttl time.Duration, flush, all bool) *dnsmessage.Message {
+	seconds := uint32(ttl / time.Second)
+	header := func(name dnsmessage.Name, unique bool) dnsmessage.ResourceHeader {
+		class := dnsmessage.ClassINET
+		if unique && flush {
+			class |= cacheFlush
+		}
+		return dnsmessage.ResourceHeader{Name: name, Class: class, TTL: seconds}
+	}
+	ptr := dnsmessage.Resource{Header: header(a.service, false), Body: &dnsmessage.PTRResource{PTR: a.name}}
+	srv := dnsmessage.Resource{Header: header(a.name, true), Body: &dnsmessage.SRVResource{Target: a.host, Port: a.port}}
+	txt := dnsmessage.Resource{Header: header(a.name, true), Body: &dnsmessage.TXTResource{TXT: a.txt}}
+	var hosts []dnsmessage.Resource
+	for _, addr := range a.addrs {
+		var a4 [4]byte
+		copy(a4[:], addr)
+		hosts = append(hosts, dnsmessage.Resource{Header: header(a.host, true), Body: &dnsmessage.AResource{A: a4}})
+	}
+
+	m := &dnsmessage.Message{Header: dnsmessage.Header{Response: true, Authoritative: true}}
+	if all {
+		m.Answers = append([]dnsmessage.Resource{ptr, srv, txt}, hosts...)
+		return m
+	}
+	for _, q := range questions {
+		name := strings.ToLower(q.Name.String())
+		anyType := q.Type == dnsmessage.TypeALL
+		switch {
+		case name == strings.ToLower(a.service.String()) && (q.Type == dnsmessage.TypePTR || anyType):
+			m.Answers = append(m.Answers, ptr)
+			m.Additionals = append(append(m.Additionals, srv, txt), hosts...)
+		case name == servicesName && (q.Type == dnsmessage.TypePTR || anyType):
+			m.Answers = append(m.Answers, dnsmessage.Resource{
+				Header: header(a.enum, false),
+				Body:   &dnsmessage.PTRResource{PTR: a.service},
+			})
+		case name == strings.ToLower(a.name.String()):
+			if q.Type == dnsmessage.TypeSRV || anyType {
+				m.Answers = append(m.Answers, srv)
+				m.Additionals = append(m.Additionals, hosts...)
+			}
+			if q.Type == dnsmessage.TypeTXT || anyType {
+				m.Answers = append(m.Answers, txt)
+			}
+		case name == strings.ToLower(a.host.String()) && (q.Type == dnsmessage.TypeA || anyType):
+			m.Answers = append(m.Answers, hosts...)
+		}
+	}
+	if len(m.Answers) == 0 {
+		return nil
+	}
+	return m
+}
+
+// send packs m and sends it to target. Errors are ignored; mDNS tolerates lost packets.
+func (a *Advertiser) send(m *dnsmessage.Message, target *net.UDPAddr) {
+	packet, err := m.Pack()
+	if err != nil {
+		fmt.Printf("discovery: Failed to pack response: %v\n", err)
+		return
+	}
+	_, _ = a.conn.WriteToUDP(packet, target)
+}
+
+// Close sends a goodbye, so browsers drop the instance, and stops answering queries. It
+// is safe to call multiple times.
+func (a *Advertiser) Close() error {
+	var err error
+	a.once.Do(func() {
+		close(a.done)
+		a.send(a.response(nil, 0, true, true), mdnsGroup)
+		err = a.conn.Close()
+		a.wg.Wait()
+	})
+	return err
+}
+
+// Ensure Advertiser satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Advertiser)(nil)
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: MIT
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+// DefaultBrowseTimeout is how long Browse collects answers when the context has no
+// deadline.
+const DefaultBrowseTimeout = 2 * time.Second
+
+// BrowseOptions controls Browse.
+type BrowseOptions struct {
+	Interface string // Interface to send the query on (empty uses the system default).
+}
+
+// Browse queries the local network for engines and returns those that answered before
+// ctx is done, or within DefaultBrowseTimeout if ctx has no deadline, sorted by
+// instance name. The query is repeated once halfway, in case it was lost.
+func Browse(ctx context.Context, options BrowseOptions) ([]Service, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultBrowseTimeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("discovery: failed to open socket: %w", err)
+	}
+	defer conn.Close()
+	if options.Interface != "" {
+		ifi, err := net.InterfaceByName(options.Interface)
+		if err != nil {
+			return nil, fmt.Errorf("discovery: unknown interface %q: %w", options.Interface, err)
+		}
+		if err := ipv4.NewPacketConn(conn).SetMulticastInterface(ifi); err != nil {
+			return nil, fmt.Errorf("discovery: failed to set multicast interface: %w", err)
+		}
+	}
+
+	query, err := browseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, fmt.Errorf("discovery: failed to send query: %w", err)
+	}
+	resend := time.AfterFunc(time.Until(deadline)/2, func() { _, _ = conn.WriteToUDP(query, mdnsGroup) })
+	defer resend.Stop()
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	var records browseRecords
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			return nil, fmt.Errorf("discovery: failed to receive answers: %w", err)
+		}
+		records.add(buf[:n])
+	}
+	return records.services(), nil
+}
+
+// browseQuery builds the PTR query for ServiceType.
+func browseQuery() ([]byte, error) {
+	name, err := newName(serviceName())
+	if err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	return query.Pack()
+}
+
+// browseRecords collects the records of the answers to a browse query, keyed by
+// lower-case name.
+type browseRecords struct {
+	instances []string             // Instance names as received, in order of arrival.
+	srv       map[string]srvRecord // SRV records by instance name.
+	txt       map[string][]string  // TXT records by instance name.
+	addrs     map[string][]net.IP  // A records by host name.
+}
+
+// srvRecord is the target of an SRV record.
+type srvRecord struct {
+	host string
+	port uint16
+}
+
+// add records the answers and additional records of one response. Malformed packets and
+// unrelated records are ignored.
+func (r *browseRecords) add(packet []byte) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || !header.Response {
+		return
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return
+	}
+	additionals, _ := p.AllAdditionals() // Keep the answers even if these are cut short.
+
+	if r.srv == nil {
+		r.srv = make(map[string]srvRecord)
+		r.txt = make(map[string][]string)
+		r.addrs = make(map[string][]net.IP)
+	}
+	service := strings.ToLower(serviceName())
+	for _, rr := range append(answers, additionals...) {
+		name := strings.ToLower(rr.Header.Name.String())
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			instance := body.PTR.String()
+			if name == service && rr.Header.TTL > 0 && !slices.ContainsFunc(r.instances, func(s string) bool { return strings.EqualFold(s, instance) }) {
+				r.instances = append(r.instances, instance)
+			}
+		case *dnsmessage.SRVResource:
+			r.srv[name] = srvRecord{host: body.Target.String(), port: body.Port}
+		case *dnsmessage.TXTResource:
+			r.txt[name] = body.TXT
+		case *dnsmessage.AResource:
+			ip := net.IP(body.A[:])
+			if !slices.ContainsFunc(r.addrs[name], ip.Equal) {
+				r.addrs[name] = append(r.addrs[name], ip)
+			}
+		}
+	}
+}
+
+// services returns the instances for which an SRV record was received.
+func (r *browseRecords) services() []Service {
+	suffix := "." + serviceName()
+	var services []Service
+	for _, instance := range r.instances {
+		srv, ok := r.srv[strings.ToLower(instance)]
+		if !ok || len(instance) <= len(suffix) || !strings.EqualFold(instance[len(instance)-len(suffix):], suffix) {
+			continue
+		}
+		info := parseInfo(r.txt[strings.ToLower(instance)])
+		if info.ControlPort == 0 {
+			info.ControlPort = int(srv.port)
+		}
+		services = append(services, Service{
+			Instance: instance[:len(instance)-len(suffix)],
+			Host:     srv.host,
+			Addrs:    r.addrs[strings.ToLower(srv.host)],
+			Info:     info,
+		})
+	}
+	slices.SortFunc(services, func(a, b Service) int { return strings.Compare(a.Instance, b.Instance) })
+	return services
+}
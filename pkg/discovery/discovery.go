@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: MIT
+
+// Package discovery finds engines on the local network with multicast DNS service
+// discovery (RFC 6762, RFC 6763). Every engine advertises one instance of the
+// "_phase4._udp" service with an Advertiser. The SRV record points at the engine's
+// subscription control port, and the TXT record describes its stream:
+//
+//	txtvers=1     Version of these TXT keys.
+//	proto=1       Packet protocol version (audio/pkg/protocol.Version).
+//	rate=48000    Sample rate of the analysed audio in Hz.
+//	fft=1024      FFT size in points.
+//	channel=0     Channel ID written to the packet headers.
+//	control=9091  Subscription control port, absent when subscriptions are disabled.
+//	tcp=9092      TCP stream port, absent when the TCP stream is disabled.
+//	enc=float32   Spectrum encoding subscribers receive (float32, db16 or db8).
+//	sec=none      Packet security: none, signed or encrypted.
+//
+// Browse sends a one-shot query and collects the answers of every engine (and of any
+// other mDNS responder that knows about the service), so receivers need no configured
+// addresses. Only IPv4 is used.
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ServiceType is the DNS-SD service type engines advertise.
+const ServiceType = "_phase4._udp"
+
+// TTL is the time to live of advertised records; the engine re-announces within it.
+const TTL = 120 * time.Second
+
+// mdnsGroup is the IPv4 mDNS multicast group and port.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Info is the stream description carried in the TXT record.
+type Info struct {
+	ProtocolVersion int    // Packet protocol version.
+	SampleRate      int    // Sample rate in Hz.
+	FFTSize         int    // FFT size in points.
+	Channel         uint16 // Channel ID of the stream.
+	ControlPort     int    // Subscription control port, 0 if subscriptions are disabled.
+	TCPPort         int    // TCP stream port, 0 if the TCP stream is disabled.
+	Encoding        string // Spectrum encoding of subscriptions.
+	Security        string // "none", "signed" or "encrypted".
+}
+
+// text returns the TXT strings of info.
+func (info Info) text() []string {
+	txt := []string{
+		"txtvers=1",
+		"proto=" + strconv.Itoa(info.ProtocolVersion),
+		"rate=" + strconv.Itoa(info.SampleRate),
+		"fft=" + strconv.Itoa(info.FFTSize),
+		"channel=" + strconv.Itoa(int(info.Channel)),
+	}
+	if info.ControlPort > 0 {
+		txt = append(txt, "control="+strconv.Itoa(info.ControlPort))
+	}
+	if info.TCPPort > 0 {
+		txt = append(txt, "tcp="+strconv.Itoa(info.TCPPort))
+	}
+	if info.Encoding != "" {
+		txt = append(txt, "enc="+info.Encoding)
+	}
+	if info.Security != "" {
+		txt = append(txt, "sec="+info.Security)
+	}
+	return txt
+}
+
+// parseInfo reads the keys of a TXT record. Unknown keys and malformed values are ignored.
+func parseInfo(txt []string) Info {
+	var info Info
+	for _, s := range txt {
+		key, value, _ := strings.Cut(s, "=")
+		n, _ := strconv.Atoi(value)
+		switch strings.ToLower(key) {
+		case "proto":
+			info.ProtocolVersion = n
+		case "rate":
+			info.SampleRate = n
+		case "fft":
+			info.FFTSize = n
+		case "channel":
+			info.Channel = uint16(n)
+		case "control":
+			info.ControlPort = n
+		case "tcp":
+			info.TCPPort = n
+		case "enc":
+			info.Encoding = value
+		case "sec":
+			info.Security = value
+		}
+	}
+	return info
+}
+
+// Service is an advertised engine.
+type Service struct {
+	Instance string   // Instance name, unique on the network (e.g. "stage-left").
+	Host     string   // Host name of the engine (e.g. "rig1.local.").
+	Addrs    []net.IP // Addresses of Host.
+	Info     Info     // Stream description from the TXT record.
+}
+
+// ControlAddress returns the "host:port" to subscribe at (see client.Options.Subscribe),
+// or "" if the engine doesn't accept subscriptions.
+func (s Service) ControlAddress() string {
+	return s.address(s.Info.ControlPort)
+}
+
+// TCPAddress returns the "host:port" of the engine's TCP stream (see client.Dial), or ""
+// if it is disabled.
+func (s Service) TCPAddress() string {
+	return s.address(s.Info.TCPPort)
+}
+
+// address joins the first address of the service, or its host name, with port.
+func (s Service) address(port int) string {
+	if port <= 0 {
+		return ""
+	}
+	host := strings.TrimSuffix(s.Host, ".")
+	if len(s.Addrs) > 0 {
+		host = s.Addrs[0].String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// serviceName returns the fully qualified name of the service type.
+func serviceName() string {
+	return ServiceType + ".local."
+}
+
+// instanceName returns the fully qualified name of an instance. Dots can't be escaped in
+// dnsmessage names, so they are replaced.
+func instanceName(instance string) string {
+	return strings.ReplaceAll(instance, ".", "-") + "." + serviceName()
+}
+
+// newName converts a fully qualified name, which must fit in 255 bytes.
+func newName(name string) (dnsmessage.Name, error) {
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return dnsmessage.Name{}, fmt.Errorf("discovery: invalid name %q: %w", name, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: MIT
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var testInfo = Info{
+	ProtocolVersion: 1,
+	SampleRate:      48000,
+	FFTSize:         1024,
+	Channel:         3,
+	ControlPort:     9091,
+	TCPPort:         9092,
+	Encoding:        "db8",
+	Security:        "signed",
+}
+
+// answer returns the packed response of a to the browse query.
+func answer(t *testing.T, a *Advertiser, ttl time.Duration) []byte {
+	t.Helper()
+	query, err := browseQuery()
+	if err != nil {
+		t.Fatalf("browseQuery error: %v", err)
+	}
+	var p dnsmessage.Parser
+	if _, err := p.Start(query); err != nil {
+		t.Fatalf("Parse query error: %v", err)
+	}
+	questions, _ := p.AllQuestions()
+	reply := a.response(questions, ttl, false, false)
+	if reply == nil {
+		t.Fatal("no response to the browse query")
+	}
+	packet, err := reply.Pack()
+	if err != nil {
+		t.Fatalf("Pack error: %v", err)
+	}
+	return packet
+}
+
+func TestResponse_RoundTrip(t *testing.T) {
+	a, err := newAdvertiser("Stage Left v1.2", testInfo, "rig1", []net.IP{net.IPv4(192, 168, 1, 20).To4()})
+	if err != nil {
+		t.Fatalf("newAdvertiser error: %v", err)
+	}
+	other, _ := newAdvertiser("Booth", Info{ProtocolVersion: 1}, "rig2", []net.IP{net.IPv4(10, 0, 0, 2).To4()})
+
+	var records browseRecords
+	records.add(answer(t, a, legacyTTL))
+	records.add(answer(t, other, legacyTTL))
+	records.add(answer(t, a, legacyTTL)) // Repeated answers are merged.
+	services := records.services()
+	if len(services) != 2 {
+		t.Fatalf("services = %+v, want 2", services)
+	}
+
+	booth, stage := services[0], services[1]
+	if stage.Instance != "Stage Left v1-2" || stage.Host != "rig1.local." || stage.Info != testInfo {
+		t.Errorf("service = %+v", stage)
+	}
+	if got := stage.ControlAddress(); got != "192.168.1.20:9091" {
+		t.Errorf("ControlAddress = %q", got)
+	}
+	if got := stage.TCPAddress(); got != "192.168.1.20:9092" {
+		t.Errorf("TCPAddress = %q", got)
+	}
+	if booth.Instance != "Booth" || booth.ControlAddress() != "" || booth.TCPAddress() != "" {
+		t.Errorf("service without ports = %+v", booth)
+	}
+
+	// A goodbye (TTL 0) doesn't list the instance.
+	var goodbye browseRecords
+	goodbye.add(answer(t, a, 0))
+	if services := goodbye.services(); len(services) != 0 {
+		t.Errorf("services after goodbye = %+v", services)
+	}
+
+	// Questions about other names are not answered.
+	name, _ := dnsmessage.NewName("_http._tcp.local.")
+	if reply := a.response([]dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}}, TTL, true, false); reply != nil {
+		t.Errorf("response to another service = %+v", reply)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	a, err := NewAdvertiser("phase4 test", testInfo, AdvertiserOptions{Host: "phase4-test"})
+	if err != nil {
+		t.Skipf("mDNS unavailable: %v", err)
+	}
+	defer a.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	services, err := Browse(ctx, BrowseOptions{})
+	if err != nil {
+		t.Fatalf("Browse error: %v", err)
+	}
+	for _, s := range services {
+		if s.Instance == "phase4 test" {
+			if s.Info != testInfo || s.Host != "phase4-test.local." || len(s.Addrs) == 0 {
+				t.Errorf("service = %+v", s)
+			}
+			return
+		}
+	}
+	t.Errorf("advertised service not found in %+v", services)
+}
@@ -0,0 +1,406 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// MessageSpectrumEncoded carries a magnitude spectrum in a compact encoding (see
+// SpectrumEncoding).
+const MessageSpectrumEncoded MessageType = 0x02
+
+// EncodedHeaderSize is the size of the encoded spectrum header preceding the data.
+const EncodedHeaderSize = 20
+
+// MaxEncodedCount bounds the number of samples a receiver accepts, so a corrupt or
+// hostile packet can't make it decompress gigabytes.
+const MaxEncodedCount = 1 << 22
+
+// SampleFormat is the representation of the samples of an encoded spectrum.
+type SampleFormat uint8
+
+// Sample formats.
+const (
+	FormatFloat32 SampleFormat = 0 // float32 magnitudes.
+	FormatDB16    SampleFormat = 1 // uint16 quantized dBFS levels.
+	FormatDB8     SampleFormat = 2 // uint8 quantized dBFS levels.
+)
+
+// Compression is the codec applied to the data of an encoded spectrum.
+type Compression uint8
+
+// Compression codecs.
+const (
+	CompressionNone Compression = 0
+	CompressionZstd Compression = 1
+	CompressionLZ4  Compression = 2
+)
+
+// EncodingFlags are the option bits of an encoded spectrum.
+type EncodingFlags uint8
+
+// EncodingDelta marks samples stored as differences to the message with sequence Base.
+const EncodingDelta EncodingFlags = 1 << 0
+
+// ErrMissingBase is returned for delta messages whose base message wasn't decoded, e.g.
+// because it was lost. Decoding resumes with the next keyframe.
+var ErrMissingBase = errors.New("protocol: base of delta-encoded spectrum not received")
+
+// EncodedHeader is the header of an encoded spectrum payload.
+type EncodedHeader struct {
+	Count       uint32        // Number of samples.
+	Format      SampleFormat  // Sample representation.
+	Compression Compression   // Codec applied to the data.
+	Flags       EncodingFlags // Option bits.
+	Offset      float32       // Level of quantized sample q is Offset + q*Scale (dBFS).
+	Scale       float32       // Level step of a quantized sample (dB).
+	Base        uint32        // Sequence number of the reference message for deltas.
+}
+
+// ParseSampleFormat converts a configuration name ("float32", "db16", "db8") to a
+// SampleFormat. The empty string selects FormatFloat32.
+func ParseSampleFormat(name string) (SampleFormat, error) {
+	switch name {
+	case "", "float32":
+		return FormatFloat32, nil
+	case "db16":
+		return FormatDB16, nil
+	case "db8":
+		return FormatDB8, nil
+	default:
+		return 0, fmt.Errorf("protocol: unknown sample format %q (use float32, db16 or db8)", name)
+	}
+}
+
+// ParseCompression converts a configuration name ("none", "zstd", "lz4") to a
+// Compression. The empty string selects CompressionNone.
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "", "none":
+		return CompressionNone, nil
+	case "zstd":
+		return CompressionZstd, nil
+	case "lz4":
+		return CompressionLZ4, nil
+	default:
+		return 0, fmt.Errorf("protocol: unknown compression %q (use none, zstd or lz4)", name)
+	}
+}
+
+// sampleSize returns the size of one sample in bytes, or 0 for unknown formats.
+func (f SampleFormat) sampleSize() int {
+	switch f {
+	case FormatFloat32:
+		return 4
+	case FormatDB16:
+		return 2
+	case FormatDB8:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// maxLevel returns the largest quantized level of the format.
+func (f SampleFormat) maxLevel() uint32 {
+	return 1<<(8*f.sampleSize()) - 1
+}
+
+// AppendEncodedHeader appends the encoded spectrum header to dst.
+func AppendEncodedHeader(dst []byte, h EncodedHeader) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, h.Count)
+	dst = append(dst, byte(h.Format), byte(h.Compression), byte(h.Flags), 0)
+	dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(h.Offset))
+	dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(h.Scale))
+	return binary.BigEndian.AppendUint32(dst, h.Base)
+}
+
+// ParseEncodedHeader decodes the header of an encoded spectrum payload and returns it
+// with the (possibly compressed) data following it.
+func ParseEncodedHeader(payload []byte) (EncodedHeader, []byte, error) {
+	if len(payload) < EncodedHeaderSize {
+		return EncodedHeader{}, nil, ErrShortPacket
+	}
+	h := EncodedHeader{
+		Count:       binary.BigEndian.Uint32(payload[0:4]),
+		Format:      SampleFormat(payload[4]),
+		Compression: Compression(payload[5]),
+		Flags:       EncodingFlags(payload[6]),
+		Offset:      math.Float32frombits(binary.BigEndian.Uint32(payload[8:12])),
+		Scale:       math.Float32frombits(binary.BigEndian.Uint32(payload[12:16])),
+		Base:        binary.BigEndian.Uint32(payload[16:20]),
+	}
+	if h.Format.sampleSize() == 0 {
+		return h, nil, fmt.Errorf("protocol: unknown sample format %d", h.Format)
+	}
+	if h.Count > MaxEncodedCount {
+		return h, nil, fmt.Errorf("protocol: encoded spectrum of %d samples exceeds the limit of %d", h.Count, MaxEncodedCount)
+	}
+	return h, payload[EncodedHeaderSize:], nil
+}
+
+// SpectrumEncoding selects how spectra are encoded.
+type SpectrumEncoding struct {
+	Format      SampleFormat // Sample representation.
+	Compression Compression  // Codec applied to the samples.
+	MinDB       float64      // Lowest level of quantized formats; quieter bins become silence (dBFS).
+	MaxDB       float64      // Highest level of quantized formats (dBFS).
+
+	// KeyframeInterval enables delta encoding: every message but each KeyframeInterval-th
+	// stores differences to the previous one. Zero disables deltas.
+	KeyframeInterval int
+}
+
+// Plain reports whether e is the uncompressed float32 encoding of MessageSpectrum.
+func (e SpectrumEncoding) Plain() bool {
+	return e.Format == FormatFloat32 && e.Compression == CompressionNone && e.KeyframeInterval <= 0
+}
+
+// SpectrumEncoder encodes consecutive spectra of one stream as MessageSpectrumEncoded
+// payloads. It keeps the previous spectrum for delta encoding and is not safe for
+// concurrent use.
+type SpectrumEncoder struct {
+	encoding   SpectrumEncoding
+	zstd       *zstd.Encoder
+	lz4        lz4.Compressor
+	samples    []uint32 // Samples of the current message.
+	previous   []uint32 // Samples of the previous message.
+	base       uint32   // Sequence number of the previous message.
+	sinceKey   int      // Messages since the latest keyframe.
+	raw        []byte   // Encoded samples before compression.
+	compressed []byte   // Reusable buffer for LZ4 output.
+}
+
+// NewSpectrumEncoder creates an encoder for e.
+func NewSpectrumEncoder(e SpectrumEncoding) (*SpectrumEncoder, error) {
+	if e.Format.sampleSize() == 0 {
+		return nil, fmt.Errorf("protocol: unknown sample format %d", e.Format)
+	}
+	if e.Format != FormatFloat32 && !(e.MaxDB > e.MinDB) {
+		return nil, fmt.Errorf("protocol: quantized range [%g, %g] dBFS is empty", e.MinDB, e.MaxDB)
+	}
+	enc := &SpectrumEncoder{encoding: e}
+	switch e.Compression {
+	case CompressionNone, CompressionLZ4:
+	case CompressionZstd:
+		var err error
+		enc.zstd, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+		if err != nil {
+			return nil, fmt.Errorf("protocol: failed to create zstd encoder: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("protocol: unknown compression %d", e.Compression)
+	}
+	return enc, nil
+}
+
+// AppendPayload encodes magnitudes, the spectrum of a fftSize-point FFT sent with
+// sequence number sequence, and appends the payload to dst.
+func (e *SpectrumEncoder) AppendPayload(dst []byte, sequence uint32, fftSize int, magnitudes []float32) []byte {
+	h := EncodedHeader{
+		Count:       uint32(len(magnitudes)),
+		Format:      e.encoding.Format,
+		Compression: e.encoding.Compression,
+	}
+
+	// Convert to samples.
+	e.samples = e.samples[:0]
+	if h.Format == FormatFloat32 {
+		for _, m := range magnitudes {
+			e.samples = append(e.samples, math.Float32bits(m))
+		}
+	} else {
+		maxLevel := h.Format.maxLevel()
+		h.Offset = float32(e.encoding.MinDB)
+		h.Scale = float32((e.encoding.MaxDB - e.encoding.MinDB) / float64(maxLevel))
+		reference := 2 / float64(max(fftSize, 1))
+		for _, m := range magnitudes {
+			var q uint32
+			if db := 20 * math.Log10(float64(m)*reference); db > float64(h.Offset) {
+				q = uint32(min(max(math.Round((db-float64(h.Offset))/float64(h.Scale)), 1), float64(maxLevel)))
+			}
+			e.samples = append(e.samples, q)
+		}
+	}
+
+	// Replace samples with differences, unless a keyframe is due.
+	delta := e.encoding.KeyframeInterval > 0 && e.sinceKey > 0 && e.sinceKey < e.encoding.KeyframeInterval &&
+		len(e.previous) == len(e.samples)
+	if delta {
+		h.Flags |= EncodingDelta
+		h.Base = e.base
+		e.sinceKey++
+	} else {
+		e.sinceKey = 1
+	}
+	e.raw = e.raw[:0]
+	mask := h.Format.maxLevel()
+	if h.Format == FormatFloat32 {
+		mask = math.MaxUint32
+	}
+	for i, v := range e.samples {
+		if delta {
+			v = (v - e.previous[i]) & mask
+		}
+		switch h.Format.sampleSize() {
+		case 1:
+			e.raw = append(e.raw, byte(v))
+		case 2:
+			e.raw = binary.BigEndian.AppendUint16(e.raw, uint16(v))
+		default:
+			e.raw = binary.BigEndian.AppendUint32(e.raw, v)
+		}
+	}
+	e.previous = append(e.previous[:0], e.samples...)
+	e.base = sequence
+
+	dst = AppendEncodedHeader(dst, h)
+	switch h.Compression {
+	case CompressionZstd:
+		return e.zstd.EncodeAll(e.raw, dst)
+	case CompressionLZ4:
+		if need := lz4.CompressBlockBound(len(e.raw)); cap(e.compressed) < need {
+			e.compressed = make([]byte, need)
+		}
+		n, err := e.lz4.CompressBlock(e.raw, e.compressed[:cap(e.compressed)])
+		if err != nil || n == 0 {
+			// Incompressible data: LZ4 can still represent it as literals.
+			n = lz4LiteralBlock(e.raw, e.compressed[:cap(e.compressed)])
+		}
+		return append(dst, e.compressed[:n]...)
+	default:
+		return append(dst, e.raw...)
+	}
+}
+
+// lz4LiteralBlock writes src as a single LZ4 sequence of literals into dst (which must
+// hold CompressBlockBound(len(src)) bytes) and returns its size. CompressBlock reports
+// incompressible input instead of encoding it this way.
+func lz4LiteralBlock(src, dst []byte) int {
+	n := len(src)
+	i := 1
+	if n < 15 {
+		dst[0] = byte(n << 4)
+	} else {
+		dst[0] = 0xf0
+		for rest := n - 15; ; rest -= 255 {
+			if rest < 255 {
+				dst[i] = byte(rest)
+				i++
+				break
+			}
+			dst[i] = 255
+			i++
+		}
+	}
+	return i + copy(dst[i:], src)
+}
+
+// SpectrumDecoder decodes consecutive MessageSpectrumEncoded payloads of one stream. It
+// keeps the latest decoded samples as the base of delta messages and is not safe for
+// concurrent use.
+type SpectrumDecoder struct {
+	zstd     *zstd.Decoder
+	samples  []uint32 // Samples of the latest decoded message.
+	sequence uint32   // Sequence number of the latest decoded message.
+	valid    bool     // Whether samples holds a decoded message.
+	raw      []byte   // Decompressed data.
+}
+
+// NewSpectrumDecoder creates a decoder for one stream.
+func NewSpectrumDecoder() *SpectrumDecoder {
+	return &SpectrumDecoder{}
+}
+
+// Decode decodes the payload of the message with the given sequence number and FFT size
+// (from its packet header) and appends the magnitudes to dst[:0]. Quantized levels are
+// converted back to magnitudes on the scale of MessageSpectrum.
+func (d *SpectrumDecoder) Decode(payload []byte, sequence uint32, fftSize uint32, dst []float32) ([]float32, error) {
+	h, data, err := ParseEncodedHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+	size := int(h.Count) * h.Format.sampleSize()
+
+	switch h.Compression {
+	case CompressionNone:
+		d.raw = data
+	case CompressionZstd:
+		if d.zstd == nil {
+			d.zstd, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(MaxEncodedCount*4))
+			if err != nil {
+				return nil, fmt.Errorf("protocol: failed to create zstd decoder: %w", err)
+			}
+		}
+		d.raw, err = d.zstd.DecodeAll(data, d.raw[:0])
+		if err != nil {
+			return nil, fmt.Errorf("protocol: zstd: %w", err)
+		}
+	case CompressionLZ4:
+		if cap(d.raw) < size {
+			d.raw = make([]byte, size)
+		}
+		n, err := lz4.UncompressBlock(data, d.raw[:size])
+		if err != nil {
+			return nil, fmt.Errorf("protocol: lz4: %w", err)
+		}
+		d.raw = d.raw[:n]
+	default:
+		return nil, fmt.Errorf("protocol: unknown compression %d", h.Compression)
+	}
+	if len(d.raw) < size {
+		return nil, fmt.Errorf("%w: encoded spectrum claims %d samples in %d bytes", ErrShortPacket, h.Count, len(d.raw))
+	}
+
+	delta := h.Flags&EncodingDelta != 0
+	if delta && (!d.valid || d.sequence != h.Base || len(d.samples) != int(h.Count)) {
+		d.valid = false
+		return nil, ErrMissingBase
+	}
+	if !delta {
+		d.samples = append(d.samples[:0], make([]uint32, h.Count)...)
+	}
+	mask := h.Format.maxLevel()
+	if h.Format == FormatFloat32 {
+		mask = math.MaxUint32
+	}
+	for i := range d.samples {
+		var v uint32
+		switch h.Format.sampleSize() {
+		case 1:
+			v = uint32(d.raw[i])
+		case 2:
+			v = uint32(binary.BigEndian.Uint16(d.raw[2*i:]))
+		default:
+			v = binary.BigEndian.Uint32(d.raw[4*i:])
+		}
+		if delta {
+			v = (d.samples[i] + v) & mask
+		}
+		d.samples[i] = v
+	}
+	d.sequence, d.valid = sequence, true
+
+	dst = dst[:0]
+	if h.Format == FormatFloat32 {
+		for _, v := range d.samples {
+			dst = append(dst, math.Float32frombits(v))
+		}
+		return dst, nil
+	}
+	reference := float64(fftSize) / 2
+	for _, q := range d.samples {
+		var m float32
+		if q > 0 {
+			m = float32(reference * math.Pow(10, (float64(h.Offset)+float64(q)*float64(h.Scale))/20))
+		}
+		dst = append(dst, m)
+	}
+	return dst, nil
+}
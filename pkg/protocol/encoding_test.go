@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// testSpectrum returns a 1025-bin spectrum of a 2048-point FFT whose levels drift with
+// frame, with a silent bin at 0.
+func testSpectrum(frame int) []float32 {
+	mags := make([]float32, 1025)
+	for i := 1; i < len(mags); i++ {
+		db := -100 + 90*float64(i%97)/96 + float64(frame%5)
+		mags[i] = float32(1024 * math.Pow(10, db/20))
+	}
+	return mags
+}
+
+func TestSpectrumEncoding_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding SpectrumEncoding
+		maxError float64 // Largest level error (dB), 0 for exact.
+	}{
+		{"float32", SpectrumEncoding{Format: FormatFloat32, Compression: CompressionZstd}, 0},
+		{"float32 delta lz4", SpectrumEncoding{Format: FormatFloat32, Compression: CompressionLZ4, KeyframeInterval: 4}, 0},
+		{"db16", SpectrumEncoding{Format: FormatDB16, MinDB: -120, MaxDB: 0}, 0.001},
+		{"db16 delta zstd", SpectrumEncoding{Format: FormatDB16, Compression: CompressionZstd, MinDB: -120, MaxDB: 0, KeyframeInterval: 3}, 0.001},
+		{"db8 delta lz4", SpectrumEncoding{Format: FormatDB8, Compression: CompressionLZ4, MinDB: -120, MaxDB: 0, KeyframeInterval: 8}, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := NewSpectrumEncoder(tt.encoding)
+			if err != nil {
+				t.Fatalf("NewSpectrumEncoder error: %v", err)
+			}
+			dec := NewSpectrumDecoder()
+			var got []float32
+			for frame := range 10 {
+				want := testSpectrum(frame)
+				payload := enc.AppendPayload(nil, uint32(100+frame), 2048, want)
+				h, _, err := ParseEncodedHeader(payload)
+				if err != nil {
+					t.Fatalf("ParseEncodedHeader error: %v", err)
+				}
+				keyframe := tt.encoding.KeyframeInterval == 0 || frame%tt.encoding.KeyframeInterval == 0
+				if delta := h.Flags&EncodingDelta != 0; delta == keyframe || (delta && h.Base != uint32(99+frame)) {
+					t.Errorf("frame %d: flags %b base %d", frame, h.Flags, h.Base)
+				}
+
+				got, err = dec.Decode(payload, uint32(100+frame), 2048, got)
+				if err != nil {
+					t.Fatalf("frame %d: Decode error: %v", frame, err)
+				}
+				if len(got) != len(want) || got[0] != 0 {
+					t.Fatalf("frame %d: decoded %d bins, silent bin %g", frame, len(got), got[0])
+				}
+				for i := 1; i < len(want); i++ {
+					if diff := math.Abs(20 * math.Log10(float64(got[i]/want[i]))); diff > tt.maxError {
+						t.Fatalf("frame %d bin %d: %g, want %g (%.3f dB off)", frame, i, got[i], want[i], diff)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSpectrumEncoding_Size(t *testing.T) {
+	plain := SpectrumPayloadSize(1025)
+	for _, e := range []SpectrumEncoding{
+		{Format: FormatDB8, MinDB: -120, MaxDB: 0},
+		{Format: FormatDB16, Compression: CompressionZstd, MinDB: -120, MaxDB: 0, KeyframeInterval: 10},
+	} {
+		enc, err := NewSpectrumEncoder(e)
+		if err != nil {
+			t.Fatalf("NewSpectrumEncoder error: %v", err)
+		}
+		enc.AppendPayload(nil, 1, 2048, testSpectrum(0))
+		if size := len(enc.AppendPayload(nil, 2, 2048, testSpectrum(0))); size > plain/3 {
+			t.Errorf("%+v: payload of %d bytes, want at most a third of %d", e, size, plain)
+		}
+	}
+}
+
+func TestSpectrumDecoder_MissingBase(t *testing.T) {
+	enc, err := NewSpectrumEncoder(SpectrumEncoding{Format: FormatDB8, MinDB: -120, MaxDB: 0, KeyframeInterval: 3})
+	if err != nil {
+		t.Fatalf("NewSpectrumEncoder error: %v", err)
+	}
+	dec := NewSpectrumDecoder()
+	for seq := range uint32(4) {
+		payload := enc.AppendPayload(nil, seq, 2048, testSpectrum(int(seq)))
+		if seq == 1 {
+			continue // Lost.
+		}
+		_, err := dec.Decode(payload, seq, 2048, nil)
+		switch seq {
+		case 2:
+			if !errors.Is(err, ErrMissingBase) {
+				t.Errorf("Decode(%d) error = %v, want ErrMissingBase", seq, err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("Decode(%d) error: %v", seq, err)
+			}
+		}
+	}
+}
+
+func TestSpectrumEncoding_Errors(t *testing.T) {
+	if _, err := NewSpectrumEncoder(SpectrumEncoding{Format: FormatDB8}); err == nil {
+		t.Error("NewSpectrumEncoder accepted an empty dB range")
+	}
+	if _, err := ParseSampleFormat("db12"); err == nil {
+		t.Error("ParseSampleFormat accepted db12")
+	}
+	if _, err := ParseCompression("gzip"); err == nil {
+		t.Error("ParseCompression accepted gzip")
+	}
+	payload := AppendEncodedHeader(nil, EncodedHeader{Count: 4, Format: FormatDB16})
+	if _, err := NewSpectrumDecoder().Decode(append(payload, 1, 2, 3), 0, 8, nil); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("Decode of truncated data error = %v, want ErrShortPacket", err)
+	}
+	payload = AppendEncodedHeader(nil, EncodedHeader{Count: 4, Format: FormatDB16, Compression: CompressionLZ4})
+	if _, err := NewSpectrumDecoder().Decode(append(payload, 0xff, 0xff), 0, 8, nil); err == nil {
+		t.Error("Decode accepted corrupt LZ4 data")
+	}
+}
@@ -0,0 +1,363 @@
+// SPDX-License-Identifier: MIT
+/*
+Package protocol defines the binary wire format used by the engine's network
+transports. Every datagram starts with a fixed 32 byte, self-describing header
+followed by a message type specific payload. All fields are BigEndian.
+
+Header (version 1):
+
+	|<- 4 ->|<1>|<1>|<- 2 ->|<-- 4 -->|<------ 8 ------>|<-- 4 -->|<-- 4 -->|<- 2 ->|<- 2 ->|
+	+-------+---+---+-------+---------+-----------------+---------+---------+-------+-------+
+	| Magic |Ver|Typ| Flags | Sequence|    Timestamp    |  Sample |   FFT   |Channel|Payload|
+	|"P4AE" |   |   |       | (uint32)| (int64, ns UTC) |  Rate   |   Size  |  ID   | Length|
+	+-------+---+---+-------+---------+-----------------+---------+---------+-------+-------+
+	0       4   5   6       8         12                20        24        28      30      32
+
+Receivers must check Magic and Version before interpreting anything else, and
+must skip message types they don't understand using PayloadLength. Bytes after
+the payload are reserved for trailers and must be ignored by receivers that
+don't recognise the flag announcing them.
+
+Spectrum payload (MessageSpectrum):
+
+	+----------------+-------------------------+
+	| Count (uint32) | Magnitudes (N * float32) |
+	+----------------+-------------------------+
+
+Encoded spectrum payload (MessageSpectrumEncoded):
+
+	|<-- 4 -->|<1>|<1>|<1>|<1>|<-- 4 -->|<-- 4 -->|<-- 4 -->|<-- ... -->|
+	+---------+---+---+---+---+---------+---------+---------+-----------+
+	|  Count  |Fmt|Cmp|Flg|Rsv| Offset  |  Scale  |  Base   |   Data    |
+	| (uint32)|   |   |   |   |(float32)|(float32)| (uint32)|           |
+	+---------+---+---+---+---+---------+---------+---------+-----------+
+	0         4   5   6   7   8         12        16        20
+
+Data holds Count samples in the sample format (Fmt), BigEndian:
+
+  - FormatFloat32: the float32 magnitudes, like MessageSpectrum.
+  - FormatDB16 / FormatDB8: levels quantized to uint16 / uint8. Zero is silence; any
+    other value q is Offset + q*Scale dBFS, where 0 dBFS is a full-scale sine, i.e. a
+    magnitude of FFTSize/2.
+
+With EncodingDelta set in Flg, every sample is the difference (modulo 2^bits of the
+sample format) to the same sample of the message whose sequence number is Base, which
+receivers must have decoded. Messages without the flag are keyframes that decode on
+their own. Finally Data may be compressed as a whole (Cmp): a zstd frame, or an LZ4
+block whose decompressed size is Count times the sample size.
+
+Subscription payload (MessageSubscribe, MessageSubscribeAck):
+
+	+-----------------+--------------+-------------+-------------------+---------------+
+	| Interval uint32 | Lease uint32 | Count uint8 | Types (N * uint8) | Cookie uint64 |
+	| (microseconds)  | (millisec.)  |             |                   |               |
+	+-----------------+--------------+-------------+-------------------+---------------+
+
+Receivers that aren't configured with a fixed target send MessageSubscribe to
+the engine's control port, from the socket they receive on. Interval is the
+requested time between messages and Lease how long the subscription lasts
+without renewal (zero asks for the engine defaults); Types lists the message
+types wanted. The engine first checks that the subscriber receives at the
+address the request came from, so forged source addresses can't make it stream
+to a victim: it answers a request without the right Cookie with a challenge, a
+MessageSubscribeAck carrying only a Cookie for that address, and no larger than
+the request. The subscriber repeats its request with the Cookie, which it echoes
+in every renewal. The engine then answers with MessageSubscribeAck carrying
+what it granted and the Cookie (Lease zero, no types and no Cookie when it
+rejects the request), and streams to the subscriber's address until the lease
+expires. Subscribers renew by sending MessageSubscribe again before then, and
+end a subscription early with MessageUnsubscribe (no payload).
+
+Fragmented messages (FlagFragment set):
+
+Messages whose payload doesn't fit in one datagram are split into chunks. Every
+chunk carries a copy of the header with FlagFragment set and PayloadLength
+covering the fragment header plus the chunk. The fragment header precedes the
+chunk data:
+
+	+------------------+--------------+--------------+----------------------+-------+
+	| Frame ID uint32  | Index uint16 | Count uint16 | Total Length uint32  | Chunk |
+	+------------------+--------------+--------------+----------------------+-------+
+
+Frame ID identifies the message the chunk belongs to, Index runs from 0 to
+Count-1 and Total Length is the size of the reassembled payload. Concatenating
+the chunks in index order yields the original payload.
+
+Stream framing (TCP):
+
+On stream transports every packet is preceded by its length as a uint32, so
+receivers don't need to understand a packet to skip it:
+
+	+-----------------+--------------------------------------+
+	| Length (uint32) | Packet (header, payload and trailer) |
+	+-----------------+--------------------------------------+
+
+Signed and encrypted packets (FlagSigned or FlagEncrypted set):
+
+Senders sharing a pre-shared key with their receivers seal every datagram
+(fragments individually) by appending a security trailer after the payload:
+
+	+---------------------+-----------------+-------------------------------+
+	| Session ID uint64   | Counter uint32  | Tag (32 or 16 bytes)          |
+	+---------------------+-----------------+-------------------------------+
+
+Session ID is chosen at random by each sender and Counter increases with every
+packet, starting at 1; together they form the nonce. Before Counter would wrap,
+the sender chooses a new Session ID and starts over, so no nonce is used twice.
+With FlagSigned the tag is the HMAC-SHA256 of header, payload and nonce. With
+FlagEncrypted the payload is AES-256-GCM ciphertext of the same length and the
+tag is the GCM tag, with header and nonce as additional data. The HMAC and AES
+keys are derived from the pre-shared key as HMAC-SHA256(key, "phase4 packet
+hmac-sha256") and HMAC-SHA256(key, "phase4 packet aes-256-gcm"). Receivers
+reject counters they have seen before (per session) and timestamps too far from
+their own clock.
+
+Legacy layout (before the header existed, still available as a compatibility
+mode on the sender):
+
+	+-----------------+------------------+---------------+-------------------------+
+	| Sequence uint32 | Timestamp int64  | Count uint16  | Magnitudes (N * float32) |
+	+-----------------+------------------+---------------+-------------------------+
+*/
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// Magic identifies engine packets: the ASCII bytes "P4AE".
+	Magic uint32 = 0x50344145
+	// Version is the protocol version written by this package.
+	Version uint8 = 1
+	// HeaderSize is the size of the version 1 header in bytes.
+	HeaderSize = 32
+	// LegacyHeaderSize is the size of the legacy header (sequence, timestamp, count).
+	LegacyHeaderSize = 14
+	// FragmentHeaderSize is the size of the fragment header preceding each chunk.
+	FragmentHeaderSize = 12
+)
+
+// MessageType identifies the payload carried by a packet.
+type MessageType uint8
+
+const (
+	// MessageSpectrum carries a magnitude spectrum (Count uint32 + float32 magnitudes).
+	MessageSpectrum MessageType = 0x01
+)
+
+// String returns a human readable name for the message type.
+func (t MessageType) String() string {
+	switch t {
+	case MessageSpectrum:
+		return "spectrum"
+	case MessageSpectrumEncoded:
+		return "spectrum-encoded"
+	case MessageSubscribe:
+		return "subscribe"
+	case MessageUnsubscribe:
+		return "unsubscribe"
+	case MessageSubscribeAck:
+		return "subscribe-ack"
+	default:
+		return fmt.Sprintf("MessageType(0x%02x)", uint8(t))
+	}
+}
+
+// Flags is a bit field describing optional packet features. Receivers must ignore bits
+// they don't know.
+type Flags uint16
+
+const (
+	// FlagFragment marks a packet carrying one chunk of a fragmented message.
+	FlagFragment Flags = 1 << 0
+)
+
+// Has reports whether all bits in f2 are set in f.
+func (f Flags) Has(f2 Flags) bool {
+	return f&f2 == f2
+}
+
+// Header is the fixed header at the start of every packet.
+type Header struct {
+	Version       uint8       // Protocol version (Version when encoding).
+	Type          MessageType // Payload message type.
+	Flags         Flags       // Optional feature bits.
+	Sequence      uint32      // Per-stream monotonically increasing sequence number.
+	Timestamp     int64       // Nanoseconds since the Unix epoch.
+	SampleRate    uint32      // Sample rate of the analysed audio (Hz).
+	FFTSize       uint32      // FFT size used to produce spectra (points).
+	Channel       uint16      // Identifies the stream when several share one port.
+	PayloadLength uint16      // Number of payload bytes following the header.
+}
+
+// Errors returned when parsing packets.
+var (
+	ErrShortPacket = errors.New("protocol: packet too short")
+	ErrBadMagic    = errors.New("protocol: bad magic")
+	ErrBadVersion  = errors.New("protocol: unsupported version")
+)
+
+// AppendHeader appends the encoded header to dst and returns the extended slice.
+// The Version field is always written as Version.
+func AppendHeader(dst []byte, h Header) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, Magic)
+	dst = append(dst, Version, uint8(h.Type))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(h.Flags))
+	dst = binary.BigEndian.AppendUint32(dst, h.Sequence)
+	dst = binary.BigEndian.AppendUint64(dst, uint64(h.Timestamp))
+	dst = binary.BigEndian.AppendUint32(dst, h.SampleRate)
+	dst = binary.BigEndian.AppendUint32(dst, h.FFTSize)
+	dst = binary.BigEndian.AppendUint16(dst, h.Channel)
+	dst = binary.BigEndian.AppendUint16(dst, h.PayloadLength)
+	return dst
+}
+
+// ParseHeader decodes the header at the start of packet and returns it together with the
+// payload (exactly PayloadLength bytes). It fails if the magic or version don't match or
+// the packet is shorter than the header says.
+func ParseHeader(packet []byte) (Header, []byte, error) {
+	if len(packet) < HeaderSize {
+		return Header{}, nil, ErrShortPacket
+	}
+	if binary.BigEndian.Uint32(packet[0:4]) != Magic {
+		return Header{}, nil, ErrBadMagic
+	}
+	h := Header{
+		Version:       packet[4],
+		Type:          MessageType(packet[5]),
+		Flags:         Flags(binary.BigEndian.Uint16(packet[6:8])),
+		Sequence:      binary.BigEndian.Uint32(packet[8:12]),
+		Timestamp:     int64(binary.BigEndian.Uint64(packet[12:20])),
+		SampleRate:    binary.BigEndian.Uint32(packet[20:24]),
+		FFTSize:       binary.BigEndian.Uint32(packet[24:28]),
+		Channel:       binary.BigEndian.Uint16(packet[28:30]),
+		PayloadLength: binary.BigEndian.Uint16(packet[30:32]),
+	}
+	if h.Version != Version {
+		return h, nil, fmt.Errorf("%w: %d", ErrBadVersion, h.Version)
+	}
+	end := HeaderSize + int(h.PayloadLength)
+	if len(packet) < end {
+		return h, nil, ErrShortPacket
+	}
+	return h, packet[HeaderSize:end], nil
+}
+
+// FragmentHeader precedes the chunk data in packets with FlagFragment set.
+type FragmentHeader struct {
+	FrameID     uint32 // Identifies the message the chunk belongs to.
+	Index       uint16 // Position of this chunk (0 to Count-1).
+	Count       uint16 // Total number of chunks in the message.
+	TotalLength uint32 // Size of the reassembled payload in bytes.
+}
+
+// AppendFragmentHeader appends the encoded fragment header to dst.
+func AppendFragmentHeader(dst []byte, f FragmentHeader) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, f.FrameID)
+	dst = binary.BigEndian.AppendUint16(dst, f.Index)
+	dst = binary.BigEndian.AppendUint16(dst, f.Count)
+	dst = binary.BigEndian.AppendUint32(dst, f.TotalLength)
+	return dst
+}
+
+// AppendFragment appends a complete fragment packet to dst: the header h with
+// FlagFragment set and PayloadLength adjusted, followed by the fragment header and chunk.
+func AppendFragment(dst []byte, h Header, f FragmentHeader, chunk []byte) []byte {
+	h.Flags |= FlagFragment
+	h.PayloadLength = uint16(FragmentHeaderSize + len(chunk))
+	dst = AppendHeader(dst, h)
+	dst = AppendFragmentHeader(dst, f)
+	return append(dst, chunk...)
+}
+
+// ParseFragmentHeader decodes the fragment header at the start of a payload and returns
+// it together with the chunk data.
+func ParseFragmentHeader(payload []byte) (FragmentHeader, []byte, error) {
+	if len(payload) < FragmentHeaderSize {
+		return FragmentHeader{}, nil, ErrShortPacket
+	}
+	f := FragmentHeader{
+		FrameID:     binary.BigEndian.Uint32(payload[0:4]),
+		Index:       binary.BigEndian.Uint16(payload[4:6]),
+		Count:       binary.BigEndian.Uint16(payload[6:8]),
+		TotalLength: binary.BigEndian.Uint32(payload[8:12]),
+	}
+	if f.Count == 0 || f.Index >= f.Count {
+		return f, nil, fmt.Errorf("protocol: invalid fragment %d of %d", f.Index, f.Count)
+	}
+	return f, payload[FragmentHeaderSize:], nil
+}
+
+// IsPacket reports whether packet starts with the protocol magic. Packets that don't are
+// assumed to use the legacy layout.
+func IsPacket(packet []byte) bool {
+	return len(packet) >= 4 && binary.BigEndian.Uint32(packet[0:4]) == Magic
+}
+
+// SpectrumPayloadSize returns the size in bytes of a spectrum payload with n magnitudes.
+func SpectrumPayloadSize(n int) int {
+	return 4 + 4*n
+}
+
+// AppendSpectrum appends a spectrum payload (count followed by float32 magnitudes) to dst.
+func AppendSpectrum(dst []byte, magnitudes []float32) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(magnitudes)))
+	for _, m := range magnitudes {
+		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(m))
+	}
+	return dst
+}
+
+// ParseSpectrum decodes a spectrum payload, appending the magnitudes to dst[:0] so
+// callers can reuse a buffer across packets.
+func ParseSpectrum(payload []byte, dst []float32) ([]float32, error) {
+	if len(payload) < 4 {
+		return nil, ErrShortPacket
+	}
+	count := int(binary.BigEndian.Uint32(payload[0:4]))
+	if len(payload)-4 < 4*count {
+		return nil, fmt.Errorf("%w: spectrum claims %d magnitudes in %d bytes", ErrShortPacket, count, len(payload)-4)
+	}
+	return appendFloat32s(dst[:0], payload[4:4+4*count]), nil
+}
+
+// AppendLegacySpectrum appends a packet in the legacy layout (sequence, timestamp, uint16
+// count, magnitudes) to dst. Spectra longer than 65535 bins cannot be represented.
+func AppendLegacySpectrum(dst []byte, sequence uint32, timestamp int64, magnitudes []float32) ([]byte, error) {
+	if len(magnitudes) > math.MaxUint16 {
+		return dst, fmt.Errorf("protocol: legacy layout cannot carry %d magnitudes (max %d)", len(magnitudes), math.MaxUint16)
+	}
+	dst = binary.BigEndian.AppendUint32(dst, sequence)
+	dst = binary.BigEndian.AppendUint64(dst, uint64(timestamp))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(magnitudes)))
+	for _, m := range magnitudes {
+		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(m))
+	}
+	return dst, nil
+}
+
+// ParseLegacySpectrum decodes a packet in the legacy layout, appending the magnitudes to dst[:0].
+func ParseLegacySpectrum(packet []byte, dst []float32) (sequence uint32, timestamp int64, magnitudes []float32, err error) {
+	if len(packet) < LegacyHeaderSize {
+		return 0, 0, nil, ErrShortPacket
+	}
+	sequence = binary.BigEndian.Uint32(packet[0:4])
+	timestamp = int64(binary.BigEndian.Uint64(packet[4:12]))
+	count := int(binary.BigEndian.Uint16(packet[12:14]))
+	if len(packet)-LegacyHeaderSize < 4*count {
+		return 0, 0, nil, fmt.Errorf("%w: legacy packet claims %d magnitudes in %d bytes", ErrShortPacket, count, len(packet)-LegacyHeaderSize)
+	}
+	return sequence, timestamp, appendFloat32s(dst[:0], packet[LegacyHeaderSize:LegacyHeaderSize+4*count]), nil
+}
+
+// appendFloat32s decodes BigEndian float32 values from b and appends them to dst.
+func appendFloat32s(dst []float32, b []byte) []float32 {
+	for i := 0; i+4 <= len(b); i += 4 {
+		dst = append(dst, math.Float32frombits(binary.BigEndian.Uint32(b[i:i+4])))
+	}
+	return dst
+}
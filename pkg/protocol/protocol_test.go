@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestHeader_RoundTrip(t *testing.T) {
+	mags := []float32{0, 1.5, -2.25, 1e6}
+	want := Header{
+		Version:       Version,
+		Type:          MessageSpectrum,
+		Flags:         0x8001,
+		Sequence:      42,
+		Timestamp:     1_700_000_000_123_456_789,
+		SampleRate:    48000,
+		FFTSize:       1024,
+		Channel:       7,
+		PayloadLength: uint16(SpectrumPayloadSize(len(mags))),
+	}
+
+	packet := AppendHeader(nil, want)
+	if len(packet) != HeaderSize {
+		t.Fatalf("header size = %d, want %d", len(packet), HeaderSize)
+	}
+	packet = AppendSpectrum(packet, mags)
+	packet = append(packet, 0xDE, 0xAD) // Trailer bytes must be ignored.
+
+	if !IsPacket(packet) {
+		t.Fatal("IsPacket = false, want true")
+	}
+	got, payload, err := ParseHeader(packet)
+	if err != nil {
+		t.Fatalf("ParseHeader error: %v", err)
+	}
+	if got != want {
+		t.Errorf("header = %+v, want %+v", got, want)
+	}
+	if !got.Flags.Has(0x8000) || got.Flags.Has(0x0002) {
+		t.Errorf("Flags.Has mismatch for %#04x", got.Flags)
+	}
+
+	decoded, err := ParseSpectrum(payload, make([]float32, 0, 8))
+	if err != nil {
+		t.Fatalf("ParseSpectrum error: %v", err)
+	}
+	if len(decoded) != len(mags) {
+		t.Fatalf("decoded %d magnitudes, want %d", len(decoded), len(mags))
+	}
+	for i := range mags {
+		if decoded[i] != mags[i] {
+			t.Errorf("magnitude %d = %v, want %v", i, decoded[i], mags[i])
+		}
+	}
+}
+
+func TestParseHeader_Errors(t *testing.T) {
+	valid := AppendHeader(nil, Header{Type: MessageSpectrum, PayloadLength: 8})
+
+	badVersion := append([]byte(nil), valid...)
+	badVersion[4] = 99
+
+	tests := []struct {
+		name   string
+		packet []byte
+		want   error
+	}{
+		{"Short", valid[:10], ErrShortPacket},
+		{"Bad magic", append([]byte{0, 0, 0, 0}, valid[4:]...), ErrBadMagic},
+		{"Bad version", badVersion, ErrBadVersion},
+		{"Truncated payload", valid, ErrShortPacket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseHeader(tt.packet); !errors.Is(err, tt.want) {
+				t.Errorf("ParseHeader error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacySpectrum_RoundTrip(t *testing.T) {
+	mags := []float32{3, 2, 1}
+	packet, err := AppendLegacySpectrum(nil, 9, 123, mags)
+	if err != nil {
+		t.Fatalf("AppendLegacySpectrum error: %v", err)
+	}
+	if IsPacket(packet) {
+		t.Error("legacy packet detected as versioned packet")
+	}
+	seq, ts, decoded, err := ParseLegacySpectrum(packet, nil)
+	if err != nil {
+		t.Fatalf("ParseLegacySpectrum error: %v", err)
+	}
+	if seq != 9 || ts != 123 || len(decoded) != 3 || decoded[2] != 1 {
+		t.Errorf("got seq=%d ts=%d mags=%v", seq, ts, decoded)
+	}
+
+	if _, err := AppendLegacySpectrum(nil, 0, 0, make([]float32, 70000)); err == nil {
+		t.Error("expected error for oversized legacy spectrum, got nil")
+	}
+}
+
+func TestFragmentHeader_RoundTrip(t *testing.T) {
+	want := FragmentHeader{FrameID: 77, Index: 2, Count: 5, TotalLength: 9000}
+	payload := AppendFragmentHeader(nil, want)
+	payload = append(payload, 1, 2, 3)
+
+	got, chunk, err := ParseFragmentHeader(payload)
+	if err != nil {
+		t.Fatalf("ParseFragmentHeader error: %v", err)
+	}
+	if got != want {
+		t.Errorf("fragment header = %+v, want %+v", got, want)
+	}
+	if len(chunk) != 3 {
+		t.Errorf("chunk length = %d, want 3", len(chunk))
+	}
+
+	bad := AppendFragmentHeader(nil, FragmentHeader{Index: 5, Count: 5})
+	if _, _, err := ParseFragmentHeader(bad); err == nil {
+		t.Error("expected error for out of range index, got nil")
+	}
+	if _, _, err := ParseFragmentHeader(payload[:4]); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("expected ErrShortPacket, got %v", err)
+	}
+}
+
+func TestFrame_RoundTrip(t *testing.T) {
+	packets := [][]byte{AppendHeader(nil, Header{Type: MessageSpectrum}), {}, {1, 2, 3}}
+	var stream []byte
+	for _, p := range packets {
+		stream = AppendFrame(stream, p)
+	}
+	r := bytes.NewReader(stream)
+	var buf []byte
+	for i, want := range packets {
+		got, err := ReadFrame(r, buf)
+		if err != nil {
+			t.Fatalf("ReadFrame %d error: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("frame %d = %v, want %v", i, got, want)
+		}
+		buf = got
+	}
+	if _, err := ReadFrame(r, buf); err != io.EOF {
+		t.Errorf("ReadFrame at end error = %v, want io.EOF", err)
+	}
+	if _, err := ReadFrame(bytes.NewReader(stream[:len(stream)-1]), nil); err != nil {
+		t.Fatalf("ReadFrame of first frame error: %v", err)
+	}
+	truncated := AppendFrame(nil, []byte{1, 2, 3})
+	if _, err := ReadFrame(bytes.NewReader(truncated[:5]), nil); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrame of truncated frame error = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0xFF}), nil); err == nil {
+		t.Error("ReadFrame of oversized frame succeeded")
+	}
+}
@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FlagSigned marks a packet followed by a security trailer with an HMAC-SHA256 of the
+	// header, payload and nonce.
+	FlagSigned Flags = 1 << 1
+	// FlagEncrypted marks a packet whose payload is AES-256-GCM ciphertext, followed by a
+	// security trailer with the GCM tag. The header and nonce are authenticated as well.
+	FlagEncrypted Flags = 1 << 2
+)
+
+const (
+	// NonceSize is the size of the nonce at the start of the security trailer: a random
+	// session ID (uint64) chosen by each sender followed by a per-packet counter (uint32).
+	// A sender starts a new session before its counter wraps, so a nonce is never reused
+	// unless two of the random session IDs collide.
+	NonceSize = 12
+	// MinKeySize is the shortest pre-shared key accepted, in bytes.
+	MinKeySize = 16
+	// DefaultMaxAge is the maximum age NewOpener uses when given zero.
+	DefaultMaxAge = 30 * time.Second
+	// maxSessions bounds the sessions whose replay state an Opener remembers.
+	maxSessions = 64
+	// replayWindow is the number of counters behind the highest one that are tracked.
+	replayWindow = 64
+)
+
+// Errors returned by Opener.Open.
+var (
+	ErrUnauthenticated = errors.New("protocol: packet not authenticated")
+	ErrReplayed        = errors.New("protocol: replayed packet")
+	ErrStale           = errors.New("protocol: packet timestamp outside the accepted age")
+)
+
+// ParseKey decodes a hex encoded pre-shared key of at least MinKeySize bytes.
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("protocol: key must be hex encoded: %w", err)
+	}
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("protocol: key has %d bytes, need at least %d", len(key), MinKeySize)
+	}
+	return key, nil
+}
+
+// packetKeys holds the keys derived from a pre-shared key, so the same secret is never
+// used for two algorithms.
+type packetKeys struct {
+	mac  []byte      // HMAC-SHA256 key.
+	aead cipher.AEAD // AES-256-GCM with a key of its own.
+}
+
+// deriveKeys derives the signing and encryption keys from a pre-shared key.
+func deriveKeys(key []byte) (packetKeys, error) {
+	if len(key) < MinKeySize {
+		return packetKeys{}, fmt.Errorf("protocol: key has %d bytes, need at least %d", len(key), MinKeySize)
+	}
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("phase4 packet aes-256-gcm"))
+	if err != nil {
+		return packetKeys{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return packetKeys{}, err
+	}
+	return packetKeys{mac: derive("phase4 packet hmac-sha256"), aead: aead}, nil
+}
+
+// Sealer signs or encrypts outgoing packets. One Sealer should be shared by all
+// publishers of a sender so they draw from one nonce sequence. It is safe for
+// concurrent use.
+type Sealer struct {
+	keys    packetKeys
+	encrypt bool
+
+	mu      sync.Mutex
+	mac     hash.Hash // Reused HMAC state.
+	session uint64    // Random ID of the current session.
+	counter uint32    // Counter of the last nonce used in the session.
+}
+
+// NewSealer creates a Sealer signing packets with key, or encrypting them if encrypt is set.
+func NewSealer(key []byte, encrypt bool) (*Sealer, error) {
+	keys, err := deriveKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	session, err := newSession()
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{
+		keys:    keys,
+		encrypt: encrypt,
+		mac:     hmac.New(sha256.New, keys.mac),
+		session: session,
+	}, nil
+}
+
+// newSession chooses a random session ID.
+func newSession() (uint64, error) {
+	var session [8]byte
+	if _, err := rand.Read(session[:]); err != nil {
+		return 0, fmt.Errorf("protocol: failed to choose session ID: %w", err)
+	}
+	return binary.BigEndian.Uint64(session[:]), nil
+}
+
+// Overhead returns the number of bytes Seal adds to a packet.
+func (s *Sealer) Overhead() int {
+	if s.encrypt {
+		return NonceSize + s.keys.aead.Overhead()
+	}
+	return NonceSize + sha256.Size
+}
+
+// Seal authenticates (and with encryption, encrypts the payload of) packet, a complete
+// header and payload, in place, and returns it with the security trailer appended.
+func (s *Sealer) Seal(packet []byte) ([]byte, error) {
+	h, _, err := ParseHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+	packet = packet[:HeaderSize+int(h.PayloadLength)] // Drop any other trailer.
+	flag := FlagSigned
+	if s.encrypt {
+		flag = FlagEncrypted
+	}
+	binary.BigEndian.PutUint16(packet[6:8], uint16((h.Flags&^(FlagSigned|FlagEncrypted))|flag))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counter == math.MaxUint32 {
+		// The counter would wrap: continue in a fresh session instead.
+		session, err := newSession()
+		if err != nil {
+			return nil, err
+		}
+		s.session, s.counter = session, 0
+	}
+	s.counter++
+	var nonce [NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[0:8], s.session)
+	binary.BigEndian.PutUint32(nonce[8:12], s.counter)
+
+	if s.encrypt {
+		aad := append(packet[:HeaderSize:HeaderSize], nonce[:]...)
+		sealed := s.keys.aead.Seal(nil, nonce[:], packet[HeaderSize:], aad)
+		// sealed is the ciphertext, the same length as the payload, followed by the tag.
+		end := copy(packet[HeaderSize:], sealed)
+		packet = append(packet, nonce[:]...)
+		return append(packet, sealed[end:]...), nil
+	}
+	s.mac.Reset()
+	s.mac.Write(packet)
+	s.mac.Write(nonce[:])
+	packet = append(packet, nonce[:]...)
+	return s.mac.Sum(packet), nil
+}
+
+// Opener verifies (and decrypts) packets sealed by a Sealer with the same key and
+// rejects replays: every session's counters are tracked in a sliding window, and
+// packets whose header timestamp is further than the maximum age from the local clock
+// are rejected.
+//
+// Only the latest sessions are remembered, and none survive a restart of the receiver.
+// A packet of a session the Opener doesn't know is accepted at any counter, so recorded
+// packets of a forgotten session can be replayed as long as their timestamp is within
+// the maximum age. With the age check disabled they can be replayed at any time.
+//
+// It is not safe for concurrent use.
+type Opener struct {
+	keys     packetKeys
+	mac      hash.Hash
+	maxAge   time.Duration
+	sessions map[uint64]*replayState
+}
+
+// replayState tracks the counters received in one session.
+type replayState struct {
+	highest  uint32    // Highest counter received.
+	window   uint64    // Bit i is set if highest-i has been received.
+	lastSeen time.Time // Time of the latest packet, for eviction.
+}
+
+// NewOpener creates an Opener for packets sealed with key, rejecting packets timestamped
+// further than maxAge from the local clock. Zero uses DefaultMaxAge, and a negative
+// maxAge disables the check for senders whose clock isn't synchronised with the
+// receiver's, at the cost of replays of forgotten sessions (see Opener).
+func NewOpener(key []byte, maxAge time.Duration) (*Opener, error) {
+	keys, err := deriveKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Opener{
+		keys:     keys,
+		mac:      hmac.New(sha256.New, keys.mac),
+		maxAge:   maxAge,
+		sessions: make(map[uint64]*replayState),
+	}, nil
+}
+
+// Open verifies packet, received at now, decrypting its payload in place if it is
+// encrypted. It returns the packet without the security trailer and with the security
+// flags cleared, ready for ParseHeader. Unsealed packets fail with ErrUnauthenticated.
+func (o *Opener) Open(packet []byte, now time.Time) ([]byte, error) {
+	h, _, err := ParseHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+	end := HeaderSize + int(h.PayloadLength)
+	var tagSize int
+	switch {
+	case h.Flags.Has(FlagEncrypted):
+		tagSize = o.keys.aead.Overhead()
+	case h.Flags.Has(FlagSigned):
+		tagSize = sha256.Size
+	default:
+		return nil, ErrUnauthenticated
+	}
+	if len(packet) < end+NonceSize+tagSize {
+		return nil, fmt.Errorf("%w: security trailer missing", ErrUnauthenticated)
+	}
+	nonce := packet[end : end+NonceSize]
+	tag := packet[end+NonceSize : end+NonceSize+tagSize]
+
+	// Authenticate before looking at anything else.
+	if h.Flags.Has(FlagEncrypted) {
+		aad := append(append(make([]byte, 0, HeaderSize+NonceSize), packet[:HeaderSize]...), nonce...)
+		sealed := append(append(make([]byte, 0, int(h.PayloadLength)+tagSize), packet[HeaderSize:end]...), tag...)
+		if _, err := o.keys.aead.Open(packet[HeaderSize:HeaderSize], nonce, sealed, aad); err != nil {
+			return nil, ErrUnauthenticated
+		}
+	} else {
+		o.mac.Reset()
+		o.mac.Write(packet[:end])
+		o.mac.Write(nonce)
+		if subtle.ConstantTimeCompare(o.mac.Sum(nil), tag) != 1 {
+			return nil, ErrUnauthenticated
+		}
+	}
+
+	if o.maxAge > 0 {
+		if age := now.Sub(time.Unix(0, h.Timestamp)); age > o.maxAge || age < -o.maxAge {
+			return nil, fmt.Errorf("%w: %s", ErrStale, age)
+		}
+	}
+	if err := o.checkReplay(binary.BigEndian.Uint64(nonce[0:8]), binary.BigEndian.Uint32(nonce[8:12]), now); err != nil {
+		return nil, err
+	}
+
+	binary.BigEndian.PutUint16(packet[6:8], uint16(h.Flags&^(FlagSigned|FlagEncrypted)))
+	return packet[:end], nil
+}
+
+// checkReplay records counter of session and fails if it was seen before or is too old.
+func (o *Opener) checkReplay(session uint64, counter uint32, now time.Time) error {
+	st := o.sessions[session]
+	if st == nil {
+		if len(o.sessions) >= maxSessions {
+			// Forget the session heard from least recently.
+			var oldest uint64
+			for id, s := range o.sessions {
+				if st == nil || s.lastSeen.Before(st.lastSeen) {
+					oldest, st = id, s
+				}
+			}
+			delete(o.sessions, oldest)
+		}
+		st = &replayState{highest: counter, window: 1, lastSeen: now}
+		o.sessions[session] = st
+		return nil
+	}
+
+	switch {
+	case counter > st.highest:
+		if shift := counter - st.highest; shift < replayWindow {
+			st.window = st.window<<shift | 1
+		} else {
+			st.window = 1
+		}
+		st.highest = counter
+	case st.highest-counter >= replayWindow:
+		return fmt.Errorf("%w: counter %d too far behind %d", ErrReplayed, counter, st.highest)
+	default:
+		bit := uint64(1) << (st.highest - counter)
+		if st.window&bit != 0 {
+			return ErrReplayed
+		}
+		st.window |= bit
+	}
+	st.lastSeen = now
+	return nil
+}
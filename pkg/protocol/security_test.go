@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+func testPacket(now time.Time, mags []float32) []byte {
+	h := Header{
+		Type:          MessageSpectrum,
+		Flags:         0x8000,
+		Sequence:      7,
+		Timestamp:     now.UnixNano(),
+		FFTSize:       8,
+		PayloadLength: uint16(SpectrumPayloadSize(len(mags))),
+	}
+	return AppendSpectrum(AppendHeader(nil, h), mags)
+}
+
+func TestSealer_RoundTrip(t *testing.T) {
+	now := time.Now()
+	mags := []float32{1, 2, 3, 4}
+	for _, encrypt := range []bool{false, true} {
+		sealer, err := NewSealer(testKey, encrypt)
+		if err != nil {
+			t.Fatalf("NewSealer error: %v", err)
+		}
+		opener, err := NewOpener(testKey, time.Second)
+		if err != nil {
+			t.Fatalf("NewOpener error: %v", err)
+		}
+		plain := testPacket(now, mags)
+		sealed, err := sealer.Seal(slices.Clone(plain))
+		if err != nil {
+			t.Fatalf("Seal error: %v", err)
+		}
+		if len(sealed) != len(plain)+sealer.Overhead() {
+			t.Errorf("encrypt=%v: sealed size = %d, want %d", encrypt, len(sealed), len(plain)+sealer.Overhead())
+		}
+		if h, _, _ := ParseHeader(sealed); h.Flags.Has(FlagEncrypted) != encrypt || h.Flags.Has(FlagSigned) == encrypt {
+			t.Errorf("encrypt=%v: flags = %#04x", encrypt, h.Flags)
+		}
+		if encrypt == bytes.Equal(sealed[HeaderSize:len(plain)], plain[HeaderSize:]) {
+			t.Errorf("encrypt=%v: payload in the clear = %v", encrypt, !encrypt)
+		}
+
+		opened, err := opener.Open(slices.Clone(sealed), now)
+		if err != nil {
+			t.Fatalf("encrypt=%v: Open error: %v", encrypt, err)
+		}
+		if !bytes.Equal(opened, plain) {
+			t.Errorf("encrypt=%v: opened packet differs from the original", encrypt)
+		}
+
+		if _, err := opener.Open(slices.Clone(sealed), now); !errors.Is(err, ErrReplayed) {
+			t.Errorf("encrypt=%v: replay error = %v, want ErrReplayed", encrypt, err)
+		}
+		for _, i := range []int{7, 12, HeaderSize + 1, len(sealed) - 1} {
+			tampered := slices.Clone(sealed)
+			tampered[i] ^= 1
+			if _, err := opener.Open(tampered, now); !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("encrypt=%v: Open with byte %d flipped error = %v, want ErrUnauthenticated", encrypt, i, err)
+			}
+		}
+		if _, err := opener.Open(plain, now); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("encrypt=%v: Open of unsealed packet error = %v, want ErrUnauthenticated", encrypt, err)
+		}
+
+		other, _ := NewOpener(bytes.Repeat([]byte{0x43}, 32), 0)
+		if _, err := other.Open(slices.Clone(sealed), now); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("encrypt=%v: Open with wrong key error = %v, want ErrUnauthenticated", encrypt, err)
+		}
+
+		stale, _ := sealer.Seal(testPacket(now.Add(-time.Minute), mags))
+		if _, err := opener.Open(slices.Clone(stale), now); !errors.Is(err, ErrStale) {
+			t.Errorf("encrypt=%v: Open of old packet error = %v, want ErrStale", encrypt, err)
+		}
+		// A new Opener, which knows no sessions, still rejects old packets by default
+		// and only accepts them with the check disabled.
+		fresh, _ := NewOpener(testKey, 0)
+		if _, err := fresh.Open(slices.Clone(stale), now); !errors.Is(err, ErrStale) {
+			t.Errorf("encrypt=%v: Open of old packet with the default age error = %v, want ErrStale", encrypt, err)
+		}
+		unchecked, _ := NewOpener(testKey, -1)
+		if _, err := unchecked.Open(stale, now); err != nil {
+			t.Errorf("encrypt=%v: Open of old packet without the age check error: %v", encrypt, err)
+		}
+	}
+}
+
+func TestOpener_ReplayWindow(t *testing.T) {
+	now := time.Now()
+	sealer, _ := NewSealer(testKey, false)
+	opener, _ := NewOpener(testKey, 0)
+	packets := make([][]byte, replayWindow+6)
+	for i := range packets {
+		packets[i], _ = sealer.Seal(testPacket(now, []float32{float32(i)}))
+	}
+
+	// Out of order delivery within the window is fine, but only once.
+	for _, i := range []int{1, 0, 3, 2} {
+		if _, err := opener.Open(slices.Clone(packets[i]), now); err != nil {
+			t.Fatalf("Open of packet %d error: %v", i, err)
+		}
+	}
+	if _, err := opener.Open(slices.Clone(packets[2]), now); !errors.Is(err, ErrReplayed) {
+		t.Errorf("second Open of packet 2 error = %v, want ErrReplayed", err)
+	}
+	// Moving the window past packet 4 makes it too old to be accepted.
+	if _, err := opener.Open(slices.Clone(packets[len(packets)-1]), now); err != nil {
+		t.Fatalf("Open of last packet error: %v", err)
+	}
+	if _, err := opener.Open(slices.Clone(packets[4]), now); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Open of packet behind the window error = %v, want ErrReplayed", err)
+	}
+	if _, err := opener.Open(slices.Clone(packets[len(packets)-2]), now); err != nil {
+		t.Errorf("Open of packet inside the window error: %v", err)
+	}
+}
+
+func TestSealer_CounterWrap(t *testing.T) {
+	now := time.Now()
+	sealer, _ := NewSealer(testKey, true)
+	opener, _ := NewOpener(testKey, 0)
+	sealer.counter = math.MaxUint32 - 1
+
+	nonce := func(packet []byte) (session uint64, counter uint32) {
+		n := packet[len(packet)-sealer.Overhead():]
+		return binary.BigEndian.Uint64(n[0:8]), binary.BigEndian.Uint32(n[8:12])
+	}
+	last, _ := sealer.Seal(testPacket(now, []float32{1}))
+	first, err := sealer.Seal(testPacket(now, []float32{2}))
+	if err != nil {
+		t.Fatalf("Seal after the last counter error: %v", err)
+	}
+	lastSession, lastCounter := nonce(last)
+	firstSession, firstCounter := nonce(first)
+	if lastCounter != math.MaxUint32 || firstCounter != 1 {
+		t.Errorf("counters = %d, %d, want %d, 1", lastCounter, firstCounter, uint32(math.MaxUint32))
+	}
+	if firstSession == lastSession {
+		t.Error("session kept after the counter ran out")
+	}
+	for i, packet := range [][]byte{last, first} {
+		if _, err := opener.Open(packet, now); err != nil {
+			t.Errorf("Open of packet %d error: %v", i, err)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if key, err := ParseKey(" 000102030405060708090a0b0c0d0e0f\n"); err != nil || len(key) != 16 || key[15] != 0x0f {
+		t.Errorf("ParseKey = %x, %v", key, err)
+	}
+	if _, err := ParseKey("0001"); err == nil {
+		t.Error("ParseKey of short key succeeded")
+	}
+	if _, err := ParseKey("not hex"); err == nil {
+		t.Error("ParseKey of non-hex key succeeded")
+	}
+}
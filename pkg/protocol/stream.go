@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// FrameLengthSize is the size of the length prefix of every packet on a stream.
+	FrameLengthSize = 4
+	// MaxFrameSize is the largest packet accepted on a stream: a header, the largest
+	// payload and room for a trailer.
+	MaxFrameSize = HeaderSize + 65535 + 1024
+)
+
+// AppendFrame appends packet to dst with its length prefix, for stream transports.
+func AppendFrame(dst []byte, packet []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(packet)))
+	return append(dst, packet...)
+}
+
+// ReadFrame reads one length-prefixed packet from r into buf, growing it if needed, and
+// returns the packet. It returns io.EOF if the stream ends cleanly before a frame and
+// io.ErrUnexpectedEOF if it ends within one.
+func ReadFrame(r io.Reader, buf []byte) ([]byte, error) {
+	var prefix [FrameLengthSize]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	if n > MaxFrameSize {
+		return nil, fmt.Errorf("protocol: frame of %d bytes exceeds %d", n, MaxFrameSize)
+	}
+	if cap(buf) < int(n) {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
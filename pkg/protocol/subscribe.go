@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Control message types, exchanged with the engine's control port.
+const (
+	// MessageSubscribe registers (or renews) the sender as a subscriber (Subscription payload).
+	MessageSubscribe MessageType = 0x10
+	// MessageUnsubscribe ends the sender's subscription (no payload).
+	MessageUnsubscribe MessageType = 0x11
+	// MessageSubscribeAck answers MessageSubscribe with what was granted (Subscription payload).
+	MessageSubscribeAck MessageType = 0x12
+)
+
+// Subscription is the payload of MessageSubscribe and MessageSubscribeAck.
+type Subscription struct {
+	Interval time.Duration // Time between messages (0 requests the engine default).
+	Lease    time.Duration // Time the subscription lasts without renewal (0 requests the engine default).
+	Types    []MessageType // Message types requested or granted.
+	Cookie   uint64        // Return-routability cookie issued by the engine and echoed by the subscriber (0 for none).
+}
+
+// SubscriptionPayloadSize returns the size of a subscription payload listing n types.
+func SubscriptionPayloadSize(n int) int {
+	return 9 + n + 8
+}
+
+// AppendSubscription appends the payload of s to dst. Durations are truncated to the
+// wire resolution (microseconds for Interval, milliseconds for Lease) and saturate.
+func AppendSubscription(dst []byte, s Subscription) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(min(max(s.Interval.Microseconds(), 0), math.MaxUint32)))
+	dst = binary.BigEndian.AppendUint32(dst, uint32(min(max(s.Lease.Milliseconds(), 0), math.MaxUint32)))
+	types := s.Types[:min(len(s.Types), math.MaxUint8)]
+	dst = append(dst, uint8(len(types)))
+	for _, t := range types {
+		dst = append(dst, byte(t))
+	}
+	return binary.BigEndian.AppendUint64(dst, s.Cookie)
+}
+
+// ParseSubscription decodes a subscription payload. Payloads without a cookie, from
+// peers that predate it, decode with Cookie zero.
+func ParseSubscription(payload []byte) (Subscription, error) {
+	if len(payload) < 9 {
+		return Subscription{}, ErrShortPacket
+	}
+	s := Subscription{
+		Interval: time.Duration(binary.BigEndian.Uint32(payload[0:4])) * time.Microsecond,
+		Lease:    time.Duration(binary.BigEndian.Uint32(payload[4:8])) * time.Millisecond,
+	}
+	n := int(payload[8])
+	if len(payload) < 9+n {
+		return s, fmt.Errorf("%w: subscription lists %d types in %d bytes", ErrShortPacket, n, len(payload))
+	}
+	s.Types = make([]MessageType, n)
+	for i := range n {
+		s.Types[i] = MessageType(payload[9+i])
+	}
+	if len(payload) >= SubscriptionPayloadSize(n) {
+		s.Cookie = binary.BigEndian.Uint64(payload[9+n:])
+	}
+	return s, nil
+}
+
+// AppendControl appends a complete control packet of type t to dst: MessageSubscribe or
+// MessageSubscribeAck with the payload of s, or MessageUnsubscribe without a payload.
+func AppendControl(dst []byte, t MessageType, s Subscription) []byte {
+	header := Header{Type: t, Timestamp: time.Now().UnixNano()}
+	if t == MessageUnsubscribe {
+		return AppendHeader(dst, header)
+	}
+	header.PayloadLength = uint16(SubscriptionPayloadSize(min(len(s.Types), math.MaxUint8)))
+	return AppendSubscription(AppendHeader(dst, header), s)
+}
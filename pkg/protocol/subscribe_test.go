@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: MIT
+package protocol
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSubscription_RoundTrip(t *testing.T) {
+	want := Subscription{
+		Interval: 20 * time.Millisecond,
+		Lease:    10 * time.Second,
+		Types:    []MessageType{MessageSpectrum, MessageSpectrumEncoded},
+		Cookie:   0x0123456789abcdef,
+	}
+	packet := AppendControl(nil, MessageSubscribe, want)
+	h, payload, err := ParseHeader(packet)
+	if err != nil {
+		t.Fatalf("ParseHeader error: %v", err)
+	}
+	if h.Type != MessageSubscribe || int(h.PayloadLength) != SubscriptionPayloadSize(2) {
+		t.Errorf("header = %+v", h)
+	}
+	got, err := ParseSubscription(payload)
+	if err != nil {
+		t.Fatalf("ParseSubscription error: %v", err)
+	}
+	if got.Interval != want.Interval || got.Lease != want.Lease || !slices.Equal(got.Types, want.Types) || got.Cookie != want.Cookie {
+		t.Errorf("subscription = %+v, want %+v", got, want)
+	}
+
+	// Payloads from peers that predate the cookie still parse.
+	if got, err := ParseSubscription(payload[:len(payload)-8]); err != nil || got.Cookie != 0 || len(got.Types) != 2 {
+		t.Errorf("ParseSubscription without cookie = %+v, %v", got, err)
+	}
+	if _, err := ParseSubscription(payload[:len(payload)-9]); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("ParseSubscription of truncated types error = %v, want ErrShortPacket", err)
+	}
+	if h, _, err := ParseHeader(AppendControl(nil, MessageUnsubscribe, want)); err != nil || h.Type != MessageUnsubscribe || h.PayloadLength != 0 {
+		t.Errorf("unsubscribe header = %+v, %v", h, err)
+	}
+}
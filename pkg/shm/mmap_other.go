@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: MIT
+
+//go:build !unix
+
+package shm
+
+import (
+	"errors"
+	"os"
+)
+
+// errUnsupported is returned on platforms without mmap support in the syscall package.
+var errUnsupported = errors.New("shm: shared memory buffers are not supported on this platform")
+
+func mapFile(*os.File, int, bool) (mapping, error) { return nil, errUnsupported }
+
+func unmap(mapping) error { return errUnsupported }
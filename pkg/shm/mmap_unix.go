@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: MIT
+
+//go:build unix
+
+package shm
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of f, shared with other processes.
+func mapFile(f *os.File, size int, writable bool) (mapping, error) {
+	prot := syscall.PROT_READ
+	if writable {
+		prot |= syscall.PROT_WRITE
+	}
+	return syscall.Mmap(int(f.Fd()), 0, size, prot, syscall.MAP_SHARED)
+}
+
+// unmap releases a mapping made by mapFile.
+func unmap(m mapping) error {
+	return syscall.Munmap(m)
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+package shm
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"sync/atomic"
+)
+
+// Reader reads spectra from a shared-memory buffer created by a Writer. A Reader is
+// not safe for concurrent use; open one per goroutine.
+type Reader struct {
+	path string      // Path the buffer was opened from.
+	file os.FileInfo // The mapped file, to detect a newer buffer at path.
+	mem  mapping     // Read-only mapping of the buffer.
+	info Info        // Decoded header.
+}
+
+// Open maps the buffer at path for reading.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("shm: failed to open buffer: %w", err)
+	}
+	defer f.Close() // The mapping stays valid after the file is closed.
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("shm: failed to stat buffer: %w", err)
+	}
+	if info.Size() < HeaderSize || info.Size() > math.MaxInt32 {
+		return nil, fmt.Errorf("shm: invalid buffer size %d", info.Size())
+	}
+	mem, err := mapFile(f, int(info.Size()), false)
+	if err != nil {
+		return nil, fmt.Errorf("shm: failed to map buffer: %w", err)
+	}
+	if err := mapping(mem).validate(); err != nil {
+		unmap(mem)
+		return nil, err
+	}
+	return &Reader{path: path, file: info, mem: mem, info: mem.info()}, nil
+}
+
+// Info returns the buffer's stream description.
+func (r *Reader) Info() Info {
+	return r.info
+}
+
+// Latest returns the number of the latest frame written, or 0 if there is none yet.
+func (r *Reader) Latest() uint64 {
+	return atomic.LoadUint64(r.mem.uint64At(offLatest))
+}
+
+// Closed reports whether the writer has closed the buffer or a newer buffer has
+// replaced it at its path, in which case the reader should be reopened.
+func (r *Reader) Closed() bool {
+	if atomic.LoadUint32(r.mem.uint32At(offState)) == stateClosed {
+		return true
+	}
+	info, err := os.Stat(r.path)
+	return err != nil || !os.SameFile(info, r.file)
+}
+
+// ReadLatest reads the latest frame into dst (grown as needed).
+func (r *Reader) ReadLatest(dst []float32) (Frame, error) {
+	n := r.Latest()
+	if n == 0 {
+		return Frame{}, ErrNoFrame
+	}
+	return r.Read(n, dst)
+}
+
+// Read reads frame n into dst (grown as needed) and returns it with its magnitudes.
+// It returns ErrNoFrame if the frame hasn't been written yet and ErrOverwritten if
+// its slot has been reused; a reader that keeps up with the writer never sees either
+// for the frames up to Latest.
+func (r *Reader) Read(n uint64, dst []float32) (Frame, error) {
+	if n == 0 {
+		return Frame{}, ErrNoFrame
+	}
+	off := r.mem.slot(n)
+	seq := r.mem.uint64At(off + offSlotSequence)
+
+	for range maxReadAttempts {
+		start := atomic.LoadUint64(seq)
+		if start&1 != 0 {
+			runtime.Gosched() // The writer is updating the slot.
+			continue
+		}
+
+		frame := atomic.LoadUint64(r.mem.uint64At(off + offSlotFrame))
+		if frame != n {
+			if atomic.LoadUint64(seq) != start {
+				continue
+			}
+			if frame > n {
+				return Frame{}, ErrOverwritten
+			}
+			return Frame{}, ErrNoFrame
+		}
+		timestamp := int64(atomic.LoadUint64(r.mem.uint64At(off + offSlotTimestamp)))
+		bins := min(int(atomic.LoadUint32(r.mem.uint32At(off+offSlotBins))), r.info.MaxBins)
+		dst = slices.Grow(dst[:0], bins)[:bins]
+		for i := range dst {
+			dst[i] = math.Float32frombits(atomic.LoadUint32(r.mem.uint32At(off + offSlotData + 4*i)))
+		}
+
+		if atomic.LoadUint64(seq) == start {
+			return Frame{Number: n, Timestamp: timestamp, Magnitudes: dst}, nil
+		}
+	}
+	return Frame{}, ErrBusy
+}
+
+// Close unmaps the buffer. It is safe to call multiple times.
+func (r *Reader) Close() error {
+	if r.mem == nil {
+		return nil
+	}
+	err := unmap(r.mem)
+	r.mem = nil
+	if err != nil {
+		return fmt.Errorf("shm: failed to unmap buffer: %w", err)
+	}
+	return nil
+}
+
+// Ensure Reader satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Reader)(nil)
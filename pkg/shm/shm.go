@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: MIT
+/*
+Package shm defines the shared-memory ring buffer the engine writes spectra to for
+consumers on the same host, with a Writer for the engine and a Reader for consumers.
+Readers map the file and read the latest spectra straight from memory, without
+system calls, sockets or packet parsing.
+
+The buffer is a file (on Linux usually under /dev/shm) made of a 64 byte header
+followed by a fixed number of equally sized slots. All fields use the host's byte
+order. The writer fills in the header before the file appears under its final name;
+fields marked (atomic) change afterwards and are only accessed with atomic loads and
+stores.
+
+Header:
+
+	Offset Size Field
+	0      4    Magic, the ASCII bytes "P4SM"
+	4      2    Version (1)
+	6      2    Header size in bytes (64)
+	8      4    Slot count
+	12     4    Slot size in bytes
+	16     4    Maximum bins per slot
+	20     4    Sample rate (Hz)
+	24     4    FFT size
+	28     2    Channel ID
+	30     2    Reserved
+	32     8    Latest frame number, 0 before the first frame (atomic)
+	40     4    State: 1 while the writer is running, 2 once closed (atomic)
+	44     20   Reserved
+
+Slot (at 64 + index * slot size):
+
+	Offset Size Field
+	0      8    Sequence counter, odd while the slot is being written (atomic)
+	8      8    Frame number (atomic)
+	16     8    Timestamp, Unix time in nanoseconds (atomic)
+	24     4    Bin count (atomic)
+	28     4    Reserved
+	32     4*N  Magnitudes as float32 bits (atomic)
+
+Frames are numbered from 1 and frame n is stored in slot (n-1) % slot count, so
+the last slot count frames stay readable. Each slot is protected by a seqlock:
+the writer increments the sequence counter to an odd value, writes the slot,
+then increments it to an even value. A reader loads the counter, retries while
+it is odd, copies the slot and accepts the copy only if the counter is unchanged.
+Readers never write to the buffer, so any number of them can map it read-only.
+
+When the engine restarts it creates a new file instead of reusing the old one;
+readers should reopen the file once State reports the writer closed.
+*/
+package shm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+const (
+	// Magic identifies shared-memory spectrum buffers: the ASCII bytes "P4SM".
+	Magic = "P4SM"
+	// Version is the layout version written by this package.
+	Version = 1
+	// HeaderSize is the size of the buffer header in bytes.
+	HeaderSize = 64
+	// SlotHeaderSize is the size of the slot header preceding the magnitudes.
+	SlotHeaderSize = 32
+	// DefaultSlots is the number of slots used when none is given.
+	DefaultSlots = 8
+)
+
+// Header field offsets.
+const (
+	offMagic      = 0
+	offVersion    = 4
+	offHeaderSize = 6
+	offSlots      = 8
+	offSlotSize   = 12
+	offMaxBins    = 16
+	offSampleRate = 20
+	offFFTSize    = 24
+	offChannel    = 28
+	offLatest     = 32
+	offState      = 40
+)
+
+// Slot field offsets.
+const (
+	offSlotSequence  = 0
+	offSlotFrame     = 8
+	offSlotTimestamp = 16
+	offSlotBins      = 24
+	offSlotData      = SlotHeaderSize
+)
+
+// Writer states.
+const (
+	stateRunning = 1
+	stateClosed  = 2
+)
+
+// maxReadAttempts bounds the number of times a read is retried while the writer is
+// updating the slot.
+const maxReadAttempts = 1000
+
+var (
+	// ErrNoFrame is returned when the requested frame has not been written yet.
+	ErrNoFrame = errors.New("shm: frame not written yet")
+	// ErrOverwritten is returned when the requested frame's slot already holds a newer frame.
+	ErrOverwritten = errors.New("shm: frame overwritten")
+	// ErrBusy is returned when a slot kept changing while it was being read.
+	ErrBusy = errors.New("shm: slot busy")
+)
+
+// Info describes a buffer.
+type Info struct {
+	Slots      int     // Number of slots in the ring.
+	MaxBins    int     // Maximum number of bins per spectrum.
+	SampleRate float64 // Sample rate of the analysed audio (Hz).
+	FFTSize    int     // FFT size; spectra have FFTSize/2+1 bins.
+	Channel    uint16  // Channel ID, as in UDP packet headers.
+}
+
+// Frame is a spectrum read from the buffer.
+type Frame struct {
+	Number     uint64    // Frame number, starting at 1.
+	Timestamp  int64     // Time the spectrum was written (Unix time in nanoseconds).
+	Magnitudes []float32 // Magnitudes, bin k at k*SampleRate/FFTSize Hz.
+}
+
+// SlotSize returns the size of a slot holding up to maxBins magnitudes, rounded up to
+// a multiple of 64 bytes so slots start on separate cache lines.
+func SlotSize(maxBins int) int {
+	return (SlotHeaderSize + 4*maxBins + 63) &^ 63
+}
+
+// Size returns the size of a buffer with the given number of slots and bins per slot.
+func Size(slots, maxBins int) int {
+	return HeaderSize + slots*SlotSize(maxBins)
+}
+
+// mapping is a mapped buffer.
+type mapping []byte
+
+func (m mapping) uint32At(off int) *uint32 { return (*uint32)(unsafe.Pointer(&m[off])) }
+func (m mapping) uint64At(off int) *uint64 { return (*uint64)(unsafe.Pointer(&m[off])) }
+
+// info decodes the header fields written once by the writer.
+func (m mapping) info() Info {
+	return Info{
+		Slots:      int(binary.NativeEndian.Uint32(m[offSlots:])),
+		MaxBins:    int(binary.NativeEndian.Uint32(m[offMaxBins:])),
+		SampleRate: float64(binary.NativeEndian.Uint32(m[offSampleRate:])),
+		FFTSize:    int(binary.NativeEndian.Uint32(m[offFFTSize:])),
+		Channel:    binary.NativeEndian.Uint16(m[offChannel:]),
+	}
+}
+
+// slotSize returns the slot size stored in the header.
+func (m mapping) slotSize() int {
+	return int(binary.NativeEndian.Uint32(m[offSlotSize:]))
+}
+
+// validate checks the header of a buffer of len(m) bytes.
+func (m mapping) validate() error {
+	if len(m) < HeaderSize {
+		return fmt.Errorf("shm: buffer too small (%d bytes)", len(m))
+	}
+	if string(m[offMagic:offMagic+4]) != Magic {
+		return fmt.Errorf("shm: bad magic %q", m[offMagic:offMagic+4])
+	}
+	if version := binary.NativeEndian.Uint16(m[offVersion:]); version != Version {
+		return fmt.Errorf("shm: unsupported version %d", version)
+	}
+	if size := binary.NativeEndian.Uint16(m[offHeaderSize:]); size != HeaderSize {
+		return fmt.Errorf("shm: unexpected header size %d", size)
+	}
+	info, slotSize := m.info(), m.slotSize()
+	if info.Slots <= 0 || info.MaxBins <= 0 || slotSize < SlotHeaderSize+4*info.MaxBins || slotSize%8 != 0 {
+		return fmt.Errorf("shm: invalid layout (slots %d, slot size %d, max bins %d)", info.Slots, slotSize, info.MaxBins)
+	}
+	if need := HeaderSize + info.Slots*slotSize; need > len(m) {
+		return fmt.Errorf("shm: buffer truncated (%d bytes, layout needs %d)", len(m), need)
+	}
+	return nil
+}
+
+// slot returns the offset of the slot holding frame n (n >= 1).
+func (m mapping) slot(n uint64) int {
+	slots := uint64(binary.NativeEndian.Uint32(m[offSlots:]))
+	return HeaderSize + int((n-1)%slots)*m.slotSize()
+}
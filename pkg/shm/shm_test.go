@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+package shm
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestWriter(t *testing.T, slots, bins int) (*Writer, *Reader) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spectrum")
+	w, err := Create(path, WriterOptions{Slots: slots, MaxBins: bins, SampleRate: 48000, FFTSize: 2 * (bins - 1), Channel: 5})
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return w, r
+}
+
+func TestWriteRead(t *testing.T) {
+	t.Parallel()
+	w, r := newTestWriter(t, 4, 5)
+
+	if info := r.Info(); info != (Info{Slots: 4, MaxBins: 5, SampleRate: 48000, FFTSize: 8, Channel: 5}) {
+		t.Errorf("Info = %+v", info)
+	}
+	if _, err := r.ReadLatest(nil); !errors.Is(err, ErrNoFrame) {
+		t.Errorf("ReadLatest on empty buffer error = %v, want ErrNoFrame", err)
+	}
+
+	for i := range 6 {
+		if n, err := w.Write(int64(100+i), []float32{float32(i), 1, 2}); err != nil || n != uint64(i+1) {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	frame, err := r.ReadLatest(nil)
+	if err != nil {
+		t.Fatalf("ReadLatest error: %v", err)
+	}
+	if frame.Number != 6 || frame.Timestamp != 105 || len(frame.Magnitudes) != 3 || frame.Magnitudes[0] != 5 {
+		t.Errorf("ReadLatest = %+v", frame)
+	}
+
+	// Frames 3 to 6 are in the ring; 1 and 2 were overwritten and 7 doesn't exist yet.
+	if frame, err := r.Read(3, nil); err != nil || frame.Magnitudes[0] != 2 {
+		t.Errorf("Read(3) = %+v, %v", frame, err)
+	}
+	if _, err := r.Read(2, nil); !errors.Is(err, ErrOverwritten) {
+		t.Errorf("Read(2) error = %v, want ErrOverwritten", err)
+	}
+	if _, err := r.Read(7, nil); !errors.Is(err, ErrNoFrame) {
+		t.Errorf("Read(7) error = %v, want ErrNoFrame", err)
+	}
+
+	if _, err := w.Write(0, make([]float32, 6)); err == nil {
+		t.Error("Write accepted more bins than the buffer holds")
+	}
+}
+
+func TestReader_Closed(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "spectrum")
+	options := WriterOptions{MaxBins: 3}
+	first, err := Create(path, options)
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	defer first.Close()
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer r.Close()
+	if r.Closed() {
+		t.Fatal("Closed = true for a running writer")
+	}
+
+	// A restarted writer replaces the file; the old reader must reopen.
+	second, err := Create(path, options)
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if !r.Closed() {
+		t.Error("Closed = false after the buffer was replaced")
+	}
+	if err := first.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	if _, err := Open(path); err != nil {
+		t.Errorf("closing the old writer removed the new buffer: %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("buffer still exists after Close")
+	}
+}
+
+func TestReader_BusySlot(t *testing.T) {
+	t.Parallel()
+	w, r := newTestWriter(t, 2, 3)
+	if _, err := w.Write(1, []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	// Simulate a writer stuck halfway through updating the slot.
+	seq := w.mem.uint64At(w.mem.slot(1) + offSlotSequence)
+	atomic.AddUint64(seq, 1)
+	if _, err := r.Read(1, nil); !errors.Is(err, ErrBusy) {
+		t.Errorf("Read during write error = %v, want ErrBusy", err)
+	}
+	atomic.AddUint64(seq, 1)
+	if _, err := r.Read(1, nil); err != nil {
+		t.Errorf("Read after write error = %v", err)
+	}
+}
+
+func TestConcurrentReadsAreConsistent(t *testing.T) {
+	t.Parallel()
+	const bins = 257
+	w, r := newTestWriter(t, 2, bins)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		magnitudes := make([]float32, bins)
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for j := range magnitudes {
+				magnitudes[j] = float32(i)
+			}
+			_, _ = w.Write(int64(i), magnitudes)
+		}
+	}()
+
+	// Every successful read must return one frame's values, never a mix of two.
+	var buf []float32
+	reads := 0
+	for reads < 2000 {
+		frame, err := r.ReadLatest(buf)
+		if err != nil {
+			continue
+		}
+		buf = frame.Magnitudes
+		for _, v := range frame.Magnitudes {
+			if v != float32(frame.Timestamp) {
+				t.Fatalf("frame %d mixes values %v and %v", frame.Number, frame.Timestamp, v)
+			}
+		}
+		reads++
+	}
+	close(done)
+	wg.Wait()
+}
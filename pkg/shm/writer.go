@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: MIT
+package shm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// WriterOptions describes the buffer created by a Writer.
+type WriterOptions struct {
+	Slots      int     // Number of slots in the ring (<= 0 uses DefaultSlots).
+	MaxBins    int     // Maximum number of bins per spectrum, usually FFTSize/2+1.
+	SampleRate float64 // Sample rate of the analysed audio (Hz).
+	FFTSize    int     // FFT size.
+	Channel    uint16  // Channel ID, as in UDP packet headers.
+}
+
+// Writer writes spectra to a shared-memory buffer. It is not safe for concurrent use;
+// a buffer has exactly one writer.
+type Writer struct {
+	path  string      // Path of the buffer file.
+	file  os.FileInfo // The buffer file, to tell it apart from a newer one at path.
+	mem   mapping     // Mapped buffer.
+	bins  int         // Maximum bins per slot.
+	frame uint64      // Number of the latest frame written.
+}
+
+// Create creates a buffer at path and maps it for writing. The buffer is prepared under
+// a temporary name and then renamed to path, so readers never see a partial header and
+// readers of a previous buffer at path keep their mapping.
+func Create(path string, options WriterOptions) (*Writer, error) {
+	if options.MaxBins <= 0 || options.MaxBins > math.MaxUint32/8 {
+		return nil, fmt.Errorf("shm: invalid max bins %d", options.MaxBins)
+	}
+	if options.Slots <= 0 {
+		options.Slots = DefaultSlots
+	}
+	size := Size(options.Slots, options.MaxBins)
+
+	dir, name := filepath.Split(path)
+	f, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return nil, fmt.Errorf("shm: failed to create buffer: %w", err)
+	}
+	tmp := f.Name()
+	mem, err := func() (mapping, error) {
+		defer f.Close() // The mapping stays valid after the file is closed.
+		if err := f.Chmod(0o644); err != nil {
+			return nil, err
+		}
+		if err := f.Truncate(int64(size)); err != nil {
+			return nil, err
+		}
+		return mapFile(f, size, true)
+	}()
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("shm: failed to map buffer: %w", err)
+	}
+
+	copy(mem[offMagic:], Magic)
+	binary.NativeEndian.PutUint16(mem[offVersion:], Version)
+	binary.NativeEndian.PutUint16(mem[offHeaderSize:], HeaderSize)
+	binary.NativeEndian.PutUint32(mem[offSlots:], uint32(options.Slots))
+	binary.NativeEndian.PutUint32(mem[offSlotSize:], uint32(SlotSize(options.MaxBins)))
+	binary.NativeEndian.PutUint32(mem[offMaxBins:], uint32(options.MaxBins))
+	binary.NativeEndian.PutUint32(mem[offSampleRate:], uint32(options.SampleRate))
+	binary.NativeEndian.PutUint32(mem[offFFTSize:], uint32(options.FFTSize))
+	binary.NativeEndian.PutUint16(mem[offChannel:], options.Channel)
+	atomic.StoreUint32(mem.uint32At(offState), stateRunning)
+
+	if err := os.Rename(tmp, path); err != nil {
+		unmap(mem)
+		os.Remove(tmp)
+		return nil, fmt.Errorf("shm: failed to publish buffer: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		unmap(mem)
+		return nil, fmt.Errorf("shm: failed to stat buffer: %w", err)
+	}
+	return &Writer{path: path, file: info, mem: mem, bins: options.MaxBins}, nil
+}
+
+// Write stores a spectrum as the next frame and returns its number.
+func (w *Writer) Write(timestamp int64, magnitudes []float32) (uint64, error) {
+	if w.mem == nil {
+		return 0, fmt.Errorf("shm: writer is closed")
+	}
+	if len(magnitudes) > w.bins {
+		return 0, fmt.Errorf("shm: %d bins exceed the buffer's maximum of %d", len(magnitudes), w.bins)
+	}
+
+	n := w.frame + 1
+	off := w.mem.slot(n)
+	seq := w.mem.uint64At(off + offSlotSequence)
+
+	// Odd while the slot is inconsistent; readers retry until it is even again.
+	atomic.AddUint64(seq, 1)
+	atomic.StoreUint64(w.mem.uint64At(off+offSlotFrame), n)
+	atomic.StoreUint64(w.mem.uint64At(off+offSlotTimestamp), uint64(timestamp))
+	atomic.StoreUint32(w.mem.uint32At(off+offSlotBins), uint32(len(magnitudes)))
+	for i, v := range magnitudes {
+		atomic.StoreUint32(w.mem.uint32At(off+offSlotData+4*i), math.Float32bits(v))
+	}
+	atomic.AddUint64(seq, 1)
+
+	atomic.StoreUint64(w.mem.uint64At(offLatest), n)
+	w.frame = n
+	return n, nil
+}
+
+// Path returns the path of the buffer file.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Close marks the buffer closed, unmaps it and removes the file unless it has been
+// replaced by a newer buffer. Readers that still map it keep their view of the last
+// frames. It is safe to call multiple times.
+func (w *Writer) Close() error {
+	if w.mem == nil {
+		return nil
+	}
+	atomic.StoreUint32(w.mem.uint32At(offState), stateClosed)
+	err := unmap(w.mem)
+	w.mem = nil
+
+	if info, statErr := os.Stat(w.path); statErr == nil && os.SameFile(info, w.file) {
+		if rmErr := os.Remove(w.path); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("shm: failed to close buffer: %w", err)
+	}
+	return nil
+}
+
+// Ensure Writer satisfies the io.Closer interface at compile time.
+var _ interface{ Close() error } = (*Writer)(nil)
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: MIT
+package main
+
+import (
+	"audio/internal/analysis"
+	"audio/internal/config"
+	"audio/internal/spectrogram"
+	"audio/internal/wav"
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runSpectrogram implements the "spectrogram" command. It decodes a WAV file, runs it
+// through the same FFT processor the engine uses for live input and writes a PNG.
+// FFT size and window default to the engine configuration so the image matches what
+// the engine would publish for the same audio.
+func runSpectrogram(configPath string, args []string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	opts := spectrogram.DefaultOptions()
+	opts.FFTSize = cfg.Audio.FramesPerBuffer
+	opts.OnsetThreshold = cfg.Analysis.Onset.Threshold
+	opts.OnsetMinInterval = cfg.Analysis.Onset.MinInterval
+	opts.MinBPM = cfg.Analysis.Tempo.MinBPM
+	opts.MaxBPM = cfg.Analysis.Tempo.MaxBPM
+	opts.TempoWindow = cfg.Analysis.Tempo.Window
+
+	fs := flag.NewFlagSet("spectrogram", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s spectrogram [flags] <input.wav>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "Output PNG path (default: input name with .png extension)")
+	windowName := fs.String("window", cfg.Audio.FFTWindow, "FFT window function")
+	fs.IntVar(&opts.Width, "width", opts.Width, "Image width in pixels")
+	fs.IntVar(&opts.Height, "height", opts.Height, "Image height in pixels")
+	fs.IntVar(&opts.FFTSize, "fft-size", opts.FFTSize, "FFT size in samples (power of 2)")
+	fs.IntVar(&opts.HopSize, "hop", 0, "Hop size in samples (0 uses the FFT size, like the engine)")
+	fs.BoolVar(&opts.LogFrequency, "log", false, "Use a logarithmic frequency axis")
+	fs.Float64Var(&opts.MinFrequency, "min-freq", 0, "Lowest frequency shown in Hz (0 = 20 Hz on log axes, 0 Hz otherwise)")
+	fs.Float64Var(&opts.MaxFrequency, "max-freq", 0, "Highest frequency shown in Hz (0 = Nyquist)")
+	fs.Float64Var(&opts.MinDB, "min-db", opts.MinDB, "Level in dBFS mapped to the bottom of the color map")
+	fs.Float64Var(&opts.MaxDB, "max-db", opts.MaxDB, "Level in dBFS mapped to the top of the color map")
+	fs.BoolVar(&opts.Markers, "markers", false, "Mark detected onsets along the top edge and beats along the bottom edge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one input file, got %d", fs.NArg())
+	}
+	input := fs.Arg(0)
+
+	opts.Window, err = analysis.ParseWindowFunc(*windowName)
+	if err != nil {
+		fmt.Printf("spectrogram: %v. Using default FFT window (Hann).\n", err)
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(input, filepath.Ext(input)) + ".png"
+	}
+
+	// --- 1. Decode Input ---
+
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer in.Close()
+
+	file, err := wav.Decode(in)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", input, err)
+	}
+	fmt.Printf("spectrogram: %s - %d Hz, %d bit, %d channel(s), %d frames\n",
+		input, file.SampleRate, file.BitDepth, file.Channels, file.Frames())
+
+	// --- 2. Analyse & Render ---
+
+	spec, err := spectrogram.Compute(file.Mono(), float64(file.SampleRate), opts)
+	if err != nil {
+		return err
+	}
+	img, err := spec.Render(opts)
+	if err != nil {
+		return err
+	}
+
+	// --- 3. Write Output ---
+
+	out, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	if opts.Markers {
+		if spec.BeatsErr != nil {
+			fmt.Printf("spectrogram: Beats not tracked, marking onsets only (use a smaller -hop): %v\n", spec.BeatsErr)
+		}
+		fmt.Printf("spectrogram: Marked %d onsets and %d beats\n", len(spec.Onsets), len(spec.Beats))
+	}
+	fmt.Printf("spectrogram: Wrote %d frames to %s (%dx%d)\n", len(spec.Frames), *output, opts.Width, opts.Height)
+	return nil
+}